@@ -0,0 +1,341 @@
+package main
+
+import (
+	"github.com/TeamD2018/geo-rest/controllers"
+	"github.com/TeamD2018/geo-rest/migrations"
+	"github.com/TeamD2018/geo-rest/services"
+	"github.com/TeamD2018/geo-rest/services/interfaces"
+	"github.com/TeamD2018/geo-rest/services/kvstore"
+	"github.com/TeamD2018/geo-rest/services/photon"
+	"github.com/olivere/elastic"
+	"github.com/spf13/viper"
+	"github.com/tarantool/go-tarantool"
+	"go.uber.org/zap"
+	"googlemaps.github.io/maps"
+	"log"
+	"os"
+	"time"
+)
+
+const (
+	createCouriersRouteSpaceFuncName = "create_couriers_route_space"
+	createResolverCacheSpaceFuncName = "create_resolver_cache"
+)
+
+type Backend string
+
+const (
+	ElasticBackend  Backend = "elastic"
+	MemoryBackend   Backend = "memory"
+	EmbeddedBackend Backend = "embedded"
+)
+
+func BackendFromString(backend string) Backend {
+	switch backend {
+	case "elastic":
+		return Backend(backend)
+	case "memory":
+		return Backend(backend)
+	case "embedded":
+		return Backend(backend)
+	default:
+		panic("INVALID BACKEND")
+	}
+}
+
+// setupElasticBackend wires the production storage: Elasticsearch, Tarantool, Google Maps and Nominatim.
+func setupElasticBackend(logger *zap.Logger) (*controllers.APIService, *services.WebhookDispatcher) {
+	elasticClient, err := elastic.NewClient(
+		elastic.SetURL(viper.GetString("elastic.url")),
+		elastic.SetSniff(viper.GetBool("elastic.sniff")),
+		elastic.SetRetrier(elastic.NewBackoffRetrier(elastic.NewConstantBackoff(time.Second*5))),
+		elastic.SetHealthcheckTimeoutStartup(time.Second*60))
+	if err != nil {
+		log.Fatal(err)
+	}
+	gmaps, err := maps.NewClient(maps.WithAPIKey(viper.GetString("google-maps.apikey")))
+	if err != nil {
+		log.Fatal(err)
+	}
+	tntClient, err := tarantool.Connect(viper.GetString("tarantool.url"), tarantool.Opts{
+		User: viper.GetString("tarantool.user"),
+		Pass: viper.GetString("tarantool.pass"),
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = migrations.Driver{Client: tntClient, Logger: logger}.Run()
+	if err != nil {
+		logger.Fatal("fail to perform migrations", zap.Error(err))
+	}
+	_, err = tntClient.Call17(createCouriersRouteSpaceFuncName, []interface{}{})
+	if err != nil {
+		log.Fatal(err)
+	}
+	_, err = tntClient.Call17(createResolverCacheSpaceFuncName, []interface{}{})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ordersCountTracker := services.NewTarantoolOrdersCountTracker(tntClient, logger)
+
+	couriersDao := services.NewCouriersElasticDAO(elasticClient, logger, "", services.DefaultCouriersReturnSize)
+	ordersDao := services.NewOrdersElasticDAO(elasticClient, logger, couriersDao, "")
+
+	tntResolver := services.NewTntResolver(tntClient, logger)
+	gmapsResolver := services.NewGMapsResolver(gmaps, logger)
+
+	couriersSuggester := services.NewCouriersSuggesterElastic(elasticClient, couriersDao, logger).
+		SetFuzziness(viper.GetInt("suggestions.couriers.fuzziness")).
+		SetFuzzinessThreshold(viper.GetInt("suggestions.couriers.threshold"))
+
+	couriersSuggestEngine := services.PrefixSuggestEngine{
+		Fuzziness: "AUTO",
+		Limit:     15,
+		Field:     "suggestions",
+		Index:     couriersDao.GetIndex(),
+	}
+	ordersPrefixSuggestEngine := services.PrefixSuggestEngine{
+		Fuzziness: "0",
+		Limit:     15,
+		Field:     "order_suggestions",
+		Index:     ordersDao.GetIndex(),
+	}
+	ordersSuggestDestinationEngine := services.OrdersSuggestEngine{
+		Fuzziness:          "1",
+		FuzzinessThreshold: 5,
+		Limit:              15,
+		Field:              "destination.address",
+		Index:              ordersDao.GetIndex(),
+	}
+	concurrentLookup := &services.ConcurrentLookupService{
+		LookupService: services.NewNominatimRegionResolver(viper.GetString("nominatim.url"), logger),
+		Concurrency:   viper.GetInt("photon.lookup_concurrency"),
+	}
+	photonPolygonSuggestionEngine := &services.PhotonSuggestEngine{
+		Limit:                   viper.GetInt("photon.limit"),
+		OSMType:                 "R",
+		Tags:                    viper.GetStringSlice("photon.tags"),
+		ConcurrentLookupService: concurrentLookup,
+	}
+
+	elasticSuggester := services.NewSuggestEngineExecutor(elasticClient, logger)
+	elasticSuggester.AddEngine("orders-engine", &ordersSuggestDestinationEngine)
+	elasticSuggester.AddEngine("couriers-engine", &couriersSuggestEngine)
+	elasticSuggester.AddEngine("orders-prefix-engine", &ordersPrefixSuggestEngine)
+
+	photonClient := photon.NewPhotonClient(viper.GetString("photon.url"))
+	photonSuggester := services.NewPhotonSuggestEngineExecutor(photonClient, logger)
+	photonSuggester.AddEngine("polygons-engine", photonPolygonSuggestionEngine)
+
+	suggestService := services.NewSuggestionService(photonSuggester, elasticSuggester)
+
+	if err := couriersDao.EnsureMapping(); err != nil {
+		logger.Fatal("Fail to ensure couriers mapping: ", zap.Error(err))
+	}
+	if err := ordersDao.EnsureMapping(); err != nil {
+		logger.Fatal("Fail to ensure orders mapping: ", zap.Error(err))
+	}
+	routeArchive := services.NewRouteArchiveElastic(elasticClient, logger, "")
+	if err := routeArchive.EnsureMapping(); err != nil {
+		logger.Fatal("Fail to ensure route archive mapping: ", zap.Error(err))
+	}
+	rejectedLocations := services.NewRejectedLocationsElastic(elasticClient, logger, "")
+	if err := rejectedLocations.EnsureMapping(); err != nil {
+		logger.Fatal("Fail to ensure rejected locations mapping: ", zap.Error(err))
+	}
+	geofences := services.NewGeofencesElastic(elasticClient, logger, "", "")
+	if err := geofences.EnsureMapping(); err != nil {
+		logger.Fatal("Fail to ensure geofences mapping: ", zap.Error(err))
+	}
+	geofenceEvents := services.NewGeofenceEventsElastic(elasticClient, logger, "")
+	if err := geofenceEvents.EnsureMapping(); err != nil {
+		logger.Fatal("Fail to ensure geofence events mapping: ", zap.Error(err))
+	}
+	webhooks := services.NewWebhooksElastic(elasticClient, logger, "", "")
+	if err := webhooks.EnsureMapping(); err != nil {
+		logger.Fatal("Fail to ensure webhooks mapping: ", zap.Error(err))
+	}
+
+	tntRouteDao := services.NewTarantoolRouteDAO(tntClient, logger)
+
+	nominatimResolver := services.NewNominatimRegionResolver(viper.GetString("nominatim.url"), logger)
+	tarantoolRegionResolver := services.NewTarantoolRegionResolver(tntClient, logger)
+	cachedRegionResolver := &services.CachedRegionResolver{
+		TarantoolResolver: tarantoolRegionResolver,
+		NominatimResolver: nominatimResolver,
+	}
+
+	webhookDispatcher := newWebhookDispatcher(webhooks, logger)
+	return &controllers.APIService{
+		CouriersDAO:        couriersDao,
+		OrdersDAO:          ordersDao,
+		CourierRouteDAO:    tntRouteDao,
+		GeoResolver:        services.NewCachedResolver(tntResolver, gmapsResolver),
+		RegionResolver:     cachedRegionResolver,
+		CourierSuggester:   couriersSuggester,
+		Logger:             logger,
+		SuggestionService:  suggestService,
+		OrdersCountTracker: ordersCountTracker,
+		RouteArchive:       routeArchive,
+		RejectedLocations:  rejectedLocations,
+		Geofences:          newGeofenceManager(geofences, geofenceEvents, logger),
+		Webhooks:           webhookDispatcher,
+		Changes:            services.NewTarantoolChangeLog(tntClient, logger),
+	}, webhookDispatcher
+}
+
+// setupMemoryBackend wires in-memory storage that needs no external services.
+// All data is lost on restart.
+func setupMemoryBackend(logger *zap.Logger) (*controllers.APIService, *services.WebhookDispatcher) {
+	couriersDao := services.NewMemoryCouriersDAO(logger, services.DefaultCouriersReturnSize)
+	ordersDao := services.NewMemoryOrdersDAO(logger, couriersDao)
+	webhookDispatcher := newWebhookDispatcher(services.NewMemoryWebhookStore(), logger)
+
+	return &controllers.APIService{
+		CouriersDAO:        couriersDao,
+		OrdersDAO:          ordersDao,
+		CourierRouteDAO:    services.NewMemoryRouteDAO(logger),
+		GeoResolver:        services.NewMemoryResolver(),
+		RegionResolver:     loadMemoryRegions(logger, viper.GetString("memory.regions")),
+		CourierSuggester:   services.NewMemoryCouriersSuggester(couriersDao),
+		Logger:             logger,
+		SuggestionService:  services.NewSuggestionService(),
+		OrdersCountTracker: services.NewMemoryOrdersCountTracker(),
+		RouteArchive:       services.NewMemoryRouteArchive(),
+		RejectedLocations:  services.NewMemoryRejectedLocationsLog(),
+		Geofences: newGeofenceManager(services.NewMemoryGeofenceStore(),
+			services.NewMemoryGeofenceEventLog(), logger),
+		Webhooks: webhookDispatcher,
+		Changes:  services.NewMemoryChangeLog(),
+	}, webhookDispatcher
+}
+
+// setupEmbeddedBackend wires storage persisted to a single local file for single-node deployments.
+// Geocoding and region resolving stay in memory like in the memory backend.
+// The returned store must be closed after the server stops and the dispatcher is closed.
+func setupEmbeddedBackend(logger *zap.Logger) (*controllers.APIService, *services.WebhookDispatcher, *kvstore.Store) {
+	store, err := kvstore.Open(viper.GetString("embedded.path"), viper.GetBool("embedded.sync"))
+	if err != nil {
+		logger.Fatal("fail to open embedded store", zap.Error(err))
+	}
+	couriersDao, err := services.NewEmbeddedCouriersDAO(store, logger,
+		viper.GetFloat64("embedded.cell_size"),
+		services.DefaultCouriersReturnSize)
+	if err != nil {
+		logger.Fatal("fail to build couriers index", zap.Error(err))
+	}
+	ordersDao := services.NewEmbeddedOrdersDAO(store, logger, couriersDao)
+	webhookDispatcher := newWebhookDispatcher(services.NewEmbeddedWebhookStore(store, logger), logger)
+
+	api := &controllers.APIService{
+		CouriersDAO:        couriersDao,
+		OrdersDAO:          ordersDao,
+		CourierRouteDAO:    services.NewEmbeddedRouteDAO(store, logger),
+		GeoResolver:        services.NewMemoryResolver(),
+		RegionResolver:     loadMemoryRegions(logger, viper.GetString("memory.regions")),
+		CourierSuggester:   services.NewMemoryCouriersSuggester(couriersDao),
+		Logger:             logger,
+		SuggestionService:  services.NewSuggestionService(),
+		OrdersCountTracker: services.NewEmbeddedOrdersCountTracker(store),
+		RouteArchive:       services.NewEmbeddedRouteArchive(store, logger),
+		RejectedLocations:  services.NewEmbeddedRejectedLocationsLog(store, logger),
+		Geofences: newGeofenceManager(services.NewEmbeddedGeofenceStore(store, logger),
+			services.NewEmbeddedGeofenceEventLog(store, logger), logger),
+		Webhooks: webhookDispatcher,
+		Changes:  services.NewEmbeddedChangeLog(store, logger),
+	}
+	return api, webhookDispatcher, store
+}
+
+func newGeofenceManager(store interfaces.GeofenceStore, log interfaces.GeofenceEventLog, logger *zap.Logger) *services.GeofenceManager {
+	return services.NewGeofenceManager(store, log, logger, viper.GetDuration("geofences.cache_ttl"))
+}
+
+func newWebhookDispatcher(store interfaces.WebhookStore, logger *zap.Logger) *services.WebhookDispatcher {
+	return services.NewWebhookDispatcher(store, logger,
+		viper.GetInt("webhooks.workers"),
+		viper.GetInt("webhooks.queue_size"),
+		viper.GetInt("webhooks.max_attempts"),
+		viper.GetDuration("webhooks.initial_backoff"),
+		viper.GetDuration("webhooks.max_backoff"),
+		viper.GetDuration("webhooks.timeout"))
+}
+
+func loadMemoryRegions(logger *zap.Logger, path string) *services.MemoryRegionResolver {
+	regionResolver := services.NewMemoryRegionResolver()
+	if path == "" {
+		return regionResolver
+	}
+	regions, err := os.Open(path)
+	if err != nil {
+		logger.Fatal("fail to open regions file", zap.Error(err))
+	}
+	defer regions.Close()
+	if err := regionResolver.Load(regions); err != nil {
+		logger.Fatal("fail to load regions", zap.Error(err))
+	}
+	return regionResolver
+}
+
+// setupServices attaches services that work on top of any storage backend.
+// The returned inactivity worker and change log trimmer, nil when disabled, must be closed before the storage.
+func setupServices(api *controllers.APIService) (*services.InactivityWorker, *services.ChangeLogTrimmer) {
+	api.RouteStatsService = services.NewRouteStatsService(api.CourierRouteDAO,
+		viper.GetDuration("route_stats.stop_duration"),
+		viper.GetFloat64("route_stats.stop_radius"))
+	api.LocationBatchChecker = services.NewLocationBatchPolicy(
+		viper.GetDuration("locations.max_clock_skew"),
+		viper.GetInt("locations.max_batch_size"))
+	api.CourierStream = services.NewCourierStreamHub(
+		viper.GetInt("stream.buffer"),
+		viper.GetInt("stream.max_subscribers"))
+	origins := viper.GetStringSlice("stream.allowed_origins")
+	if len(origins) == 0 {
+		origins = viper.GetStringSlice("cors.origins")
+	}
+	api.StreamUpgrader = controllers.NewStreamUpgrader(origins)
+	api.StreamKeepAlive = viper.GetDuration("stream.keep_alive")
+	if viper.GetBool("location_filter.enabled") {
+		api.LocationFilter = services.NewLocationFilter(api.RejectedLocations, api.Logger,
+			viper.GetString("location_filter.mode"),
+			viper.GetFloat64("location_filter.max_speed"),
+			viper.GetFloat64("location_filter.duplicate_distance"),
+			viper.GetDuration("location_filter.duplicate_interval"))
+	}
+	api.ETA = services.NewETAService(api.CourierRouteDAO,
+		services.NewStraightLineDistance(viper.GetFloat64("eta.detour_factor")),
+		viper.GetDuration("eta.speed_window"),
+		viper.GetFloat64("eta.default_speed"),
+		viper.GetFloat64("eta.min_speed"))
+	if viper.GetBool("arrivals.enabled") {
+		api.Arrivals = services.NewRadiusArrivalDetector(viper.GetFloat64("arrivals.radius"))
+	}
+	api.Dispatcher = services.NewDispatchService(api.CouriersDAO, api.OrdersCountTracker, services.DispatchConfig{
+		DistanceWeight:  viper.GetFloat64("dispatch.distance_weight"),
+		LoadWeight:      viper.GetFloat64("dispatch.load_weight"),
+		FreshnessWeight: viper.GetFloat64("dispatch.freshness_weight"),
+		InitialRadius:   viper.GetInt("dispatch.initial_radius"),
+		MaxRadius:       viper.GetInt("dispatch.max_radius"),
+		RadiusGrowth:    viper.GetFloat64("dispatch.radius_growth"),
+		Candidates:      viper.GetInt("dispatch.candidates"),
+		MaxOrders:       viper.GetInt("dispatch.max_orders"),
+		MaxIdle:         viper.GetDuration("dispatch.max_idle"),
+	})
+	var inactivity *services.InactivityWorker
+	if viper.GetBool("inactivity.enabled") {
+		inactivity = services.NewInactivityWorker(api.CouriersDAO, api.Logger,
+			viper.GetDuration("inactivity.threshold"),
+			viper.GetDuration("inactivity.interval"),
+			api.CourierWentStale)
+		inactivity.Start()
+	}
+	var trimmer *services.ChangeLogTrimmer
+	if viper.GetBool("changes.trim") && api.Changes != nil {
+		trimmer = services.NewChangeLogTrimmer(api.Changes, api.Logger,
+			viper.GetDuration("changes.retention"),
+			viper.GetDuration("changes.interval"))
+		trimmer.Start()
+	}
+	return inactivity, trimmer
+}
@@ -2,17 +2,33 @@ package controllers
 
 import (
 	"github.com/TeamD2018/geo-rest/services/interfaces"
+	"github.com/gorilla/websocket"
 	"go.uber.org/zap"
+	"time"
 )
 
 type APIService struct {
-	OrdersDAO          interfaces.IOrdersDao
-	CouriersDAO        interfaces.ICouriersDAO
-	CourierRouteDAO    interfaces.GeoRouteInterface
-	GeoResolver        interfaces.GeoResolver
-	RegionResolver     interfaces.IRegionResolver
-	CourierSuggester   interfaces.CourierSuggester
-	Logger             *zap.Logger
-	SuggestionService  interfaces.SuggestionService
-	OrdersCountTracker interfaces.OrdersCountTracker
+	OrdersDAO            interfaces.IOrdersDao
+	CouriersDAO          interfaces.ICouriersDAO
+	CourierRouteDAO      interfaces.GeoRouteInterface
+	GeoResolver          interfaces.GeoResolver
+	RegionResolver       interfaces.IRegionResolver
+	CourierSuggester     interfaces.CourierSuggester
+	Logger               *zap.Logger
+	SuggestionService    interfaces.SuggestionService
+	OrdersCountTracker   interfaces.OrdersCountTracker
+	RouteStatsService    interfaces.RouteStatsService
+	RouteArchive         interfaces.RouteArchive
+	LocationBatchChecker interfaces.LocationBatchChecker
+	LocationFilter       interfaces.LocationFilter
+	RejectedLocations    interfaces.RejectedLocationsLog
+	CourierStream        interfaces.CourierStream
+	StreamUpgrader       *websocket.Upgrader
+	StreamKeepAlive      time.Duration
+	Geofences            interfaces.GeofenceService
+	Webhooks             interfaces.WebhookService
+	Changes              interfaces.ChangeLog
+	ETA                  interfaces.ETAService
+	Arrivals             interfaces.ArrivalDetector
+	Dispatcher           interfaces.Dispatcher
 }
@@ -0,0 +1,89 @@
+package controllers
+
+import (
+	"encoding/json"
+	"github.com/TeamD2018/geo-rest/controllers/parameters"
+	"github.com/TeamD2018/geo-rest/models"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"net/http"
+	"time"
+)
+
+// GetChanges returns the changes logged after the cursor. A client resumes with next_cursor of the previous page.
+func (api *APIService) GetChanges(ctx *gin.Context) {
+	params := parameters.Changes{}
+	if err := ctx.BindQuery(&params); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, models.ErrOneOfParameterHaveIncorrectFormat)
+		return
+	}
+	if params.Limit < 0 || params.Limit > parameters.MaxChangesLimit {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, models.ErrOneOfParameterHaveIncorrectFormat.SetParameter("limit"))
+		return
+	}
+	after, err := models.ParseChangeCursor(params.Cursor)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, models.ErrOneOfParameterHaveIncorrectFormat.SetParameter("cursor"))
+		return
+	}
+	if params.Limit == 0 {
+		params.Limit = parameters.DefaultChangesLimit
+	}
+	response := models.ChangesResponse{
+		Changes:    models.Changes{},
+		NextCursor: models.ChangeCursor(after),
+	}
+	if api.Changes == nil {
+		ctx.JSON(http.StatusOK, response)
+		return
+	}
+	// one extra change tells whether the page is the last one
+	changes, err := api.Changes.Read(after, params.Limit+1)
+	if err != nil {
+		api.Logger.Error("fail to read changes", zap.Uint64("after", after), zap.Error(err))
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, models.ErrServerError)
+		return
+	}
+	if len(changes) > params.Limit {
+		changes = changes[:params.Limit]
+		response.HasMore = true
+	}
+	if len(changes) > 0 {
+		response.Changes = changes
+		response.NextCursor = models.ChangeCursor(changes[len(changes)-1].Seq)
+	}
+	ctx.JSON(http.StatusOK, response)
+}
+
+// recordChange appends a mutation to the change log. The mutation is already stored when it fails, so handlers
+// answer 500 and the client retries rather than the change feed silently missing the mutation.
+// Storing and logging are separate steps, see models.Change for what it means to the order of the log.
+func (api *APIService) recordChange(entity, op, id, courierID string, data interface{}) error {
+	if api.Changes == nil {
+		return nil
+	}
+	change := &models.Change{
+		Entity:    entity,
+		Op:        op,
+		ID:        id,
+		CourierID: courierID,
+		Ts:        time.Now().Unix(),
+	}
+	if data != nil {
+		raw, err := json.Marshal(data)
+		if err != nil {
+			api.Logger.Error("fail to marshal change", zap.String("entity", entity), zap.String("id", id), zap.Error(err))
+			return err
+		}
+		change.Data = raw
+	}
+	if err := api.Changes.Append(change); err != nil {
+		api.Logger.Error("fail to record change",
+			zap.String("entity", entity),
+			zap.String("op", op),
+			zap.String("id", id),
+			zap.Error(err))
+		return err
+	}
+	return nil
+}
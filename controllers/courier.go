@@ -3,6 +3,7 @@ package controllers
 import (
 	"github.com/TeamD2018/geo-rest/controllers/parameters"
 	"github.com/TeamD2018/geo-rest/models"
+	"github.com/TeamD2018/geo-rest/services/geo"
 	"github.com/gin-gonic/gin"
 	"github.com/satori/go.uuid"
 	"go.uber.org/zap"
@@ -21,6 +22,10 @@ func (api *APIService) CreateCourier(ctx *gin.Context) {
 		ctx.AbortWithStatusJSON(http.StatusInternalServerError, models.ErrServerError)
 		return
 	} else {
+		if err := api.recordChange(models.ChangeCourier, models.ChangeCreated, res.ID, res.ID, res); err != nil {
+			ctx.AbortWithStatusJSON(http.StatusInternalServerError, models.ErrServerError)
+			return
+		}
 		ctx.JSON(http.StatusCreated, res)
 		return
 	}
@@ -66,7 +71,42 @@ func (api *APIService) UpdateCourier(ctx *gin.Context) {
 		ctx.AbortWithStatusJSON(http.StatusBadRequest, models.ErrOneOfParameterHaveIncorrectFormat)
 		return
 	}
+	if courier.Location != nil && !courier.Location.Fix.Valid() {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, models.ErrOneOfParameterHaveIncorrectFormat.SetParameter("location"))
+		return
+	}
+	filterLocation := courier.Location != nil && courier.Location.Point != nil && api.LocationFilter != nil
+	// the activity change is only known against the current state
+	var current *models.Courier
+	if filterLocation || (courier.IsActive != nil && api.Webhooks != nil) {
+		var err error
+		if current, err = api.CouriersDAO.GetByID(courierID); err != nil {
+			ctx.AbortWithStatusJSON(http.StatusNotFound, models.ErrEntityNotFound.SetParameter(courierID))
+			return
+		}
+	}
+	if filterLocation {
+		point := &models.PointWithTs{
+			Point: courier.Location.Point,
+			Ts:    uint64(time.Now().Unix()),
+			Fix:   courier.Location.Fix,
+		}
+		if rejected := api.LocationFilter.Filter(courierID, current.LastPoint(), point, models.LocationSourceUpdate); rejected != nil {
+			ctx.AbortWithStatusJSON(http.StatusUnprocessableEntity, rejected)
+			return
+		}
+	}
 	courier.ID = &courierID
+	if courier.Location != nil {
+		// a single location is stamped with server time by the DAO
+		courier.LastSeen = nil
+	}
+	// the stale flag is set by the inactivity worker only, an explicit activity change clears it
+	courier.Stale = nil
+	if courier.IsActive != nil {
+		notStale := false
+		courier.Stale = &notStale
+	}
 	updated, err := api.CouriersDAO.Update(courier)
 	if err != nil {
 		ctx.AbortWithStatusJSON(http.StatusInternalServerError, models.ErrServerError)
@@ -76,16 +116,44 @@ func (api *APIService) UpdateCourier(ctx *gin.Context) {
 	if err := api.OrdersCountTracker.Sync(models.Couriers{updated}); err != nil {
 		api.Logger.Error("fail to sync order counter", zap.Error(err), zap.String("courier_id", courierID))
 	}
+	if courier.Location != nil && courier.Location.Point != nil && courier.IsActive == nil {
+		updated = api.reviveCourier(updated)
+	}
 
 	if updated.OrdersCount > 0 {
 		pointWithTs := &models.PointWithTs{
 			Point: updated.Location.Point,
 			Ts:    uint64(time.Now().Unix()),
+			Fix:   updated.Location.Fix,
 		}
 		if err := api.CourierRouteDAO.AddPointToRoute(courierID, pointWithTs); err != nil {
 			api.Logger.Error("fail to add point to route", zap.Error(err), zap.String("courier_id", courierID))
+		} else if err := api.recordChange(models.ChangeRoute, models.ChangeUpdated, courierID, courierID, pointWithTs); err != nil {
+			ctx.AbortWithStatusJSON(http.StatusInternalServerError, models.ErrServerError)
+			return
 		}
+		if courier.Location != nil && courier.Location.Point != nil {
+			if err := api.detectArrivals(courierID, models.Points{pointWithTs}); err != nil {
+				ctx.AbortWithStatusJSON(http.StatusInternalServerError, models.ErrServerError)
+				return
+			}
+		}
+	}
+	if err := api.recordChange(models.ChangeCourier, models.ChangeUpdated, courierID, courierID, updated); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, models.ErrServerError)
+		return
 	}
+	if courier.Location != nil && courier.Location.Point != nil {
+		api.evaluateGeofences(courierID, updated.Location.Point, time.Now().Unix())
+	}
+	if current != nil && courier.IsActive != nil && current.IsActive != updated.IsActive {
+		if updated.IsActive {
+			api.dispatchWebhook(models.WebhookCourierActivated, updated)
+		} else {
+			api.dispatchWebhook(models.WebhookCourierDeactivated, updated)
+		}
+	}
+	api.publishCourier(updated)
 	ctx.JSON(http.StatusOK, updated)
 }
 
@@ -176,34 +244,134 @@ func (api *APIService) GetRouteForCourier(ctx *gin.Context) {
 	if courierRouteParams.Since < 0 {
 		courierRouteParams.Since = 0
 	}
-	if points, err := api.CourierRouteDAO.GetRoute(courierRouteParams.CourierID, courierRouteParams.Since); err != nil {
+	if courierRouteParams.Format == "" {
+		courierRouteParams.Format = negotiateRouteFormat(ctx)
+	}
+	if !isRouteFormat(courierRouteParams.Format) {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, models.ErrOneOfParameterHaveIncorrectFormat.SetParameter("format"))
+		return
+	}
+	if courierRouteParams.Until < 0 || (courierRouteParams.Until > 0 && courierRouteParams.Until < courierRouteParams.Since) {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, models.ErrOneOfParameterHaveIncorrectFormat.SetParameter("until"))
+		return
+	}
+	if courierRouteParams.Limit < 0 {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, models.ErrOneOfParameterHaveIncorrectFormat.SetParameter("limit"))
+		return
+	}
+	if courierRouteParams.MaxAccuracy < 0 {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, models.ErrOneOfParameterHaveIncorrectFormat.SetParameter("max_accuracy"))
+		return
+	}
+	query := &models.RouteQuery{
+		Since:       courierRouteParams.Since,
+		Until:       courierRouteParams.Until,
+		Limit:       courierRouteParams.Limit,
+		MaxAccuracy: courierRouteParams.MaxAccuracy,
+	}
+	if courierRouteParams.Cursor != "" {
+		cursor, err := models.ParseRouteCursor(courierRouteParams.Cursor)
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, models.ErrOneOfParameterHaveIncorrectFormat.SetParameter("cursor"))
+			return
+		}
+		query.After = cursor
+	}
+	if courierRouteParams.Tolerance < 0 {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, models.ErrOneOfParameterHaveIncorrectFormat.SetParameter("tolerance"))
+		return
+	}
+	if courierRouteParams.MaxPoints < 0 || courierRouteParams.MaxPoints == 1 {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, models.ErrOneOfParameterHaveIncorrectFormat.SetParameter("max_points"))
+		return
+	}
+	if page, err := api.CourierRouteDAO.GetRoute(courierRouteParams.CourierID, query); err != nil {
 		api.Logger.Error("fail to get route", zap.Error(err),
 			zap.String("courier_id", courierRouteParams.CourierID),
-			zap.Int64("since", courierRouteParams.Since))
+			zap.Int64("since", courierRouteParams.Since),
+			zap.Int64("until", courierRouteParams.Until))
 		ctx.AbortWithStatusJSON(http.StatusInternalServerError, models.ErrServerError)
 		return
 	} else {
-		geoHistory := models.RouteResponse{GeoHistory: points}
-		ctx.JSON(http.StatusOK, geoHistory)
+		page.Points = geo.Simplify(page.Points, courierRouteParams.Tolerance, courierRouteParams.MaxPoints)
+		renderRoute(ctx, courierRouteParams.Format, courierRouteParams.CourierID, page)
 	}
 }
 
+func (api *APIService) GetRouteStatsForCourier(ctx *gin.Context) {
+	params := parameters.CourierRouteStats{}
+	params.CourierID = ctx.Param("courier_id")
+	if err := ctx.BindQuery(&params); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, models.ErrOneOfParameterHaveIncorrectFormat)
+		return
+	}
+	if params.Since < 0 {
+		params.Since = 0
+	}
+	if params.Until < 0 || (params.Until > 0 && params.Until < params.Since) {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, models.ErrOneOfParameterHaveIncorrectFormat.SetParameter("until"))
+		return
+	}
+	if params.StopMinutes < 0 {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, models.ErrOneOfParameterHaveIncorrectFormat.SetParameter("stop_minutes"))
+		return
+	}
+	if params.StopRadius < 0 {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, models.ErrOneOfParameterHaveIncorrectFormat.SetParameter("stop_radius"))
+		return
+	}
+	if params.MaxAccuracy < 0 {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, models.ErrOneOfParameterHaveIncorrectFormat.SetParameter("max_accuracy"))
+		return
+	}
+	query := &models.RouteQuery{
+		Since:       params.Since,
+		Until:       params.Until,
+		MaxAccuracy: params.MaxAccuracy,
+	}
+	stopDuration := time.Duration(params.StopMinutes * float64(time.Minute))
+	stats, err := api.RouteStatsService.Stats(params.CourierID, query, stopDuration, params.StopRadius)
+	if err != nil {
+		api.Logger.Error("fail to get route stats", zap.Error(err),
+			zap.String("courier_id", params.CourierID),
+			zap.Int64("since", params.Since),
+			zap.Int64("until", params.Until))
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, models.ErrServerError)
+		return
+	}
+	ctx.JSON(http.StatusOK, stats)
+}
+
 func (api *APIService) DeleteCourier(ctx *gin.Context) {
 	courierID := ctx.Param("courier_id")
 	if _, err := uuid.FromString(courierID); err != nil {
 		ctx.AbortWithStatusJSON(http.StatusBadRequest, models.ErrOneOfParameterHaveIncorrectFormat)
 		return
 	}
+	if !api.archiveRoute(courierID) {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, models.ErrServerError)
+		return
+	}
 	if err := api.CouriersDAO.Delete(courierID); err != nil {
 		api.Logger.Sugar().Error(err)
 		ctx.AbortWithStatusJSON(http.StatusInternalServerError, models.ErrServerError)
 		return
 	}
+	// the courier is gone even if a later step fails, its stream state must not be kept
+	api.publishCourierDeleted(courierID)
+	if err := api.recordChange(models.ChangeCourier, models.ChangeDeleted, courierID, courierID, nil); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, models.ErrServerError)
+		return
+	}
 	if err := api.CourierRouteDAO.DeleteCourier(courierID); err != nil {
 		api.Logger.Sugar().Error(err)
 		ctx.AbortWithStatusJSON(http.StatusInternalServerError, models.ErrServerError)
 		return
 	}
+	if err := api.recordChange(models.ChangeRoute, models.ChangeDeleted, courierID, courierID, nil); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, models.ErrServerError)
+		return
+	}
 	if err := api.OrdersDAO.DeleteOrdersForCourier(courierID); err != nil {
 		api.Logger.Error("fail to delete orders for courier",
 			zap.Error(err),
@@ -215,6 +383,8 @@ func (api *APIService) DeleteCourier(ctx *gin.Context) {
 	if err := api.OrdersCountTracker.Drop(ctx.Param("courier_id")); err != nil {
 		api.Logger.Error("fail to drop orders counter", zap.Error(err), zap.String("courier_id", courierID))
 	}
+	api.forgetGeofences(courierID)
+	api.dispatchWebhook(models.WebhookCourierDeleted, &models.WebhookDeleted{ID: courierID})
 
 	ctx.Status(http.StatusNoContent)
 }
@@ -0,0 +1,31 @@
+package controllers
+
+import (
+	"github.com/TeamD2018/geo-rest/models"
+	"go.uber.org/zap"
+)
+
+// CourierWentStale announces a courier the inactivity worker has marked stale.
+// There is no request to fail, a change that can not be logged is only logged by recordChange.
+func (api *APIService) CourierWentStale(courier *models.Courier) {
+	api.recordChange(models.ChangeCourier, models.ChangeUpdated, courier.ID, courier.ID, courier)
+	api.dispatchWebhook(models.WebhookCourierDeactivated, courier)
+	api.publishCourier(courier)
+}
+
+// reviveCourier makes a stale courier active again after it sent a location. The courier is returned unchanged
+// if it is not stale or the update fails.
+func (api *APIService) reviveCourier(courier *models.Courier) *models.Courier {
+	if !courier.Stale {
+		return courier
+	}
+	active, stale := true, false
+	revived, err := api.CouriersDAO.Update(&models.CourierUpdate{ID: &courier.ID, IsActive: &active, Stale: &stale})
+	if err != nil {
+		api.Logger.Error("fail to revive stale courier", zap.Error(err), zap.String("courier_id", courier.ID))
+		return courier
+	}
+	revived.OrdersCount = courier.OrdersCount
+	api.dispatchWebhook(models.WebhookCourierActivated, revived)
+	return revived
+}
@@ -0,0 +1,121 @@
+package controllers
+
+import (
+	"github.com/TeamD2018/geo-rest/models"
+	"github.com/gin-gonic/gin"
+	"github.com/satori/go.uuid"
+	"go.uber.org/zap"
+	"net/http"
+)
+
+// AddCourierLocations stores a batch of device positions in the route and moves the courier to the newest one.
+func (api *APIService) AddCourierLocations(ctx *gin.Context) {
+	courierID := ctx.Param("courier_id")
+	if _, err := uuid.FromString(courierID); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, models.ErrOneOfParameterHaveIncorrectFormat.SetParameter("courier_id"))
+		return
+	}
+	var batch models.LocationBatch
+	if err := ctx.ShouldBindJSON(&batch); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, models.ErrOneOfParameterHaveIncorrectFormat)
+		return
+	}
+	if len(batch.Points) == 0 || len(batch.Points) > api.LocationBatchChecker.MaxBatchSize() {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, models.ErrOneOfParameterHaveIncorrectFormat.SetParameter("points"))
+		return
+	}
+	courier, err := api.CouriersDAO.GetByID(courierID)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusNotFound, models.ErrEntityNotFound.SetParameter(courierID))
+		return
+	}
+	var lastSeen int64
+	if courier.LastSeen != nil {
+		lastSeen = *courier.LastSeen
+	}
+	result := &models.LocationBatchResult{
+		Rejected: make([]*models.LocationSampleIssue, 0),
+		Flagged:  make([]*models.LocationSampleIssue, 0),
+	}
+	accepted := api.filterSamples(courier, batch.Points, api.LocationBatchChecker.Check(batch.Points, lastSeen, result), result)
+	if len(accepted) > 0 {
+		newest := batch.Points[accepted[len(accepted)-1]]
+		if int64(newest.Ts) >= lastSeen {
+			ts := int64(newest.Ts)
+			courier, err = api.CouriersDAO.Update(&models.CourierUpdate{
+				ID:       &courierID,
+				Location: &models.Location{Point: newest.Point, Fix: newest.Fix},
+				LastSeen: &ts,
+			})
+			if err != nil {
+				api.Logger.Error("fail to update courier location", zap.Error(err), zap.String("courier_id", courierID))
+				ctx.AbortWithStatusJSON(http.StatusInternalServerError, models.ErrServerError)
+				return
+			}
+			courier = api.reviveCourier(courier)
+			if err := api.recordChange(models.ChangeCourier, models.ChangeUpdated, courierID, courierID, courier); err != nil {
+				ctx.AbortWithStatusJSON(http.StatusInternalServerError, models.ErrServerError)
+				return
+			}
+		}
+	}
+	if err := api.OrdersCountTracker.Sync(models.Couriers{courier}); err != nil {
+		api.Logger.Error("fail to sync order counter", zap.Error(err), zap.String("courier_id", courierID))
+	}
+	for _, i := range accepted {
+		if courier.OrdersCount == 0 {
+			result.Accepted++
+			continue
+		}
+		sample := batch.Points[i]
+		point := &models.PointWithTs{Point: sample.Point, Ts: sample.Ts, Fix: sample.Fix}
+		if err := api.CourierRouteDAO.AddPointToRoute(courierID, point); err != nil {
+			api.Logger.Error("fail to add point to route", zap.Error(err), zap.String("courier_id", courierID))
+			result.Reject(i, models.SampleNotStored)
+			continue
+		}
+		if err := api.recordChange(models.ChangeRoute, models.ChangeUpdated, courierID, courierID, point); err != nil {
+			ctx.AbortWithStatusJSON(http.StatusInternalServerError, models.ErrServerError)
+			return
+		}
+		result.Accepted++
+	}
+	fresh := make(models.Points, 0, len(accepted))
+	for _, i := range accepted {
+		// older samples would replay visits out of order
+		if sample := batch.Points[i]; int64(sample.Ts) >= lastSeen {
+			api.evaluateGeofences(courierID, sample.Point, int64(sample.Ts))
+			fresh = append(fresh, &models.PointWithTs{Point: sample.Point, Ts: sample.Ts})
+		}
+	}
+	if courier.OrdersCount > 0 {
+		if err := api.detectArrivals(courierID, fresh); err != nil {
+			ctx.AbortWithStatusJSON(http.StatusInternalServerError, models.ErrServerError)
+			return
+		}
+	}
+	api.publishCourier(courier)
+	result.Courier = courier
+	ctx.JSON(http.StatusOK, result)
+}
+
+// filterSamples runs accepted samples through the location filter, each compared with the one accepted before it,
+// and returns the indexes of samples that passed.
+func (api *APIService) filterSamples(courier *models.Courier, samples []*models.LocationSample, accepted []int,
+	result *models.LocationBatchResult) []int {
+	if api.LocationFilter == nil {
+		return accepted
+	}
+	passed := make([]int, 0, len(accepted))
+	previous := courier.LastPoint()
+	for _, i := range accepted {
+		point := &models.PointWithTs{Point: samples[i].Point, Ts: samples[i].Ts, Fix: samples[i].Fix}
+		if rejected := api.LocationFilter.Filter(courier.ID, previous, point, models.LocationSourceBatch); rejected != nil {
+			result.Reject(i, rejected.Reason)
+			continue
+		}
+		passed = append(passed, i)
+		previous = point
+	}
+	return passed
+}
@@ -0,0 +1,298 @@
+package controllers
+
+import (
+	"encoding/json"
+	"github.com/TeamD2018/geo-rest/controllers/parameters"
+	"github.com/TeamD2018/geo-rest/models"
+	"github.com/TeamD2018/geo-rest/services/interfaces"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultStreamKeepAlive is how often an idle stream is pinged so that proxies keep it open,
+// unless APIService.StreamKeepAlive is set.
+const DefaultStreamKeepAlive = 30 * time.Second
+
+// NewStreamUpgrader accepts WebSocket streams from browsers on the listed origins, "*" allows any origin.
+// Requests without an Origin header do not come from a browser and are accepted.
+func NewStreamUpgrader(origins []string) *websocket.Upgrader {
+	allowed := make(map[string]bool, len(origins))
+	for _, origin := range origins {
+		allowed[strings.ToLower(strings.TrimSuffix(origin, "/"))] = true
+	}
+	return &websocket.Upgrader{
+		CheckOrigin: func(r *http.Request) bool {
+			origin := r.Header.Get("Origin")
+			return origin == "" || allowed["*"] || allowed[strings.ToLower(origin)]
+		},
+	}
+}
+
+// StreamCouriers pushes changes of couriers inside a viewport over a WebSocket, or over Server-Sent Events
+// when the request is not a WebSocket upgrade. WebSocket clients move the viewport by sending it as a JSON
+// message, SSE clients with PUT /stream/couriers/:subscription_id.
+func (api *APIService) StreamCouriers(ctx *gin.Context) {
+	if api.CourierStream == nil {
+		ctx.AbortWithStatusJSON(http.StatusNotFound, models.ErrEntityNotFound.SetParameter("stream"))
+		return
+	}
+	params := parameters.CourierStream{}
+	if err := ctx.BindQuery(&params); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, models.ErrOneOfParameterHaveIncorrectFormat)
+		return
+	}
+	viewport, apiErr := api.resolveViewport(&params)
+	if apiErr != nil {
+		ctx.AbortWithStatusJSON(apiErr.HttpStatus(), apiErr)
+		return
+	}
+	// subscribed before the snapshot is read, so that nothing published meanwhile is missed
+	subscription, err := api.CourierStream.Subscribe(viewport)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusServiceUnavailable, err)
+		return
+	}
+	defer subscription.Close()
+	if apiErr := api.sendSnapshot(subscription, viewport, params.Size); apiErr != nil {
+		ctx.AbortWithStatusJSON(apiErr.HttpStatus(), apiErr)
+		return
+	}
+	if websocket.IsWebSocketUpgrade(ctx.Request) {
+		api.streamWebSocket(ctx, subscription)
+		return
+	}
+	api.streamSSE(ctx, subscription)
+}
+
+// MoveCourierStream changes the viewport of a Server-Sent Events subscription.
+// The new snapshot is delivered over the stream.
+func (api *APIService) MoveCourierStream(ctx *gin.Context) {
+	if api.CourierStream == nil {
+		ctx.AbortWithStatusJSON(http.StatusNotFound, models.ErrEntityNotFound.SetParameter("stream"))
+		return
+	}
+	subscriptionID := ctx.Param("subscription_id")
+	subscription, ok := api.CourierStream.Subscription(subscriptionID)
+	if !ok {
+		ctx.AbortWithStatusJSON(http.StatusNotFound, models.ErrEntityNotFound.SetParameter(subscriptionID))
+		return
+	}
+	params := parameters.CourierStream{}
+	if err := ctx.BindQuery(&params); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, models.ErrOneOfParameterHaveIncorrectFormat)
+		return
+	}
+	viewport, apiErr := api.resolveViewport(&params)
+	if apiErr != nil {
+		ctx.AbortWithStatusJSON(apiErr.HttpStatus(), apiErr)
+		return
+	}
+	subscription.SetViewport(viewport)
+	if apiErr := api.sendSnapshot(subscription, viewport, params.Size); apiErr != nil {
+		ctx.AbortWithStatusJSON(apiErr.HttpStatus(), apiErr)
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
+
+// resolveViewport validates params and returns the viewport they describe.
+func (api *APIService) resolveViewport(params *parameters.CourierStream) (*models.Viewport, *models.Error) {
+	viewport := &models.Viewport{ActiveOnly: params.ActiveOnly}
+	if circle := params.Circle(); circle != nil {
+		if circle.Radius < 0 {
+			return nil, models.ErrOneOfParameterHaveIncorrectFormat.SetParameter("radius")
+		}
+		if !validLatLon(circle.Lat, circle.Lon) {
+			return nil, models.ErrOneOfParameterHaveIncorrectFormat.SetParameter("lat")
+		}
+		viewport.Circle = circle.ToCircleField()
+	} else if polygon := params.Polygon(); polygon != nil {
+		var err error
+		viewport.Polygon, err = api.RegionResolver.ResolveRegion(polygon.ToOSMEntity())
+		if err != nil {
+			api.Logger.Error("fail to resolve region", zap.Error(err), zap.Int("osm_id", polygon.OSMID))
+			return nil, &models.ErrServerError
+		}
+	} else {
+		box := params.Box()
+		if !validLatLon(box.TopLeftLat, box.TopLeftLon) || !validLatLon(box.BottomRightLat, box.BottomRightLon) {
+			return nil, models.ErrOneOfParameterHaveIncorrectFormat.SetParameter("box")
+		}
+		viewport.Box = box.ToBoxField()
+	}
+	return viewport, nil
+}
+
+// sendSnapshot reads the couriers viewport holds now and sends them to subscription,
+// which holds the changes published since it was subscribed or moved to viewport.
+func (api *APIService) sendSnapshot(subscription interfaces.CourierSubscription, viewport *models.Viewport,
+	size int) *models.Error {
+	var couriers models.Couriers
+	var err error
+	switch {
+	case viewport.Circle != nil:
+		couriers, err = api.CouriersDAO.GetByCircleField(viewport.Circle, size, viewport.ActiveOnly)
+	case viewport.Box != nil:
+		couriers, err = api.CouriersDAO.GetByBoxField(viewport.Box, size, viewport.ActiveOnly)
+	default:
+		couriers, err = api.CouriersDAO.GetByPolygon(viewport.Polygon, size, viewport.ActiveOnly)
+	}
+	if err != nil {
+		api.Logger.Error("fail to get couriers for stream snapshot", zap.Error(err))
+		return &models.ErrServerError
+	}
+	if err := api.OrdersCountTracker.Sync(couriers); err != nil {
+		api.Logger.Error("fail to sync couriers counters", zap.Error(err))
+	}
+	subscription.SetSnapshot(viewport, couriers)
+	return nil
+}
+
+func (api *APIService) streamWebSocket(ctx *gin.Context, subscription interfaces.CourierSubscription) {
+	upgrader := api.StreamUpgrader
+	if upgrader == nil {
+		// only the origin of the server itself is accepted
+		upgrader = &websocket.Upgrader{}
+	}
+	conn, err := upgrader.Upgrade(ctx.Writer, ctx.Request, nil)
+	if err != nil {
+		api.Logger.Debug("fail to upgrade stream to websocket", zap.Error(err))
+		return
+	}
+	defer conn.Close()
+	// a connection has a single writer, the reader hands its replies over
+	replies := make(chan *models.CourierEvent)
+	go api.readViewports(conn, subscription, replies)
+	keepAlive := time.NewTicker(api.streamKeepAlive())
+	defer keepAlive.Stop()
+	for {
+		select {
+		case event := <-subscription.Events():
+			if err := conn.WriteJSON(event); err != nil {
+				return
+			}
+		case reply := <-replies:
+			if err := conn.WriteJSON(reply); err != nil {
+				return
+			}
+		case <-keepAlive.C:
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		case <-subscription.Done():
+			return
+		}
+	}
+}
+
+// readViewports applies viewports sent by a WebSocket client until the connection is closed.
+// Invalid viewports are answered with error events through replies.
+func (api *APIService) readViewports(conn *websocket.Conn, subscription interfaces.CourierSubscription,
+	replies chan<- *models.CourierEvent) {
+	defer subscription.Close()
+	reply := func(event *models.CourierEvent) {
+		select {
+		case replies <- event:
+		case <-subscription.Done():
+		}
+	}
+	for {
+		messageType, message, err := conn.ReadMessage()
+		if err != nil {
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				api.Logger.Debug("fail to read stream message", zap.Error(err))
+			}
+			return
+		}
+		if messageType != websocket.TextMessage {
+			continue
+		}
+		params := parameters.CourierStream{}
+		if err := json.Unmarshal(message, &params); err != nil {
+			reply(&models.CourierEvent{
+				Type:  models.CourierEventError,
+				Error: models.ErrUnmarshalJSON.SetParameter(err.Error()),
+			})
+			continue
+		}
+		viewport, apiErr := api.resolveViewport(&params)
+		if apiErr != nil {
+			reply(&models.CourierEvent{Type: models.CourierEventError, Error: apiErr})
+			continue
+		}
+		subscription.SetViewport(viewport)
+		if apiErr := api.sendSnapshot(subscription, viewport, params.Size); apiErr != nil {
+			reply(&models.CourierEvent{Type: models.CourierEventError, Error: apiErr})
+		}
+	}
+}
+
+func (api *APIService) streamSSE(ctx *gin.Context, subscription interfaces.CourierSubscription) {
+	ctx.Header("Cache-Control", "no-cache")
+	ctx.Header("Connection", "keep-alive")
+	// disables response buffering in nginx
+	ctx.Header("X-Accel-Buffering", "no")
+	keepAlive := time.NewTicker(api.streamKeepAlive())
+	defer keepAlive.Stop()
+	ctx.Stream(func(w io.Writer) bool {
+		select {
+		case event := <-subscription.Events():
+			ctx.SSEvent(event.Type, event)
+			return true
+		case <-keepAlive.C:
+			_, err := io.WriteString(w, ": ping\n\n")
+			return err == nil
+		case <-subscription.Done():
+			return false
+		case <-ctx.Request.Context().Done():
+			return false
+		}
+	})
+}
+
+func (api *APIService) streamKeepAlive() time.Duration {
+	if api.StreamKeepAlive <= 0 {
+		return DefaultStreamKeepAlive
+	}
+	return api.StreamKeepAlive
+}
+
+// publishCourier tells stream subscribers about courier, whose orders count must be synced.
+func (api *APIService) publishCourier(courier *models.Courier) {
+	if api.CourierStream == nil || courier == nil {
+		return
+	}
+	api.CourierStream.PublishUpdate(courier)
+}
+
+// publishCourierByID re-reads the courier for stream subscribers, e.g. after its orders count changed.
+func (api *APIService) publishCourierByID(courierID string) {
+	if api.CourierStream == nil {
+		return
+	}
+	courier, err := api.CouriersDAO.GetByID(courierID)
+	if err != nil {
+		api.Logger.Error("fail to get courier for stream", zap.Error(err), zap.String("courier_id", courierID))
+		return
+	}
+	if err := api.OrdersCountTracker.Sync(models.Couriers{courier}); err != nil {
+		api.Logger.Error("fail to sync order counter", zap.Error(err), zap.String("courier_id", courierID))
+	}
+	api.CourierStream.PublishUpdate(courier)
+}
+
+func (api *APIService) publishCourierDeleted(courierID string) {
+	if api.CourierStream == nil {
+		return
+	}
+	api.CourierStream.PublishDelete(courierID)
+}
+
+func validLatLon(lat, lon float64) bool {
+	return lat >= -90 && lat <= 90 && lon >= -180 && lon <= 180
+}
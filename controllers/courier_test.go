@@ -1,12 +1,15 @@
 package controllers
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/json"
 	"fmt"
 	"github.com/TeamD2018/geo-rest/controllers/mocks"
 	"github.com/TeamD2018/geo-rest/models"
+	"github.com/TeamD2018/geo-rest/services"
 	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
 	"github.com/olivere/elastic"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
@@ -14,7 +17,9 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strings"
 	"testing"
+	"time"
 )
 
 type ControllerCouriersTestSuite struct {
@@ -80,6 +85,10 @@ func (ts *ControllerCouriersTestSuite) BeforeTest(suiteName, testName string) {
 	ts.ordersTrackerMock.On("DecAndGet", mock.AnythingOfType("string")).Return(1, nil)
 	ts.ordersTrackerMock.On("Sync", mock.Anything).Return(nil)
 	ts.api.OrdersCountTracker = ts.ordersTrackerMock
+	ts.api.LocationFilter = nil
+	ts.api.RejectedLocations = nil
+	ts.api.Geofences = nil
+	ts.api.Arrivals = nil
 }
 
 func (ts *ControllerCouriersTestSuite) TestAPIService_CreateCourier_Created() {
@@ -120,7 +129,7 @@ func (ts *ControllerCouriersTestSuite) TestAPIService_UpdateCourier_OK() {
 	ts.testCourier.Location = ts.testCourierUpdate.Location
 	ts.couriersDAOMock.On("Update", mock.Anything).Return(ts.testCourier, nil)
 	ts.geoRouteMock.On("AddPointToRoute", mock.Anything, mock.Anything).Return(nil)
-	ts.ordersDAOMock.On("GetOrdersForCourier", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(models.Orders{}, nil)
+	ts.ordersDAOMock.On("GetOrdersForCourier", mock.Anything, mock.Anything).Return(&models.OrdersPage{Orders: models.Orders{}}, nil)
 	ts.api.OrdersDAO = ts.ordersDAOMock
 	ts.api.CouriersDAO = ts.couriersDAOMock
 	ts.api.CourierRouteDAO = ts.geoRouteMock
@@ -143,7 +152,7 @@ func (ts *ControllerCouriersTestSuite) TestAPIService_DeleteOrder_NoContent() {
 	ts.api.CouriersDAO = ts.couriersDAOMock
 	ts.geoRouteMock.On("DeleteCourier", ts.testCourier.ID).Return(nil)
 	ts.api.CourierRouteDAO = ts.geoRouteMock
-	ts.ordersDAOMock.On("GetOrdersForCourier", ts.testCourier.ID, 0, true, false).Return(models.Orders{}, nil)
+	ts.ordersDAOMock.On("GetOrdersForCourier", ts.testCourier.ID, mock.Anything).Return(&models.OrdersPage{Orders: models.Orders{}}, nil)
 	ts.ordersDAOMock.On("DeleteOrdersForCourier", mock.AnythingOfType("string")).Return(nil)
 	ts.api.OrdersDAO = ts.ordersDAOMock
 
@@ -213,3 +222,668 @@ func (ts *ControllerCouriersTestSuite) TestAPIService_GetCouriersByBoxField() {
 	ts.Equal(http.StatusOK, w.Code)
 	ts.Equal(testCouriers, got)
 }
+
+// testRoute has an altitude only for its second point
+func (ts *ControllerCouriersTestSuite) testRoute() []*models.PointWithTs {
+	altitude := 150.5
+	return []*models.PointWithTs{
+		{Point: elastic.GeoPointFromLatLon(55.75, 37.61), Ts: 1546300800},
+		{Point: elastic.GeoPointFromLatLon(55.76, 37.62), Ts: 1546300860, Fix: models.Fix{Altitude: &altitude}},
+	}
+}
+
+func (ts *ControllerCouriersTestSuite) TestAPIService_GetRouteForCourier_GeoJSON() {
+	ts.geoRouteMock.On("GetRoute", ts.testCourier.ID, mock.Anything).Return(&models.RoutePage{Points: ts.testRoute()}, nil)
+	ts.api.CourierRouteDAO = ts.geoRouteMock
+
+	uri := fmt.Sprintf("/couriers/%s/geo_history?format=geojson", ts.testCourier.ID)
+	req, _ := http.NewRequest("GET", uri, nil)
+	w := httptest.NewRecorder()
+	ts.router.ServeHTTP(w, req)
+
+	var got models.GeoJSONFeature
+	err := json.Unmarshal(w.Body.Bytes(), &got)
+
+	ts.NoError(err)
+	ts.Equal(http.StatusOK, w.Code)
+	ts.Equal(models.MIMEGeoJSON, w.Header().Get("Content-Type"))
+	ts.Equal("LineString", got.Geometry.Type)
+	ts.Equal([][2]float64{{37.61, 55.75}, {37.62, 55.76}}, got.Geometry.Coordinates)
+	ts.Equal([]interface{}{"2019-01-01T00:00:00Z", "2019-01-01T00:01:00Z"}, got.Properties["coordTimes"])
+}
+
+func (ts *ControllerCouriersTestSuite) TestAPIService_GetRouteForCourier_GPXFromAcceptHeader() {
+	ts.geoRouteMock.On("GetRoute", ts.testCourier.ID, mock.Anything).Return(&models.RoutePage{Points: ts.testRoute()}, nil)
+	ts.api.CourierRouteDAO = ts.geoRouteMock
+
+	uri := fmt.Sprintf("/couriers/%s/geo_history", ts.testCourier.ID)
+	req, _ := http.NewRequest("GET", uri, nil)
+	req.Header.Set("Accept", models.MIMEGPX)
+	w := httptest.NewRecorder()
+	ts.router.ServeHTTP(w, req)
+
+	ts.Equal(http.StatusOK, w.Code)
+	ts.Equal(models.MIMEGPX, w.Header().Get("Content-Type"))
+	ts.Contains(w.Body.String(), `<trkpt lat="55.75" lon="37.61">`)
+	ts.Contains(w.Body.String(), `<time>2019-01-01T00:01:00Z</time>`)
+	// only the point with a known altitude has an elevation
+	ts.Equal(1, strings.Count(w.Body.String(), "<ele>"))
+	ts.Contains(w.Body.String(), `<ele>150.5</ele>`)
+}
+
+func (ts *ControllerCouriersTestSuite) TestAPIService_GetRouteForCourier_KML() {
+	ts.geoRouteMock.On("GetRoute", ts.testCourier.ID, mock.Anything).Return(&models.RoutePage{Points: ts.testRoute()}, nil)
+	ts.api.CourierRouteDAO = ts.geoRouteMock
+
+	uri := fmt.Sprintf("/couriers/%s/geo_history?format=kml", ts.testCourier.ID)
+	req, _ := http.NewRequest("GET", uri, nil)
+	w := httptest.NewRecorder()
+	ts.router.ServeHTTP(w, req)
+
+	ts.Equal(http.StatusOK, w.Code)
+	ts.Contains(w.Body.String(), `<when>2019-01-01T00:00:00Z</when>`)
+	ts.Contains(w.Body.String(), `<gx:coord>37.61 55.75</gx:coord>`)
+	ts.Contains(w.Body.String(), `<gx:coord>37.62 55.76 150.5</gx:coord>`)
+}
+
+func (ts *ControllerCouriersTestSuite) TestAPIService_GetRouteForCourier_UnknownFormat() {
+	ts.api.CourierRouteDAO = ts.geoRouteMock
+
+	uri := fmt.Sprintf("/couriers/%s/geo_history?format=shp", ts.testCourier.ID)
+	req, _ := http.NewRequest("GET", uri, nil)
+	w := httptest.NewRecorder()
+	ts.router.ServeHTTP(w, req)
+
+	ts.Equal(http.StatusBadRequest, w.Code)
+	ts.geoRouteMock.AssertNotCalled(ts.T(), "GetRoute", ts.testCourier.ID, mock.Anything)
+}
+
+func (ts *ControllerCouriersTestSuite) TestAPIService_GetRouteStatsForCourier_OK() {
+	stats := &models.RouteStats{CourierID: ts.testCourier.ID, Since: 10, Until: 20, Distance: 1500}
+	statsMock := new(mocks.RouteStatsServiceMock)
+	query := &models.RouteQuery{Since: 10, Until: 20, MaxAccuracy: 25}
+	statsMock.On("Stats", ts.testCourier.ID, query, 2*time.Minute, float64(30)).Return(stats, nil)
+	ts.api.RouteStatsService = statsMock
+
+	uri := fmt.Sprintf("/couriers/%s/geo_history/stats?since=10&until=20&stop_minutes=2&stop_radius=30&max_accuracy=25", ts.testCourier.ID)
+	req, _ := http.NewRequest("GET", uri, nil)
+	w := httptest.NewRecorder()
+	ts.router.ServeHTTP(w, req)
+
+	var got models.RouteStats
+	err := json.Unmarshal(w.Body.Bytes(), &got)
+
+	ts.NoError(err)
+	ts.Equal(http.StatusOK, w.Code)
+	ts.Equal(stats.Distance, got.Distance)
+	statsMock.AssertExpectations(ts.T())
+}
+
+func (ts *ControllerCouriersTestSuite) TestAPIService_GetRouteStatsForCourier_UntilBeforeSince() {
+	statsMock := new(mocks.RouteStatsServiceMock)
+	ts.api.RouteStatsService = statsMock
+
+	uri := fmt.Sprintf("/couriers/%s/geo_history/stats?since=20&until=10", ts.testCourier.ID)
+	req, _ := http.NewRequest("GET", uri, nil)
+	w := httptest.NewRecorder()
+	ts.router.ServeHTTP(w, req)
+
+	ts.Equal(http.StatusBadRequest, w.Code)
+	statsMock.AssertNotCalled(ts.T(), "Stats", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (ts *ControllerCouriersTestSuite) TestAPIService_GetRouteForCourier_MaxPoints() {
+	route := append(ts.testRoute(), &models.PointWithTs{Point: elastic.GeoPointFromLatLon(55.77, 37.60), Ts: 1546300920})
+	ts.geoRouteMock.On("GetRoute", ts.testCourier.ID, mock.Anything).Return(&models.RoutePage{Points: route}, nil)
+	ts.api.CourierRouteDAO = ts.geoRouteMock
+
+	uri := fmt.Sprintf("/couriers/%s/geo_history?max_points=2", ts.testCourier.ID)
+	req, _ := http.NewRequest("GET", uri, nil)
+	w := httptest.NewRecorder()
+	ts.router.ServeHTTP(w, req)
+
+	var got models.RouteResponse
+	err := json.Unmarshal(w.Body.Bytes(), &got)
+
+	ts.NoError(err)
+	ts.Equal(http.StatusOK, w.Code)
+	if ts.Len(got.GeoHistory, 2) {
+		ts.Equal(route[0].Ts, got.GeoHistory[0].Ts)
+		ts.Equal(route[2].Ts, got.GeoHistory[1].Ts)
+	}
+}
+
+func (ts *ControllerCouriersTestSuite) TestAPIService_GetRouteForCourier_InvalidTolerance() {
+	ts.api.CourierRouteDAO = ts.geoRouteMock
+
+	uri := fmt.Sprintf("/couriers/%s/geo_history?tolerance=-1", ts.testCourier.ID)
+	req, _ := http.NewRequest("GET", uri, nil)
+	w := httptest.NewRecorder()
+	ts.router.ServeHTTP(w, req)
+
+	ts.Equal(http.StatusBadRequest, w.Code)
+	ts.geoRouteMock.AssertNotCalled(ts.T(), "GetRoute", ts.testCourier.ID, mock.Anything)
+}
+
+func (ts *ControllerCouriersTestSuite) TestAPIService_GetRouteForCourier_MaxAccuracy() {
+	query := &models.RouteQuery{MaxAccuracy: 15.5}
+	ts.geoRouteMock.On("GetRoute", ts.testCourier.ID, query).Return(&models.RoutePage{Points: ts.testRoute()}, nil)
+	ts.api.CourierRouteDAO = ts.geoRouteMock
+
+	uri := fmt.Sprintf("/couriers/%s/geo_history?max_accuracy=15.5", ts.testCourier.ID)
+	req, _ := http.NewRequest("GET", uri, nil)
+	w := httptest.NewRecorder()
+	ts.router.ServeHTTP(w, req)
+
+	ts.Equal(http.StatusOK, w.Code)
+	ts.geoRouteMock.AssertExpectations(ts.T())
+}
+
+func (ts *ControllerCouriersTestSuite) TestAPIService_GetRouteForCourier_Page() {
+	query := &models.RouteQuery{Since: 10, Until: 20, Limit: 2, After: &models.RouteCursor{Ts: 12, Seq: 7}}
+	page := &models.RoutePage{Points: ts.testRoute(), Next: &models.RouteCursor{Ts: 1546300860, Seq: 9}}
+	ts.geoRouteMock.On("GetRoute", ts.testCourier.ID, query).Return(page, nil)
+	ts.api.CourierRouteDAO = ts.geoRouteMock
+
+	uri := fmt.Sprintf("/couriers/%s/geo_history?since=10&until=20&limit=2&cursor=12-7", ts.testCourier.ID)
+	req, _ := http.NewRequest("GET", uri, nil)
+	w := httptest.NewRecorder()
+	ts.router.ServeHTTP(w, req)
+
+	var got models.RouteResponse
+	err := json.Unmarshal(w.Body.Bytes(), &got)
+
+	ts.NoError(err)
+	ts.Equal(http.StatusOK, w.Code)
+	ts.Len(got.GeoHistory, 2)
+	ts.Equal("1546300860-9", got.NextCursor)
+	ts.Equal("1546300860-9", w.Header().Get(NextCursorHeader))
+}
+
+func (ts *ControllerCouriersTestSuite) TestAPIService_GetRouteForCourier_InvalidCursor() {
+	ts.api.CourierRouteDAO = ts.geoRouteMock
+
+	uri := fmt.Sprintf("/couriers/%s/geo_history?cursor=abc", ts.testCourier.ID)
+	req, _ := http.NewRequest("GET", uri, nil)
+	w := httptest.NewRecorder()
+	ts.router.ServeHTTP(w, req)
+
+	ts.Equal(http.StatusBadRequest, w.Code)
+	ts.geoRouteMock.AssertNotCalled(ts.T(), "GetRoute", ts.testCourier.ID, mock.Anything)
+}
+
+func (ts *ControllerCouriersTestSuite) TestAPIService_AddCourierLocations_OK() {
+	policy := services.NewLocationBatchPolicy(time.Minute, 10)
+	policy.Now = func() time.Time { return time.Unix(1000, 0) }
+	lastSeen := int64(250)
+	courier := &models.Courier{ID: ts.testCourier.ID, Name: ts.testCourier.Name, LastSeen: &lastSeen}
+	moved := &models.Courier{ID: ts.testCourier.ID, Name: ts.testCourier.Name, OrdersCount: 1}
+	ts.couriersDAOMock.On("GetByID", ts.testCourier.ID).Return(courier, nil)
+	ts.couriersDAOMock.On("Update", mock.MatchedBy(func(update *models.CourierUpdate) bool {
+		return *update.LastSeen == 300 && update.Location.Point.Lat == 4
+	})).Return(moved, nil)
+	ts.geoRouteMock.On("AddPointToRoute", ts.testCourier.ID, mock.Anything).Return(nil)
+	ts.api.CouriersDAO = ts.couriersDAOMock
+	ts.api.CourierRouteDAO = ts.geoRouteMock
+	ts.api.LocationBatchChecker = policy
+	tracker := services.NewMemoryOrdersCountTracker()
+	ts.NoError(tracker.Inc(ts.testCourier.ID))
+	ts.api.OrdersCountTracker = tracker
+
+	speed := 5.0
+	batch := &models.LocationBatch{Points: []*models.LocationSample{
+		{Point: elastic.GeoPointFromLatLon(1, 1), Ts: 200, Fix: models.Fix{Speed: &speed}},
+		{Point: elastic.GeoPointFromLatLon(2, 2), Ts: 150},
+		{Point: elastic.GeoPointFromLatLon(3, 3), Ts: 2000},
+		{Point: elastic.GeoPointFromLatLon(4, 4), Ts: 300},
+	}}
+	uri := fmt.Sprintf("/couriers/%s/locations", ts.testCourier.ID)
+	req, _ := http.NewRequest("POST", uri, toByteReader(batch))
+	w := httptest.NewRecorder()
+	ts.router.ServeHTTP(w, req)
+
+	var got models.LocationBatchResult
+	err := json.Unmarshal(w.Body.Bytes(), &got)
+
+	ts.NoError(err)
+	ts.Equal(http.StatusOK, w.Code)
+	ts.Equal(2, got.Accepted)
+	ts.Equal([]*models.LocationSampleIssue{
+		{Index: 1, Reason: models.SampleOutOfOrder},
+		{Index: 2, Reason: models.SampleFutureTimestamp},
+	}, got.Rejected)
+	ts.Equal([]*models.LocationSampleIssue{{Index: 0, Reason: models.SampleOlderThanCurrent}}, got.Flagged)
+	ts.geoRouteMock.AssertNumberOfCalls(ts.T(), "AddPointToRoute", 2)
+	ts.geoRouteMock.AssertCalled(ts.T(), "AddPointToRoute", ts.testCourier.ID, &models.PointWithTs{
+		Point: elastic.GeoPointFromLatLon(1, 1), Ts: 200, Fix: models.Fix{Speed: &speed},
+	})
+}
+
+func (ts *ControllerCouriersTestSuite) TestAPIService_AddCourierLocations_EmptyBatch() {
+	ts.api.CouriersDAO = ts.couriersDAOMock
+	ts.api.LocationBatchChecker = services.NewLocationBatchPolicy(time.Minute, 10)
+
+	uri := fmt.Sprintf("/couriers/%s/locations", ts.testCourier.ID)
+	req, _ := http.NewRequest("POST", uri, toByteReader(&models.LocationBatch{}))
+	w := httptest.NewRecorder()
+	ts.router.ServeHTTP(w, req)
+
+	ts.Equal(http.StatusBadRequest, w.Code)
+	ts.couriersDAOMock.AssertNotCalled(ts.T(), "GetByID", mock.Anything)
+}
+
+func (ts *ControllerCouriersTestSuite) newLocationFilter() *services.MemoryRejectedLocationsLog {
+	log := services.NewMemoryRejectedLocationsLog()
+	ts.api.RejectedLocations = log
+	ts.api.LocationFilter = services.NewLocationFilter(log, zap.NewNop(), services.LocationFilterReject, 30, 5, 10*time.Second)
+	return log
+}
+
+func (ts *ControllerCouriersTestSuite) TestAPIService_UpdateCourier_RejectsOutlier() {
+	lastSeen := time.Now().Unix()
+	current := &models.Courier{
+		ID:       ts.testCourier.ID,
+		Location: &models.Location{Point: elastic.GeoPointFromLatLon(20, 20)},
+		LastSeen: &lastSeen,
+	}
+	ts.couriersDAOMock.On("GetByID", ts.testCourier.ID).Return(current, nil)
+	ts.api.CouriersDAO = ts.couriersDAOMock
+	ts.api.CourierRouteDAO = ts.geoRouteMock
+	log := ts.newLocationFilter()
+
+	w := httptest.NewRecorder()
+	uri := fmt.Sprintf("/couriers/%s", ts.testCourier.ID)
+	req, _ := http.NewRequest("PUT", uri, toByteReader(ts.testCourierUpdate))
+	ts.router.ServeHTTP(w, req)
+
+	var got models.RejectedLocation
+	err := json.Unmarshal(w.Body.Bytes(), &got)
+
+	ts.NoError(err)
+	ts.Equal(http.StatusUnprocessableEntity, w.Code)
+	ts.Equal(models.OutlierImpossibleSpeed, got.Reason)
+	ts.couriersDAOMock.AssertNotCalled(ts.T(), "Update", mock.Anything)
+	ts.geoRouteMock.AssertNotCalled(ts.T(), "AddPointToRoute", mock.Anything, mock.Anything)
+	recorded, err := log.Find(&models.RejectedLocationQuery{CourierID: ts.testCourier.ID})
+	ts.NoError(err)
+	ts.Len(recorded, 1)
+}
+
+func (ts *ControllerCouriersTestSuite) TestAPIService_AddCourierLocations_FiltersOutliers() {
+	policy := services.NewLocationBatchPolicy(time.Minute, 10)
+	policy.Now = func() time.Time { return time.Unix(1000, 0) }
+	lastSeen := int64(100)
+	courier := &models.Courier{
+		ID:       ts.testCourier.ID,
+		Location: &models.Location{Point: elastic.GeoPointFromLatLon(0, 0)},
+		LastSeen: &lastSeen,
+	}
+	ts.couriersDAOMock.On("GetByID", ts.testCourier.ID).Return(courier, nil)
+	ts.couriersDAOMock.On("Update", mock.MatchedBy(func(update *models.CourierUpdate) bool {
+		return *update.LastSeen == 140 && update.Location.Point.Lat == 0.002
+	})).Return(courier, nil)
+	ts.api.CouriersDAO = ts.couriersDAOMock
+	ts.api.CourierRouteDAO = ts.geoRouteMock
+	ts.api.LocationBatchChecker = policy
+	ts.newLocationFilter()
+
+	batch := &models.LocationBatch{Points: []*models.LocationSample{
+		{Point: elastic.GeoPointFromLatLon(0.001, 0), Ts: 120},
+		{Point: elastic.GeoPointFromLatLon(1, 0), Ts: 125},
+		{Point: elastic.GeoPointFromLatLon(0.001, 0), Ts: 128},
+		{Point: elastic.GeoPointFromLatLon(0.002, 0), Ts: 140},
+	}}
+	uri := fmt.Sprintf("/couriers/%s/locations", ts.testCourier.ID)
+	req, _ := http.NewRequest("POST", uri, toByteReader(batch))
+	w := httptest.NewRecorder()
+	ts.router.ServeHTTP(w, req)
+
+	var got models.LocationBatchResult
+	err := json.Unmarshal(w.Body.Bytes(), &got)
+
+	ts.NoError(err)
+	ts.Equal(http.StatusOK, w.Code)
+	ts.Equal(2, got.Accepted)
+	ts.Equal([]*models.LocationSampleIssue{
+		{Index: 1, Reason: models.OutlierImpossibleSpeed},
+		{Index: 2, Reason: models.OutlierNearDuplicate},
+	}, got.Rejected)
+	ts.couriersDAOMock.AssertExpectations(ts.T())
+}
+
+func (ts *ControllerCouriersTestSuite) TestAPIService_GetRejectedLocations() {
+	log := ts.newLocationFilter()
+	for _, reason := range []string{models.OutlierDuplicate, models.OutlierImpossibleSpeed} {
+		ts.NoError(log.Record(&models.RejectedLocation{CourierID: ts.testCourier.ID, Reason: reason, RejectedAt: 10}))
+	}
+
+	uri := fmt.Sprintf("/couriers/%s/rejected_locations?reason=impossible_speed", ts.testCourier.ID)
+	req, _ := http.NewRequest("GET", uri, nil)
+	w := httptest.NewRecorder()
+	ts.router.ServeHTTP(w, req)
+
+	var got models.RejectedLocations
+	err := json.Unmarshal(w.Body.Bytes(), &got)
+
+	ts.NoError(err)
+	ts.Equal(http.StatusOK, w.Code)
+	if ts.Len(got, 1) {
+		ts.Equal(models.OutlierImpossibleSpeed, got[0].Reason)
+	}
+}
+
+func (ts *ControllerCouriersTestSuite) TestAPIService_GetRejectedLocations_UnknownReason() {
+	uri := fmt.Sprintf("/couriers/%s/rejected_locations?reason=teleport", ts.testCourier.ID)
+	req, _ := http.NewRequest("GET", uri, nil)
+	w := httptest.NewRecorder()
+	ts.router.ServeHTTP(w, req)
+
+	ts.Equal(http.StatusBadRequest, w.Code)
+}
+
+// readStreamEvent returns the data of the next Server-Sent Event.
+func (ts *ControllerCouriersTestSuite) readStreamEvent(reader *bufio.Reader) *models.CourierEvent {
+	for {
+		line, err := reader.ReadString('\n')
+		ts.Require().NoError(err)
+		if strings.HasPrefix(line, "data:") {
+			var event models.CourierEvent
+			ts.Require().NoError(json.Unmarshal([]byte(strings.TrimPrefix(line, "data:")), &event))
+			return &event
+		}
+	}
+}
+
+func (ts *ControllerCouriersTestSuite) TestAPIService_StreamCouriers_SSE() {
+	hub := services.NewCourierStreamHub(8, 10)
+	ts.api.CourierStream = hub
+	defer func() { ts.api.CourierStream = nil }()
+	inside := &models.Courier{ID: ts.testCourier.ID, Location: &models.Location{Point: elastic.GeoPointFromLatLon(5, 5)}}
+	ts.couriersDAOMock.On("GetByBoxField", mock.Anything, 0, false).Return(models.Couriers{inside}, nil)
+	ts.couriersDAOMock.On("GetByCircleField", mock.Anything, 0, false).Return(models.Couriers{}, nil)
+	ts.api.CouriersDAO = ts.couriersDAOMock
+	server := httptest.NewServer(ts.router)
+	defer server.Close()
+
+	stream, err := http.Get(server.URL + "/stream/couriers?top_left_lat=10&top_left_lon=0&bottom_right_lat=0&bottom_right_lon=10")
+	ts.Require().NoError(err)
+	defer stream.Body.Close()
+	ts.Equal("text/event-stream", stream.Header.Get("Content-Type"))
+	reader := bufio.NewReader(stream.Body)
+
+	snapshot := ts.readStreamEvent(reader)
+	ts.Equal(models.CourierEventSnapshot, snapshot.Type)
+	ts.Len(snapshot.Couriers, 1)
+
+	hub.PublishUpdate(&models.Courier{ID: ts.testCourier.ID, Location: &models.Location{Point: elastic.GeoPointFromLatLon(6, 6)}})
+	update := ts.readStreamEvent(reader)
+	ts.Equal(models.CourierEventUpdate, update.Type)
+	ts.Equal(6.0, update.Courier.Location.Point.Lat)
+
+	req, _ := http.NewRequest("PUT", server.URL+"/stream/couriers/"+snapshot.SubscriptionID+"?lat=50&lon=50&radius=100", nil)
+	moved, err := http.DefaultClient.Do(req)
+	ts.Require().NoError(err)
+	moved.Body.Close()
+	ts.Equal(http.StatusNoContent, moved.StatusCode)
+	moveSnapshot := ts.readStreamEvent(reader)
+	ts.Equal(models.CourierEventSnapshot, moveSnapshot.Type)
+	ts.Empty(moveSnapshot.Couriers)
+}
+
+func (ts *ControllerCouriersTestSuite) TestAPIService_StreamCouriers_WebSocket() {
+	ts.api.CourierStream = services.NewCourierStreamHub(8, 10)
+	ts.api.StreamUpgrader = NewStreamUpgrader([]string{"http://allowed.test"})
+	defer func() {
+		ts.api.CourierStream = nil
+		ts.api.StreamUpgrader = nil
+	}()
+	inside := &models.Courier{ID: ts.testCourier.ID, Location: &models.Location{Point: elastic.GeoPointFromLatLon(5, 5)}}
+	ts.couriersDAOMock.On("GetByBoxField", mock.Anything, 0, false).Return(models.Couriers{inside}, nil)
+	ts.api.CouriersDAO = ts.couriersDAOMock
+	server := httptest.NewServer(ts.router)
+	defer server.Close()
+	stream := "ws" + strings.TrimPrefix(server.URL, "http") +
+		"/stream/couriers?top_left_lat=10&top_left_lon=0&bottom_right_lat=0&bottom_right_lon=10"
+
+	_, resp, err := websocket.DefaultDialer.Dial(stream, http.Header{"Origin": {"http://evil.test"}})
+	ts.Require().Error(err)
+	ts.Equal(http.StatusForbidden, resp.StatusCode)
+
+	conn, _, err := websocket.DefaultDialer.Dial(stream, http.Header{"Origin": {"http://allowed.test"}})
+	ts.Require().NoError(err)
+	defer conn.Close()
+	var snapshot models.CourierEvent
+	ts.Require().NoError(conn.ReadJSON(&snapshot))
+	ts.Equal(models.CourierEventSnapshot, snapshot.Type)
+	ts.Len(snapshot.Couriers, 1)
+
+	ts.Require().NoError(conn.WriteMessage(websocket.TextMessage, []byte("{")))
+	var reply models.CourierEvent
+	ts.Require().NoError(conn.ReadJSON(&reply))
+	ts.Equal(models.CourierEventError, reply.Type)
+}
+
+func (ts *ControllerCouriersTestSuite) TestAPIService_UpdateCourier_PublishesToStream() {
+	hub := services.NewCourierStreamHub(8, 10)
+	ts.api.CourierStream = hub
+	defer func() { ts.api.CourierStream = nil }()
+	viewport := &models.Viewport{Circle: &models.CircleField{
+		Center: ts.testCourierUpdate.Location.Point,
+		Radius: 1000,
+	}}
+	subscription, err := hub.Subscribe(viewport)
+	ts.Require().NoError(err)
+	subscription.SetSnapshot(viewport, nil)
+	<-subscription.Events()
+
+	updated := &models.Courier{ID: ts.testCourier.ID, Location: ts.testCourierUpdate.Location}
+	ts.couriersDAOMock.On("Update", mock.Anything).Return(updated, nil)
+	ts.api.CouriersDAO = ts.couriersDAOMock
+	ts.api.CourierRouteDAO = ts.geoRouteMock
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", fmt.Sprintf("/couriers/%s", ts.testCourier.ID), toByteReader(ts.testCourierUpdate))
+	ts.router.ServeHTTP(w, req)
+
+	ts.Equal(http.StatusOK, w.Code)
+	select {
+	case event := <-subscription.Events():
+		ts.Equal(models.CourierEventUpdate, event.Type)
+		ts.Equal(ts.testCourier.ID, event.Courier.ID)
+	default:
+		ts.Fail("no stream event")
+	}
+}
+
+func (ts *ControllerCouriersTestSuite) newGeofences() *services.GeofenceManager {
+	manager := services.NewGeofenceManager(services.NewMemoryGeofenceStore(), services.NewMemoryGeofenceEventLog(),
+		zap.NewNop(), time.Minute)
+	ts.api.Geofences = manager
+	return manager
+}
+
+func (ts *ControllerCouriersTestSuite) TestAPIService_CreateGeofence() {
+	ts.newGeofences()
+	create := &models.GeofenceCreate{Name: "warehouse", Center: elastic.GeoPointFromLatLon(10, 10), Radius: 100}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/geofences", toByteReader(create))
+	ts.router.ServeHTTP(w, req)
+
+	var created models.Geofence
+	ts.NoError(json.Unmarshal(w.Body.Bytes(), &created))
+	ts.Equal(http.StatusCreated, w.Code)
+	ts.NotEmpty(created.ID)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/geofences/"+created.ID, nil)
+	ts.router.ServeHTTP(w, req)
+
+	var got models.Geofence
+	ts.NoError(json.Unmarshal(w.Body.Bytes(), &got))
+	ts.Equal(http.StatusOK, w.Code)
+	ts.Equal(created, got)
+}
+
+func (ts *ControllerCouriersTestSuite) TestAPIService_CreateGeofence_InvalidShape() {
+	ts.newGeofences()
+	create := &models.GeofenceCreate{Name: "warehouse", Center: elastic.GeoPointFromLatLon(10, 10)}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/geofences", toByteReader(create))
+	ts.router.ServeHTTP(w, req)
+
+	ts.Equal(http.StatusBadRequest, w.Code)
+}
+
+func (ts *ControllerCouriersTestSuite) TestAPIService_DeleteGeofence_NotFound() {
+	ts.newGeofences()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "/geofences/"+ts.testCourier.ID, nil)
+	ts.router.ServeHTTP(w, req)
+
+	ts.Equal(http.StatusNotFound, w.Code)
+}
+
+func (ts *ControllerCouriersTestSuite) TestAPIService_UpdateCourier_EmitsGeofenceEvents() {
+	manager := ts.newGeofences()
+	geofence, err := manager.Create(&models.GeofenceCreate{Name: "warehouse", Center: elastic.GeoPointFromLatLon(10, 10), Radius: 100})
+	ts.Require().NoError(err)
+	updated := &models.Courier{ID: ts.testCourier.ID, Location: ts.testCourierUpdate.Location}
+	ts.couriersDAOMock.On("Update", mock.Anything).Return(updated, nil)
+	ts.api.CouriersDAO = ts.couriersDAOMock
+	ts.api.CourierRouteDAO = ts.geoRouteMock
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "/couriers/"+ts.testCourier.ID, toByteReader(ts.testCourierUpdate))
+	ts.router.ServeHTTP(w, req)
+	ts.Equal(http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/geofence_events?type=enter&courier_id="+ts.testCourier.ID, nil)
+	ts.router.ServeHTTP(w, req)
+
+	var events models.GeofenceEvents
+	ts.NoError(json.Unmarshal(w.Body.Bytes(), &events))
+	ts.Equal(http.StatusOK, w.Code)
+	if ts.Len(events, 1) {
+		ts.Equal(geofence.ID, events[0].GeofenceID)
+		ts.Equal("warehouse", events[0].GeofenceName)
+	}
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", fmt.Sprintf("/couriers/%s/geofences", ts.testCourier.ID), nil)
+	ts.router.ServeHTTP(w, req)
+
+	var presence models.GeofencePresences
+	ts.NoError(json.Unmarshal(w.Body.Bytes(), &presence))
+	if ts.Len(presence, 1) {
+		ts.Equal(geofence.ID, presence[0].GeofenceID)
+	}
+}
+
+func (ts *ControllerCouriersTestSuite) TestAPIService_GetGeofenceEvents_UnknownType() {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/geofence_events?type=teleport", nil)
+	ts.router.ServeHTTP(w, req)
+
+	ts.Equal(http.StatusBadRequest, w.Code)
+}
+
+func (ts *ControllerCouriersTestSuite) TestAPIService_UpdateCourier_DispatchesActivityChange() {
+	received, stop := newWebhookReceiver(ts.T(), ts.api)
+	defer stop()
+	active := &models.Courier{ID: ts.testCourier.ID, IsActive: true}
+	inactive := &models.Courier{ID: ts.testCourier.ID}
+	// the second update finds the courier already inactive
+	ts.couriersDAOMock.On("GetByID", ts.testCourier.ID).Return(active, nil).Once()
+	ts.couriersDAOMock.On("GetByID", ts.testCourier.ID).Return(inactive, nil).Once()
+	ts.couriersDAOMock.On("Update", mock.Anything).Return(inactive, nil)
+	ts.api.CouriersDAO = ts.couriersDAOMock
+
+	isActive := false
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", "/couriers/"+ts.testCourier.ID, toByteReader(&models.CourierUpdate{IsActive: &isActive}))
+		ts.router.ServeHTTP(w, req)
+		ts.Equal(http.StatusOK, w.Code)
+	}
+
+	events := received()
+	if ts.Len(events, 1) {
+		ts.Equal(models.WebhookCourierDeactivated, events[0].Type)
+	}
+}
+
+func (ts *ControllerCouriersTestSuite) TestAPIService_UpdateCourier_RevivesStaleCourier() {
+	received, stop := newWebhookReceiver(ts.T(), ts.api)
+	defer stop()
+	point := elastic.GeoPointFromLatLon(55.75, 37.61)
+	stale := &models.Courier{ID: ts.testCourier.ID, Location: &models.Location{Point: point}, Stale: true}
+	revived := &models.Courier{ID: ts.testCourier.ID, Location: &models.Location{Point: point}, IsActive: true}
+	ts.couriersDAOMock.On("Update", mock.MatchedBy(func(update *models.CourierUpdate) bool {
+		return update.Location != nil && update.Stale == nil
+	})).Return(stale, nil).Once()
+	ts.couriersDAOMock.On("Update", mock.MatchedBy(func(update *models.CourierUpdate) bool {
+		return update.Location == nil && *update.IsActive && !*update.Stale
+	})).Return(revived, nil).Once()
+	ts.api.CouriersDAO = ts.couriersDAOMock
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "/couriers/"+ts.testCourier.ID,
+		toByteReader(&models.CourierUpdate{Location: &models.Location{Point: point}}))
+	ts.router.ServeHTTP(w, req)
+	ts.Equal(http.StatusOK, w.Code)
+
+	var got models.Courier
+	ts.NoError(json.Unmarshal(w.Body.Bytes(), &got))
+	ts.True(got.IsActive)
+	ts.False(got.Stale)
+	ts.couriersDAOMock.AssertExpectations(ts.T())
+	events := received()
+	if ts.Len(events, 1) {
+		ts.Equal(models.WebhookCourierActivated, events[0].Type)
+	}
+}
+
+func (ts *ControllerCouriersTestSuite) TestAPIService_UpdateCourier_RecordsArrival() {
+	received, stop := newWebhookReceiver(ts.T(), ts.api)
+	defer stop()
+	ts.api.Arrivals = services.NewRadiusArrivalDetector(50)
+	destination := elastic.GeoPointFromLatLon(55.71, 37.60)
+	courier := &models.Courier{ID: ts.testCourier.ID, IsActive: true, Location: &models.Location{Point: destination}}
+	order := &models.Order{ID: "660e8400-e29b-41d4-a716-446655440000", CourierID: courier.ID, Destination: models.Location{Point: destination}}
+	arrived := *order
+	ts.couriersDAOMock.On("Update", mock.Anything).Return(courier, nil)
+	ts.geoRouteMock.On("AddPointToRoute", courier.ID, mock.Anything).Return(nil)
+	ts.ordersDAOMock.On("GetOrdersForCourier", courier.ID, &models.OrdersQuery{SinceIsLower: true, ExcludeDelivered: true,
+		Statuses: models.ActiveOrderStatuses()}).
+		Return(&models.OrdersPage{Orders: models.Orders{order}, Total: 1}, nil)
+	ts.ordersDAOMock.On("Update", mock.MatchedBy(func(update *models.OrderUpdate) bool {
+		arrived.ArrivedAtDestination = *update.ArrivedAtDestination
+		return update.ArrivedAtPickup == nil && update.PickedUp == nil
+	})).Return(&arrived, nil).Once()
+	ts.api.CouriersDAO = ts.couriersDAOMock
+	ts.api.CourierRouteDAO = ts.geoRouteMock
+	ts.api.OrdersDAO = ts.ordersDAOMock
+	tracker := services.NewMemoryOrdersCountTracker()
+	ts.NoError(tracker.Inc(courier.ID))
+	ts.api.OrdersCountTracker = tracker
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "/couriers/"+courier.ID,
+		toByteReader(&models.CourierUpdate{Location: &models.Location{Point: destination}}))
+	ts.router.ServeHTTP(w, req)
+	ts.Equal(http.StatusOK, w.Code)
+
+	ts.ordersDAOMock.AssertExpectations(ts.T())
+	ts.NotZero(arrived.ArrivedAtDestination)
+	events := received()
+	if ts.Len(events, 1) {
+		ts.Equal(models.WebhookOrderArrivedAtDestination, events[0].Type)
+		var got models.Order
+		ts.NoError(json.Unmarshal(events[0].Data, &got))
+		ts.Equal(arrived.ArrivedAtDestination, got.ArrivedAtDestination)
+	}
+}
@@ -0,0 +1,72 @@
+package controllers
+
+import (
+	"github.com/TeamD2018/geo-rest/controllers/parameters"
+	"github.com/TeamD2018/geo-rest/models"
+	"github.com/gin-gonic/gin"
+	"github.com/olivere/elastic"
+	"github.com/satori/go.uuid"
+	"go.uber.org/zap"
+	"net/http"
+)
+
+// DispatchOrder ranks couriers for an unassigned order and assigns it to the best one.
+// With dry_run=true only the ranking is returned and any order may be ranked.
+func (api *APIService) DispatchOrder(ctx *gin.Context) {
+	orderID := ctx.Param("order_id")
+	if _, err := uuid.FromString(orderID); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, models.ErrOneOfParameterHaveIncorrectFormat.SetParameter("order_id"))
+		return
+	}
+	var params parameters.Dispatch
+	if err := ctx.ShouldBindQuery(&params); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, models.ErrOneOfParameterHaveIncorrectFormat.SetParameter("dry_run"))
+		return
+	}
+	order, err := api.OrdersDAO.Get(orderID)
+	if err == nil {
+		if !params.DryRun && order.CurrentStatus() != models.OrderUnassigned {
+			ctx.AbortWithStatusJSON(http.StatusConflict, models.ErrOrderAlreadyClaimed.SetParameter(orderID))
+			return
+		}
+		var ranking *models.DispatchRanking
+		ranking, err = api.dispatchOrder(order, params.DryRun)
+		if err == nil {
+			ctx.JSON(http.StatusOK, ranking)
+			return
+		}
+	}
+	api.Logger.Error("fail to dispatch order", zap.String("order_id", orderID), zap.Error(err))
+	switch err.(type) {
+	case *elastic.Error:
+		err := err.(*elastic.Error)
+		if err.Status == 404 {
+			ctx.AbortWithStatusJSON(http.StatusNotFound, models.ErrEntityNotFound)
+			return
+		}
+	case *models.Error:
+		err := err.(*models.Error)
+		ctx.AbortWithStatusJSON(err.HttpStatus(), err)
+		return
+	}
+	ctx.AbortWithStatusJSON(http.StatusInternalServerError, models.ErrServerError)
+}
+
+// dispatchOrder ranks the couriers for the order and, unless it is a dry run, claims the order
+// for the best candidate. The order stays unassigned when nobody fits.
+func (api *APIService) dispatchOrder(order *models.Order, dryRun bool) (*models.DispatchRanking, error) {
+	ranking, err := api.Dispatcher.Rank(order)
+	if err != nil {
+		return nil, err
+	}
+	ranking.DryRun = dryRun
+	best := ranking.Best()
+	if dryRun || best == nil {
+		return ranking, nil
+	}
+	ranking.Assigned, err = api.claimOrder(order.ID, best.Courier.ID)
+	if err != nil {
+		return nil, err
+	}
+	return ranking, nil
+}
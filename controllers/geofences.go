@@ -0,0 +1,205 @@
+package controllers
+
+import (
+	"github.com/TeamD2018/geo-rest/controllers/parameters"
+	"github.com/TeamD2018/geo-rest/models"
+	"github.com/gin-gonic/gin"
+	"github.com/olivere/elastic"
+	"github.com/satori/go.uuid"
+	"go.uber.org/zap"
+	"net/http"
+)
+
+func (api *APIService) CreateGeofence(ctx *gin.Context) {
+	if api.Geofences == nil {
+		ctx.AbortWithStatusJSON(http.StatusNotFound, models.ErrEntityNotFound.SetParameter("geofences"))
+		return
+	}
+	var create models.GeofenceCreate
+	if err := ctx.ShouldBindJSON(&create); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, models.ErrOneOfParameterHaveIncorrectFormat)
+		return
+	}
+	geofence, err := api.Geofences.Create(&create)
+	if err != nil {
+		api.abortWithGeofenceError(ctx, err, "fail to create geofence", "")
+		return
+	}
+	ctx.JSON(http.StatusCreated, geofence)
+}
+
+func (api *APIService) GetGeofence(ctx *gin.Context) {
+	geofenceID, ok := api.geofenceID(ctx)
+	if !ok {
+		return
+	}
+	geofence, err := api.Geofences.Get(geofenceID)
+	if err != nil {
+		api.abortWithGeofenceError(ctx, err, "fail to get geofence", geofenceID)
+		return
+	}
+	ctx.JSON(http.StatusOK, geofence)
+}
+
+func (api *APIService) GetGeofences(ctx *gin.Context) {
+	if api.Geofences == nil {
+		ctx.JSON(http.StatusOK, models.Geofences{})
+		return
+	}
+	geofences, err := api.Geofences.List()
+	if err != nil {
+		api.Logger.Error("fail to list geofences", zap.Error(err))
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, models.ErrServerError)
+		return
+	}
+	ctx.JSON(http.StatusOK, geofences)
+}
+
+func (api *APIService) UpdateGeofence(ctx *gin.Context) {
+	geofenceID, ok := api.geofenceID(ctx)
+	if !ok {
+		return
+	}
+	var update models.GeofenceUpdate
+	if err := ctx.ShouldBindJSON(&update); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, models.ErrOneOfParameterHaveIncorrectFormat)
+		return
+	}
+	geofence, err := api.Geofences.Update(geofenceID, &update)
+	if err != nil {
+		api.abortWithGeofenceError(ctx, err, "fail to update geofence", geofenceID)
+		return
+	}
+	ctx.JSON(http.StatusOK, geofence)
+}
+
+func (api *APIService) DeleteGeofence(ctx *gin.Context) {
+	geofenceID, ok := api.geofenceID(ctx)
+	if !ok {
+		return
+	}
+	if err := api.Geofences.Delete(geofenceID); err != nil {
+		api.abortWithGeofenceError(ctx, err, "fail to delete geofence", geofenceID)
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
+
+// GetGeofenceEvents returns enter, exit and dwell events, latest first.
+func (api *APIService) GetGeofenceEvents(ctx *gin.Context) {
+	params := parameters.GeofenceEvents{}
+	if err := ctx.BindQuery(&params); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, models.ErrOneOfParameterHaveIncorrectFormat)
+		return
+	}
+	if params.CourierID != "" {
+		if _, err := uuid.FromString(params.CourierID); err != nil {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, models.ErrOneOfParameterHaveIncorrectFormat.SetParameter("courier_id"))
+			return
+		}
+	}
+	if params.GeofenceID != "" {
+		if _, err := uuid.FromString(params.GeofenceID); err != nil {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, models.ErrOneOfParameterHaveIncorrectFormat.SetParameter("geofence_id"))
+			return
+		}
+	}
+	switch params.Type {
+	case "", models.GeofenceEnter, models.GeofenceExit, models.GeofenceDwell:
+	default:
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, models.ErrOneOfParameterHaveIncorrectFormat.SetParameter("type"))
+		return
+	}
+	if params.Since < 0 {
+		params.Since = 0
+	}
+	if params.Until < 0 || (params.Until > 0 && params.Until < params.Since) {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, models.ErrOneOfParameterHaveIncorrectFormat.SetParameter("until"))
+		return
+	}
+	if params.Limit < 0 || params.Limit > parameters.MaxGeofenceEventsLimit {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, models.ErrOneOfParameterHaveIncorrectFormat.SetParameter("limit"))
+		return
+	}
+	if api.Geofences == nil {
+		ctx.JSON(http.StatusOK, models.GeofenceEvents{})
+		return
+	}
+	query := &models.GeofenceEventQuery{
+		CourierID:  params.CourierID,
+		GeofenceID: params.GeofenceID,
+		Type:       params.Type,
+		Since:      params.Since,
+		Until:      params.Until,
+		Limit:      params.Limit,
+	}
+	events, err := api.Geofences.Events(query)
+	if err != nil {
+		api.Logger.Error("fail to find geofence events", zap.Error(err), zap.Any("query", query))
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, models.ErrServerError)
+		return
+	}
+	ctx.JSON(http.StatusOK, events)
+}
+
+// GetCourierGeofences returns the geofences the courier is currently inside of.
+func (api *APIService) GetCourierGeofences(ctx *gin.Context) {
+	courierID := ctx.Param("courier_id")
+	if _, err := uuid.FromString(courierID); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, models.ErrOneOfParameterHaveIncorrectFormat.SetParameter("courier_id"))
+		return
+	}
+	if api.Geofences == nil {
+		ctx.JSON(http.StatusOK, models.GeofencePresences{})
+		return
+	}
+	presence, err := api.Geofences.Presence(courierID)
+	if err != nil {
+		api.Logger.Error("fail to get geofence presence", zap.Error(err), zap.String("courier_id", courierID))
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, models.ErrServerError)
+		return
+	}
+	ctx.JSON(http.StatusOK, presence)
+}
+
+// evaluateGeofences records geofence events for a new courier location. Failures are only logged:
+// the location itself is already stored.
+func (api *APIService) evaluateGeofences(courierID string, point *elastic.GeoPoint, ts int64) {
+	if api.Geofences == nil || point == nil {
+		return
+	}
+	if _, err := api.Geofences.Evaluate(courierID, point, ts); err != nil {
+		api.Logger.Error("fail to evaluate geofences", zap.Error(err), zap.String("courier_id", courierID))
+	}
+}
+
+func (api *APIService) forgetGeofences(courierID string) {
+	if api.Geofences == nil {
+		return
+	}
+	if err := api.Geofences.Forget(courierID); err != nil {
+		api.Logger.Error("fail to forget geofence presence", zap.Error(err), zap.String("courier_id", courierID))
+	}
+}
+
+func (api *APIService) geofenceID(ctx *gin.Context) (string, bool) {
+	geofenceID := ctx.Param("geofence_id")
+	if _, err := uuid.FromString(geofenceID); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, models.ErrOneOfParameterHaveIncorrectFormat.SetParameter("geofence_id"))
+		return "", false
+	}
+	if api.Geofences == nil {
+		ctx.AbortWithStatusJSON(http.StatusNotFound, models.ErrEntityNotFound.SetParameter(geofenceID))
+		return "", false
+	}
+	return geofenceID, true
+}
+
+func (api *APIService) abortWithGeofenceError(ctx *gin.Context, err error, msg string, geofenceID string) {
+	if err, ok := err.(*models.Error); ok {
+		ctx.AbortWithStatusJSON(err.HttpStatus(), err)
+		return
+	}
+	api.Logger.Error(msg, zap.Error(err), zap.String("geofence_id", geofenceID))
+	ctx.AbortWithStatusJSON(http.StatusInternalServerError, models.ErrServerError)
+}
@@ -0,0 +1,28 @@
+package mocks
+
+import (
+	"github.com/TeamD2018/geo-rest/models"
+	"github.com/stretchr/testify/mock"
+)
+
+type ChangeLogMock struct {
+	mock.Mock
+}
+
+func (m *ChangeLogMock) Append(change *models.Change) error {
+	args := m.Called(change)
+	return args.Error(0)
+}
+
+func (m *ChangeLogMock) Read(after uint64, limit int) (models.Changes, error) {
+	args := m.Called(after, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(models.Changes), args.Error(1)
+}
+
+func (m *ChangeLogMock) Trim(before int64) (int, error) {
+	args := m.Called(before)
+	return args.Int(0), args.Error(1)
+}
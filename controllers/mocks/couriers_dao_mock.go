@@ -52,3 +52,8 @@ func (c *CouriersDAOMock) Exists(courierID string) (bool, error) {
 	args := c.Called(courierID)
 	return args.Bool(0), args.Error(1)
 }
+
+func (c *CouriersDAOMock) GetSilent(before int64, size int) (models.Couriers, error) {
+	args := c.Called(before, size)
+	return args.Get(0).(models.Couriers), args.Error(1)
+}
@@ -0,0 +1,18 @@
+package mocks
+
+import (
+	"github.com/TeamD2018/geo-rest/models"
+	"github.com/stretchr/testify/mock"
+)
+
+type DispatcherMock struct {
+	mock.Mock
+}
+
+func (m *DispatcherMock) Rank(order *models.Order) (*models.DispatchRanking, error) {
+	args := m.Called(order)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.DispatchRanking), args.Error(1)
+}
@@ -24,7 +24,10 @@ func (m *GeoRouteMock) DeleteCourier(courierID string) error {
 	return args.Error(0)
 }
 
-func (m *GeoRouteMock) GetRoute(courierID string, since int64) ([]*models.PointWithTs, error) {
-	args := m.Called(courierID)
-	return args.Get(0).([]*models.PointWithTs), args.Error(1)
+func (m *GeoRouteMock) GetRoute(courierID string, query *models.RouteQuery) (*models.RoutePage, error) {
+	args := m.Called(courierID, query)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.RoutePage), args.Error(1)
 }
@@ -2,7 +2,6 @@ package mocks
 
 import (
 	"context"
-	"github.com/TeamD2018/geo-rest/controllers/parameters"
 	"github.com/TeamD2018/geo-rest/models"
 	"github.com/stretchr/testify/mock"
 )
@@ -66,21 +65,81 @@ func (o *OrdersDAOMock) DeleteOrdersForCourier(orderID string) error {
 	return args.Error(0)
 }
 
-func (o *OrdersDAOMock) GetOrdersForCourier(courierID string, since int64, asc parameters.DirectionFlag, excludeDelivered parameters.DeliveredFlag) (models.Orders, error) {
-	args := o.Called(courierID, since, asc, excludeDelivered)
+func (o *OrdersDAOMock) Claim(orderID, courierID string) (*models.Order, error) {
+	args := o.Called(orderID, courierID)
 	v := args.Get(0)
 	err := args.Error(1)
 	switch v.(type) {
-	case models.Orders:
+	case *models.Order:
 		if v == nil {
 			return nil, err
 		}
-		return v.(models.Orders), err
+		return v.(*models.Order), err
 	default:
 		return nil, err
 	}
 }
 
+func (o *OrdersDAOMock) Reassign(orderID, courierID, reason string) (*models.Order, error) {
+	args := o.Called(orderID, courierID, reason)
+	v := args.Get(0)
+	err := args.Error(1)
+	switch v.(type) {
+	case *models.Order:
+		if v == nil {
+			return nil, err
+		}
+		return v.(*models.Order), err
+	default:
+		return nil, err
+	}
+}
+
+func (o *OrdersDAOMock) Transition(orderID, courierID string, change *models.OrderStatusChange) (*models.Order, error) {
+	args := o.Called(orderID, courierID, change)
+	v := args.Get(0)
+	err := args.Error(1)
+	switch v.(type) {
+	case *models.Order:
+		if v == nil {
+			return nil, err
+		}
+		return v.(*models.Order), err
+	default:
+		return nil, err
+	}
+}
+
+func (o *OrdersDAOMock) GetOrdersForCourier(courierID string, query *models.OrdersQuery) (*models.OrdersPage, error) {
+	args := o.Called(courierID, query)
+	v := args.Get(0)
+	err := args.Error(1)
+	switch v.(type) {
+	case *models.OrdersPage:
+		if v == nil {
+			return nil, err
+		}
+		return v.(*models.OrdersPage), err
+	default:
+		return nil, err
+	}
+}
+
+func (o *OrdersDAOMock) GetByBoxField(field *models.BoxField, search *models.OrderSearch) (models.Orders, error) {
+	args := o.Called(field, search)
+	return args.Get(0).(models.Orders), args.Error(1)
+}
+
+func (o *OrdersDAOMock) GetByCircleField(field *models.CircleField, search *models.OrderSearch) (models.Orders, error) {
+	args := o.Called(field, search)
+	return args.Get(0).(models.Orders), args.Error(1)
+}
+
+func (o *OrdersDAOMock) GetByPolygon(polygon models.FlatPolygon, search *models.OrderSearch) (models.Orders, error) {
+	args := o.Called(polygon, search)
+	return args.Get(0).(models.Orders), args.Error(1)
+}
+
 type GeoResolverMock struct {
 	mock.Mock
 }
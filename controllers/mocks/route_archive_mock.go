@@ -0,0 +1,23 @@
+package mocks
+
+import (
+	"github.com/TeamD2018/geo-rest/models"
+	"github.com/stretchr/testify/mock"
+)
+
+type RouteArchiveMock struct {
+	mock.Mock
+}
+
+func (m *RouteArchiveMock) Save(route *models.ArchivedRoute) error {
+	args := m.Called(route)
+	return args.Error(0)
+}
+
+func (m *RouteArchiveMock) Find(query *models.RouteArchiveQuery) (models.ArchivedRoutes, error) {
+	args := m.Called(query)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(models.ArchivedRoutes), args.Error(1)
+}
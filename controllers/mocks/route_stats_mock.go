@@ -0,0 +1,19 @@
+package mocks
+
+import (
+	"github.com/TeamD2018/geo-rest/models"
+	"github.com/stretchr/testify/mock"
+	"time"
+)
+
+type RouteStatsServiceMock struct {
+	mock.Mock
+}
+
+func (m *RouteStatsServiceMock) Stats(courierID string, query *models.RouteQuery, stopDuration time.Duration, stopRadius float64) (*models.RouteStats, error) {
+	args := m.Called(courierID, query, stopDuration, stopRadius)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.RouteStats), args.Error(1)
+}
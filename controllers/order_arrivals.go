@@ -0,0 +1,80 @@
+package controllers
+
+import (
+	"github.com/TeamD2018/geo-rest/models"
+	"go.uber.org/zap"
+)
+
+// detectArrivals checks the active orders of the courier against its new locations, ordered by timestamp,
+// and stores the recorded arrivals. Failures to detect are only logged: the locations are already stored.
+// Only a stored arrival that could not be logged as a change is returned.
+func (api *APIService) detectArrivals(courierID string, points models.Points) error {
+	if api.Arrivals == nil || len(points) == 0 {
+		return nil
+	}
+	orders, err := api.OrdersDAO.GetOrdersForCourier(courierID, &models.OrdersQuery{
+		SinceIsLower:     true,
+		ExcludeDelivered: true,
+		Statuses:         models.ActiveOrderStatuses(),
+	})
+	if err != nil {
+		api.Logger.Error("fail to get orders for arrival detection", zap.String("courier_id", courierID), zap.Error(err))
+		return nil
+	}
+	for _, order := range orders.Orders {
+		events := make([]string, 0)
+		for _, point := range points {
+			events = append(events, api.Arrivals.Detect(order, point.Point, int64(point.Ts))...)
+		}
+		if len(events) == 0 {
+			continue
+		}
+		orderID := order.ID
+		update := &models.OrderUpdate{
+			ID:                   &orderID,
+			ArrivedAtPickup:      arrivalTimestamp(order.ArrivedAtPickup),
+			PickedUp:             arrivalTimestamp(order.PickedUp),
+			ArrivedAtDestination: arrivalTimestamp(order.ArrivedAtDestination),
+		}
+		updated, err := api.OrdersDAO.Update(update)
+		if err != nil {
+			api.Logger.Error("fail to record order arrival",
+				zap.String("courier_id", courierID),
+				zap.String("order_id", order.ID),
+				zap.Error(err))
+			continue
+		}
+		// leaving the pickup moves an assigned order on, later statuses are set through transitions.
+		// The order may have moved on meanwhile, then the transition is rejected and nothing changes
+		statusChanged := false
+		if order.PickedUp != 0 && updated.CurrentStatus() == models.OrderAssigned {
+			change := &models.OrderStatusChange{
+				To:     models.OrderPickedUp,
+				At:     order.PickedUp,
+				Actor:  models.OrderActorSystem,
+				Reason: "left pickup",
+			}
+			if pickedUp, err := api.OrdersDAO.Transition(order.ID, courierID, change); err == nil {
+				updated = pickedUp
+				statusChanged = true
+			}
+		}
+		if err := api.recordChange(models.ChangeOrder, models.ChangeUpdated, order.ID, courierID, updated); err != nil {
+			return err
+		}
+		for _, event := range events {
+			api.dispatchWebhook(event, updated)
+		}
+		if statusChanged {
+			api.dispatchWebhook(models.WebhookOrderStatusChanged, updated)
+		}
+	}
+	return nil
+}
+
+func arrivalTimestamp(ts int64) *int64 {
+	if ts == 0 {
+		return nil
+	}
+	return &ts
+}
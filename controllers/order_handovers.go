@@ -0,0 +1,52 @@
+package controllers
+
+import (
+	"github.com/TeamD2018/geo-rest/models"
+	"github.com/gin-gonic/gin"
+	"github.com/olivere/elastic"
+	"github.com/satori/go.uuid"
+	"go.uber.org/zap"
+	"net/http"
+)
+
+// GetOrderHandovers returns the couriers the order was handed over between, the oldest handover first.
+// The log is open to the current courier and to every courier who carried the order before.
+func (api *APIService) GetOrderHandovers(ctx *gin.Context) {
+	courierID := ctx.Param("courier_id")
+	orderID := ctx.Param("order_id")
+	if _, err := uuid.FromString(courierID); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, models.ErrOneOfParameterHaveIncorrectFormat.SetParameter("courier_id"))
+		return
+	}
+	if _, err := uuid.FromString(orderID); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, models.ErrOneOfParameterHaveIncorrectFormat.SetParameter("order_id"))
+		return
+	}
+	order, err := api.OrdersDAO.Get(orderID)
+	if err != nil {
+		api.Logger.Error("fail to get order", zap.String("order_id", orderID), zap.Error(err))
+		switch err.(type) {
+		case *elastic.Error:
+			err := err.(*elastic.Error)
+			if err.Status == 404 {
+				ctx.AbortWithStatusJSON(http.StatusNotFound, models.ErrEntityNotFound)
+				return
+			}
+		case *models.Error:
+			err := err.(*models.Error)
+			ctx.AbortWithStatusJSON(err.HttpStatus(), err)
+			return
+		}
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, models.ErrServerError)
+		return
+	}
+	if !order.HeldBy(courierID) {
+		ctx.AbortWithStatusJSON(http.StatusNotFound, models.ErrEntityNotFound.SetParameter(orderID))
+		return
+	}
+	handovers := order.Handovers
+	if handovers == nil {
+		handovers = []*models.OrderHandover{}
+	}
+	ctx.JSON(http.StatusOK, handovers)
+}
@@ -0,0 +1,142 @@
+package controllers
+
+import (
+	"context"
+	"github.com/TeamD2018/geo-rest/controllers/parameters"
+	"github.com/TeamD2018/geo-rest/models"
+	"github.com/gin-gonic/gin"
+	"github.com/olivere/elastic"
+	"github.com/satori/go.uuid"
+	"go.uber.org/zap"
+	"net/http"
+)
+
+// CreateOpenOrder creates an order with no courier. It waits in the unassigned status until a courier claims it,
+// with dispatch=true it is assigned to the best ranked courier right away if there is one.
+func (api *APIService) CreateOpenOrder(ctx *gin.Context) {
+	var params parameters.CreateOpenOrder
+	if err := ctx.ShouldBindQuery(&params); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, models.ErrOneOfParameterHaveIncorrectFormat.SetParameter("dispatch"))
+		return
+	}
+	var order models.OrderCreate
+	if err := ctx.ShouldBindJSON(&order); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, models.ErrOneOfParameterHaveIncorrectFormat)
+		return
+	}
+	order.CourierID = nil
+	exCtx := context.Background()
+	if err := api.GeoResolver.Resolve(&order.Destination, exCtx); err != nil {
+		api.Logger.Error("fail to resolve destination", zap.Error(err), zap.Any("dest", order.Destination))
+	}
+	if err := api.GeoResolver.Resolve(&order.Source, exCtx); err != nil {
+		api.Logger.Error("fail to resolve source", zap.Error(err), zap.Any("source", order.Source))
+	}
+	created, err := api.OrdersDAO.Create(&order)
+	if err != nil {
+		api.Logger.Error("fail to create open order", zap.Error(err))
+		switch err.(type) {
+		case *models.Error:
+			err := err.(*models.Error)
+			ctx.AbortWithStatusJSON(err.HttpStatus(), err)
+			return
+		}
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, models.ErrServerError)
+		return
+	}
+	if err := api.recordChange(models.ChangeOrder, models.ChangeCreated, created.ID, "", created); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, models.ErrServerError)
+		return
+	}
+	api.dispatchWebhook(models.WebhookOrderCreated, created)
+	if params.Dispatch {
+		// the order is created either way, a failed dispatch leaves it open for claiming
+		ranking, err := api.dispatchOrder(created, false)
+		if err != nil {
+			api.Logger.Error("fail to dispatch order", zap.String("order_id", created.ID), zap.Error(err))
+		} else if ranking.Assigned != nil {
+			created = ranking.Assigned
+		}
+	}
+
+	ctx.JSON(http.StatusCreated, created)
+}
+
+// GetOpenOrders returns unassigned orders whose source lies within radius meters of the point.
+func (api *APIService) GetOpenOrders(ctx *gin.Context) {
+	var circle parameters.CircleFieldQuery
+	if err := ctx.ShouldBindQuery(&circle); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, models.ErrOneOfParameterHaveIncorrectFormat)
+		return
+	}
+	if circle.Size < 0 {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, models.ErrOneOfParameterHaveIncorrectFormat.SetParameter("size"))
+		return
+	}
+	search := &models.OrderSearch{
+		Point:    models.OrderPointSource,
+		Statuses: []string{models.OrderUnassigned},
+		Size:     circle.Size,
+	}
+	orders, err := api.OrdersDAO.GetByCircleField(circle.ToCircleField(), search)
+	if err != nil {
+		api.Logger.Error("fail to get open orders", zap.Error(err))
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, models.ErrServerError)
+		return
+	}
+	ctx.JSON(http.StatusOK, orders)
+}
+
+// ClaimOrder assigns an unassigned order to the courier. Exactly one courier wins a claim, the others get 409.
+func (api *APIService) ClaimOrder(ctx *gin.Context) {
+	courierID := ctx.Param("courier_id")
+	orderID := ctx.Param("order_id")
+	if _, err := uuid.FromString(courierID); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, models.ErrOneOfParameterHaveIncorrectFormat.SetParameter("courier_id"))
+		return
+	}
+	if _, err := uuid.FromString(orderID); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, models.ErrOneOfParameterHaveIncorrectFormat.SetParameter("order_id"))
+		return
+	}
+	claimed, err := api.claimOrder(orderID, courierID)
+	if err != nil {
+		api.Logger.Error("fail to claim order",
+			zap.String("courier_id", courierID),
+			zap.String("order_id", orderID),
+			zap.Error(err))
+		switch err.(type) {
+		case *elastic.Error:
+			err := err.(*elastic.Error)
+			if err.Status == 404 {
+				ctx.AbortWithStatusJSON(http.StatusNotFound, models.ErrEntityNotFound)
+				return
+			}
+		case *models.Error:
+			err := err.(*models.Error)
+			ctx.AbortWithStatusJSON(err.HttpStatus(), err)
+			return
+		}
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, models.ErrServerError)
+		return
+	}
+	ctx.JSON(http.StatusOK, claimed)
+}
+
+// claimOrder assigns the unassigned order to the courier and counts it the way CreateOrder does.
+func (api *APIService) claimOrder(orderID, courierID string) (*models.Order, error) {
+	claimed, err := api.OrdersDAO.Claim(orderID, courierID)
+	if err != nil {
+		return nil, err
+	}
+	changeErr := api.recordChange(models.ChangeOrder, models.ChangeUpdated, orderID, courierID, claimed)
+	if err := api.trackNewOrder(courierID); err != nil {
+		return nil, err
+	}
+	if changeErr != nil {
+		return nil, changeErr
+	}
+	api.dispatchWebhook(models.WebhookOrderClaimed, claimed)
+	api.dispatchWebhook(models.WebhookOrderStatusChanged, claimed)
+	return claimed, nil
+}
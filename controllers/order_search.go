@@ -0,0 +1,93 @@
+package controllers
+
+import (
+	"github.com/TeamD2018/geo-rest/controllers/parameters"
+	"github.com/TeamD2018/geo-rest/models"
+	"github.com/gin-gonic/gin"
+	"github.com/satori/go.uuid"
+	"go.uber.org/zap"
+	"net/http"
+)
+
+// SearchOrders finds orders whose source or destination lies in an area. The area is a circle when radius is set,
+// an OSM region when osm_id is set and a bounding box otherwise, the same way couriers are searched.
+func (api *APIService) SearchOrders(ctx *gin.Context) {
+	var params parameters.OrderSearch
+	if err := ctx.ShouldBindQuery(&params); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, models.ErrOneOfParameterHaveIncorrectFormat)
+		return
+	}
+	search, paramErr := orderSearch(&params)
+	if paramErr != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, paramErr)
+		return
+	}
+	var orders models.Orders
+	var err error
+	query := ctx.Request.URL.Query()
+	if query.Get("radius") != "" {
+		var circle parameters.CircleFieldQuery
+		if err := ctx.ShouldBindQuery(&circle); err != nil {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, models.ErrOneOfParameterHaveIncorrectFormat)
+			return
+		}
+		orders, err = api.OrdersDAO.GetByCircleField(circle.ToCircleField(), search)
+	} else if query.Get("osm_id") != "" {
+		var region parameters.PolygonQuery
+		if err := ctx.ShouldBindQuery(&region); err != nil {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, models.ErrOneOfParameterHaveIncorrectFormat)
+			return
+		}
+		polygon, resolveErr := api.RegionResolver.ResolveRegion(region.ToOSMEntity())
+		if resolveErr != nil {
+			api.Logger.Error("fail to resolve region", zap.Int("osm_id", region.OSMID), zap.Error(resolveErr))
+			ctx.AbortWithStatusJSON(http.StatusInternalServerError, models.ErrServerError)
+			return
+		}
+		orders, err = api.OrdersDAO.GetByPolygon(polygon, search)
+	} else {
+		var box parameters.BoxFieldQuery
+		if err := ctx.ShouldBindQuery(&box); err != nil {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, models.ErrOneOfParameterHaveIncorrectFormat)
+			return
+		}
+		orders, err = api.OrdersDAO.GetByBoxField(box.ToBoxField(), search)
+	}
+	if err != nil {
+		api.Logger.Error("fail to search orders", zap.Error(err))
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, models.ErrServerError)
+		return
+	}
+	ctx.JSON(http.StatusOK, orders)
+}
+
+func orderSearch(params *parameters.OrderSearch) (*models.OrderSearch, *models.Error) {
+	if params.Point != "" && !models.IsOrderPoint(params.Point) {
+		return nil, models.ErrOneOfParameterHaveIncorrectFormat.SetParameter("point")
+	}
+	if params.CourierID != "" {
+		if _, err := uuid.FromString(params.CourierID); err != nil {
+			return nil, models.ErrOneOfParameterHaveIncorrectFormat.SetParameter("courier_id")
+		}
+	}
+	statuses := params.Statuses()
+	for _, status := range statuses {
+		if !models.IsOrderStatus(status) {
+			return nil, models.ErrOneOfParameterHaveIncorrectFormat.SetParameter("status")
+		}
+	}
+	if params.Since < 0 || params.Until < 0 || (params.Until != 0 && params.Until < params.Since) {
+		return nil, models.ErrOneOfParameterHaveIncorrectFormat.SetParameter("until")
+	}
+	if params.Size < 0 {
+		return nil, models.ErrOneOfParameterHaveIncorrectFormat.SetParameter("size")
+	}
+	return &models.OrderSearch{
+		Point:     params.Point,
+		CourierID: params.CourierID,
+		Statuses:  statuses,
+		Since:     params.Since,
+		Until:     params.Until,
+		Size:      params.Size,
+	}, nil
+}
@@ -0,0 +1,92 @@
+package controllers
+
+import (
+	"github.com/TeamD2018/geo-rest/models"
+	"github.com/gin-gonic/gin"
+	"github.com/satori/go.uuid"
+	"go.uber.org/zap"
+	"net/http"
+	"time"
+)
+
+// TransitionOrder moves the order to the requested status. Moves the state machine does not allow are rejected
+// with 409, the order of the courier stops being counted once it reaches a final status.
+func (api *APIService) TransitionOrder(ctx *gin.Context) {
+	courierID := ctx.Param("courier_id")
+	orderID := ctx.Param("order_id")
+	if _, err := uuid.FromString(courierID); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, models.ErrOneOfParameterHaveIncorrectFormat.SetParameter("courier_id"))
+		return
+	}
+	if _, err := uuid.FromString(orderID); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, models.ErrOneOfParameterHaveIncorrectFormat.SetParameter("order_id"))
+		return
+	}
+	var transition models.OrderTransition
+	if err := ctx.ShouldBindJSON(&transition); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, models.ErrOneOfParameterHaveIncorrectFormat)
+		return
+	}
+	if !models.IsOrderStatus(transition.Status) {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, models.ErrOneOfParameterHaveIncorrectFormat.SetParameter("status"))
+		return
+	}
+	change := &models.OrderStatusChange{
+		To:     transition.Status,
+		At:     time.Now().Unix(),
+		Actor:  transition.Actor,
+		Reason: transition.Reason,
+	}
+	// the status is checked against the stored order, so of concurrent transitions from one status only one is applied
+	updated, err := api.OrdersDAO.Transition(orderID, courierID, change)
+	if err != nil {
+		api.Logger.Error("fail to update order status",
+			zap.String("courier_id", courierID),
+			zap.String("order_id", orderID),
+			zap.Error(err))
+		switch err.(type) {
+		case *models.Error:
+			err := err.(*models.Error)
+			ctx.AbortWithStatusJSON(err.HttpStatus(), err)
+			return
+		}
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, models.ErrServerError)
+		return
+	}
+	// the order is released even if its change can not be logged, so the counter matches the stored order
+	changeErr := api.recordChange(models.ChangeOrder, models.ChangeUpdated, orderID, courierID, updated)
+	wasActive := models.IsActiveOrderStatus(updated.LastStatusChange().From)
+	if wasActive && !models.IsActiveOrderStatus(updated.CurrentStatus()) {
+		if err := api.releaseOrder(courierID); err != nil {
+			changeErr = err
+		}
+	}
+	if changeErr != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, models.ErrServerError)
+		return
+	}
+	api.dispatchWebhook(models.WebhookOrderStatusChanged, updated)
+	if updated.CurrentStatus() == models.OrderDelivered {
+		api.dispatchWebhook(models.WebhookOrderDelivered, updated)
+	}
+	ctx.JSON(http.StatusOK, updated)
+}
+
+// releaseOrder stops counting an order of the courier that reached a final status or was deleted. The route of the courier
+// is archived once it carries nothing. Only a failure to log the route deletion is returned.
+func (api *APIService) releaseOrder(courierID string) error {
+	var changeErr error
+	ordersCount, err := api.OrdersCountTracker.DecAndGet(courierID)
+	if err == nil && ordersCount == 0 && api.archiveRoute(courierID) {
+		if err := api.CourierRouteDAO.DeleteCourier(courierID); err != nil {
+			api.Logger.Error("fail to cleanup courier route", zap.Error(err))
+		} else {
+			changeErr = api.recordChange(models.ChangeRoute, models.ChangeDeleted, courierID, courierID, nil)
+		}
+	}
+	if err != nil {
+		api.Logger.Error("fail to decrement courier orders count", zap.Error(err))
+	}
+	api.publishCourierByID(courierID)
+	return changeErr
+}
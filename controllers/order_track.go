@@ -0,0 +1,126 @@
+package controllers
+
+import (
+	"github.com/TeamD2018/geo-rest/controllers/parameters"
+	"github.com/TeamD2018/geo-rest/models"
+	"github.com/TeamD2018/geo-rest/services/geo"
+	"github.com/gin-gonic/gin"
+	"github.com/olivere/elastic"
+	"github.com/satori/go.uuid"
+	"go.uber.org/zap"
+	"net/http"
+	"sort"
+)
+
+// GetOrderTrack returns the trip of the order: the route of each courier that carried it, from taking the order
+// until handing it over or the order reaching a final status.
+func (api *APIService) GetOrderTrack(ctx *gin.Context) {
+	courierID := ctx.Param("courier_id")
+	orderID := ctx.Param("order_id")
+	if _, err := uuid.FromString(courierID); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, models.ErrOneOfParameterHaveIncorrectFormat.SetParameter("courier_id"))
+		return
+	}
+	if _, err := uuid.FromString(orderID); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, models.ErrOneOfParameterHaveIncorrectFormat.SetParameter("order_id"))
+		return
+	}
+	var params parameters.OrderTrack
+	if err := ctx.BindQuery(&params); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, models.ErrOneOfParameterHaveIncorrectFormat)
+		return
+	}
+	if params.Format == "" {
+		params.Format = negotiateRouteFormat(ctx)
+	}
+	if !isRouteFormat(params.Format) {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, models.ErrOneOfParameterHaveIncorrectFormat.SetParameter("format"))
+		return
+	}
+	if params.Tolerance < 0 {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, models.ErrOneOfParameterHaveIncorrectFormat.SetParameter("tolerance"))
+		return
+	}
+	if params.MaxPoints < 0 || params.MaxPoints == 1 {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, models.ErrOneOfParameterHaveIncorrectFormat.SetParameter("max_points"))
+		return
+	}
+	order, err := api.OrdersDAO.Get(orderID)
+	if err != nil {
+		api.Logger.Error("fail to get order", zap.String("order_id", orderID), zap.Error(err))
+		switch err.(type) {
+		case *elastic.Error:
+			err := err.(*elastic.Error)
+			if err.Status == 404 {
+				ctx.AbortWithStatusJSON(http.StatusNotFound, models.ErrEntityNotFound)
+				return
+			}
+		case *models.Error:
+			err := err.(*models.Error)
+			ctx.AbortWithStatusJSON(err.HttpStatus(), err)
+			return
+		}
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, models.ErrServerError)
+		return
+	}
+	if order.CourierID != courierID {
+		ctx.AbortWithStatusJSON(http.StatusNotFound, models.ErrEntityNotFound.SetParameter(orderID))
+		return
+	}
+	points, err := api.orderTrack(order)
+	if err != nil {
+		api.Logger.Error("fail to get order track", zap.Error(err),
+			zap.String("courier_id", courierID),
+			zap.String("order_id", orderID))
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, models.ErrServerError)
+		return
+	}
+	points = geo.Simplify(points, params.Tolerance, params.MaxPoints)
+	renderRoute(ctx, params.Format, courierID, &models.RoutePage{Points: points})
+}
+
+// orderTrack collects the points of every courier that carried the order within its segment of the trip,
+// from the live routes and, once the trip of a courier is over, from the archive.
+func (api *APIService) orderTrack(order *models.Order) (models.Points, error) {
+	points := make(models.Points, 0)
+	for _, segment := range order.Segments() {
+		segmentPoints, err := api.segmentTrack(segment)
+		if err != nil {
+			return nil, err
+		}
+		points = append(points, segmentPoints...)
+	}
+	sort.SliceStable(points, func(i, j int) bool {
+		return points[i].Ts < points[j].Ts
+	})
+	return points, nil
+}
+
+// segmentTrack collects route points of the segment courier with since <= ts <= until.
+func (api *APIService) segmentTrack(segment *models.OrderSegment) (models.Points, error) {
+	query := &models.RouteQuery{Since: segment.Since - 1, Until: segment.Until}
+	page, err := api.CourierRouteDAO.GetRoute(segment.CourierID, query)
+	if err != nil {
+		return nil, err
+	}
+	points := page.Points
+	if api.RouteArchive != nil {
+		routes, err := api.RouteArchive.Find(&models.RouteArchiveQuery{
+			CourierID: segment.CourierID,
+			Since:     segment.Since,
+			Until:     segment.Until,
+			Limit:     parameters.MaxRouteArchiveLimit,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, route := range routes {
+			for _, p := range route.GeoHistory {
+				if int64(p.Ts) >= segment.Since && (segment.Until == 0 || int64(p.Ts) <= segment.Until) {
+					points = append(points, p)
+				}
+			}
+		}
+	}
+	return points, nil
+}
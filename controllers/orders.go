@@ -9,6 +9,8 @@ import (
 	"github.com/satori/go.uuid"
 	"go.uber.org/zap"
 	"net/http"
+	"strconv"
+	"time"
 )
 
 func (api *APIService) GetOrder(ctx *gin.Context) {
@@ -38,6 +40,7 @@ func (api *APIService) GetOrder(ctx *gin.Context) {
 		ctx.AbortWithStatusJSON(http.StatusInternalServerError, models.ErrServerError)
 		return
 	}
+	api.estimateArrival(order.CourierID, models.Orders{order})
 	ctx.JSON(http.StatusOK, order)
 }
 
@@ -79,12 +82,42 @@ func (api *APIService) UpdateOrder(ctx *gin.Context) {
 
 	order.ID = &orderID
 	order.CourierID = nil
+	order.Status = nil
+	order.StatusHistory = nil
+	delivered := order.DeliveredAt != nil
+	if delivered {
+		// delivery is the transition to delivered, a final order can not be delivered again. delivered_at is the
+		// client clock in milliseconds: it is kept as reported, the order is delivered at the server time
+		order.ReportedDeliveredAt = order.DeliveredAt
+		order.DeliveredAt = nil
+		change := &models.OrderStatusChange{To: models.OrderDelivered, At: time.Now().Unix(), Actor: courierID}
+		if _, err := api.OrdersDAO.Transition(orderID, courierID, change); err != nil {
+			api.Logger.Error("fail to deliver order",
+				zap.String("courier_id", courierID),
+				zap.String("order_id", orderID),
+				zap.Error(err))
+			switch err.(type) {
+			case *models.Error:
+				err := err.(*models.Error)
+				ctx.AbortWithStatusJSON(err.HttpStatus(), err)
+				return
+			}
+			ctx.AbortWithStatusJSON(http.StatusInternalServerError, models.ErrServerError)
+			return
+		}
+	}
 	created, err := api.OrdersDAO.Update(&order)
 	if err != nil {
 		api.Logger.Error("fail to update order",
 			zap.String("courier_id", courierID),
 			zap.String("order_id", orderID),
 			zap.Error(err))
+		// the order is delivered all the same, so it stops being counted
+		if delivered {
+			if err := api.releaseOrder(courierID); err != nil {
+				api.Logger.Error("fail to record route change", zap.String("courier_id", courierID), zap.Error(err))
+			}
+		}
 		switch err.(type) {
 		case *elastic.Error:
 			err := err.(*elastic.Error)
@@ -100,17 +133,23 @@ func (api *APIService) UpdateOrder(ctx *gin.Context) {
 		ctx.AbortWithStatusJSON(http.StatusInternalServerError, models.ErrServerError)
 		return
 	}
-	if order.DeliveredAt != nil {
-		ordersCount, err := api.OrdersCountTracker.DecAndGet(courierID)
-		if err == nil && ordersCount == 0 {
-			if err := api.CourierRouteDAO.DeleteCourier(courierID); err != nil {
-				api.Logger.Error("fail to cleanup courier route", zap.Error(err))
-			}
-		}
-		if err != nil {
-			api.Logger.Error("fail to decrement courier orders count", zap.Error(err))
+	// a delivered order is released even if its change can not be logged, so the counter matches the stored order
+	changeErr := api.recordChange(models.ChangeOrder, models.ChangeUpdated, orderID, created.CourierID, created)
+	if delivered {
+		if err := api.releaseOrder(courierID); err != nil {
+			changeErr = err
 		}
 	}
+	if changeErr != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, models.ErrServerError)
+		return
+	}
+	if delivered {
+		api.dispatchWebhook(models.WebhookOrderStatusChanged, created)
+		api.dispatchWebhook(models.WebhookOrderDelivered, created)
+	} else {
+		api.dispatchWebhook(models.WebhookOrderUpdated, created)
+	}
 	ctx.JSON(http.StatusOK, created)
 }
 
@@ -153,21 +192,45 @@ func (api *APIService) CreateOrder(ctx *gin.Context) {
 		ctx.AbortWithStatusJSON(http.StatusInternalServerError, models.ErrServerError)
 		return
 	}
-	err = api.CourierRouteDAO.CreateCourier(*order.CourierID)
-	if err != nil {
+	changeErr := api.recordChange(models.ChangeOrder, models.ChangeCreated, created.ID, courierID, created)
+	if err := api.trackNewOrder(courierID); err != nil {
 		api.Logger.Error("fail to create order", zap.String("courier_id", courierID), zap.Error(err))
 		//TODO: error handling
 		ctx.AbortWithStatusJSON(http.StatusInternalServerError, models.ErrServerError)
 		return
 	}
-
-	if err := api.OrdersCountTracker.Inc(ctx.Param("courier_id")); err != nil {
-		api.Logger.Error("fail to increment order counter", zap.Error(err))
+	if changeErr != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, models.ErrServerError)
+		return
 	}
+	api.dispatchWebhook(models.WebhookOrderCreated, created)
 
 	ctx.JSON(http.StatusCreated, created)
 }
 
+// trackNewOrder counts a new active order of the courier and starts its route with the first one.
+// Only failures to start the route or to log its start are returned.
+func (api *APIService) trackNewOrder(courierID string) error {
+	ordersCount, err := api.OrdersCountTracker.IncAndGet(courierID)
+	if err != nil {
+		api.Logger.Error("fail to increment order counter", zap.Error(err))
+	}
+	// the route is restarted only by the first active order, so tracks of orders already carried stay intact
+	if (err != nil || ordersCount == 1) && api.archiveRoute(courierID) {
+		if err := api.CourierRouteDAO.CreateCourier(courierID); err != nil {
+			return err
+		}
+		if err := api.recordChange(models.ChangeRoute, models.ChangeCreated, courierID, courierID, nil); err != nil {
+			return err
+		}
+	}
+	api.publishCourierByID(courierID)
+	return nil
+}
+
+// AssignNewCourier hands an active order over to the courier of the path. The previous courier stops counting
+// the order, the new one counts it and starts its route the way CreateOrder does. The optional reason of the body
+// is kept in the order handovers.
 func (api *APIService) AssignNewCourier(ctx *gin.Context) {
 	courierID := ctx.Param("courier_id")
 	orderID := ctx.Param("order_id")
@@ -176,7 +239,18 @@ func (api *APIService) AssignNewCourier(ctx *gin.Context) {
 		ctx.AbortWithStatusJSON(http.StatusBadRequest, models.ErrOneOfParameterHaveIncorrectFormat)
 		return
 	}
-	updated, err := api.OrdersDAO.Update(&models.OrderUpdate{CourierID: &courierID})
+	if _, err := uuid.FromString(courierID); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, models.ErrOneOfParameterHaveIncorrectFormat.SetParameter("courier_id"))
+		return
+	}
+	var params parameters.Reassign
+	if ctx.Request.ContentLength != 0 {
+		if err := ctx.ShouldBindJSON(&params); err != nil {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, models.ErrOneOfParameterHaveIncorrectFormat)
+			return
+		}
+	}
+	updated, err := api.OrdersDAO.Reassign(orderID, courierID, params.Reason)
 	if err != nil {
 		api.Logger.Error("fail to assing order to another courier", zap.String("courier_id", courierID), zap.String("order_id", orderID), zap.Error(err))
 		switch err.(type) {
@@ -194,6 +268,26 @@ func (api *APIService) AssignNewCourier(ctx *gin.Context) {
 		ctx.AbortWithStatusJSON(http.StatusInternalServerError, models.ErrServerError)
 		return
 	}
+	previousCourierID := updated.LastHandover().From
+	// both couriers see the order change hands in their change feeds
+	changeErr := api.recordChange(models.ChangeOrder, models.ChangeUpdated, orderID, previousCourierID, updated)
+	if err := api.recordChange(models.ChangeOrder, models.ChangeUpdated, orderID, courierID, updated); err != nil {
+		changeErr = err
+	}
+	// the counters follow the stored order even if its changes can not be logged
+	if err := api.releaseOrder(previousCourierID); err != nil {
+		changeErr = err
+	}
+	if err := api.trackNewOrder(courierID); err != nil {
+		api.Logger.Error("fail to create courier route", zap.String("courier_id", courierID), zap.Error(err))
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, models.ErrServerError)
+		return
+	}
+	if changeErr != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, models.ErrServerError)
+		return
+	}
+	api.dispatchWebhook(models.WebhookOrderReassigned, updated)
 	ctx.JSON(http.StatusOK, updated)
 }
 
@@ -204,6 +298,29 @@ func (api *APIService) DeleteOrder(ctx *gin.Context) {
 		ctx.AbortWithStatusJSON(http.StatusBadRequest, models.ErrOneOfParameterHaveIncorrectFormat)
 		return
 	}
+	courierID := ctx.Param("courier_id")
+	order, err := api.OrdersDAO.Get(orderID)
+	if err != nil {
+		api.Logger.Error("fail to get order", zap.String("order_id", orderID), zap.Error(err))
+		switch err.(type) {
+		case *elastic.Error:
+			err := err.(*elastic.Error)
+			if err.Status == 404 {
+				ctx.AbortWithStatusJSON(http.StatusNotFound, models.ErrEntityNotFound)
+				return
+			}
+		case *models.Error:
+			err := err.(*models.Error)
+			ctx.AbortWithStatusJSON(err.HttpStatus(), err)
+			return
+		}
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, models.ErrServerError)
+		return
+	}
+	if order.CourierID != courierID {
+		ctx.AbortWithStatusJSON(http.StatusNotFound, models.ErrEntityNotFound.SetParameter(orderID))
+		return
+	}
 	if err := api.OrdersDAO.Delete(orderID); err != nil {
 		api.Logger.Error("fail to delete order", zap.String("order_id", orderID), zap.Error(err))
 		switch err.(type) {
@@ -222,9 +339,20 @@ func (api *APIService) DeleteOrder(ctx *gin.Context) {
 		return
 	}
 
-	if err := api.OrdersCountTracker.Dec(ctx.Param("courier_id")); err != nil {
-		api.Logger.Error("fail to decrement order counter", zap.Error(err))
+	changeErr := api.recordChange(models.ChangeOrder, models.ChangeDeleted, orderID, courierID, nil)
+	// orders in a final status were already released
+	if models.IsActiveOrderStatus(order.CurrentStatus()) {
+		if err := api.releaseOrder(order.CourierID); err != nil {
+			changeErr = err
+		}
+	} else {
+		api.publishCourierByID(courierID)
 	}
+	if changeErr != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, models.ErrServerError)
+		return
+	}
+	api.dispatchWebhook(models.WebhookOrderDeleted, &models.WebhookDeleted{ID: orderID, CourierID: courierID})
 
 	ctx.Status(http.StatusNoContent)
 }
@@ -241,10 +369,12 @@ func (api *APIService) GetOrdersForCourier(ctx *gin.Context) {
 		ctx.AbortWithStatusJSON(http.StatusBadRequest, models.ErrOneOfParameterHaveIncorrectFormat)
 		return
 	}
-	orders, err := api.OrdersDAO.GetOrdersForCourier(courierID,
-		params.Since,
-		params.Asc,
-		params.ExcludeDelivered)
+	query, paramErr := api.ordersQuery(courierID, &params)
+	if paramErr != nil {
+		ctx.AbortWithStatusJSON(paramErr.HttpStatus(), paramErr)
+		return
+	}
+	page, err := api.OrdersDAO.GetOrdersForCourier(courierID, query)
 	if err != nil {
 		api.Logger.Error("fail to get orders for courier", zap.String("courier_id", courierID), zap.Error(err))
 		switch err.(type) {
@@ -262,5 +392,71 @@ func (api *APIService) GetOrdersForCourier(ctx *gin.Context) {
 		ctx.AbortWithStatusJSON(http.StatusInternalServerError, models.ErrServerError)
 		return
 	}
-	ctx.JSON(http.StatusOK, orders)
+	api.estimateArrival(courierID, page.Orders)
+	ctx.Header(TotalCountHeader, strconv.FormatInt(page.Total, 10))
+	if page.Next != nil {
+		ctx.Header(NextCursorHeader, page.Next.String())
+	}
+	ctx.JSON(http.StatusOK, page.Orders)
+}
+
+// ordersQuery validates the listing parameters. Sorting by distance needs the current courier position.
+func (api *APIService) ordersQuery(courierID string, params *parameters.GetOrdersForCourierParams) (*models.OrdersQuery, *models.Error) {
+	query := &models.OrdersQuery{
+		Since:            params.Since,
+		SinceIsLower:     bool(params.Asc),
+		ExcludeDelivered: bool(params.ExcludeDelivered),
+		Statuses:         params.Statuses(),
+		Sort:             params.Sort,
+		Desc:             params.Desc,
+		Limit:            params.Limit,
+	}
+	for _, status := range query.Statuses {
+		if !models.IsOrderStatus(status) {
+			return nil, models.ErrOneOfParameterHaveIncorrectFormat.SetParameter("status")
+		}
+	}
+	if query.Sort != "" && !models.IsOrdersSort(query.Sort) {
+		return nil, models.ErrOneOfParameterHaveIncorrectFormat.SetParameter("sort")
+	}
+	if query.Limit < 0 || query.Limit > parameters.MaxOrdersLimit {
+		return nil, models.ErrOneOfParameterHaveIncorrectFormat.SetParameter("limit")
+	}
+	if query.Limit == 0 {
+		query.Limit = parameters.DefaultOrdersLimit
+	}
+	if params.Cursor != "" {
+		cursor, err := models.ParseOrdersCursor(params.Cursor)
+		if err != nil {
+			return nil, models.ErrOneOfParameterHaveIncorrectFormat.SetParameter("cursor")
+		}
+		query.After = cursor
+	}
+	if query.Sort == models.OrdersSortDistance {
+		courier, err := api.CouriersDAO.GetByID(courierID)
+		if err != nil {
+			return nil, models.ErrEntityNotFound.SetParameter(courierID)
+		}
+		if courier.Location == nil || courier.Location.Point == nil {
+			return nil, models.ErrOneOfParameterHaveIncorrectFormat.SetParameter("sort")
+		}
+		query.From = courier.Location.Point
+	}
+	return query, nil
+}
+
+// estimateArrival sets ETA of undelivered orders of the courier. Failures are only logged, orders are returned without it.
+func (api *APIService) estimateArrival(courierID string, orders models.Orders) {
+	// unassigned orders have no courier to arrive
+	if api.ETA == nil || len(orders) == 0 || courierID == "" {
+		return
+	}
+	courier, err := api.CouriersDAO.GetByID(courierID)
+	if err != nil {
+		api.Logger.Error("fail to get courier for eta", zap.String("courier_id", courierID), zap.Error(err))
+		return
+	}
+	if err := api.ETA.Estimate(courier, orders); err != nil {
+		api.Logger.Error("fail to estimate arrival", zap.String("courier_id", courierID), zap.Error(err))
+	}
 }
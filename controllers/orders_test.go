@@ -8,14 +8,20 @@ import (
 	"github.com/TeamD2018/geo-rest/controllers/mocks"
 	"github.com/TeamD2018/geo-rest/controllers/parameters"
 	"github.com/TeamD2018/geo-rest/models"
+	"github.com/TeamD2018/geo-rest/services"
 	"github.com/gin-gonic/gin"
 	"github.com/olivere/elastic"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 	"go.uber.org/zap"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
+	"sync"
 	"testing"
+	"time"
 )
 
 type OrdersControllersTestSuite struct {
@@ -87,7 +93,7 @@ func (oc *OrdersControllersTestSuite) BeforeTest(suiteName, testName string) {
 
 	geoResolverMock := new(mocks.GeoResolverMock)
 	geoResolverMock.On("Resolve", mock.Anything, mock.Anything).Return(nil)
-	geoResolverMock.On("GetOrdersForCourier", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(mock.AnythingOfType("models.Orders"), mock.AnythingOfType("error"))
+	geoResolverMock.On("GetOrdersForCourier", mock.Anything, mock.Anything).Return(mock.AnythingOfType("*models.OrdersPage"), mock.AnythingOfType("error"))
 	oc.ordersDAOMock.On("DeleteOrdersForCourier", mock.AnythingOfType("string")).Return(nil)
 	oc.api.GeoResolver = geoResolverMock
 	oc.suggesterMock = new(mocks.CouriersSuggestorMock)
@@ -100,6 +106,10 @@ func (oc *OrdersControllersTestSuite) BeforeTest(suiteName, testName string) {
 	oc.ordersTrackerMock.On("DecAndGet", mock.AnythingOfType("string")).Return(1, nil)
 	oc.ordersTrackerMock.On("Sync", mock.Anything).Return(nil)
 	oc.api.OrdersCountTracker = oc.ordersTrackerMock
+	oc.api.RouteArchive = nil
+	oc.api.Changes = nil
+	oc.api.ETA = nil
+	oc.api.Dispatcher = nil
 }
 
 func (oc *OrdersControllersTestSuite) TestAPIService_CreateOrder_Created() {
@@ -126,7 +136,7 @@ func (oc *OrdersControllersTestSuite) TestAPIService_CreateOrder_Created_If_Reso
 	oc.api.OrdersDAO = oc.ordersDAOMock
 	georesolver := new(mocks.GeoResolverMock)
 	georesolver.On("Resolve", mock.Anything, mock.Anything).Return(errors.New("test error"))
-	georesolver.On("GetOrdersForCourier", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(mock.AnythingOfType("models.Orders"), mock.AnythingOfType("error"))
+	georesolver.On("GetOrdersForCourier", mock.Anything, mock.Anything).Return(mock.AnythingOfType("*models.OrdersPage"), mock.AnythingOfType("error"))
 	oc.api.GeoResolver = georesolver
 
 	w := httptest.NewRecorder()
@@ -198,6 +208,33 @@ func (oc *OrdersControllersTestSuite) TestAPIService_GetOrder_OK() {
 	oc.Equal(oc.testOrder, &got)
 }
 
+func (oc *OrdersControllersTestSuite) TestAPIService_GetOrder_WithETA() {
+	order := *oc.testOrder
+	oc.ordersDAOMock.On("Get", oc.testOrder.ID).Return(&order, nil)
+	oc.api.OrdersDAO = oc.ordersDAOMock
+	couriersDAOMock := new(mocks.CouriersDAOMock)
+	couriersDAOMock.On("GetByID", oc.testOrder.CourierID).Return(&models.Courier{
+		ID:       oc.testOrder.CourierID,
+		Location: &models.Location{Point: elastic.GeoPointFromLatLon(20, 19.99)},
+	}, nil)
+	oc.api.CouriersDAO = couriersDAOMock
+	oc.api.ETA = services.NewETAService(services.NewMemoryRouteDAO(zap.NewNop()), services.NewStraightLineDistance(1), 0, 5, 0)
+
+	url := fmt.Sprintf("/couriers/%s/orders/%s", oc.testOrder.CourierID, oc.testOrder.ID)
+	req, _ := http.NewRequest("GET", url, nil)
+	w := httptest.NewRecorder()
+	oc.router.ServeHTTP(w, req)
+	oc.Equal(http.StatusOK, w.Code)
+
+	var got models.Order
+	oc.NoError(json.Unmarshal(w.Body.Bytes(), &got))
+	if oc.NotNil(got.ETA) {
+		oc.InDelta(1045, got.ETA.Distance, 5)
+		oc.Equal(models.ETASpeedDefault, got.ETA.SpeedSource)
+		oc.Equal(got.ETA.ComputedAt+got.ETA.Duration, got.ETA.ArrivalAt)
+	}
+}
+
 func (oc *OrdersControllersTestSuite) TestAPIService_GetOrder_NotFound() {
 	oc.ordersDAOMock.On("Get", oc.testOrder.ID).Return(oc.testOrder, &models.ErrEntityNotFound)
 	oc.api.OrdersDAO = oc.ordersDAOMock
@@ -270,7 +307,7 @@ func (oc *OrdersControllersTestSuite) TestAPIService_UpdateOrder_UnexpectedError
 func (oc *OrdersControllersTestSuite) TestAPIService_UpdateOrder_OK_If_Resolver_Failed() {
 	oc.testOrder.Destination = *oc.testOrderUpdate.Destination
 	oc.ordersDAOMock.On("Update", mock.Anything).Return(oc.testOrder, nil)
-	oc.ordersDAOMock.On("GetOrdersForCourier", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(models.Orders{oc.testOrder}, nil)
+	oc.ordersDAOMock.On("GetOrdersForCourier", mock.Anything, mock.Anything).Return(&models.OrdersPage{Orders: models.Orders{oc.testOrder}, Total: 1}, nil)
 	oc.api.OrdersDAO = oc.ordersDAOMock
 
 	w := httptest.NewRecorder()
@@ -287,7 +324,7 @@ func (oc *OrdersControllersTestSuite) TestAPIService_UpdateOrder_OK_If_Resolver_
 }
 
 func (oc *OrdersControllersTestSuite) TestAPIService_DeleteOrder_NoContent() {
-
+	oc.mockGetOrder()
 	oc.ordersDAOMock.On("Delete", oc.testOrder.ID).Return(nil)
 	oc.geoRouteMock.On("DeleteCourier", oc.testOrder.CourierID).Return(nil)
 	oc.api.OrdersDAO = oc.ordersDAOMock
@@ -302,7 +339,7 @@ func (oc *OrdersControllersTestSuite) TestAPIService_DeleteOrder_NoContent() {
 }
 
 func (oc *OrdersControllersTestSuite) TestAPIService_DeleteOrder_NotFound() {
-
+	oc.mockGetOrder()
 	oc.ordersDAOMock.On("Delete", oc.testOrder.ID).Return(&models.ErrEntityNotFound)
 	oc.api.OrdersDAO = oc.ordersDAOMock
 
@@ -319,7 +356,7 @@ func (oc *OrdersControllersTestSuite) TestAPIService_DeleteOrder_NotFound() {
 }
 
 func (oc *OrdersControllersTestSuite) TestAPIService_DeleteOrder_UnexpectedError() {
-
+	oc.mockGetOrder()
 	oc.ordersDAOMock.On("Delete", oc.testOrder.ID).Return(errors.New("unexpected"))
 	oc.api.OrdersDAO = oc.ordersDAOMock
 
@@ -335,13 +372,55 @@ func (oc *OrdersControllersTestSuite) TestAPIService_DeleteOrder_UnexpectedError
 	oc.Equal(models.ErrServerError.Code, got.Code)
 }
 
+func (oc *OrdersControllersTestSuite) TestAPIService_DeleteOrder_OtherCourier() {
+	oc.mockGetOrder()
+	oc.api.OrdersDAO = oc.ordersDAOMock
+
+	w := httptest.NewRecorder()
+	url := fmt.Sprintf("/couriers/%s/orders/%s", "770e8400-e29b-41d4-a716-446655440000", oc.testOrder.ID)
+	req, _ := http.NewRequest("DELETE", url, nil)
+	oc.router.ServeHTTP(w, req)
+
+	oc.Equal(http.StatusNotFound, w.Code)
+	oc.ordersDAOMock.AssertNotCalled(oc.T(), "Delete", oc.testOrder.ID)
+	oc.ordersTrackerMock.AssertNotCalled(oc.T(), "DecAndGet", mock.Anything)
+}
+
+func (oc *OrdersControllersTestSuite) TestAPIService_DeleteOrder_DeletesRouteOfLastOrder() {
+	oc.mockGetOrder()
+	oc.ordersDAOMock.On("Delete", oc.testOrder.ID).Return(nil)
+	tracker := new(mocks.OrdersCountTrackerMock)
+	tracker.On("DecAndGet", oc.testOrder.CourierID).Return(0, nil)
+	oc.geoRouteMock.On("DeleteCourier", oc.testOrder.CourierID).Return(nil)
+	oc.api.OrdersDAO = oc.ordersDAOMock
+	oc.api.CourierRouteDAO = oc.geoRouteMock
+	oc.api.OrdersCountTracker = tracker
+
+	w := httptest.NewRecorder()
+	url := fmt.Sprintf("/couriers/%s/orders/%s", oc.testOrder.CourierID, oc.testOrder.ID)
+	req, _ := http.NewRequest("DELETE", url, nil)
+	oc.router.ServeHTTP(w, req)
+
+	oc.Equal(http.StatusNoContent, w.Code)
+	tracker.AssertExpectations(oc.T())
+	oc.geoRouteMock.AssertCalled(oc.T(), "DeleteCourier", oc.testOrder.CourierID)
+}
+
+// mockGetOrder returns a copy of testOrder, handlers change the order they get
+func (oc *OrdersControllersTestSuite) mockGetOrder() *models.Order {
+	order := *oc.testOrder
+	oc.ordersDAOMock.On("Get", oc.testOrder.ID).Return(&order, nil)
+	return &order
+}
+
 func toByteReader(source interface{}) *bytes.Reader {
 	bin, _ := json.Marshal(source)
 	return bytes.NewReader(bin)
 }
 
 func (oc *OrdersControllersTestSuite) TestAPIService_GetOrdersForCourier_OK() {
-	oc.ordersDAOMock.On("GetOrdersForCourier", oc.testOrder.CourierID, int64(0), parameters.WithUpperThreshold, parameters.IncludeDelivered).Return(models.Orders{oc.testOrder}, nil)
+	oc.ordersDAOMock.On("GetOrdersForCourier", oc.testOrder.CourierID, &models.OrdersQuery{Limit: parameters.DefaultOrdersLimit}).
+		Return(&models.OrdersPage{Orders: models.Orders{oc.testOrder}, Total: 1}, nil)
 	oc.api.OrdersDAO = oc.ordersDAOMock
 
 	w := httptest.NewRecorder()
@@ -374,3 +453,886 @@ func (oc *OrdersControllersTestSuite) TestAPIService_SuggestCouriers_OK() {
 	oc.Equal(http.StatusOK, w.Code)
 	oc.Contains(got, oc.testCourier)
 }
+
+// transitedOrder returns the test order after the transition to status.
+func (oc *OrdersControllersTestSuite) transitedOrder(status string) *models.Order {
+	order := *oc.testOrder
+	order.StartStatus()
+	oc.Require().NoError(order.ApplyTransition(status, "courier", "", 200))
+	return &order
+}
+
+func (oc *OrdersControllersTestSuite) deliverLastOrder(archive *mocks.RouteArchiveMock) *httptest.ResponseRecorder {
+	delivered := oc.transitedOrder(models.OrderDelivered)
+	reportedDeliveredAt := int64(200000)
+	oc.ordersDAOMock.On("Transition", oc.testOrder.ID, oc.testOrder.CourierID, mock.Anything).Return(delivered, nil)
+	oc.ordersDAOMock.On("Update", mock.Anything).Return(delivered, nil)
+	tracker := new(mocks.OrdersCountTrackerMock)
+	tracker.On("DecAndGet", oc.testOrder.CourierID).Return(0, nil)
+	oc.geoRouteMock.On("GetRoute", oc.testOrder.CourierID, &models.RouteQuery{}).Return(&models.RoutePage{Points: models.Points{
+		{Point: elastic.GeoPointFromLatLon(10, 10), Ts: 150},
+		{Point: elastic.GeoPointFromLatLon(20, 20), Ts: 190},
+	}}, nil)
+	oc.geoRouteMock.On("DeleteCourier", oc.testOrder.CourierID).Return(nil)
+	oc.api.OrdersDAO = oc.ordersDAOMock
+	oc.api.CourierRouteDAO = oc.geoRouteMock
+	oc.api.OrdersCountTracker = tracker
+	oc.api.RouteArchive = archive
+
+	w := httptest.NewRecorder()
+	url := fmt.Sprintf("/couriers/%s/orders/%s", oc.testOrder.CourierID, oc.testOrder.ID)
+	req, _ := http.NewRequest("PUT", url, toByteReader(&models.OrderUpdate{DeliveredAt: &reportedDeliveredAt}))
+	oc.router.ServeHTTP(w, req)
+	return w
+}
+
+func (oc *OrdersControllersTestSuite) TestAPIService_UpdateOrder_ArchivesRouteOnLastDelivery() {
+	archive := new(mocks.RouteArchiveMock)
+	archive.On("Save", mock.MatchedBy(func(route *models.ArchivedRoute) bool {
+		return route.CourierID == oc.testOrder.CourierID &&
+			route.StartedAt == 150 && route.FinishedAt == 190 && len(route.GeoHistory) == 2
+	})).Return(nil)
+
+	w := oc.deliverLastOrder(archive)
+
+	oc.Equal(http.StatusOK, w.Code)
+	archive.AssertExpectations(oc.T())
+	oc.geoRouteMock.AssertCalled(oc.T(), "DeleteCourier", oc.testOrder.CourierID)
+}
+
+func (oc *OrdersControllersTestSuite) TestAPIService_UpdateOrder_KeepsRouteIfArchiveFailed() {
+	archive := new(mocks.RouteArchiveMock)
+	archive.On("Save", mock.Anything).Return(errors.New("test error"))
+
+	w := oc.deliverLastOrder(archive)
+
+	oc.Equal(http.StatusOK, w.Code)
+	oc.geoRouteMock.AssertNotCalled(oc.T(), "DeleteCourier", oc.testOrder.CourierID)
+}
+
+func (oc *OrdersControllersTestSuite) TestAPIService_UpdateOrder_DeliveryUsesServerTime() {
+	deliveredAt := time.Now().Unix()*1000 + 3600*1000
+	delivered := oc.transitedOrder(models.OrderDelivered)
+	oc.ordersDAOMock.On("Transition", oc.testOrder.ID, oc.testOrder.CourierID, mock.Anything).Return(delivered, nil)
+	oc.ordersDAOMock.On("Update", mock.Anything).Return(delivered, nil)
+	oc.api.OrdersDAO = oc.ordersDAOMock
+	oc.api.CourierRouteDAO = oc.geoRouteMock
+
+	before := time.Now().Unix()
+	w := httptest.NewRecorder()
+	url := fmt.Sprintf("/couriers/%s/orders/%s", oc.testOrder.CourierID, oc.testOrder.ID)
+	req, _ := http.NewRequest("PUT", url, toByteReader(&models.OrderUpdate{DeliveredAt: &deliveredAt}))
+	oc.router.ServeHTTP(w, req)
+
+	oc.Equal(http.StatusOK, w.Code)
+	oc.ordersDAOMock.AssertCalled(oc.T(), "Transition", oc.testOrder.ID, oc.testOrder.CourierID,
+		mock.MatchedBy(func(change *models.OrderStatusChange) bool {
+			return change.To == models.OrderDelivered && change.At >= before && change.At <= time.Now().Unix()
+		}))
+	// the client clock is kept apart, so delivered_at is always in server seconds
+	oc.ordersDAOMock.AssertCalled(oc.T(), "Update", mock.MatchedBy(func(update *models.OrderUpdate) bool {
+		return update.DeliveredAt == nil && *update.ReportedDeliveredAt == deliveredAt && update.Status == nil
+	}))
+}
+
+func (oc *OrdersControllersTestSuite) TestAPIService_UpdateOrder_DeliveryByOtherCourier() {
+	deliveredAt := int64(200)
+	oc.ordersDAOMock.On("Transition", oc.testOrder.ID, "770e8400-e29b-41d4-a716-446655440000", mock.Anything).
+		Return(nil, models.ErrEntityNotFound.SetParameter(oc.testOrder.ID))
+	oc.api.OrdersDAO = oc.ordersDAOMock
+
+	w := httptest.NewRecorder()
+	url := fmt.Sprintf("/couriers/%s/orders/%s", "770e8400-e29b-41d4-a716-446655440000", oc.testOrder.ID)
+	req, _ := http.NewRequest("PUT", url, toByteReader(&models.OrderUpdate{DeliveredAt: &deliveredAt}))
+	oc.router.ServeHTTP(w, req)
+
+	oc.Equal(http.StatusNotFound, w.Code)
+	oc.ordersDAOMock.AssertNotCalled(oc.T(), "Update", mock.Anything)
+}
+
+func (oc *OrdersControllersTestSuite) TestAPIService_GetArchivedRoutes_ByOrder() {
+	order := *oc.testOrder
+	order.StartStatus()
+	oc.Require().NoError(order.ApplyTransition(models.OrderDelivered, order.CourierID, "", 300))
+	// the client clock does not bound the lookup
+	order.ReportedDeliveredAt = 280000
+	oc.ordersDAOMock.On("Get", order.ID).Return(&order, nil)
+	routes := models.ArchivedRoutes{{ID: "route", CourierID: order.CourierID, StartedAt: 150, FinishedAt: 250}}
+	archive := new(mocks.RouteArchiveMock)
+	archive.On("Find", &models.RouteArchiveQuery{CourierID: order.CourierID, Since: 100, Until: 300}).Return(routes, nil)
+	oc.api.OrdersDAO = oc.ordersDAOMock
+	oc.api.RouteArchive = archive
+
+	w := httptest.NewRecorder()
+	url := fmt.Sprintf("/routes/archive?order_id=%s&until=1000", order.ID)
+	req, _ := http.NewRequest("GET", url, nil)
+	oc.router.ServeHTTP(w, req)
+
+	var got models.ArchivedRoutes
+	err := json.Unmarshal(w.Body.Bytes(), &got)
+
+	oc.NoError(err)
+	oc.Equal(http.StatusOK, w.Code)
+	oc.Equal(routes, got)
+}
+
+func (oc *OrdersControllersTestSuite) TestAPIService_GetArchivedRoutes_InvalidWindow() {
+	archive := new(mocks.RouteArchiveMock)
+	oc.api.RouteArchive = archive
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/routes/archive?since=20&until=10", nil)
+	oc.router.ServeHTTP(w, req)
+
+	oc.Equal(http.StatusBadRequest, w.Code)
+	archive.AssertNotCalled(oc.T(), "Find", mock.Anything)
+}
+
+func (oc *OrdersControllersTestSuite) TestAPIService_CreateOrder_KeepsRouteOfCarriedOrders() {
+	oc.ordersDAOMock.On("Create", mock.Anything).Return(oc.testOrder, nil)
+	tracker := new(mocks.OrdersCountTrackerMock)
+	tracker.On("IncAndGet", oc.testOrder.CourierID).Return(2, nil)
+	oc.api.OrdersDAO = oc.ordersDAOMock
+	oc.api.CourierRouteDAO = oc.geoRouteMock
+	oc.api.OrdersCountTracker = tracker
+
+	w := httptest.NewRecorder()
+	url := fmt.Sprintf("/couriers/%s/orders", oc.testOrder.CourierID)
+	req, _ := http.NewRequest("POST", url, toByteReader(oc.testOrderCreate))
+	oc.router.ServeHTTP(w, req)
+
+	oc.Equal(http.StatusCreated, w.Code)
+	oc.geoRouteMock.AssertNotCalled(oc.T(), "CreateCourier", mock.Anything)
+}
+
+func (oc *OrdersControllersTestSuite) TestAPIService_GetOrderTrack_OK() {
+	order := *oc.testOrder
+	order.CreatedAt = 100
+	order.StartStatus()
+	oc.Require().NoError(order.ApplyTransition(models.OrderDelivered, order.CourierID, "", 300))
+	// the client clock does not bound the track
+	order.ReportedDeliveredAt = 280000
+	oc.ordersDAOMock.On("Get", order.ID).Return(&order, nil)
+	oc.geoRouteMock.On("GetRoute", order.CourierID, &models.RouteQuery{Since: 99, Until: 300}).
+		Return(&models.RoutePage{Points: models.Points{
+			{Point: elastic.GeoPointFromLatLon(12, 12), Ts: 250},
+		}}, nil)
+	archive := new(mocks.RouteArchiveMock)
+	archive.On("Find", mock.Anything).Return(models.ArchivedRoutes{{
+		CourierID: order.CourierID,
+		GeoHistory: models.Points{
+			{Point: elastic.GeoPointFromLatLon(10, 10), Ts: 50},
+			{Point: elastic.GeoPointFromLatLon(11, 11), Ts: 100},
+			{Point: elastic.GeoPointFromLatLon(13, 13), Ts: 350},
+		},
+	}}, nil)
+	oc.api.OrdersDAO = oc.ordersDAOMock
+	oc.api.CourierRouteDAO = oc.geoRouteMock
+	oc.api.RouteArchive = archive
+
+	w := httptest.NewRecorder()
+	url := fmt.Sprintf("/couriers/%s/orders/%s/track", order.CourierID, order.ID)
+	req, _ := http.NewRequest("GET", url, nil)
+	oc.router.ServeHTTP(w, req)
+
+	var got models.RouteResponse
+	err := json.Unmarshal(w.Body.Bytes(), &got)
+
+	oc.NoError(err)
+	oc.Equal(http.StatusOK, w.Code)
+	if oc.Len(got.GeoHistory, 2) {
+		oc.Equal(uint64(100), got.GeoHistory[0].Ts)
+		oc.Equal(uint64(250), got.GeoHistory[1].Ts)
+	}
+}
+
+func (oc *OrdersControllersTestSuite) TestAPIService_GetOrderTrack_Handover() {
+	previousCourierID := "770e8400-e29b-41d4-a716-446655440000"
+	order := *oc.testOrder
+	order.CourierID = previousCourierID
+	order.CreatedAt = 100
+	order.StartStatus()
+	oc.Require().NoError(order.Reassign(oc.testOrder.CourierID, "broke down", 200))
+	oc.Require().NoError(order.ApplyTransition(models.OrderDelivered, order.CourierID, "", 300))
+	oc.ordersDAOMock.On("Get", order.ID).Return(&order, nil)
+	oc.geoRouteMock.On("GetRoute", previousCourierID, &models.RouteQuery{Since: 99, Until: 200}).
+		Return(&models.RoutePage{Points: models.Points{
+			{Point: elastic.GeoPointFromLatLon(10, 10), Ts: 150},
+		}}, nil)
+	oc.geoRouteMock.On("GetRoute", order.CourierID, &models.RouteQuery{Since: 199, Until: 300}).
+		Return(&models.RoutePage{Points: models.Points{
+			{Point: elastic.GeoPointFromLatLon(11, 11), Ts: 250},
+		}}, nil)
+	oc.api.OrdersDAO = oc.ordersDAOMock
+	oc.api.CourierRouteDAO = oc.geoRouteMock
+
+	w := httptest.NewRecorder()
+	url := fmt.Sprintf("/couriers/%s/orders/%s/track", order.CourierID, order.ID)
+	req, _ := http.NewRequest("GET", url, nil)
+	oc.router.ServeHTTP(w, req)
+
+	var got models.RouteResponse
+	oc.NoError(json.Unmarshal(w.Body.Bytes(), &got))
+	oc.Equal(http.StatusOK, w.Code)
+	if oc.Len(got.GeoHistory, 2) {
+		oc.Equal(uint64(150), got.GeoHistory[0].Ts)
+		oc.Equal(uint64(250), got.GeoHistory[1].Ts)
+	}
+}
+
+func (oc *OrdersControllersTestSuite) TestAPIService_GetOrderTrack_OtherCourier() {
+	order := *oc.testOrder
+	order.CourierID = "770e8400-e29b-41d4-a716-446655440000"
+	oc.ordersDAOMock.On("Get", order.ID).Return(&order, nil)
+	oc.api.OrdersDAO = oc.ordersDAOMock
+	oc.api.CourierRouteDAO = oc.geoRouteMock
+
+	w := httptest.NewRecorder()
+	url := fmt.Sprintf("/couriers/%s/orders/%s/track", oc.testOrder.CourierID, order.ID)
+	req, _ := http.NewRequest("GET", url, nil)
+	oc.router.ServeHTTP(w, req)
+
+	oc.Equal(http.StatusNotFound, w.Code)
+	oc.geoRouteMock.AssertNotCalled(oc.T(), "GetRoute", mock.Anything, mock.Anything)
+}
+
+// newWebhookReceiver subscribes a local receiver to every event api dispatches. It returns the events
+// received with a valid signature, once every dispatched one is handled, and a function stopping both.
+func newWebhookReceiver(t *testing.T, api *APIService) (func() []*models.WebhookEvent, func()) {
+	events := make([]*models.WebhookEvent, 0)
+	var mu sync.Mutex
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		timestamp, _ := strconv.ParseInt(r.Header.Get(services.WebhookTimestampHeader), 10, 64)
+		if r.Header.Get(services.WebhookSignatureHeader) != services.SignWebhook("secret", timestamp, body) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var event models.WebhookEvent
+		if err := json.Unmarshal(body, &event); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		events = append(events, &event)
+		mu.Unlock()
+	}))
+	dispatcher := services.NewWebhookDispatcher(services.NewMemoryWebhookStore(), zap.NewNop(), 1, 10, 1, 0, 0, 0)
+	_, err := dispatcher.Create(&models.WebhookSubscriptionCreate{URL: receiver.URL, Secret: "secret"})
+	require.NoError(t, err)
+	api.Webhooks = dispatcher
+	received := func() []*models.WebhookEvent {
+		dispatcher.Wait()
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]*models.WebhookEvent{}, events...)
+	}
+	stop := func() {
+		api.Webhooks = nil
+		dispatcher.Close()
+		receiver.Close()
+	}
+	return received, stop
+}
+
+func (oc *OrdersControllersTestSuite) TestAPIService_CreateOrder_DispatchesWebhook() {
+	received, stop := newWebhookReceiver(oc.T(), oc.api)
+	defer stop()
+	oc.ordersDAOMock.On("Create", mock.Anything).Return(oc.testOrder, nil)
+	oc.geoRouteMock.On("CreateCourier", mock.Anything).Return(nil)
+	oc.api.OrdersDAO = oc.ordersDAOMock
+	oc.api.CourierRouteDAO = oc.geoRouteMock
+
+	w := httptest.NewRecorder()
+	url := fmt.Sprintf("/couriers/%s/orders", oc.testOrder.CourierID)
+	req, _ := http.NewRequest("POST", url, toByteReader(oc.testOrderCreate))
+	oc.router.ServeHTTP(w, req)
+	oc.Equal(http.StatusCreated, w.Code)
+
+	events := received()
+	if oc.Len(events, 1) {
+		oc.Equal(models.WebhookOrderCreated, events[0].Type)
+		var order models.Order
+		oc.NoError(json.Unmarshal(events[0].Data, &order))
+		oc.Equal(oc.testOrder, &order)
+	}
+}
+
+func (oc *OrdersControllersTestSuite) TestAPIService_AssignNewCourier_DispatchesWebhook() {
+	received, stop := newWebhookReceiver(oc.T(), oc.api)
+	defer stop()
+	newCourierID := "770e8400-e29b-41d4-a716-446655440000"
+	reassigned := *oc.testOrder
+	oc.Require().NoError(reassigned.Reassign(newCourierID, "", 200))
+	oc.ordersDAOMock.On("Reassign", oc.testOrder.ID, newCourierID, "").Return(&reassigned, nil)
+	oc.geoRouteMock.On("CreateCourier", newCourierID).Return(nil)
+	oc.api.OrdersDAO = oc.ordersDAOMock
+	oc.api.CourierRouteDAO = oc.geoRouteMock
+
+	w := httptest.NewRecorder()
+	url := fmt.Sprintf("/couriers/%s/orders/%s", newCourierID, oc.testOrder.ID)
+	req, _ := http.NewRequest("PATCH", url, nil)
+	oc.router.ServeHTTP(w, req)
+	oc.Equal(http.StatusOK, w.Code)
+
+	events := received()
+	if oc.Len(events, 1) {
+		oc.Equal(models.WebhookOrderReassigned, events[0].Type)
+	}
+}
+
+func (oc *OrdersControllersTestSuite) TestAPIService_DeleteOrder_DispatchesWebhook() {
+	received, stop := newWebhookReceiver(oc.T(), oc.api)
+	defer stop()
+	oc.mockGetOrder()
+	oc.ordersDAOMock.On("Delete", oc.testOrder.ID).Return(nil)
+	oc.api.OrdersDAO = oc.ordersDAOMock
+
+	w := httptest.NewRecorder()
+	url := fmt.Sprintf("/couriers/%s/orders/%s", oc.testOrder.CourierID, oc.testOrder.ID)
+	req, _ := http.NewRequest("DELETE", url, nil)
+	oc.router.ServeHTTP(w, req)
+	oc.Equal(http.StatusNoContent, w.Code)
+
+	events := received()
+	if oc.Len(events, 1) {
+		oc.Equal(models.WebhookOrderDeleted, events[0].Type)
+		var deleted models.WebhookDeleted
+		oc.NoError(json.Unmarshal(events[0].Data, &deleted))
+		oc.Equal(models.WebhookDeleted{ID: oc.testOrder.ID, CourierID: oc.testOrder.CourierID}, deleted)
+	}
+}
+
+func (oc *OrdersControllersTestSuite) getChanges(query string) (*models.ChangesResponse, int) {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/changes"+query, nil)
+	oc.router.ServeHTTP(w, req)
+	var got models.ChangesResponse
+	if w.Code == http.StatusOK {
+		oc.NoError(json.Unmarshal(w.Body.Bytes(), &got))
+	}
+	return &got, w.Code
+}
+
+func (oc *OrdersControllersTestSuite) TestAPIService_CreateOrder_RecordsChanges() {
+	oc.api.Changes = services.NewMemoryChangeLog()
+	oc.ordersDAOMock.On("Create", mock.Anything).Return(oc.testOrder, nil)
+	oc.mockGetOrder()
+	oc.ordersDAOMock.On("Delete", oc.testOrder.ID).Return(nil)
+	oc.geoRouteMock.On("CreateCourier", mock.Anything).Return(nil)
+	oc.api.OrdersDAO = oc.ordersDAOMock
+	oc.api.CourierRouteDAO = oc.geoRouteMock
+
+	w := httptest.NewRecorder()
+	url := fmt.Sprintf("/couriers/%s/orders", oc.testOrder.CourierID)
+	req, _ := http.NewRequest("POST", url, toByteReader(oc.testOrderCreate))
+	oc.router.ServeHTTP(w, req)
+	oc.Equal(http.StatusCreated, w.Code)
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("DELETE", fmt.Sprintf("%s/%s", url, oc.testOrder.ID), nil)
+	oc.router.ServeHTTP(w, req)
+	oc.Equal(http.StatusNoContent, w.Code)
+
+	page, code := oc.getChanges("?limit=2")
+	oc.Require().Equal(http.StatusOK, code)
+	oc.Require().Len(page.Changes, 2)
+	oc.True(page.HasMore)
+	oc.Equal(models.ChangeOrder, page.Changes[0].Entity)
+	oc.Equal(models.ChangeCreated, page.Changes[0].Op)
+	var order models.Order
+	oc.NoError(json.Unmarshal(page.Changes[0].Data, &order))
+	oc.Equal(oc.testOrder, &order)
+	oc.Equal(models.ChangeRoute, page.Changes[1].Entity)
+	oc.Equal(oc.testOrder.CourierID, page.Changes[1].ID)
+
+	page, code = oc.getChanges("?cursor=" + page.NextCursor)
+	oc.Require().Equal(http.StatusOK, code)
+	oc.Require().Len(page.Changes, 1)
+	oc.False(page.HasMore)
+	oc.Equal(models.ChangeDeleted, page.Changes[0].Op)
+	oc.Equal(oc.testOrder.ID, page.Changes[0].ID)
+
+	cursor := page.NextCursor
+	page, code = oc.getChanges("?cursor=" + cursor)
+	oc.Equal(http.StatusOK, code)
+	oc.Empty(page.Changes)
+	oc.Equal(cursor, page.NextCursor)
+}
+
+func (oc *OrdersControllersTestSuite) TestAPIService_GetChanges_InvalidCursor() {
+	oc.api.Changes = services.NewMemoryChangeLog()
+	_, code := oc.getChanges("?cursor=abc")
+	oc.Equal(http.StatusBadRequest, code)
+	_, code = oc.getChanges("?limit=100000")
+	oc.Equal(http.StatusBadRequest, code)
+}
+
+func (oc *OrdersControllersTestSuite) transitionOrder(transition *models.OrderTransition) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	url := fmt.Sprintf("/couriers/%s/orders/%s/transitions", oc.testOrder.CourierID, oc.testOrder.ID)
+	req, _ := http.NewRequest("POST", url, toByteReader(transition))
+	oc.router.ServeHTTP(w, req)
+	return w
+}
+
+func (oc *OrdersControllersTestSuite) TestAPIService_TransitionOrder_OK() {
+	received, stop := newWebhookReceiver(oc.T(), oc.api)
+	defer stop()
+	order := oc.transitedOrder(models.OrderCancelled)
+	order.StatusHistory[1].Actor = "dispatcher"
+	order.StatusHistory[1].Reason = "customer refused"
+	oc.ordersDAOMock.On("Transition", oc.testOrder.ID, oc.testOrder.CourierID, mock.MatchedBy(func(change *models.OrderStatusChange) bool {
+		return change.To == models.OrderCancelled && change.Actor == "dispatcher" && change.Reason == "customer refused"
+	})).Return(order, nil)
+	oc.geoRouteMock.On("DeleteCourier", oc.testOrder.CourierID).Return(nil)
+	oc.api.OrdersDAO = oc.ordersDAOMock
+	oc.api.CourierRouteDAO = oc.geoRouteMock
+
+	w := oc.transitionOrder(&models.OrderTransition{Status: models.OrderCancelled, Actor: "dispatcher", Reason: "customer refused"})
+
+	oc.Require().Equal(http.StatusOK, w.Code)
+	var got models.Order
+	oc.NoError(json.Unmarshal(w.Body.Bytes(), &got))
+	oc.Equal(models.OrderCancelled, got.Status)
+	if oc.Len(got.StatusHistory, 2) {
+		oc.Equal(models.OrderAssigned, got.StatusHistory[1].From)
+		oc.Equal("dispatcher", got.StatusHistory[1].Actor)
+		oc.Equal("customer refused", got.StatusHistory[1].Reason)
+	}
+	oc.ordersTrackerMock.AssertCalled(oc.T(), "DecAndGet", oc.testOrder.CourierID)
+	events := received()
+	if oc.Len(events, 1) {
+		oc.Equal(models.WebhookOrderStatusChanged, events[0].Type)
+	}
+}
+
+func (oc *OrdersControllersTestSuite) TestAPIService_TransitionOrder_KeepsActiveCount() {
+	order := oc.transitedOrder(models.OrderPickedUp)
+	oc.ordersDAOMock.On("Transition", oc.testOrder.ID, oc.testOrder.CourierID, mock.Anything).Return(order, nil)
+	oc.api.OrdersDAO = oc.ordersDAOMock
+
+	w := oc.transitionOrder(&models.OrderTransition{Status: models.OrderPickedUp, Actor: "courier"})
+
+	oc.Equal(http.StatusOK, w.Code)
+	oc.ordersTrackerMock.AssertNotCalled(oc.T(), "DecAndGet", mock.Anything)
+}
+
+func (oc *OrdersControllersTestSuite) TestAPIService_TransitionOrder_ChangeNotRecorded() {
+	changes := new(mocks.ChangeLogMock)
+	changes.On("Append", mock.Anything).Return(errors.New("test error"))
+	oc.api.Changes = changes
+	order := oc.transitedOrder(models.OrderCancelled)
+	oc.ordersDAOMock.On("Transition", oc.testOrder.ID, oc.testOrder.CourierID, mock.Anything).Return(order, nil)
+	oc.api.OrdersDAO = oc.ordersDAOMock
+
+	w := oc.transitionOrder(&models.OrderTransition{Status: models.OrderCancelled, Actor: "dispatcher"})
+
+	oc.Equal(http.StatusInternalServerError, w.Code)
+	// the order is stored, so it stops being counted all the same
+	oc.ordersTrackerMock.AssertCalled(oc.T(), "DecAndGet", oc.testOrder.CourierID)
+}
+
+func (oc *OrdersControllersTestSuite) TestAPIService_TransitionOrder_InvalidTransition() {
+	oc.ordersDAOMock.On("Transition", oc.testOrder.ID, oc.testOrder.CourierID, mock.Anything).
+		Return(nil, models.ErrInvalidOrderTransition.SetParameter(models.OrderPickedUp))
+	oc.api.OrdersDAO = oc.ordersDAOMock
+
+	w := oc.transitionOrder(&models.OrderTransition{Status: models.OrderPickedUp, Actor: "courier"})
+
+	var got models.Error
+	oc.NoError(json.Unmarshal(w.Body.Bytes(), &got))
+	oc.Equal(http.StatusConflict, w.Code)
+	oc.Equal(models.ErrInvalidOrderTransition.Code, got.Code)
+	// a rejected transition changed nothing, so the order is still counted
+	oc.ordersTrackerMock.AssertNotCalled(oc.T(), "DecAndGet", mock.Anything)
+}
+
+func (oc *OrdersControllersTestSuite) TestAPIService_TransitionOrder_UnknownStatus() {
+	oc.api.OrdersDAO = oc.ordersDAOMock
+
+	w := oc.transitionOrder(&models.OrderTransition{Status: "lost", Actor: "courier"})
+
+	oc.Equal(http.StatusBadRequest, w.Code)
+}
+
+func (oc *OrdersControllersTestSuite) TestAPIService_GetOrdersForCourier_ByStatus() {
+	statuses := []string{models.OrderAssigned, models.OrderPickedUp, models.OrderInTransit}
+	oc.ordersDAOMock.On("GetOrdersForCourier", oc.testOrder.CourierID,
+		&models.OrdersQuery{Statuses: statuses, Limit: parameters.DefaultOrdersLimit}).
+		Return(&models.OrdersPage{Orders: models.Orders{oc.testOrder}, Total: 1}, nil)
+	oc.api.OrdersDAO = oc.ordersDAOMock
+
+	w := httptest.NewRecorder()
+	url := fmt.Sprintf("/couriers/%s/orders?status=assigned,picked_up&status=in_transit", oc.testOrder.CourierID)
+	req, _ := http.NewRequest("GET", url, nil)
+	oc.router.ServeHTTP(w, req)
+	oc.Equal(http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	url = fmt.Sprintf("/couriers/%s/orders?status=lost", oc.testOrder.CourierID)
+	req, _ = http.NewRequest("GET", url, nil)
+	oc.router.ServeHTTP(w, req)
+	oc.Equal(http.StatusBadRequest, w.Code)
+}
+
+func (oc *OrdersControllersTestSuite) TestAPIService_SearchOrders_Circle() {
+	oc.ordersDAOMock.On("GetByCircleField",
+		&models.CircleField{Center: elastic.GeoPointFromLatLon(20, 20), Radius: 2000},
+		&models.OrderSearch{
+			Point:     models.OrderPointSource,
+			CourierID: oc.testOrder.CourierID,
+			Statuses:  []string{models.OrderAssigned, models.OrderPickedUp},
+			Since:     100,
+			Until:     200,
+		}).Return(models.Orders{oc.testOrder}, nil)
+	oc.api.OrdersDAO = oc.ordersDAOMock
+
+	w := httptest.NewRecorder()
+	url := fmt.Sprintf("/orders?lat=20&lon=20&radius=2000&point=source&courier_id=%s&status=assigned,picked_up&since=100&until=200",
+		oc.testOrder.CourierID)
+	req, _ := http.NewRequest("GET", url, nil)
+	oc.router.ServeHTTP(w, req)
+
+	var got models.Orders
+	oc.NoError(json.Unmarshal(w.Body.Bytes(), &got))
+	oc.Equal(http.StatusOK, w.Code)
+	oc.Contains(got, oc.testOrder)
+}
+
+func (oc *OrdersControllersTestSuite) TestAPIService_SearchOrders_Box() {
+	oc.ordersDAOMock.On("GetByBoxField", mock.Anything, &models.OrderSearch{Size: 5}).Return(models.Orders{}, nil)
+	oc.api.OrdersDAO = oc.ordersDAOMock
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/orders?top_left_lat=30&top_left_lon=10&bottom_right_lat=10&bottom_right_lon=30&size=5", nil)
+	oc.router.ServeHTTP(w, req)
+
+	oc.Equal(http.StatusOK, w.Code)
+	oc.ordersDAOMock.AssertCalled(oc.T(), "GetByBoxField", mock.Anything, &models.OrderSearch{Size: 5})
+}
+
+func (oc *OrdersControllersTestSuite) TestAPIService_SearchOrders_InvalidParameters() {
+	oc.api.OrdersDAO = oc.ordersDAOMock
+	for _, query := range []string{"point=pickup", "status=lost", "courier_id=abc", "since=200&until=100"} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/orders?lat=20&lon=20&radius=100&"+query, nil)
+		oc.router.ServeHTTP(w, req)
+		oc.Equal(http.StatusBadRequest, w.Code, query)
+	}
+}
+
+func (oc *OrdersControllersTestSuite) TestAPIService_GetOrdersForCourier_Page() {
+	courierPoint := elastic.GeoPointFromLatLon(10, 10)
+	couriersDAOMock := new(mocks.CouriersDAOMock)
+	couriersDAOMock.On("GetByID", oc.testOrder.CourierID).Return(&models.Courier{
+		ID:       oc.testOrder.CourierID,
+		Location: &models.Location{Point: courierPoint},
+	}, nil)
+	oc.api.CouriersDAO = couriersDAOMock
+	after := &models.OrdersCursor{Value: 1500.5, ID: "550e8400-e29b-41d4-a716-446655440001"}
+	next := &models.OrdersCursor{Value: 2000, ID: oc.testOrder.ID}
+	oc.ordersDAOMock.On("GetOrdersForCourier", oc.testOrder.CourierID, &models.OrdersQuery{
+		Sort:  models.OrdersSortDistance,
+		Desc:  true,
+		From:  courierPoint,
+		Limit: 1,
+		After: after,
+	}).Return(&models.OrdersPage{Orders: models.Orders{oc.testOrder}, Total: 7, Next: next}, nil)
+	oc.api.OrdersDAO = oc.ordersDAOMock
+
+	w := httptest.NewRecorder()
+	url := fmt.Sprintf("/couriers/%s/orders?sort=distance&desc=true&limit=1&cursor=%s", oc.testOrder.CourierID, after)
+	req, _ := http.NewRequest("GET", url, nil)
+	oc.router.ServeHTTP(w, req)
+
+	oc.Require().Equal(http.StatusOK, w.Code)
+	oc.Equal("7", w.Header().Get(TotalCountHeader))
+	oc.Equal(next.String(), w.Header().Get(NextCursorHeader))
+	var got models.Orders
+	oc.NoError(json.Unmarshal(w.Body.Bytes(), &got))
+	oc.Contains(got, oc.testOrder)
+}
+
+func (oc *OrdersControllersTestSuite) TestAPIService_GetOrdersForCourier_InvalidPage() {
+	couriersDAOMock := new(mocks.CouriersDAOMock)
+	couriersDAOMock.On("GetByID", oc.testOrder.CourierID).Return(&models.Courier{ID: oc.testOrder.CourierID}, nil)
+	oc.api.CouriersDAO = couriersDAOMock
+	oc.api.OrdersDAO = oc.ordersDAOMock
+	for _, query := range []string{"sort=price", "limit=-1", "limit=100000", "cursor=abc", "sort=distance"} {
+		w := httptest.NewRecorder()
+		url := fmt.Sprintf("/couriers/%s/orders?%s", oc.testOrder.CourierID, query)
+		req, _ := http.NewRequest("GET", url, nil)
+		oc.router.ServeHTTP(w, req)
+		oc.Equal(http.StatusBadRequest, w.Code, query)
+	}
+}
+
+func (oc *OrdersControllersTestSuite) claimOrder() *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	url := fmt.Sprintf("/couriers/%s/orders/%s/claim", oc.testOrder.CourierID, oc.testOrder.ID)
+	req, _ := http.NewRequest("POST", url, nil)
+	oc.router.ServeHTTP(w, req)
+	return w
+}
+
+func (oc *OrdersControllersTestSuite) TestAPIService_ClaimOrder_OK() {
+	received, stop := newWebhookReceiver(oc.T(), oc.api)
+	defer stop()
+	claimed := *oc.testOrder
+	claimed.CourierID = ""
+	claimed.StartStatus()
+	oc.Require().NoError(claimed.Claim(oc.testOrder.CourierID, 200))
+	oc.ordersDAOMock.On("Claim", oc.testOrder.ID, oc.testOrder.CourierID).Return(&claimed, nil)
+	oc.geoRouteMock.On("CreateCourier", oc.testOrder.CourierID).Return(nil)
+	oc.api.OrdersDAO = oc.ordersDAOMock
+	oc.api.CourierRouteDAO = oc.geoRouteMock
+
+	w := oc.claimOrder()
+
+	oc.Require().Equal(http.StatusOK, w.Code)
+	var got models.Order
+	oc.NoError(json.Unmarshal(w.Body.Bytes(), &got))
+	oc.Equal(oc.testOrder.CourierID, got.CourierID)
+	oc.Equal(models.OrderAssigned, got.Status)
+	oc.ordersTrackerMock.AssertCalled(oc.T(), "IncAndGet", oc.testOrder.CourierID)
+	oc.geoRouteMock.AssertCalled(oc.T(), "CreateCourier", oc.testOrder.CourierID)
+	events := received()
+	if oc.Len(events, 2) {
+		oc.Equal(models.WebhookOrderClaimed, events[0].Type)
+		oc.Equal(models.WebhookOrderStatusChanged, events[1].Type)
+	}
+}
+
+func (oc *OrdersControllersTestSuite) TestAPIService_ClaimOrder_AlreadyClaimed() {
+	oc.ordersDAOMock.On("Claim", oc.testOrder.ID, oc.testOrder.CourierID).
+		Return(nil, models.ErrOrderAlreadyClaimed.SetParameter(oc.testOrder.ID))
+	oc.api.OrdersDAO = oc.ordersDAOMock
+	oc.api.CourierRouteDAO = oc.geoRouteMock
+
+	w := oc.claimOrder()
+
+	oc.Equal(http.StatusConflict, w.Code)
+	oc.ordersTrackerMock.AssertNotCalled(oc.T(), "IncAndGet", mock.Anything)
+	oc.geoRouteMock.AssertNotCalled(oc.T(), "CreateCourier", mock.Anything)
+}
+
+func (oc *OrdersControllersTestSuite) TestAPIService_CreateOpenOrder_Created() {
+	open := *oc.testOrder
+	open.CourierID = ""
+	open.StartStatus()
+	oc.ordersDAOMock.On("Create", mock.MatchedBy(func(create *models.OrderCreate) bool {
+		return create.CourierID == nil
+	})).Return(&open, nil)
+	oc.api.OrdersDAO = oc.ordersDAOMock
+	oc.api.CourierRouteDAO = oc.geoRouteMock
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/orders", toByteReader(oc.testOrderCreate))
+	oc.router.ServeHTTP(w, req)
+
+	oc.Require().Equal(http.StatusCreated, w.Code)
+	var got models.Order
+	oc.NoError(json.Unmarshal(w.Body.Bytes(), &got))
+	oc.Equal(models.OrderUnassigned, got.Status)
+	oc.ordersTrackerMock.AssertNotCalled(oc.T(), "IncAndGet", mock.Anything)
+	oc.geoRouteMock.AssertNotCalled(oc.T(), "CreateCourier", mock.Anything)
+}
+
+func (oc *OrdersControllersTestSuite) TestAPIService_GetOpenOrders_OK() {
+	oc.ordersDAOMock.On("GetByCircleField",
+		&models.CircleField{Center: elastic.GeoPointFromLatLon(10, 10), Radius: 500},
+		&models.OrderSearch{Point: models.OrderPointSource, Statuses: []string{models.OrderUnassigned}, Size: 3},
+	).Return(models.Orders{oc.testOrder}, nil)
+	oc.api.OrdersDAO = oc.ordersDAOMock
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/orders/open?lat=10&lon=10&radius=500&size=3", nil)
+	oc.router.ServeHTTP(w, req)
+
+	oc.Require().Equal(http.StatusOK, w.Code)
+	var got models.Orders
+	oc.NoError(json.Unmarshal(w.Body.Bytes(), &got))
+	oc.Contains(got, oc.testOrder)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/orders/open?lat=10&lon=10", nil)
+	oc.router.ServeHTTP(w, req)
+	oc.Equal(http.StatusBadRequest, w.Code)
+}
+
+func (oc *OrdersControllersTestSuite) dispatchOrder(query string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	url := fmt.Sprintf("/orders/%s/dispatch?%s", oc.testOrder.ID, query)
+	req, _ := http.NewRequest("POST", url, nil)
+	oc.router.ServeHTTP(w, req)
+	return w
+}
+
+func (oc *OrdersControllersTestSuite) mockDispatcher() *mocks.DispatcherMock {
+	dispatcher := new(mocks.DispatcherMock)
+	dispatcher.On("Rank", mock.AnythingOfType("*models.Order")).Return(&models.DispatchRanking{
+		OrderID: oc.testOrder.ID,
+		Radius:  1000,
+		Candidates: []*models.DispatchCandidate{
+			{Courier: &models.Courier{ID: oc.testOrder.CourierID}, Distance: 100, Score: 0.1},
+			{Courier: &models.Courier{ID: "770e8400-e29b-41d4-a716-446655440000"}, Distance: 500, Score: 0.3},
+		},
+	}, nil)
+	oc.api.Dispatcher = dispatcher
+	return dispatcher
+}
+
+func (oc *OrdersControllersTestSuite) TestAPIService_DispatchOrder_DryRun() {
+	order := oc.mockGetOrder()
+	order.StartStatus()
+	oc.mockDispatcher()
+	oc.api.OrdersDAO = oc.ordersDAOMock
+
+	w := oc.dispatchOrder("dry_run=true")
+
+	oc.Require().Equal(http.StatusOK, w.Code)
+	var got models.DispatchRanking
+	oc.NoError(json.Unmarshal(w.Body.Bytes(), &got))
+	oc.True(got.DryRun)
+	oc.Nil(got.Assigned)
+	if oc.Len(got.Candidates, 2) {
+		oc.Equal(oc.testOrder.CourierID, got.Candidates[0].Courier.ID)
+	}
+	oc.ordersDAOMock.AssertNotCalled(oc.T(), "Claim", mock.Anything, mock.Anything)
+}
+
+func (oc *OrdersControllersTestSuite) TestAPIService_DispatchOrder_AssignsBest() {
+	order := oc.mockGetOrder()
+	order.CourierID = ""
+	order.StartStatus()
+	claimed := *order
+	oc.Require().NoError(claimed.Claim(oc.testOrder.CourierID, 200))
+	oc.ordersDAOMock.On("Claim", oc.testOrder.ID, oc.testOrder.CourierID).Return(&claimed, nil)
+	oc.geoRouteMock.On("CreateCourier", oc.testOrder.CourierID).Return(nil)
+	oc.mockDispatcher()
+	oc.api.OrdersDAO = oc.ordersDAOMock
+	oc.api.CourierRouteDAO = oc.geoRouteMock
+
+	w := oc.dispatchOrder("")
+
+	oc.Require().Equal(http.StatusOK, w.Code)
+	var got models.DispatchRanking
+	oc.NoError(json.Unmarshal(w.Body.Bytes(), &got))
+	oc.False(got.DryRun)
+	if oc.NotNil(got.Assigned) {
+		oc.Equal(oc.testOrder.CourierID, got.Assigned.CourierID)
+		oc.Equal(models.OrderAssigned, got.Assigned.Status)
+	}
+	oc.ordersTrackerMock.AssertCalled(oc.T(), "IncAndGet", oc.testOrder.CourierID)
+}
+
+func (oc *OrdersControllersTestSuite) TestAPIService_DispatchOrder_AlreadyAssigned() {
+	order := oc.mockGetOrder()
+	order.StartStatus()
+	dispatcher := oc.mockDispatcher()
+	oc.api.OrdersDAO = oc.ordersDAOMock
+
+	w := oc.dispatchOrder("")
+
+	oc.Equal(http.StatusConflict, w.Code)
+	dispatcher.AssertNotCalled(oc.T(), "Rank", mock.Anything)
+}
+
+func (oc *OrdersControllersTestSuite) TestAPIService_CreateOpenOrder_Dispatch() {
+	open := *oc.testOrder
+	open.CourierID = ""
+	open.StartStatus()
+	claimed := open
+	oc.Require().NoError(claimed.Claim(oc.testOrder.CourierID, 200))
+	oc.ordersDAOMock.On("Create", mock.Anything).Return(&open, nil)
+	oc.ordersDAOMock.On("Claim", oc.testOrder.ID, oc.testOrder.CourierID).Return(&claimed, nil)
+	oc.geoRouteMock.On("CreateCourier", oc.testOrder.CourierID).Return(nil)
+	oc.mockDispatcher()
+	oc.api.OrdersDAO = oc.ordersDAOMock
+	oc.api.CourierRouteDAO = oc.geoRouteMock
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/orders?dispatch=true", toByteReader(oc.testOrderCreate))
+	oc.router.ServeHTTP(w, req)
+
+	oc.Require().Equal(http.StatusCreated, w.Code)
+	var got models.Order
+	oc.NoError(json.Unmarshal(w.Body.Bytes(), &got))
+	oc.Equal(oc.testOrder.CourierID, got.CourierID)
+	oc.Equal(models.OrderAssigned, got.Status)
+}
+
+func (oc *OrdersControllersTestSuite) TestAPIService_AssignNewCourier_MovesCounters() {
+	newCourierID := "770e8400-e29b-41d4-a716-446655440000"
+	reassigned := *oc.testOrder
+	oc.Require().NoError(reassigned.Reassign(newCourierID, "shift ended", 200))
+	oc.ordersDAOMock.On("Reassign", oc.testOrder.ID, newCourierID, "shift ended").Return(&reassigned, nil)
+	tracker := new(mocks.OrdersCountTrackerMock)
+	tracker.On("DecAndGet", oc.testOrder.CourierID).Return(0, nil)
+	tracker.On("IncAndGet", newCourierID).Return(1, nil)
+	oc.geoRouteMock.On("DeleteCourier", oc.testOrder.CourierID).Return(nil)
+	oc.geoRouteMock.On("CreateCourier", newCourierID).Return(nil)
+	oc.api.OrdersDAO = oc.ordersDAOMock
+	oc.api.CourierRouteDAO = oc.geoRouteMock
+	oc.api.OrdersCountTracker = tracker
+
+	w := httptest.NewRecorder()
+	url := fmt.Sprintf("/couriers/%s/orders/%s", newCourierID, oc.testOrder.ID)
+	req, _ := http.NewRequest("PATCH", url, toByteReader(&parameters.Reassign{Reason: "shift ended"}))
+	oc.router.ServeHTTP(w, req)
+
+	oc.Require().Equal(http.StatusOK, w.Code)
+	var got models.Order
+	oc.NoError(json.Unmarshal(w.Body.Bytes(), &got))
+	oc.Equal(newCourierID, got.CourierID)
+	if oc.Len(got.Handovers, 1) {
+		oc.Equal(models.OrderHandover{From: oc.testOrder.CourierID, To: newCourierID, At: 200, Reason: "shift ended"}, *got.Handovers[0])
+	}
+	tracker.AssertExpectations(oc.T())
+	oc.geoRouteMock.AssertCalled(oc.T(), "DeleteCourier", oc.testOrder.CourierID)
+	oc.geoRouteMock.AssertCalled(oc.T(), "CreateCourier", newCourierID)
+}
+
+func (oc *OrdersControllersTestSuite) TestAPIService_AssignNewCourier_NotReassignable() {
+	oc.ordersDAOMock.On("Reassign", oc.testOrder.ID, oc.testOrder.CourierID, "").
+		Return(nil, models.ErrOrderNotReassignable.SetParameter(oc.testOrder.ID))
+	oc.api.OrdersDAO = oc.ordersDAOMock
+	oc.api.CourierRouteDAO = oc.geoRouteMock
+
+	w := httptest.NewRecorder()
+	url := fmt.Sprintf("/couriers/%s/orders/%s", oc.testOrder.CourierID, oc.testOrder.ID)
+	req, _ := http.NewRequest("PATCH", url, nil)
+	oc.router.ServeHTTP(w, req)
+
+	oc.Equal(http.StatusConflict, w.Code)
+	oc.ordersTrackerMock.AssertNotCalled(oc.T(), "DecAndGet", mock.Anything)
+	oc.ordersTrackerMock.AssertNotCalled(oc.T(), "IncAndGet", mock.Anything)
+}
+
+func (oc *OrdersControllersTestSuite) TestAPIService_GetOrderHandovers() {
+	newCourierID := "770e8400-e29b-41d4-a716-446655440000"
+	order := oc.mockGetOrder()
+	oc.Require().NoError(order.Reassign(newCourierID, "shift ended", 200))
+	oc.api.OrdersDAO = oc.ordersDAOMock
+
+	for _, courierID := range []string{oc.testOrder.CourierID, newCourierID} {
+		w := httptest.NewRecorder()
+		url := fmt.Sprintf("/couriers/%s/orders/%s/handovers", courierID, oc.testOrder.ID)
+		req, _ := http.NewRequest("GET", url, nil)
+		oc.router.ServeHTTP(w, req)
+
+		oc.Require().Equal(http.StatusOK, w.Code, courierID)
+		var got []*models.OrderHandover
+		oc.NoError(json.Unmarshal(w.Body.Bytes(), &got))
+		if oc.Len(got, 1) {
+			oc.Equal(oc.testOrder.CourierID, got[0].From)
+			oc.Equal(newCourierID, got[0].To)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	url := fmt.Sprintf("/couriers/%s/orders/%s/handovers", "880e8400-e29b-41d4-a716-446655440000", oc.testOrder.ID)
+	req, _ := http.NewRequest("GET", url, nil)
+	oc.router.ServeHTTP(w, req)
+	oc.Equal(http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,12 @@
+package parameters
+
+const (
+	DefaultChangesLimit = 100
+	MaxChangesLimit     = 1000
+)
+
+type Changes struct {
+	// Seq of the last change seen, empty to read from the beginning
+	Cursor string `form:"cursor"`
+	Limit  int    `form:"limit"`
+}
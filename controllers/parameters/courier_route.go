@@ -3,4 +3,31 @@ package parameters
 type CourierRoute struct {
 	CourierID string `form:"courier_id"`
 	Since     int64  `form:"since"`
+	// Upper bound of the window, unix seconds. Zero means now.
+	Until int64 `form:"until"`
+	// Page size. Zero returns every point of the window.
+	Limit int `form:"limit"`
+	// Opaque next_cursor of the previous page
+	Cursor string `form:"cursor"`
+	// Export format: json, geojson, gpx or kml. Negotiated from the Accept header when empty.
+	Format string `form:"format"`
+	// Simplification tolerance in metres: dropped points lie within it from the returned line. Zero keeps every point.
+	Tolerance float64 `form:"tolerance"`
+	// Upper limit on returned points, the first and the last points are always kept. Zero means no limit.
+	MaxPoints int `form:"max_points"`
+	// Skip points with accuracy worse than it, metres. Zero keeps every point.
+	MaxAccuracy float64 `form:"max_accuracy"`
+}
+
+type CourierRouteStats struct {
+	CourierID string `form:"courier_id"`
+	Since     int64  `form:"since"`
+	// Upper bound of the window, unix seconds. Zero means now.
+	Until int64 `form:"until"`
+	// Minimal dwell in minutes that counts as a stop. Zero falls back to the configured default.
+	StopMinutes float64 `form:"stop_minutes"`
+	// Radius in metres a courier must stay within during a stop. Zero falls back to the configured default.
+	StopRadius float64 `form:"stop_radius"`
+	// Skip points with accuracy worse than it, metres. Zero keeps every point.
+	MaxAccuracy float64 `form:"max_accuracy"`
 }
@@ -0,0 +1,48 @@
+package parameters
+
+// CourierStream selects the viewport of a live courier stream the way GET /couriers does:
+// a circle when radius is set, an OSM region when osm_id is set and a bounding box otherwise.
+// WebSocket clients send it as a JSON message to move the viewport.
+type CourierStream struct {
+	// Limits the snapshot of the viewport, later updates are not limited
+	Size       int  `form:"size" json:"size"`
+	ActiveOnly bool `form:"active_only" json:"active_only"`
+
+	TopLeftLat     float64 `form:"top_left_lat" json:"top_left_lat"`
+	TopLeftLon     float64 `form:"top_left_lon" json:"top_left_lon"`
+	BottomRightLat float64 `form:"bottom_right_lat" json:"bottom_right_lat"`
+	BottomRightLon float64 `form:"bottom_right_lon" json:"bottom_right_lon"`
+
+	Lat    float64 `form:"lat" json:"lat"`
+	Lon    float64 `form:"lon" json:"lon"`
+	Radius int     `form:"radius" json:"radius"`
+
+	OSMID   int    `form:"osm_id" json:"osm_id"`
+	OSMType string `form:"osm_type" json:"osm_type"`
+}
+
+// Circle returns the circle query of the viewport, or nil when it is not a circle.
+func (s *CourierStream) Circle() *CircleFieldQuery {
+	if s.Radius == 0 {
+		return nil
+	}
+	return &CircleFieldQuery{Lat: s.Lat, Lon: s.Lon, Radius: s.Radius, ActiveOnly: s.ActiveOnly}
+}
+
+// Polygon returns the region query of the viewport, or nil when it is not a region.
+func (s *CourierStream) Polygon() *PolygonQuery {
+	if s.OSMID == 0 {
+		return nil
+	}
+	return &PolygonQuery{OSMID: s.OSMID, OSMType: s.OSMType, ActiveOnly: s.ActiveOnly}
+}
+
+func (s *CourierStream) Box() *BoxFieldQuery {
+	return &BoxFieldQuery{
+		ActiveOnly:     s.ActiveOnly,
+		TopLeftLat:     s.TopLeftLat,
+		TopLeftLon:     s.TopLeftLon,
+		BottomRightLat: s.BottomRightLat,
+		BottomRightLon: s.BottomRightLon,
+	}
+}
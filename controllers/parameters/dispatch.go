@@ -0,0 +1,11 @@
+package parameters
+
+type Dispatch struct {
+	// Return the ranked candidates without assigning the order
+	DryRun bool `form:"dry_run"`
+}
+
+type CreateOpenOrder struct {
+	// Assign the created order to the best ranked courier right away
+	Dispatch bool `form:"dispatch"`
+}
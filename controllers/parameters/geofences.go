@@ -0,0 +1,14 @@
+package parameters
+
+const MaxGeofenceEventsLimit = 500
+
+type GeofenceEvents struct {
+	CourierID  string `form:"courier_id"`
+	GeofenceID string `form:"geofence_id"`
+	// enter, exit or dwell, empty for every type
+	Type string `form:"type"`
+	// Window of event time in unix seconds
+	Since int64 `form:"since"`
+	Until int64 `form:"until"`
+	Limit int   `form:"limit"`
+}
@@ -0,0 +1,8 @@
+package parameters
+
+type OrderTrack struct {
+	// Export format: json, geojson, gpx or kml. Negotiated from the Accept header when empty.
+	Format    string  `form:"format"`
+	Tolerance float64 `form:"tolerance"`
+	MaxPoints int     `form:"max_points"`
+}
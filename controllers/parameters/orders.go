@@ -1,5 +1,7 @@
 package parameters
 
+import "strings"
+
 type DirectionFlag bool
 type DeliveredFlag bool
 
@@ -9,8 +11,65 @@ const WithUpperThreshold DirectionFlag = false
 const IncludeDelivered DeliveredFlag = false
 const ExcludeDelivered DeliveredFlag = true
 
+const (
+	DefaultOrdersLimit = 100
+	MaxOrdersLimit     = 1000
+)
+
 type GetOrdersForCourierParams struct {
 	Since            int64         `form:"since"`
 	Asc              DirectionFlag `form:"asc"`
 	ExcludeDelivered DeliveredFlag `form:"exclude_delivered"`
+	// Order statuses to return, repeated or comma separated. Empty returns every status.
+	Status []string `form:"status"`
+	// created_at, order_number or distance from the current courier position. created_at when empty.
+	Sort string `form:"sort"`
+	Desc bool   `form:"desc"`
+	// Page size, DefaultOrdersLimit when zero
+	Limit int `form:"limit"`
+	// Opaque cursor sent in the X-Next-Cursor header of the previous page
+	Cursor string `form:"cursor"`
+}
+
+// Statuses returns the requested statuses with comma separated values split.
+func (p *GetOrdersForCourierParams) Statuses() []string {
+	return splitValues(p.Status)
+}
+
+// Reassign is the optional body of a handover of the order to another courier.
+type Reassign struct {
+	Reason string `json:"reason"`
+}
+
+// OrderSearch filters a geo search over orders, the area itself is taken the way couriers are searched.
+type OrderSearch struct {
+	// Order point matched against the area: source or destination. Destination when empty.
+	Point     string `form:"point"`
+	CourierID string `form:"courier_id"`
+	// Order statuses to return, repeated or comma separated. Empty returns every status.
+	Status []string `form:"status"`
+	// Bounds of the order creation time, unix seconds. Zero does not bound.
+	Since int64 `form:"since"`
+	Until int64 `form:"until"`
+	Size  int   `form:"size"`
+}
+
+// Statuses returns the requested statuses with comma separated values split.
+func (p *OrderSearch) Statuses() []string {
+	return splitValues(p.Status)
+}
+
+func splitValues(values []string) []string {
+	if len(values) == 0 {
+		return nil
+	}
+	split := make([]string, 0, len(values))
+	for _, value := range values {
+		for _, v := range strings.Split(value, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				split = append(split, v)
+			}
+		}
+	}
+	return split
 }
@@ -0,0 +1,13 @@
+package parameters
+
+const MaxRejectedLocationsLimit = 500
+
+type RejectedLocations struct {
+	CourierID string `form:"courier_id"`
+	// impossible_speed, duplicate or near_duplicate, empty for every reason
+	Reason string `form:"reason"`
+	// Window of rejection time in unix seconds
+	Since int64 `form:"since"`
+	Until int64 `form:"until"`
+	Limit int   `form:"limit"`
+}
@@ -0,0 +1,13 @@
+package parameters
+
+const MaxRouteArchiveLimit = 100
+
+type RouteArchive struct {
+	CourierID string `form:"courier_id"`
+	// Narrows the search to the courier and the lifetime of the order
+	OrderID string `form:"order_id"`
+	// Window in unix seconds, routes overlapping it are returned
+	Since int64 `form:"since"`
+	Until int64 `form:"until"`
+	Limit int   `form:"limit"`
+}
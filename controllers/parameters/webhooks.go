@@ -0,0 +1,7 @@
+package parameters
+
+const MaxWebhookDeadLettersLimit = 500
+
+type WebhookDeadLetters struct {
+	Limit int `form:"limit"`
+}
@@ -0,0 +1,59 @@
+package controllers
+
+import (
+	"github.com/TeamD2018/geo-rest/controllers/parameters"
+	"github.com/TeamD2018/geo-rest/models"
+	"github.com/gin-gonic/gin"
+	"github.com/satori/go.uuid"
+	"go.uber.org/zap"
+	"net/http"
+)
+
+// GetRejectedLocations returns the audit log of points the location filter kept out of the courier location and route.
+func (api *APIService) GetRejectedLocations(ctx *gin.Context) {
+	params := parameters.RejectedLocations{}
+	params.CourierID = ctx.Param("courier_id")
+	if err := ctx.BindQuery(&params); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, models.ErrOneOfParameterHaveIncorrectFormat)
+		return
+	}
+	if _, err := uuid.FromString(params.CourierID); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, models.ErrOneOfParameterHaveIncorrectFormat.SetParameter("courier_id"))
+		return
+	}
+	switch params.Reason {
+	case "", models.OutlierImpossibleSpeed, models.OutlierDuplicate, models.OutlierNearDuplicate:
+	default:
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, models.ErrOneOfParameterHaveIncorrectFormat.SetParameter("reason"))
+		return
+	}
+	if params.Since < 0 {
+		params.Since = 0
+	}
+	if params.Until < 0 || (params.Until > 0 && params.Until < params.Since) {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, models.ErrOneOfParameterHaveIncorrectFormat.SetParameter("until"))
+		return
+	}
+	if params.Limit < 0 || params.Limit > parameters.MaxRejectedLocationsLimit {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, models.ErrOneOfParameterHaveIncorrectFormat.SetParameter("limit"))
+		return
+	}
+	if api.RejectedLocations == nil {
+		ctx.JSON(http.StatusOK, models.RejectedLocations{})
+		return
+	}
+	query := &models.RejectedLocationQuery{
+		CourierID: params.CourierID,
+		Reason:    params.Reason,
+		Since:     params.Since,
+		Until:     params.Until,
+		Limit:     params.Limit,
+	}
+	rejected, err := api.RejectedLocations.Find(query)
+	if err != nil {
+		api.Logger.Error("fail to find rejected locations", zap.Error(err), zap.Any("query", query))
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, models.ErrServerError)
+		return
+	}
+	ctx.JSON(http.StatusOK, rejected)
+}
@@ -0,0 +1,110 @@
+package controllers
+
+import (
+	"github.com/TeamD2018/geo-rest/controllers/parameters"
+	"github.com/TeamD2018/geo-rest/models"
+	"github.com/gin-gonic/gin"
+	"github.com/olivere/elastic"
+	"github.com/satori/go.uuid"
+	"go.uber.org/zap"
+	"net/http"
+	"time"
+)
+
+// archiveRoute copies the live route of the courier to the archive and reports whether the route may be wiped.
+func (api *APIService) archiveRoute(courierID string) bool {
+	if api.RouteArchive == nil {
+		return true
+	}
+	page, err := api.CourierRouteDAO.GetRoute(courierID, &models.RouteQuery{})
+	if err != nil {
+		api.Logger.Error("fail to get route for archive", zap.Error(err), zap.String("courier_id", courierID))
+		return false
+	}
+	if len(page.Points) == 0 {
+		return true
+	}
+	route := models.NewArchivedRoute(courierID, page.Points, time.Now().Unix())
+	if err := api.RouteArchive.Save(route); err != nil {
+		api.Logger.Error("fail to archive route", zap.Error(err), zap.String("courier_id", courierID))
+		return false
+	}
+	return true
+}
+
+func (api *APIService) GetArchivedRoutes(ctx *gin.Context) {
+	var params parameters.RouteArchive
+	if err := ctx.BindQuery(&params); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, models.ErrOneOfParameterHaveIncorrectFormat)
+		return
+	}
+	if params.CourierID != "" {
+		if _, err := uuid.FromString(params.CourierID); err != nil {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, models.ErrOneOfParameterHaveIncorrectFormat.SetParameter("courier_id"))
+			return
+		}
+	}
+	if params.Since < 0 {
+		params.Since = 0
+	}
+	if params.Until < 0 || (params.Until > 0 && params.Until < params.Since) {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, models.ErrOneOfParameterHaveIncorrectFormat.SetParameter("until"))
+		return
+	}
+	if params.Limit < 0 || params.Limit > parameters.MaxRouteArchiveLimit {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, models.ErrOneOfParameterHaveIncorrectFormat.SetParameter("limit"))
+		return
+	}
+	query := &models.RouteArchiveQuery{
+		CourierID: params.CourierID,
+		Since:     params.Since,
+		Until:     params.Until,
+		Limit:     params.Limit,
+	}
+	if params.OrderID != "" {
+		if _, err := uuid.FromString(params.OrderID); err != nil {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, models.ErrOneOfParameterHaveIncorrectFormat.SetParameter("order_id"))
+			return
+		}
+		order, err := api.OrdersDAO.Get(params.OrderID)
+		if err != nil {
+			api.Logger.Error("fail to get order", zap.String("order_id", params.OrderID), zap.Error(err))
+			switch err.(type) {
+			case *elastic.Error:
+				err := err.(*elastic.Error)
+				if err.Status == 404 {
+					ctx.AbortWithStatusJSON(http.StatusNotFound, models.ErrEntityNotFound)
+					return
+				}
+			case *models.Error:
+				err := err.(*models.Error)
+				ctx.AbortWithStatusJSON(err.HttpStatus(), err)
+				return
+			}
+			ctx.AbortWithStatusJSON(http.StatusInternalServerError, models.ErrServerError)
+			return
+		}
+		if query.CourierID != "" && query.CourierID != order.CourierID {
+			ctx.JSON(http.StatusOK, models.ArchivedRoutes{})
+			return
+		}
+		query.CourierID = order.CourierID
+		if order.CreatedAt > query.Since {
+			query.Since = order.CreatedAt
+		}
+		if deliveredAt := order.DeliveryTime(); deliveredAt > 0 && (query.Until == 0 || deliveredAt < query.Until) {
+			query.Until = deliveredAt
+		}
+		if query.Until > 0 && query.Until < query.Since {
+			ctx.JSON(http.StatusOK, models.ArchivedRoutes{})
+			return
+		}
+	}
+	routes, err := api.RouteArchive.Find(query)
+	if err != nil {
+		api.Logger.Error("fail to find archived routes", zap.Error(err), zap.Any("query", query))
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, models.ErrServerError)
+		return
+	}
+	ctx.JSON(http.StatusOK, routes)
+}
@@ -0,0 +1,70 @@
+package controllers
+
+import (
+	"encoding/xml"
+	"github.com/TeamD2018/geo-rest/models"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+var routeFormatsByMIME = map[string]string{
+	gin.MIMEJSON:       models.RouteFormatJSON,
+	models.MIMEGeoJSON: models.RouteFormatGeoJSON,
+	models.MIMEGPX:     models.RouteFormatGPX,
+	models.MIMEKML:     models.RouteFormatKML,
+}
+
+// negotiateRouteFormat picks the export format from the Accept header, falling back to plain JSON.
+func negotiateRouteFormat(ctx *gin.Context) string {
+	mime := ctx.NegotiateFormat(gin.MIMEJSON, models.MIMEGeoJSON, models.MIMEGPX, models.MIMEKML)
+	if format, ok := routeFormatsByMIME[mime]; ok {
+		return format
+	}
+	return models.RouteFormatJSON
+}
+
+const (
+	NextCursorHeader = "X-Next-Cursor"
+	// Number of items matching a paginated query
+	TotalCountHeader = "X-Total-Count"
+)
+
+func isRouteFormat(format string) bool {
+	for _, known := range routeFormatsByMIME {
+		if format == known {
+			return true
+		}
+	}
+	return false
+}
+
+// renderRoute writes the page in the export format, which must be validated with isRouteFormat.
+// The next page cursor is sent in the NextCursorHeader for every format.
+func renderRoute(ctx *gin.Context, format string, courierID string, page *models.RoutePage) {
+	points := page.Points
+	var nextCursor string
+	if page.Next != nil {
+		nextCursor = page.Next.String()
+		ctx.Header(NextCursorHeader, nextCursor)
+	}
+	switch format {
+	case models.RouteFormatJSON:
+		ctx.JSON(http.StatusOK, models.RouteResponse{GeoHistory: points, NextCursor: nextCursor})
+	case models.RouteFormatGeoJSON:
+		ctx.Header("Content-Type", models.MIMEGeoJSON)
+		ctx.JSON(http.StatusOK, models.NewGeoJSONFeature(courierID, points))
+	case models.RouteFormatGPX:
+		renderXML(ctx, models.MIMEGPX, models.NewGPX(courierID, points))
+	case models.RouteFormatKML:
+		renderXML(ctx, models.MIMEKML, models.NewKML(courierID, points))
+	}
+}
+
+func renderXML(ctx *gin.Context, contentType string, document interface{}) {
+	body, err := xml.MarshalIndent(document, "", "  ")
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, models.ErrServerError)
+		return
+	}
+	ctx.Data(http.StatusOK, contentType, append([]byte(xml.Header), body...))
+}
@@ -13,16 +13,50 @@ func SetupRouters(router *gin.Engine, api *APIService) {
 	g.PATCH("/:courier_id/orders/:order_id", api.AssignNewCourier)
 	g.DELETE("/:courier_id/orders/:order_id", api.DeleteOrder)
 	g.GET("/:courier_id/orders", api.GetOrdersForCourier)
+	g.GET("/:courier_id/orders/:order_id/track", api.GetOrderTrack)
+	g.POST("/:courier_id/orders/:order_id/transitions", api.TransitionOrder)
+	g.POST("/:courier_id/orders/:order_id/claim", api.ClaimOrder)
+	g.GET("/:courier_id/orders/:order_id/handovers", api.GetOrderHandovers)
 
 	//couriers endpoints
 	g.POST("", api.CreateCourier)
 	g.GET("", api.MiddlewareGeoSearch)
 	g.GET("/:courier_id", api.GetCourierByID)
 	g.PUT("/:courier_id", api.UpdateCourier)
+	g.POST("/:courier_id/locations", api.AddCourierLocations)
+	g.GET("/:courier_id/rejected_locations", api.GetRejectedLocations)
+	g.GET("/:courier_id/geofences", api.GetCourierGeofences)
 	g.DELETE("/:courier_id", api.DeleteCourier)
 	g.GET("/:courier_id/geo_history", api.GetRouteForCourier)
+	g.GET("/:courier_id/geo_history/stats", api.GetRouteStatsForCourier)
 
 	router.GET("/suggestions/couriers", api.SuggestCourier)
 	router.GET("/suggestions", api.Suggest)
 	router.GET("/polygon", api.GetPolygon)
+	router.GET("/routes/archive", api.GetArchivedRoutes)
+	router.GET("/stream/couriers", api.StreamCouriers)
+	router.PUT("/stream/couriers/:subscription_id", api.MoveCourierStream)
+
+	//geofences endpoints
+	router.POST("/geofences", api.CreateGeofence)
+	router.GET("/geofences", api.GetGeofences)
+	router.GET("/geofences/:geofence_id", api.GetGeofence)
+	router.PUT("/geofences/:geofence_id", api.UpdateGeofence)
+	router.DELETE("/geofences/:geofence_id", api.DeleteGeofence)
+	router.GET("/geofence_events", api.GetGeofenceEvents)
+
+	//webhooks endpoints
+	router.POST("/webhooks", api.CreateWebhook)
+	router.GET("/webhooks", api.GetWebhooks)
+	router.GET("/webhooks/:webhook_id", api.GetWebhook)
+	router.DELETE("/webhooks/:webhook_id", api.DeleteWebhook)
+	router.GET("/webhooks/:webhook_id/dead_letters", api.GetWebhookDeadLetters)
+	router.POST("/webhooks/:webhook_id/dead_letters/:dead_letter_id/redeliver", api.RedeliverWebhook)
+
+	router.GET("/orders", api.SearchOrders)
+	router.POST("/orders", api.CreateOpenOrder)
+	router.GET("/orders/open", api.GetOpenOrders)
+	router.POST("/orders/:order_id/dispatch", api.DispatchOrder)
+
+	router.GET("/changes", api.GetChanges)
 }
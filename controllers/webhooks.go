@@ -0,0 +1,143 @@
+package controllers
+
+import (
+	"github.com/TeamD2018/geo-rest/controllers/parameters"
+	"github.com/TeamD2018/geo-rest/models"
+	"github.com/gin-gonic/gin"
+	"github.com/satori/go.uuid"
+	"go.uber.org/zap"
+	"net/http"
+)
+
+// CreateWebhook subscribes a URL to lifecycle events. The response is the only one carrying the secret.
+func (api *APIService) CreateWebhook(ctx *gin.Context) {
+	if api.Webhooks == nil {
+		ctx.AbortWithStatusJSON(http.StatusNotFound, models.ErrEntityNotFound.SetParameter("webhooks"))
+		return
+	}
+	var create models.WebhookSubscriptionCreate
+	if err := ctx.ShouldBindJSON(&create); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, models.ErrOneOfParameterHaveIncorrectFormat)
+		return
+	}
+	subscription, err := api.Webhooks.Create(&create)
+	if err != nil {
+		api.abortWithWebhookError(ctx, err, "fail to create webhook", "")
+		return
+	}
+	ctx.JSON(http.StatusCreated, subscription)
+}
+
+func (api *APIService) GetWebhook(ctx *gin.Context) {
+	subscriptionID, ok := api.webhookID(ctx)
+	if !ok {
+		return
+	}
+	subscription, err := api.Webhooks.Get(subscriptionID)
+	if err != nil {
+		api.abortWithWebhookError(ctx, err, "fail to get webhook", subscriptionID)
+		return
+	}
+	subscription.Secret = ""
+	ctx.JSON(http.StatusOK, subscription)
+}
+
+func (api *APIService) GetWebhooks(ctx *gin.Context) {
+	if api.Webhooks == nil {
+		ctx.JSON(http.StatusOK, models.WebhookSubscriptions{})
+		return
+	}
+	subscriptions, err := api.Webhooks.List()
+	if err != nil {
+		api.Logger.Error("fail to list webhooks", zap.Error(err))
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, models.ErrServerError)
+		return
+	}
+	for _, subscription := range subscriptions {
+		subscription.Secret = ""
+	}
+	ctx.JSON(http.StatusOK, subscriptions)
+}
+
+func (api *APIService) DeleteWebhook(ctx *gin.Context) {
+	subscriptionID, ok := api.webhookID(ctx)
+	if !ok {
+		return
+	}
+	if err := api.Webhooks.Delete(subscriptionID); err != nil {
+		api.abortWithWebhookError(ctx, err, "fail to delete webhook", subscriptionID)
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
+
+// GetWebhookDeadLetters returns events that could not be delivered to the subscription, latest first.
+func (api *APIService) GetWebhookDeadLetters(ctx *gin.Context) {
+	subscriptionID, ok := api.webhookID(ctx)
+	if !ok {
+		return
+	}
+	params := parameters.WebhookDeadLetters{}
+	if err := ctx.BindQuery(&params); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, models.ErrOneOfParameterHaveIncorrectFormat)
+		return
+	}
+	if params.Limit < 0 || params.Limit > parameters.MaxWebhookDeadLettersLimit {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, models.ErrOneOfParameterHaveIncorrectFormat.SetParameter("limit"))
+		return
+	}
+	deadLetters, err := api.Webhooks.DeadLetters(subscriptionID, params.Limit)
+	if err != nil {
+		api.abortWithWebhookError(ctx, err, "fail to get webhook dead letters", subscriptionID)
+		return
+	}
+	ctx.JSON(http.StatusOK, deadLetters)
+}
+
+// RedeliverWebhook queues a dead letter for delivery again.
+func (api *APIService) RedeliverWebhook(ctx *gin.Context) {
+	subscriptionID, ok := api.webhookID(ctx)
+	if !ok {
+		return
+	}
+	deadLetterID := ctx.Param("dead_letter_id")
+	if _, err := uuid.FromString(deadLetterID); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, models.ErrOneOfParameterHaveIncorrectFormat.SetParameter("dead_letter_id"))
+		return
+	}
+	if err := api.Webhooks.Redeliver(subscriptionID, deadLetterID); err != nil {
+		api.abortWithWebhookError(ctx, err, "fail to redeliver webhook", subscriptionID)
+		return
+	}
+	ctx.Status(http.StatusAccepted)
+}
+
+// dispatchWebhook queues a lifecycle event for subscribers; delivery never fails the request.
+func (api *APIService) dispatchWebhook(eventType string, data interface{}) {
+	if api.Webhooks == nil {
+		return
+	}
+	api.Webhooks.Dispatch(eventType, data)
+}
+
+func (api *APIService) webhookID(ctx *gin.Context) (string, bool) {
+	subscriptionID := ctx.Param("webhook_id")
+	if _, err := uuid.FromString(subscriptionID); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, models.ErrOneOfParameterHaveIncorrectFormat.SetParameter("webhook_id"))
+		return "", false
+	}
+	if api.Webhooks == nil {
+		ctx.AbortWithStatusJSON(http.StatusNotFound, models.ErrEntityNotFound.SetParameter(subscriptionID))
+		return "", false
+	}
+	return subscriptionID, true
+}
+
+func (api *APIService) abortWithWebhookError(ctx *gin.Context, err error, msg string, subscriptionID string) {
+	if err, ok := err.(*models.Error); ok {
+		ctx.AbortWithStatusJSON(err.HttpStatus(), err)
+		return
+	}
+	api.Logger.Error(msg, zap.Error(err), zap.String("webhook_id", subscriptionID))
+	ctx.AbortWithStatusJSON(http.StatusInternalServerError, models.ErrServerError)
+}
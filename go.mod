@@ -16,6 +16,7 @@ require (
 	github.com/gin-gonic/gin v1.3.0
 	github.com/gobuffalo/packr/v2 v2.0.3
 	github.com/google/uuid v1.1.1 // indirect
+	github.com/gorilla/websocket v1.4.2
 	github.com/gotestyourself/gotestyourself v2.2.0+incompatible // indirect
 	github.com/inconshreveable/mousetrap v1.0.0 // indirect
 	github.com/json-iterator/go v1.1.5
@@ -32,10 +33,11 @@ require (
 	github.com/spf13/viper v1.3.1
 	github.com/stretchr/testify v1.3.0
 	github.com/tarantool/go-tarantool v0.0.0-20190109135625-9b19a0255fbf
+	go.etcd.io/bbolt v1.3.5
 	go.uber.org/atomic v1.3.2 // indirect
 	go.uber.org/multierr v1.1.0 // indirect
 	go.uber.org/zap v1.9.1
-	golang.org/x/sys v0.0.0-20190116161447-11f53e031339 // indirect
+	golang.org/x/sys v0.7.0 // indirect
 	golang.org/x/time v0.0.0-20190308202827-9d24e82272b4 // indirect
 	googlemaps.github.io/maps v0.0.0-20190206003505-be134e760d70
 	gopkg.in/vmihailenco/msgpack.v2 v2.9.1 // indirect
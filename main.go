@@ -1,29 +1,28 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"github.com/TeamD2018/geo-rest/controllers"
-	"github.com/TeamD2018/geo-rest/migrations"
 	"github.com/TeamD2018/geo-rest/services"
-	"github.com/TeamD2018/geo-rest/services/photon"
+	"github.com/TeamD2018/geo-rest/services/geo"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-contrib/pprof"
 	"github.com/gin-gonic/gin"
-	"github.com/olivere/elastic"
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
-	"github.com/tarantool/go-tarantool"
 	"go.uber.org/zap"
-	"googlemaps.github.io/maps"
+	"io"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 )
 
-const (
-	createCouriersRouteSpaceFuncName = "create_couriers_route_space"
-	createResolverCacheSpaceFuncName = "create_resolver_cache"
-)
+// DefaultShutdownTimeout bounds the wait for in-flight requests on shutdown.
+const DefaultShutdownTimeout = 10 * time.Second
 
 func init() {
 	pflag.CommandLine.AddGoFlagSet(flag.CommandLine)
@@ -35,6 +34,50 @@ func init() {
 		panic("err in bind flag")
 	}
 
+	viper.SetDefault("server.shutdown_timeout", DefaultShutdownTimeout)
+	viper.SetDefault("backend", string(ElasticBackend))
+	viper.SetDefault("embedded.path", "./geo-rest.db")
+	viper.SetDefault("embedded.cell_size", geo.DefaultCellSize)
+	viper.SetDefault("route_stats.stop_duration", services.DefaultStopDuration)
+	viper.SetDefault("route_stats.stop_radius", services.DefaultStopRadius)
+	viper.SetDefault("locations.max_clock_skew", services.DefaultMaxClockSkew)
+	viper.SetDefault("locations.max_batch_size", services.DefaultMaxLocationsBatch)
+	viper.SetDefault("stream.buffer", services.DefaultStreamBuffer)
+	viper.SetDefault("stream.max_subscribers", services.DefaultStreamMaxSubscribers)
+	viper.SetDefault("stream.keep_alive", controllers.DefaultStreamKeepAlive)
+	viper.SetDefault("location_filter.enabled", true)
+	viper.SetDefault("location_filter.mode", services.LocationFilterReject)
+	viper.SetDefault("location_filter.max_speed", services.DefaultMaxCourierSpeed)
+	viper.SetDefault("location_filter.duplicate_distance", services.DefaultDuplicateDistance)
+	viper.SetDefault("location_filter.duplicate_interval", services.DefaultDuplicateInterval)
+	viper.SetDefault("eta.detour_factor", services.DefaultDetourFactor)
+	viper.SetDefault("eta.speed_window", services.DefaultETASpeedWindow)
+	viper.SetDefault("eta.default_speed", services.DefaultETASpeed)
+	viper.SetDefault("eta.min_speed", services.DefaultETAMinSpeed)
+	viper.SetDefault("arrivals.enabled", true)
+	viper.SetDefault("arrivals.radius", services.DefaultArrivalRadius)
+	viper.SetDefault("dispatch.distance_weight", services.DefaultDispatchDistanceWeight)
+	viper.SetDefault("dispatch.load_weight", services.DefaultDispatchLoadWeight)
+	viper.SetDefault("dispatch.freshness_weight", services.DefaultDispatchFreshnessWeight)
+	viper.SetDefault("dispatch.initial_radius", services.DefaultDispatchInitialRadius)
+	viper.SetDefault("dispatch.max_radius", services.DefaultDispatchMaxRadius)
+	viper.SetDefault("dispatch.radius_growth", services.DefaultDispatchRadiusGrowth)
+	viper.SetDefault("dispatch.candidates", services.DefaultDispatchCandidates)
+	viper.SetDefault("dispatch.max_orders", services.DefaultDispatchMaxOrders)
+	viper.SetDefault("dispatch.max_idle", services.DefaultDispatchMaxIdle)
+	viper.SetDefault("inactivity.enabled", true)
+	viper.SetDefault("inactivity.threshold", services.DefaultInactivityThreshold)
+	viper.SetDefault("inactivity.interval", services.DefaultInactivityInterval)
+	viper.SetDefault("changes.trim", true)
+	viper.SetDefault("changes.retention", services.DefaultChangesRetention)
+	viper.SetDefault("changes.interval", services.DefaultChangesTrimInterval)
+	viper.SetDefault("geofences.cache_ttl", services.DefaultGeofenceCacheTTL)
+	viper.SetDefault("webhooks.workers", services.DefaultWebhookWorkers)
+	viper.SetDefault("webhooks.queue_size", services.DefaultWebhookQueueSize)
+	viper.SetDefault("webhooks.max_attempts", services.DefaultWebhookMaxAttempts)
+	viper.SetDefault("webhooks.initial_backoff", services.DefaultWebhookInitialBackoff)
+	viper.SetDefault("webhooks.max_backoff", services.DefaultWebhookMaxBackoff)
+	viper.SetDefault("webhooks.timeout", services.DefaultWebhookTimeout)
 	viper.SetDefault("suggestions.couriers.fuzziness", services.CouriersDefaultFuzziness)
 	viper.SetDefault("suggestions.couriers.threshold", services.CouriersDefaultFuzzinessThreshold)
 
@@ -94,118 +137,20 @@ func main() {
 	if mode == Production {
 		gin.SetMode("release")
 	}
-	elasticClient, err := elastic.NewClient(
-		elastic.SetURL(viper.GetString("elastic.url")),
-		elastic.SetSniff(viper.GetBool("elastic.sniff")),
-		elastic.SetRetrier(elastic.NewBackoffRetrier(elastic.NewConstantBackoff(time.Second*5))),
-		elastic.SetHealthcheckTimeoutStartup(time.Second*60))
-	if err != nil {
-		log.Fatal(err)
-	}
-	gmaps, err := maps.NewClient(maps.WithAPIKey(viper.GetString("google-maps.apikey")))
-	if err != nil {
-		log.Fatal(err)
-	}
-	tntClient, err := tarantool.Connect(viper.GetString("tarantool.url"), tarantool.Opts{
-		User: viper.GetString("tarantool.user"),
-		Pass: viper.GetString("tarantool.pass"),
-	})
-	if err != nil {
-		log.Fatal(err)
-	}
-	err = migrations.Driver{Client: tntClient, Logger: logger}.Run()
-	if err != nil {
-		logger.Fatal("fail to perform migrations", zap.Error(err))
-	}
-	_, err = tntClient.Call17(createCouriersRouteSpaceFuncName, []interface{}{})
-	if err != nil {
-		log.Fatal(err)
-	}
-	_, err = tntClient.Call17(createResolverCacheSpaceFuncName, []interface{}{})
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	ordersCountTracker := services.NewTarantoolOrdersCountTracker(tntClient, logger)
-
-	couriersDao := services.NewCouriersElasticDAO(elasticClient, logger, "", services.DefaultCouriersReturnSize)
-	ordersDao := services.NewOrdersElasticDAO(elasticClient, logger, couriersDao, "")
-
-	tntResolver := services.NewTntResolver(tntClient, logger)
-	gmapsResolver := services.NewGMapsResolver(gmaps, logger)
-
-	couriersSuggester := services.NewCouriersSuggesterElastic(elasticClient, couriersDao, logger).
-		SetFuzziness(viper.GetInt("suggestions.couriers.fuzziness")).
-		SetFuzzinessThreshold(viper.GetInt("suggestions.couriers.threshold"))
-
-	couriersSuggestEngine := services.PrefixSuggestEngine{
-		Fuzziness: "AUTO",
-		Limit:     15,
-		Field:     "suggestions",
-		Index:     couriersDao.GetIndex(),
-	}
-	ordersPrefixSuggestEngine := services.PrefixSuggestEngine{
-		Fuzziness: "0",
-		Limit:     15,
-		Field:     "order_suggestions",
-		Index:     ordersDao.GetIndex(),
-	}
-	ordersSuggestDestinationEngine := services.OrdersSuggestEngine{
-		Fuzziness:          "1",
-		FuzzinessThreshold: 5,
-		Limit:              15,
-		Field:              "destination.address",
-		Index:              ordersDao.GetIndex(),
-	}
-	concurrentLookup := &services.ConcurrentLookupService{
-		LookupService: services.NewNominatimRegionResolver(viper.GetString("nominatim.url"), logger),
-		Concurrency:   viper.GetInt("photon.lookup_concurrency"),
-	}
-	photonPolygonSuggestionEngine := &services.PhotonSuggestEngine{
-		Limit:                   viper.GetInt("photon.limit"),
-		OSMType:                 "R",
-		Tags:                    viper.GetStringSlice("photon.tags"),
-		ConcurrentLookupService: concurrentLookup,
-	}
-
-	elasticSuggester := services.NewSuggestEngineExecutor(elasticClient, logger)
-	elasticSuggester.AddEngine("orders-engine", &ordersSuggestDestinationEngine)
-	elasticSuggester.AddEngine("couriers-engine", &couriersSuggestEngine)
-	elasticSuggester.AddEngine("orders-prefix-engine", &ordersPrefixSuggestEngine)
-
-	photonClient := photon.NewPhotonClient(viper.GetString("photon.url"))
-	photonSuggester := services.NewPhotonSuggestEngineExecutor(photonClient, logger)
-	photonSuggester.AddEngine("polygons-engine", photonPolygonSuggestionEngine)
-
-	suggestService := services.NewSuggestionService(photonSuggester, elasticSuggester)
-
-	if err := couriersDao.EnsureMapping(); err != nil {
-		logger.Fatal("Fail to ensure couriers mapping: ", zap.Error(err))
-	}
-	if err := ordersDao.EnsureMapping(); err != nil {
-		logger.Fatal("Fail to ensure orders mapping: ", zap.Error(err))
-	}
-
-	tntRouteDao := services.NewTarantoolRouteDAO(tntClient, logger)
-
-	nominatimResolver := services.NewNominatimRegionResolver(viper.GetString("nominatim.url"), logger)
-	tarantoolRegionResolver := services.NewTarantoolRegionResolver(tntClient, logger)
-	cachedRegionResolver := &services.CachedRegionResolver{
-		TarantoolResolver: tarantoolRegionResolver,
-		NominatimResolver: nominatimResolver,
-	}
-
-	api := controllers.APIService{
-		CouriersDAO:        couriersDao,
-		OrdersDAO:          ordersDao,
-		CourierRouteDAO:    tntRouteDao,
-		GeoResolver:        services.NewCachedResolver(tntResolver, gmapsResolver),
-		RegionResolver:     cachedRegionResolver,
-		CourierSuggester:   couriersSuggester,
-		Logger:             logger,
-		SuggestionService:  suggestService,
-		OrdersCountTracker: ordersCountTracker,
+	var api *controllers.APIService
+	// closed once the server has stopped serving requests: the inactivity worker and the change log trimmer,
+	// then the dispatcher the worker dispatches to, then the storage they write to
+	var webhooks *services.WebhookDispatcher
+	var storage io.Closer
+	switch BackendFromString(viper.GetString("backend")) {
+	case MemoryBackend:
+		api, webhooks = setupMemoryBackend(logger)
+	case EmbeddedBackend:
+		api, webhooks, storage = setupEmbeddedBackend(logger)
+	default:
+		api, webhooks = setupElasticBackend(logger)
 	}
+	inactivity, trimmer := setupServices(api)
 	router := gin.New()
 
 	router.Use(func(ctx *gin.Context) {
@@ -218,9 +163,37 @@ func main() {
 	config.AllowOrigins = viper.GetStringSlice("cors.origins")
 	router.Use(cors.New(config))
 
-	controllers.SetupRouters(router, &api)
+	controllers.SetupRouters(router, api)
 
-	if err := router.Run(viper.GetString("server.url")); err != nil {
-		log.Fatal(err)
+	server := &http.Server{
+		Addr:    viper.GetString("server.url"),
+		Handler: router,
+	}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+	}()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	<-signals
+	logger.Info("shutting down")
+	ctx, cancel := context.WithTimeout(context.Background(), viper.GetDuration("server.shutdown_timeout"))
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		logger.Error("fail to shut down server", zap.Error(err))
+	}
+	if inactivity != nil {
+		inactivity.Close()
+	}
+	if trimmer != nil {
+		trimmer.Close()
+	}
+	webhooks.Close()
+	if storage != nil {
+		if err := storage.Close(); err != nil {
+			logger.Error("fail to close storage", zap.Error(err))
+		}
 	}
 }
@@ -0,0 +1,69 @@
+local CHANGES_SPACE = 'changes'
+local CHANGES_SEQUENCE = 'changes_seq'
+
+function create_changes_space()
+    box.schema.sequence.create(CHANGES_SEQUENCE, { if_not_exists = true })
+    local s = box.schema.space.create(CHANGES_SPACE, { if_not_exists = true })
+    s:format({
+        { name = 'seq', type = 'unsigned' },
+        { name = 'ts', type = 'integer' },
+        { name = 'entity', type = 'string' },
+        { name = 'op', type = 'string' },
+        { name = 'id', type = 'string' },
+        { name = 'courier_id', type = 'string' },
+        { name = 'data', type = 'string', is_nullable = true },
+    })
+    return s:create_index('primary', { type = 'TREE', unique = true, if_not_exists = true, parts = { 1, 'unsigned' } })
+end
+
+create_changes_space()
+
+---append_change logs a change and returns its seq
+---@param change table {ts, entity, op, id, courier_id, data}
+function append_change(change)
+    if type(change.entity) ~= 'string' or type(change.op) ~= 'string' or type(change.id) ~= 'string' then
+        error('entity, op and id must be strings')
+    end
+    local seq = box.sequence[CHANGES_SEQUENCE]:next()
+    local data = change.data
+    if data == nil or data == '' then
+        data = box.NULL
+    end
+    box.space[CHANGES_SPACE]:insert { seq, change.ts or 0, change.entity, change.op, change.id, change.courier_id or '', data }
+    return seq
+end
+
+---get_changes returns changes with seq > after ordered by seq
+---@param after number
+---@param limit number maximal number of changes
+function get_changes(after, limit)
+    if after == nil or after < 0 then
+        after = 0
+    end
+    local res = {}
+    for _, t in box.space[CHANGES_SPACE].index.primary:pairs({ after }, { iterator = 'GT' }) do
+        if #res >= limit then
+            break
+        end
+        table.insert(res, {
+            seq = t[1], ts = t[2], entity = t[3], op = t[4], id = t[5], courier_id = t[6], data = t[7],
+        })
+    end
+    return res
+end
+
+---trim_changes removes changes logged before ts in seq order, up to the first one logged later, and returns how many
+---@param before number unix seconds
+function trim_changes(before)
+    local seqs = {}
+    for _, t in box.space[CHANGES_SPACE].index.primary:pairs() do
+        if t[2] >= before then
+            break
+        end
+        table.insert(seqs, t[1])
+    end
+    for _, seq in ipairs(seqs) do
+        box.space[CHANGES_SPACE]:delete(seq)
+    end
+    return #seqs
+end
@@ -1,25 +1,119 @@
+local ROUTE_SPACE = 'couriers_route'
+local LEGACY_ROUTE_SPACE = 'couriers_route_legacy'
+local ROUTE_SEQUENCE = 'couriers_route_seq'
+
+---is_legacy_route_space
+---@param s table space with one {courier_id, route} tuple per courier
+local function is_legacy_route_space(s)
+    return s ~= nil and s.index.courier_id ~= nil and s.index.primary == nil
+end
+
+---delete_route_points removes every point of the courier
+---@param courier_id string
+local function delete_route_points(courier_id)
+    local s = box.space[ROUTE_SPACE]
+    local keys = {}
+    for _, t in s.index.primary:pairs({ courier_id }, { iterator = 'EQ' }) do
+        table.insert(keys, { t[1], t[2], t[3] })
+    end
+    for _, key in ipairs(keys) do
+        s:delete(key)
+    end
+end
+
+---migrate_legacy_routes moves routes stored as one array per courier to one tuple per point.
+---Every courier is moved in its own transaction, so an interrupted migration resumes on next start.
+local function migrate_legacy_routes()
+    local legacy = box.space[LEGACY_ROUTE_SPACE]
+    if legacy == nil then
+        return
+    end
+    local s = box.space[ROUTE_SPACE]
+    local seq = box.sequence[ROUTE_SEQUENCE]
+    -- the space is not modified while it is iterated, and the transactions below may yield
+    local courier_ids = {}
+    for _, t in legacy:pairs() do
+        table.insert(courier_ids, t[1])
+    end
+    for _, courier_id in ipairs(courier_ids) do
+        box.begin()
+        local t = legacy:get { courier_id }
+        if t ~= nil then
+            delete_route_points(courier_id)
+            for _, p in ipairs(t[2]) do
+                s:insert { courier_id, p.ts or 0, seq:next(), p.lat, p.lon, box.NULL, box.NULL, box.NULL, box.NULL, box.NULL }
+            end
+            legacy:delete { courier_id }
+        end
+        box.commit()
+    end
+    legacy:drop()
+end
+
 function create_couriers_route_space()
-    local s = box.schema.space.create('couriers_route', { if_not_exists = true, field_count = 2 })
-    return s:create_index('courier_id', { type = 'HASH', unique = true, if_not_exists = true, parts = { 1, 'string' } })
+    local s = box.space[ROUTE_SPACE]
+    if is_legacy_route_space(s) then
+        s:rename(LEGACY_ROUTE_SPACE)
+    end
+    box.schema.sequence.create(ROUTE_SEQUENCE, { if_not_exists = true })
+    s = box.schema.space.create(ROUTE_SPACE, { if_not_exists = true })
+    s:format({
+        { name = 'courier_id', type = 'string' },
+        { name = 'ts', type = 'unsigned' },
+        { name = 'seq', type = 'unsigned' },
+        { name = 'lat', type = 'number' },
+        { name = 'lon', type = 'number' },
+        { name = 'accuracy', type = 'number', is_nullable = true },
+        { name = 'speed', type = 'number', is_nullable = true },
+        { name = 'bearing', type = 'number', is_nullable = true },
+        { name = 'altitude', type = 'number', is_nullable = true },
+        { name = 'provider', type = 'string', is_nullable = true },
+    })
+    local primary = s:create_index('primary', {
+        type = 'TREE',
+        unique = true,
+        if_not_exists = true,
+        parts = { 1, 'string', 2, 'unsigned', 3, 'unsigned' }
+    })
+    migrate_legacy_routes()
+    return primary
 end
 
 create_couriers_route_space()
 
+---add_courier starts an empty route for the courier
+---@param courier_id string
 function add_courier(courier_id)
-    local s = box.space.couriers_route
     if type(courier_id) ~= 'string' then
         error('courier_id must be a string')
     end
-    return s:replace { courier_id, {} }
+    box.begin()
+    delete_route_points(courier_id)
+    box.commit()
 end
 
+---optional_field returns point[name] or box.NULL, checking its type
+---@param point table
+---@param name string
+---@param field_type string
+local function optional_field(point, name, field_type)
+    local value = point[name]
+    if value == nil then
+        return box.NULL
+    end
+    if type(value) ~= field_type then
+        error(name .. ' must be a ' .. field_type)
+    end
+    return value
+end
+
+---add_point_to_route
+---@param courier_id string
+---@param point table {lat, lon, ts, accuracy, speed, bearing, altitude, provider}
 function add_point_to_route(courier_id, point)
-    local courier_id_idx = box.space.couriers_route.index.courier_id
-    local res            = box.space.couriers_route.index.courier_id:get { courier_id }
-    if res == nil then
-        res = add_courier(courier_id)
+    if type(courier_id) ~= 'string' then
+        error('courier_id must be a string')
     end
-    local route = res[2]
     if point.lat == nil or point.lon == nil then
         error('lat or lon not found')
     end
@@ -29,26 +123,66 @@ function add_point_to_route(courier_id, point)
     if point.lat < -90 or point.lat > 90 or point.lon < -180 or point.lon > 180 then
         error('lat or lon have invalid format (-90 < lat < 90, -180 < lon < 180')
     end
-    table.insert(route, { lat = point.lat, lon = point.lon, ts = point.ts })
-    courier_id_idx:update({ courier_id }, { { '=', 2, route } })
+    local seq = box.sequence[ROUTE_SEQUENCE]:next()
+    box.space[ROUTE_SPACE]:insert {
+        courier_id, point.ts or 0, seq, point.lat, point.lon,
+        optional_field(point, 'accuracy', 'number'),
+        optional_field(point, 'speed', 'number'),
+        optional_field(point, 'bearing', 'number'),
+        optional_field(point, 'altitude', 'number'),
+        optional_field(point, 'provider', 'string'),
+    }
 end
 
+---delete_courier
+---@param courier_id string
 function delete_courier(courier_id)
-    box.space.couriers_route:delete { courier_id }
+    box.begin()
+    delete_route_points(courier_id)
+    box.commit()
 end
 
-function get_route(courier_id, since)
+---get_route returns points with since < ts <= until ordered by ts and seq
+---@param courier_id string
+---@param since number
+---@param until number upper bound, 0 or nil for none
+---@param limit number maximal number of points, 0 or nil for none
+---@param after table optional {ts, seq} cursor, only points after it are returned
+---@param max_accuracy number skip points with accuracy worse than it, 0 or nil for none
+function get_route(courier_id, since, until, limit, after, max_accuracy)
     if type(courier_id) ~= 'string' then
         error('courier_id must be a string')
     end
-    local temp_res = box.space.couriers_route.index.courier_id:get { courier_id }
-    if temp_res == nil then
-        return {}
+    if since == nil or since < 0 then
+        since = 0
+    end
+    if until == nil or until < 0 then
+        until = 0
+    end
+    if limit == nil or limit < 0 then
+        limit = 0
+    end
+    if max_accuracy == nil or max_accuracy < 0 then
+        max_accuracy = 0
+    end
+    local key = { courier_id, since }
+    if after ~= nil and after[1] >= since then
+        key = { courier_id, after[1], after[2] }
     end
     local res = {}
-    for _, v in pairs(temp_res[2]) do
-        if v.ts > since then
-            table.insert(res, v)
+    for _, t in box.space[ROUTE_SPACE].index.primary:pairs(key, { iterator = 'GT' }) do
+        if t[1] ~= courier_id or (until > 0 and t[2] > until) then
+            break
+        end
+        if limit > 0 and #res >= limit then
+            break
+        end
+        local accurate = max_accuracy == 0 or t[6] == nil or t[6] <= max_accuracy
+        if t[2] > since and accurate then
+            table.insert(res, {
+                lat = t[4], lon = t[5], ts = t[2], seq = t[3],
+                accuracy = t[6], speed = t[7], bearing = t[8], altitude = t[9], provider = t[10],
+            })
         end
     end
     return res
@@ -0,0 +1,68 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+)
+
+var ErrInvalidChangeCursor = errors.New("invalid change cursor")
+
+// Entities a change is about.
+const (
+	ChangeCourier = "courier"
+	ChangeOrder   = "order"
+	// ID of a route change is the courier ID
+	ChangeRoute = "route"
+)
+
+// Change operations. A route is updated by every added point.
+const (
+	ChangeCreated = "created"
+	ChangeUpdated = "updated"
+	ChangeDeleted = "deleted"
+)
+
+// Change is an entry of the change log. Seq grows strictly with every entry and never repeats.
+// It is the order changes were logged in: a mutation is stored first and logged after, so two concurrent mutations
+// of one entity may be logged in the other order than they were applied in, and Data of the last change of an entity
+// be older than the stored entity. A consumer that needs the current state reads the entity.
+type Change struct {
+	Seq       uint64 `json:"seq"`
+	Entity    string `json:"entity"`
+	Op        string `json:"op"`
+	ID        string `json:"id"`
+	CourierID string `json:"courier_id,omitempty"`
+	// Unix seconds
+	Ts int64 `json:"timestamp"`
+	// State after the change: the courier, the order or the added route point. Empty for deletions.
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+type Changes []*Change
+
+// ChangesResponse is a page of the change log. NextCursor resumes right after the last returned change,
+// or stays at the requested position when nothing new was logged.
+type ChangesResponse struct {
+	Changes    Changes `json:"changes"`
+	NextCursor string  `json:"next_cursor"`
+	// More changes are already logged after this page
+	HasMore bool `json:"has_more"`
+}
+
+// ChangeCursor returns the cursor resuming after the change with seq.
+func ChangeCursor(seq uint64) string {
+	return strconv.FormatUint(seq, 10)
+}
+
+// ParseChangeCursor returns the seq of the last change a client has seen, zero for an empty cursor.
+func ParseChangeCursor(cursor string) (uint64, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+	seq, err := strconv.ParseUint(cursor, 10, 64)
+	if err != nil {
+		return 0, ErrInvalidChangeCursor
+	}
+	return seq, nil
+}
@@ -0,0 +1,33 @@
+package models
+
+// Types of courier stream events.
+const (
+	// The couriers currently inside the viewport, sent on subscription and on every viewport change
+	CourierEventSnapshot = "snapshot"
+	// A courier inside the viewport moved, entered it or changed its active flag or orders count
+	CourierEventUpdate = "update"
+	// A courier left the viewport
+	CourierEventLeave = "leave"
+	// A courier inside the viewport was deleted
+	CourierEventDelete = "delete"
+	// The client message could not be applied, the subscription keeps its viewport
+	CourierEventError = "error"
+)
+
+// CourierEvent is a message of the live courier stream.
+type CourierEvent struct {
+	Type           string   `json:"type"`
+	SubscriptionID string   `json:"subscription_id,omitempty"`
+	Courier        *Courier `json:"courier,omitempty"`
+	CourierID      string   `json:"courier_id,omitempty"`
+	Couriers       Couriers `json:"couriers,omitempty"`
+	Error          *Error   `json:"error,omitempty"`
+}
+
+// Viewport is the area a stream subscriber watches: a box, a circle or a polygon.
+type Viewport struct {
+	Box        *BoxField
+	Circle     *CircleField
+	Polygon    FlatPolygon
+	ActiveOnly bool
+}
@@ -0,0 +1,33 @@
+package models
+
+// DispatchCandidate is a courier scored for an order. Every factor adds to Score in [0, weight],
+// so the candidate with the lowest Score fits the order best.
+type DispatchCandidate struct {
+	Courier *Courier `json:"courier"`
+	// Metres from the courier location to the order source
+	Distance float64 `json:"distance"`
+	// Active orders the courier carries
+	OrdersCount int `json:"orders_count"`
+	// Seconds since the courier was last seen
+	Idle  int64   `json:"idle"`
+	Score float64 `json:"score"`
+}
+
+// DispatchRanking lists the candidates for an order, the best first.
+type DispatchRanking struct {
+	OrderID string `json:"order_id"`
+	// Metres around the order source the candidates were found within
+	Radius     int                  `json:"radius"`
+	Candidates []*DispatchCandidate `json:"candidates"`
+	DryRun     bool                 `json:"dry_run"`
+	// The order after it was assigned to the best candidate, empty on a dry run or when nobody fits
+	Assigned *Order `json:"assigned,omitempty"`
+}
+
+// Best returns the best candidate, nil when there are none.
+func (r *DispatchRanking) Best() *DispatchCandidate {
+	if len(r.Candidates) == 0 {
+		return nil
+	}
+	return r.Candidates[0]
+}
@@ -0,0 +1,23 @@
+package models
+
+// Sources of the speed an ETA is based on.
+const (
+	// Average moving speed over the recent route of the courier
+	ETASpeedRoute = "route"
+	// Configured fallback, used while the recent route is too short or the courier stands still
+	ETASpeedDefault = "default"
+)
+
+// ETA is the estimated arrival of the courier at the order destination.
+type ETA struct {
+	// Metres left to the destination
+	Distance float64 `json:"distance"`
+	// m/s
+	Speed       float64 `json:"speed"`
+	SpeedSource string  `json:"speed_source"`
+	// Seconds left
+	Duration int64 `json:"duration"`
+	// Unix seconds
+	ArrivalAt  int64 `json:"arrival_at"`
+	ComputedAt int64 `json:"computed_at"`
+}
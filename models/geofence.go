@@ -0,0 +1,121 @@
+package models
+
+import "github.com/olivere/elastic"
+
+// Geofence is a named area, either a polygon or a circle, whose borders crossed by couriers are logged.
+type Geofence struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// Free-form category, e.g. warehouse, restricted or client
+	Kind    string      `json:"kind,omitempty"`
+	Polygon FlatPolygon `json:"polygon,omitempty"`
+	// Circle center and radius in metres
+	Center *elastic.GeoPoint `json:"center,omitempty"`
+	Radius float64           `json:"radius,omitempty"`
+	// Seconds a courier stays inside before a dwell event, zero disables dwell events
+	DwellTime int64 `json:"dwell_time,omitempty"`
+	CreatedAt int64 `json:"created_at"`
+	UpdatedAt int64 `json:"updated_at"`
+}
+
+type Geofences []*Geofence
+
+type GeofenceCreate struct {
+	Name      string            `json:"name" binding:"required"`
+	Kind      string            `json:"kind"`
+	Polygon   FlatPolygon       `json:"polygon"`
+	Center    *elastic.GeoPoint `json:"center"`
+	Radius    float64           `json:"radius"`
+	DwellTime int64             `json:"dwell_time"`
+}
+
+// GeofenceUpdate changes the given fields. A new polygon or center replaces the whole shape.
+type GeofenceUpdate struct {
+	Name      *string           `json:"name"`
+	Kind      *string           `json:"kind"`
+	Polygon   FlatPolygon       `json:"polygon"`
+	Center    *elastic.GeoPoint `json:"center"`
+	Radius    *float64          `json:"radius"`
+	DwellTime *int64            `json:"dwell_time"`
+}
+
+// Valid reports whether the geofence has a name and exactly one well-formed shape.
+func (g *Geofence) Valid() bool {
+	if g.Name == "" || g.DwellTime < 0 {
+		return false
+	}
+	if len(g.Polygon) > 0 {
+		if g.Center != nil || g.Radius != 0 || len(g.Polygon) < 3 {
+			return false
+		}
+		for _, p := range g.Polygon {
+			if !validPoint(p) {
+				return false
+			}
+		}
+		return true
+	}
+	return validPoint(g.Center) && g.Radius > 0
+}
+
+func validPoint(p *elastic.GeoPoint) bool {
+	return p != nil && p.Lat >= -90 && p.Lat <= 90 && p.Lon >= -180 && p.Lon <= 180
+}
+
+// Geofence event types.
+const (
+	GeofenceEnter = "enter"
+	GeofenceExit  = "exit"
+	// The courier has stayed inside for the dwell time of the geofence, sent once per visit
+	GeofenceDwell = "dwell"
+)
+
+type GeofenceEvent struct {
+	ID           string            `json:"id"`
+	Type         string            `json:"type"`
+	GeofenceID   string            `json:"geofence_id"`
+	GeofenceName string            `json:"geofence_name"`
+	CourierID    string            `json:"courier_id"`
+	Point        *elastic.GeoPoint `json:"point"`
+	// Time of the location that caused the event, unix seconds
+	Ts int64 `json:"timestamp"`
+}
+
+type GeofenceEvents []*GeofenceEvent
+
+// GeofenceEventQuery selects events with Since <= ts <= Until, latest first.
+// Empty string fields match everything and zero Until means no upper bound.
+type GeofenceEventQuery struct {
+	CourierID  string
+	GeofenceID string
+	Type       string
+	Since      int64
+	Until      int64
+	Limit      int
+}
+
+// Matches reports whether event satisfies the query filters; Limit is not taken into account.
+func (q *GeofenceEventQuery) Matches(event *GeofenceEvent) bool {
+	if q.CourierID != "" && event.CourierID != q.CourierID {
+		return false
+	}
+	if q.GeofenceID != "" && event.GeofenceID != q.GeofenceID {
+		return false
+	}
+	if q.Type != "" && event.Type != q.Type {
+		return false
+	}
+	if event.Ts < q.Since {
+		return false
+	}
+	return q.Until <= 0 || event.Ts <= q.Until
+}
+
+// GeofencePresence is a geofence the courier is inside of.
+type GeofencePresence struct {
+	GeofenceID string `json:"geofence_id"`
+	EnteredAt  int64  `json:"entered_at"`
+	Dwelled    bool   `json:"dwelled"`
+}
+
+type GeofencePresences []*GeofencePresence
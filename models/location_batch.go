@@ -0,0 +1,48 @@
+package models
+
+import "github.com/olivere/elastic"
+
+// LocationSample is a position reported by a courier device with the device timestamp.
+type LocationSample struct {
+	Point *elastic.GeoPoint `json:"point"`
+	// Device time, unix seconds
+	Ts uint64 `json:"timestamp"`
+	Fix
+}
+
+// LocationBatch is a series of samples ordered by timestamp, e.g. buffered while the device was offline.
+type LocationBatch struct {
+	Points []*LocationSample `json:"points"`
+}
+
+// Reasons of rejected or flagged location samples.
+const (
+	SampleInvalidPoint     = "invalid_point"
+	SampleOutOfOrder       = "out_of_order"
+	SampleFutureTimestamp  = "future_timestamp"
+	SampleNotStored        = "not_stored"
+	SampleOlderThanCurrent = "older_than_last_seen"
+)
+
+type LocationSampleIssue struct {
+	// Index of the sample in the batch
+	Index  int    `json:"index"`
+	Reason string `json:"reason"`
+}
+
+// LocationBatchResult reports what happened to every sample of a batch.
+// Flagged samples are stored in the route but do not move the courier.
+type LocationBatchResult struct {
+	Accepted int                    `json:"accepted"`
+	Rejected []*LocationSampleIssue `json:"rejected"`
+	Flagged  []*LocationSampleIssue `json:"flagged"`
+	Courier  *Courier               `json:"courier,omitempty"`
+}
+
+func (r *LocationBatchResult) Reject(index int, reason string) {
+	r.Rejected = append(r.Rejected, &LocationSampleIssue{Index: index, Reason: reason})
+}
+
+func (r *LocationBatchResult) Flag(index int, reason string) {
+	r.Flagged = append(r.Flagged, &LocationSampleIssue{Index: index, Reason: reason})
+}
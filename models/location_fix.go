@@ -0,0 +1,76 @@
+package models
+
+// Location providers reported by courier devices.
+const (
+	ProviderGPS     = "gps"
+	ProviderNetwork = "network"
+	ProviderManual  = "manual"
+)
+
+// Fix describes how a position was measured. Every field is optional.
+type Fix struct {
+	// Horizontal accuracy, metres
+	Accuracy *float64 `json:"accuracy,omitempty"`
+	// Metres per second
+	Speed *float64 `json:"speed,omitempty"`
+	// Direction of travel, degrees clockwise from north
+	Bearing *float64 `json:"bearing,omitempty"`
+	// Metres above the WGS 84 ellipsoid
+	Altitude *float64 `json:"altitude,omitempty"`
+	// gps, network or manual
+	Provider *string `json:"provider,omitempty"`
+}
+
+// Valid reports whether the present fields are within their ranges.
+func (f *Fix) Valid() bool {
+	if f.Accuracy != nil && *f.Accuracy < 0 {
+		return false
+	}
+	if f.Speed != nil && *f.Speed < 0 {
+		return false
+	}
+	if f.Bearing != nil && (*f.Bearing < 0 || *f.Bearing >= 360) {
+		return false
+	}
+	if f.Provider != nil {
+		switch *f.Provider {
+		case ProviderGPS, ProviderNetwork, ProviderManual:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// AccurateTo reports whether the fix is at least as accurate as maxAccuracy metres.
+// Fixes without accuracy are trusted.
+func (f *Fix) AccurateTo(maxAccuracy float64) bool {
+	return maxAccuracy <= 0 || f.Accuracy == nil || *f.Accuracy <= maxAccuracy
+}
+
+// Copy returns a fix that shares no pointers with f.
+func (f Fix) Copy() Fix {
+	return Fix{
+		Accuracy: copyFloat64(f.Accuracy),
+		Speed:    copyFloat64(f.Speed),
+		Bearing:  copyFloat64(f.Bearing),
+		Altitude: copyFloat64(f.Altitude),
+		Provider: copyString(f.Provider),
+	}
+}
+
+func copyFloat64(v *float64) *float64 {
+	if v == nil {
+		return nil
+	}
+	copied := *v
+	return &copied
+}
+
+func copyString(v *string) *string {
+	if v == nil {
+		return nil
+	}
+	copied := *v
+	return &copied
+}
@@ -1,5 +1,7 @@
 package models
 
+import "github.com/olivere/elastic"
+
 // Courier - Strict courier schema
 type Courier struct {
 	ID string `json:"id"`
@@ -15,4 +17,21 @@ type Courier struct {
 	LastSeen    *int64 `json:"last_seen,omitempty"`
 	OrdersCount int    `json:"orders_count"`
 	IsActive    bool   `json:"is_active,omitempty"`
+	// Set with IsActive=false when the courier went silent for too long, cleared by the next location update
+	Stale bool `json:"stale,omitempty"`
+}
+
+// LastPoint returns the current location as a route point stamped with LastSeen, or nil if the location is unknown.
+func (c *Courier) LastPoint() *PointWithTs {
+	if c.Location == nil || c.Location.Point == nil {
+		return nil
+	}
+	point := &PointWithTs{
+		Point: elastic.GeoPointFromLatLon(c.Location.Point.Lat, c.Location.Point.Lon),
+		Fix:   c.Location.Fix.Copy(),
+	}
+	if c.LastSeen != nil {
+		point.Ts = uint64(*c.LastSeen)
+	}
+	return point
 }
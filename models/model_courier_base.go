@@ -7,6 +7,7 @@ type CourierUpdate struct {
 	Phone    *string   `json:"phone,omitempty"`
 	LastSeen *int64    `json:"last_seen,omitempty"`
 	IsActive *bool     `json:"is_active,omitempty"`
+	Stale    *bool     `json:"stale,omitempty"`
 }
 
 type CourierCreate struct {
@@ -34,4 +34,9 @@ var (
 	ErrOneOfParameterHaveIncorrectFormat = Error{Message: "One of parameter (%s) have incorrect format", Code: 40, HttpCode: http.StatusBadRequest}
 	ErrEntityNotFound                    = Error{Message: "Entity with such id %s not found", Code: 50, HttpCode: http.StatusNotFound}
 	ErrUnmarshalJSON                     = Error{Message: "Error with unmarshal JSON: %s", Code: 70, HttpCode: http.StatusBadRequest}
+	ErrTooManySubscribers                = Error{Message: "Too many stream subscribers", Code: 80, HttpCode: http.StatusServiceUnavailable}
+	ErrInvalidOrderTransition            = Error{Message: "Order can not move to status %s", Code: 90, HttpCode: http.StatusConflict}
+	ErrOrderAlreadyClaimed               = Error{Message: "Order %s is already claimed", Code: 100, HttpCode: http.StatusConflict}
+	ErrOrderSourceUnknown                = Error{Message: "Order %s has no source point", Code: 110, HttpCode: http.StatusUnprocessableEntity}
+	ErrOrderNotReassignable              = Error{Message: "Order %s can not be handed over to the courier", Code: 120, HttpCode: http.StatusConflict}
 )
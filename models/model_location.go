@@ -8,4 +8,6 @@ type Location struct {
 
 	//Address for location
 	Address *string `json:"address,omitempty"`
+
+	Fix
 }
@@ -11,14 +11,30 @@ type Order struct {
 	// Order creation time in UTC format(ms)
 	CreatedAt int64 `json:"created_at,omitempty"`
 
-	// Order cancellation time in UTC format(ms)
+	// Delivery time, server unix seconds: the time of the transition to delivered
 	DeliveredAt int64 `json:"delivered_at,omitempty"`
+	// Delivery time reported by the courier, client clock in milliseconds
+	ReportedDeliveredAt int64 `json:"reported_delivered_at,omitempty"`
 
 	Destination Location `json:"destination,omitempty"`
 
 	Source Location `json:"source,omitempty"`
 
 	OrderNumber int `json:"order_number"`
+
+	Status        string               `json:"status,omitempty"`
+	StatusHistory []*OrderStatusChange `json:"status_history,omitempty"`
+	// Couriers the order was handed over between, the oldest first
+	Handovers []*OrderHandover `json:"handovers,omitempty"`
+
+	// Arrival timestamps recorded from courier locations, unix seconds
+	ArrivedAtPickup int64 `json:"arrived_at_pickup,omitempty"`
+	// The courier left the pickup after arriving at it
+	PickedUp             int64 `json:"picked_up,omitempty"`
+	ArrivedAtDestination int64 `json:"arrived_at_destination,omitempty"`
+
+	// Estimated arrival, computed on read for undelivered orders and never stored
+	ETA *ETA `json:"eta,omitempty"`
 }
 
 type OrderCreate struct {
@@ -38,12 +54,21 @@ type OrderUpdate struct {
 	// Order creation time in UTC format(ms)
 	CreatedAt *int64 `json:"created_at,omitempty"`
 
-	// Order cancellation time in UTC format(ms)
-	DeliveredAt *int64 `json:"delivered_at,omitempty"`
+	// Delivers the order. Stored by the service as reported_delivered_at, delivered_at is set by the transition
+	DeliveredAt         *int64 `json:"delivered_at,omitempty"`
+	ReportedDeliveredAt *int64 `json:"reported_delivered_at,omitempty"`
 
 	//Destination of order
 	Destination *Location `json:"destination,omitempty"`
 
 	//Source of order
 	Source *Location `json:"source,omitempty"`
+
+	ArrivedAtPickup      *int64 `json:"arrived_at_pickup,omitempty"`
+	PickedUp             *int64 `json:"picked_up,omitempty"`
+	ArrivedAtDestination *int64 `json:"arrived_at_destination,omitempty"`
+
+	// Changed through transitions only, the whole history is replaced
+	Status        *string              `json:"status,omitempty"`
+	StatusHistory []*OrderStatusChange `json:"status_history,omitempty"`
 }
@@ -0,0 +1,78 @@
+package models
+
+// OrderHandover is an entry of the order handover log, written every time the order moves to another courier.
+type OrderHandover struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	// Unix seconds
+	At     int64  `json:"at"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// Reassign hands an active order over to another courier and logs the handover.
+func (o *Order) Reassign(courierID, reason string, at int64) error {
+	if !IsActiveOrderStatus(o.CurrentStatus()) || o.CourierID == courierID {
+		return ErrOrderNotReassignable.SetParameter(o.ID)
+	}
+	o.Handovers = append(o.Handovers, &OrderHandover{From: o.CourierID, To: courierID, At: at, Reason: reason})
+	o.CourierID = courierID
+	return nil
+}
+
+// LastHandover returns the latest handover, nil when the order never changed couriers.
+func (o *Order) LastHandover() *OrderHandover {
+	if len(o.Handovers) == 0 {
+		return nil
+	}
+	return o.Handovers[len(o.Handovers)-1]
+}
+
+// HeldBy reports whether the courier carries the order or carried it before a handover.
+func (o *Order) HeldBy(courierID string) bool {
+	if o.CourierID == courierID {
+		return true
+	}
+	for _, handover := range o.Handovers {
+		if handover.From == courierID {
+			return true
+		}
+	}
+	return false
+}
+
+// OrderSegment is the part of the trip of an order made by one courier. Until is 0 while the courier still carries it.
+type OrderSegment struct {
+	CourierID string
+	Since     int64
+	Until     int64
+}
+
+// Segments splits the trip of the order between its couriers by the handover log, the oldest first. The first
+// courier carries the order from its creation, or from the claim for orders created unassigned, every handover
+// passes it on and the last courier carries it until the order reaches a final status.
+// An order that never had a courier has no segments.
+func (o *Order) Segments() []*OrderSegment {
+	first := o.CourierID
+	if len(o.Handovers) > 0 {
+		first = o.Handovers[0].From
+	}
+	if first == "" {
+		return nil
+	}
+	since := o.CreatedAt
+	for _, change := range o.StatusHistory {
+		if change.From == OrderUnassigned {
+			since = change.At
+			break
+		}
+	}
+	segments := make([]*OrderSegment, 0, len(o.Handovers)+1)
+	current := &OrderSegment{CourierID: first, Since: since}
+	for _, handover := range o.Handovers {
+		current.Until = handover.At
+		segments = append(segments, current)
+		current = &OrderSegment{CourierID: handover.To, Since: handover.At}
+	}
+	current.Until = o.FinishedAt()
+	return append(segments, current)
+}
@@ -0,0 +1,47 @@
+package models
+
+import "github.com/olivere/elastic"
+
+// Points of an order a geo search matches against.
+const (
+	OrderPointSource      = "source"
+	OrderPointDestination = "destination"
+)
+
+// OrderSearch narrows a geo search over orders. Zero values do not filter.
+type OrderSearch struct {
+	// OrderPointSource or OrderPointDestination, destination when empty
+	Point     string
+	CourierID string
+	Statuses  []string
+	// Bounds of created_at, unix seconds inclusive
+	Since int64
+	Until int64
+	Size  int
+}
+
+func IsOrderPoint(point string) bool {
+	return point == OrderPointSource || point == OrderPointDestination
+}
+
+// PointOf returns the searched point of the order, nil when it is not resolved.
+func (s *OrderSearch) PointOf(order *Order) *elastic.GeoPoint {
+	if s.Point == OrderPointSource {
+		return order.Source.Point
+	}
+	return order.Destination.Point
+}
+
+// Match reports whether the order passes every filter except the geo one.
+func (s *OrderSearch) Match(order *Order) bool {
+	if s.CourierID != "" && order.CourierID != s.CourierID {
+		return false
+	}
+	if s.Since != 0 && order.CreatedAt < s.Since {
+		return false
+	}
+	if s.Until != 0 && order.CreatedAt > s.Until {
+		return false
+	}
+	return order.HasStatus(s.Statuses)
+}
@@ -0,0 +1,194 @@
+package models
+
+// Order statuses. An order is created assigned to a courier, or unassigned until a courier claims it;
+// delivered, failed and cancelled are final.
+const (
+	OrderUnassigned = "unassigned"
+	OrderAssigned   = "assigned"
+	OrderPickedUp   = "picked_up"
+	OrderInTransit  = "in_transit"
+	OrderDelivered  = "delivered"
+	OrderFailed     = "failed"
+	OrderCancelled  = "cancelled"
+)
+
+// Actor of transitions made by the service itself, e.g. on order creation or arrival detection.
+const OrderActorSystem = "system"
+
+// Reason of the transition of a claimed order to assigned.
+const OrderClaimReason = "claimed"
+
+// orderTransitions lists the statuses an order may move to from each status.
+var orderTransitions = map[string][]string{
+	OrderUnassigned: {OrderAssigned, OrderCancelled},
+	OrderAssigned:   {OrderPickedUp, OrderDelivered, OrderFailed, OrderCancelled},
+	OrderPickedUp:   {OrderInTransit, OrderDelivered, OrderFailed, OrderCancelled},
+	OrderInTransit:  {OrderDelivered, OrderFailed, OrderCancelled},
+}
+
+func IsOrderStatus(status string) bool {
+	switch status {
+	case OrderUnassigned, OrderAssigned, OrderPickedUp, OrderInTransit, OrderDelivered, OrderFailed, OrderCancelled:
+		return true
+	}
+	return false
+}
+
+// IsActiveOrderStatus reports whether orders in status are counted by the OrdersCountTracker of their courier.
+// Unassigned orders have no courier to be counted by.
+func IsActiveOrderStatus(status string) bool {
+	_, ok := orderTransitions[status]
+	return ok && status != OrderUnassigned
+}
+
+// ActiveOrderStatuses lists the statuses IsActiveOrderStatus accepts.
+func ActiveOrderStatuses() []string {
+	return []string{OrderAssigned, OrderPickedUp, OrderInTransit}
+}
+
+// OrderTransitions returns the statuses an order may move to from each status.
+func OrderTransitions() map[string][]string {
+	transitions := make(map[string][]string, len(orderTransitions))
+	for from, to := range orderTransitions {
+		transitions[from] = append([]string(nil), to...)
+	}
+	return transitions
+}
+
+func CanTransition(from, to string) bool {
+	for _, status := range orderTransitions[from] {
+		if status == to {
+			return true
+		}
+	}
+	return false
+}
+
+// OrderStatusChange is an entry of the order status history.
+type OrderStatusChange struct {
+	// Empty for the initial status
+	From string `json:"from,omitempty"`
+	To   string `json:"to"`
+	// Unix seconds
+	At     int64  `json:"at"`
+	Actor  string `json:"actor"`
+	Reason string `json:"reason,omitempty"`
+}
+
+type OrderTransition struct {
+	Status string `json:"status" binding:"required"`
+	// Who made the change, e.g. a courier or a dispatcher id
+	Actor  string `json:"actor" binding:"required"`
+	Reason string `json:"reason"`
+}
+
+// CurrentStatus returns the status of the order. Orders stored before statuses were introduced have none,
+// theirs is derived from DeliveredAt.
+func (o *Order) CurrentStatus() string {
+	if o.Status != "" {
+		return o.Status
+	}
+	if o.DeliveredAt != 0 {
+		return OrderDelivered
+	}
+	return OrderAssigned
+}
+
+// StartStatus sets the initial status of a new order: unassigned when it has no courier.
+func (o *Order) StartStatus() {
+	o.Status = OrderAssigned
+	if o.CourierID == "" {
+		o.Status = OrderUnassigned
+	}
+	o.StatusHistory = []*OrderStatusChange{{To: o.Status, At: o.CreatedAt, Actor: OrderActorSystem}}
+}
+
+// Claim assigns an unassigned order to the courier, the courier is the actor of the transition.
+func (o *Order) Claim(courierID string, at int64) error {
+	if o.CurrentStatus() != OrderUnassigned {
+		return ErrOrderAlreadyClaimed.SetParameter(o.ID)
+	}
+	if err := o.ApplyTransition(OrderAssigned, courierID, OrderClaimReason, at); err != nil {
+		return err
+	}
+	o.CourierID = courierID
+	return nil
+}
+
+// ApplyTransition moves the order to status and logs the change. Moving to delivered also sets DeliveredAt.
+func (o *Order) ApplyTransition(status, actor, reason string, at int64) error {
+	from := o.CurrentStatus()
+	if !CanTransition(from, status) {
+		return ErrInvalidOrderTransition.SetParameter(status)
+	}
+	o.Status = status
+	o.StatusHistory = append(o.StatusHistory, &OrderStatusChange{From: from, To: status, At: at, Actor: actor, Reason: reason})
+	if status == OrderDelivered && o.DeliveredAt == 0 {
+		o.DeliveredAt = at
+	}
+	return nil
+}
+
+// DeliveryTime returns the server time of the transition to delivered, 0 if the order was not delivered through one.
+func (o *Order) DeliveryTime() int64 {
+	for i := len(o.StatusHistory) - 1; i >= 0; i-- {
+		if o.StatusHistory[i].To == OrderDelivered {
+			return o.StatusHistory[i].At
+		}
+	}
+	return 0
+}
+
+// FinishedAt returns the server time the order reached its final status, 0 while it is not final
+// or for orders stored without a status history.
+func (o *Order) FinishedAt() int64 {
+	status := o.CurrentStatus()
+	if IsActiveOrderStatus(status) || status == OrderUnassigned {
+		return 0
+	}
+	if last := o.LastStatusChange(); last != nil && last.To == status {
+		return last.At
+	}
+	return 0
+}
+
+// LastStatusChange returns the latest entry of the status history, nil for orders stored without one.
+func (o *Order) LastStatusChange() *OrderStatusChange {
+	if len(o.StatusHistory) == 0 {
+		return nil
+	}
+	return o.StatusHistory[len(o.StatusHistory)-1]
+}
+
+// TransitionFor applies change, its From is ignored, to an order of the courier. Orders of another courier are not found.
+func (o *Order) TransitionFor(courierID string, change *OrderStatusChange) error {
+	if o.CourierID != courierID {
+		return ErrEntityNotFound.SetParameter(o.ID)
+	}
+	return o.ApplyTransition(change.To, change.Actor, change.Reason, change.At)
+}
+
+// StatusUpdate returns the partial update storing the status of the order.
+func (o *Order) StatusUpdate() *OrderUpdate {
+	id, status := o.ID, o.Status
+	update := &OrderUpdate{ID: &id, Status: &status, StatusHistory: o.StatusHistory}
+	if o.DeliveredAt != 0 {
+		deliveredAt := o.DeliveredAt
+		update.DeliveredAt = &deliveredAt
+	}
+	return update
+}
+
+// HasStatus reports whether the order is in one of statuses, an empty list matches every order.
+func (o *Order) HasStatus(statuses []string) bool {
+	if len(statuses) == 0 {
+		return true
+	}
+	current := o.CurrentStatus()
+	for _, status := range statuses {
+		if status == current {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,98 @@
+package models
+
+import (
+	"errors"
+	"github.com/olivere/elastic"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Sort keys of courier orders.
+const (
+	OrdersSortCreatedAt   = "created_at"
+	OrdersSortOrderNumber = "order_number"
+	// Distance from OrdersQuery.From to the order destination, orders without a resolved destination go last
+	OrdersSortDistance = "distance"
+)
+
+var ErrInvalidOrdersCursor = errors.New("invalid orders cursor")
+
+func IsOrdersSort(sort string) bool {
+	switch sort {
+	case OrdersSortCreatedAt, OrdersSortOrderNumber, OrdersSortDistance:
+		return true
+	}
+	return false
+}
+
+// OrdersQuery selects a page of the orders of a courier. A zero Limit returns every order.
+type OrdersQuery struct {
+	Since int64
+	// Orders created at or after Since when set, at or before it otherwise
+	SinceIsLower     bool
+	ExcludeDelivered bool
+	// Empty matches every status
+	Statuses []string
+	// OrdersSortCreatedAt when empty. Ties are broken by order id.
+	Sort string
+	Desc bool
+	// Point distances are measured from, required by OrdersSortDistance
+	From  *elastic.GeoPoint
+	Limit int
+	// Continue strictly after the order the cursor points to
+	After *OrdersCursor
+}
+
+// OrdersCursor is the sort key of an order: the value it is sorted by and its id.
+// Orders without a distance are sorted by an infinite one.
+type OrdersCursor struct {
+	Value float64
+	ID    string
+	// Sort values of the order as the storage returned them, passed back as is when set. Not kept by String.
+	Raw []interface{}
+}
+
+func (c *OrdersCursor) String() string {
+	value := strconv.FormatFloat(c.Value, 'g', -1, 64)
+	if math.IsInf(c.Value, 1) {
+		// "+Inf" would lose its plus in a query string, ParseFloat reads both
+		value = "Infinity"
+	}
+	return value + "_" + c.ID
+}
+
+func ParseOrdersCursor(cursor string) (*OrdersCursor, error) {
+	parts := strings.SplitN(cursor, "_", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return nil, ErrInvalidOrdersCursor
+	}
+	value, err := strconv.ParseFloat(parts[0], 64)
+	if err != nil {
+		return nil, ErrInvalidOrdersCursor
+	}
+	return &OrdersCursor{Value: value, ID: parts[1]}, nil
+}
+
+// Passed reports whether the order with the sort key (value, id) is the cursor order or goes before it
+// in the given direction.
+func (c *OrdersCursor) Passed(value float64, id string, desc bool) bool {
+	if value == c.Value {
+		if desc {
+			return id >= c.ID
+		}
+		return id <= c.ID
+	}
+	if desc {
+		return value > c.Value
+	}
+	return value < c.Value
+}
+
+// OrdersPage is a page of courier orders. Total counts every order matching the query regardless of paging,
+// Next is set when the page is full and more orders may follow.
+type OrdersPage struct {
+	Orders Orders
+	Total  int64
+	Next   *OrdersCursor
+}
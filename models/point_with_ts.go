@@ -5,6 +5,7 @@ import "github.com/olivere/elastic"
 type PointWithTs struct {
 	Point *elastic.GeoPoint `json:"point"`
 	Ts    uint64            `json:"timestamp"`
+	Fix
 }
 
 type Points []*PointWithTs
@@ -0,0 +1,59 @@
+package models
+
+// Reasons the location filter rejects a point for.
+const (
+	OutlierImpossibleSpeed = "impossible_speed"
+	OutlierDuplicate       = "duplicate"
+	OutlierNearDuplicate   = "near_duplicate"
+)
+
+// Endpoints a filtered point may come from.
+const (
+	LocationSourceUpdate = "update"
+	LocationSourceBatch  = "batch"
+)
+
+// RejectedLocation is the audit record of a point the location filter kept out of the courier location and route.
+type RejectedLocation struct {
+	ID        string       `json:"id"`
+	CourierID string       `json:"courier_id"`
+	Point     *PointWithTs `json:"point"`
+	// Last accepted point the rejected one was compared with
+	Previous *PointWithTs `json:"previous,omitempty"`
+	Reason   string       `json:"reason"`
+	// Distance from the previous point in metres, less both accuracies
+	Distance float64 `json:"distance"`
+	// Speed implied by Distance, metres per second
+	Speed float64 `json:"speed"`
+	// A quarantined point is held as a candidate: the next point agreeing with it is let through
+	Quarantined bool   `json:"quarantined"`
+	Source      string `json:"source"`
+	// Unix seconds
+	RejectedAt int64 `json:"rejected_at"`
+}
+
+type RejectedLocations []*RejectedLocation
+
+// RejectedLocationQuery selects records rejected within the [Since, Until] window, latest first.
+// Empty CourierID or Reason match everything and zero Until means no upper bound.
+type RejectedLocationQuery struct {
+	CourierID string
+	Reason    string
+	Since     int64
+	Until     int64
+	Limit     int
+}
+
+// Matches reports whether rejected satisfies the query filters; Limit is not taken into account.
+func (q *RejectedLocationQuery) Matches(rejected *RejectedLocation) bool {
+	if q.CourierID != "" && rejected.CourierID != q.CourierID {
+		return false
+	}
+	if q.Reason != "" && rejected.Reason != q.Reason {
+		return false
+	}
+	if rejected.RejectedAt < q.Since {
+		return false
+	}
+	return q.Until <= 0 || rejected.RejectedAt <= q.Until
+}
@@ -0,0 +1,47 @@
+package models
+
+// ArchivedRoute is the track of a completed trip, copied from the live route before it is wiped.
+type ArchivedRoute struct {
+	ID        string `json:"id"`
+	CourierID string `json:"courier_id"`
+
+	// Timestamps of the first and the last points, unix seconds
+	StartedAt  int64 `json:"started_at"`
+	FinishedAt int64 `json:"finished_at"`
+	ArchivedAt int64 `json:"archived_at"`
+
+	GeoHistory Points `json:"geo_history"`
+}
+
+type ArchivedRoutes []*ArchivedRoute
+
+// NewArchivedRoute wraps points ordered by timestamp; points must not be empty.
+func NewArchivedRoute(courierID string, points Points, archivedAt int64) *ArchivedRoute {
+	return &ArchivedRoute{
+		CourierID:  courierID,
+		StartedAt:  int64(points[0].Ts),
+		FinishedAt: int64(points[len(points)-1].Ts),
+		ArchivedAt: archivedAt,
+		GeoHistory: points,
+	}
+}
+
+// RouteArchiveQuery selects archived routes overlapping the [Since, Until] window, latest first.
+// Empty CourierID matches every courier and zero Until means no upper bound.
+type RouteArchiveQuery struct {
+	CourierID string
+	Since     int64
+	Until     int64
+	Limit     int
+}
+
+// Matches reports whether route satisfies the query filters; Limit is not taken into account.
+func (q *RouteArchiveQuery) Matches(route *ArchivedRoute) bool {
+	if q.CourierID != "" && route.CourierID != q.CourierID {
+		return false
+	}
+	if route.FinishedAt < q.Since {
+		return false
+	}
+	return q.Until <= 0 || route.StartedAt <= q.Until
+}
@@ -0,0 +1,172 @@
+package models
+
+import (
+	"encoding/xml"
+	"fmt"
+	"time"
+)
+
+// Route export formats accepted by the geo history endpoint.
+const (
+	RouteFormatJSON    = "json"
+	RouteFormatGeoJSON = "geojson"
+	RouteFormatGPX     = "gpx"
+	RouteFormatKML     = "kml"
+)
+
+// Content types of the route export formats.
+const (
+	MIMEGeoJSON = "application/geo+json"
+	MIMEGPX     = "application/gpx+xml"
+	MIMEKML     = "application/vnd.google-earth.kml+xml"
+)
+
+const routeExportCreator = "geo-rest"
+
+// GeoJSONFeature is a GeoJSON Feature with a LineString geometry.
+// Point timestamps are kept in the "coordTimes" (RFC 3339) and "timestamps" (unix seconds) properties.
+type GeoJSONFeature struct {
+	Type       string                 `json:"type"`
+	Geometry   GeoJSONLineString      `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+type GeoJSONLineString struct {
+	Type        string       `json:"type"`
+	Coordinates [][2]float64 `json:"coordinates"`
+}
+
+func NewGeoJSONFeature(courierID string, points Points) *GeoJSONFeature {
+	coordinates := make([][2]float64, 0, len(points))
+	coordTimes := make([]string, 0, len(points))
+	timestamps := make([]uint64, 0, len(points))
+	for _, p := range points {
+		if p == nil || p.Point == nil {
+			continue
+		}
+		coordinates = append(coordinates, [2]float64{p.Point.Lon, p.Point.Lat})
+		coordTimes = append(coordTimes, formatRouteTime(p.Ts))
+		timestamps = append(timestamps, p.Ts)
+	}
+	return &GeoJSONFeature{
+		Type: "Feature",
+		Geometry: GeoJSONLineString{
+			Type:        "LineString",
+			Coordinates: coordinates,
+		},
+		Properties: map[string]interface{}{
+			"courier_id": courierID,
+			"coordTimes": coordTimes,
+			"timestamps": timestamps,
+		},
+	}
+}
+
+// GPX is a GPX 1.1 document with a single track.
+type GPX struct {
+	XMLName xml.Name `xml:"gpx"`
+	Xmlns   string   `xml:"xmlns,attr"`
+	Version string   `xml:"version,attr"`
+	Creator string   `xml:"creator,attr"`
+	Track   GPXTrack `xml:"trk"`
+}
+
+type GPXTrack struct {
+	Name    string          `xml:"name"`
+	Segment GPXTrackSegment `xml:"trkseg"`
+}
+
+type GPXTrackSegment struct {
+	Points []GPXTrackPoint `xml:"trkpt"`
+}
+
+type GPXTrackPoint struct {
+	Lat  float64  `xml:"lat,attr"`
+	Lon  float64  `xml:"lon,attr"`
+	Ele  *float64 `xml:"ele,omitempty"`
+	Time string   `xml:"time"`
+}
+
+func NewGPX(courierID string, points Points) *GPX {
+	trackPoints := make([]GPXTrackPoint, 0, len(points))
+	for _, p := range points {
+		if p == nil || p.Point == nil {
+			continue
+		}
+		trackPoints = append(trackPoints, GPXTrackPoint{
+			Lat:  p.Point.Lat,
+			Lon:  p.Point.Lon,
+			Ele:  p.Altitude,
+			Time: formatRouteTime(p.Ts),
+		})
+	}
+	return &GPX{
+		Xmlns:   "http://www.topografix.com/GPX/1/1",
+		Version: "1.1",
+		Creator: routeExportCreator,
+		Track: GPXTrack{
+			Name:    courierID,
+			Segment: GPXTrackSegment{Points: trackPoints},
+		},
+	}
+}
+
+// KML is a KML 2.2 document with a single gx:Track placemark, which keeps a timestamp for every point.
+type KML struct {
+	XMLName  xml.Name    `xml:"kml"`
+	Xmlns    string      `xml:"xmlns,attr"`
+	XmlnsGx  string      `xml:"xmlns:gx,attr"`
+	Document KMLDocument `xml:"Document"`
+}
+
+type KMLDocument struct {
+	Name      string       `xml:"name"`
+	Placemark KMLPlacemark `xml:"Placemark"`
+}
+
+type KMLPlacemark struct {
+	Name  string   `xml:"name"`
+	Track KMLTrack `xml:"gx:Track"`
+}
+
+type KMLTrack struct {
+	When  []string `xml:"when"`
+	Coord []string `xml:"gx:coord"`
+}
+
+func NewKML(courierID string, points Points) *KML {
+	track := KMLTrack{
+		When:  make([]string, 0, len(points)),
+		Coord: make([]string, 0, len(points)),
+	}
+	for _, p := range points {
+		if p == nil || p.Point == nil {
+			continue
+		}
+		track.When = append(track.When, formatRouteTime(p.Ts))
+		track.Coord = append(track.Coord, kmlCoord(p))
+	}
+	return &KML{
+		Xmlns:   "http://www.opengis.net/kml/2.2",
+		XmlnsGx: "http://www.google.com/kml/ext/2.2",
+		Document: KMLDocument{
+			Name: courierID,
+			Placemark: KMLPlacemark{
+				Name:  courierID,
+				Track: track,
+			},
+		},
+	}
+}
+
+// kmlCoord leaves the altitude out when it is unknown, KML reads a missing altitude as 0.
+func kmlCoord(p *PointWithTs) string {
+	if p.Altitude == nil {
+		return fmt.Sprintf("%v %v", p.Point.Lon, p.Point.Lat)
+	}
+	return fmt.Sprintf("%v %v %v", p.Point.Lon, p.Point.Lat, *p.Altitude)
+}
+
+func formatRouteTime(ts uint64) string {
+	return time.Unix(int64(ts), 0).UTC().Format(time.RFC3339)
+}
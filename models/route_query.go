@@ -0,0 +1,59 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidRouteCursor = errors.New("invalid route cursor")
+
+// RouteQuery selects points with Since < ts <= Until ordered by timestamp.
+// A zero Until means no upper bound and a zero Limit means no limit.
+type RouteQuery struct {
+	Since int64
+	Until int64
+	Limit int
+	// Skip points with accuracy worse than MaxAccuracy metres, zero keeps every point
+	MaxAccuracy float64
+	// Continue strictly after the point the cursor points to
+	After *RouteCursor
+}
+
+// RouteCursor identifies a route point: points sharing a timestamp are told apart by their insertion sequence.
+type RouteCursor struct {
+	Ts  uint64
+	Seq uint64
+}
+
+func (c *RouteCursor) String() string {
+	return fmt.Sprintf("%d-%d", c.Ts, c.Seq)
+}
+
+func ParseRouteCursor(cursor string) (*RouteCursor, error) {
+	parts := strings.Split(cursor, "-")
+	if len(parts) != 2 {
+		return nil, ErrInvalidRouteCursor
+	}
+	ts, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return nil, ErrInvalidRouteCursor
+	}
+	seq, err := strconv.ParseUint(parts[1], 10, 64)
+	if err != nil {
+		return nil, ErrInvalidRouteCursor
+	}
+	return &RouteCursor{Ts: ts, Seq: seq}, nil
+}
+
+// Passed reports whether the point at (ts, seq) is the cursor point or goes before it.
+func (c *RouteCursor) Passed(ts, seq uint64) bool {
+	return ts < c.Ts || (ts == c.Ts && seq <= c.Seq)
+}
+
+// RoutePage is a part of a route. Next is set when the page is full and more points may follow.
+type RoutePage struct {
+	Points Points
+	Next   *RouteCursor
+}
@@ -2,4 +2,5 @@ package models
 
 type RouteResponse struct {
 	GeoHistory Points `json:"geo_history"`
+	NextCursor string `json:"next_cursor,omitempty"`
 }
@@ -0,0 +1,41 @@
+package models
+
+import "github.com/olivere/elastic"
+
+// RouteStats - odometer numbers for a part of a courier route
+type RouteStats struct {
+	CourierID string `json:"courier_id"`
+
+	// Window bounds, unix seconds
+	Since int64 `json:"since"`
+	Until int64 `json:"until,omitempty"`
+
+	PointsCount int `json:"points_count"`
+
+	// Total haversine distance, metres
+	Distance float64 `json:"distance"`
+
+	// Time between the first and the last point, seconds
+	Duration int64 `json:"duration"`
+	// Duration outside of stops, seconds
+	MovingTime int64 `json:"moving_time"`
+	// Duration spent in stops, seconds
+	IdleTime int64 `json:"idle_time"`
+
+	// Distance over moving time, m/s
+	AverageSpeed float64 `json:"average_speed"`
+	// Fastest segment between two consecutive points, m/s
+	MaxSpeed float64 `json:"max_speed"`
+
+	Stops []*RouteStop `json:"stops"`
+}
+
+// RouteStop - courier dwell within a small radius
+type RouteStop struct {
+	// Unix seconds
+	StartedAt uint64 `json:"started_at"`
+	EndedAt   uint64 `json:"ended_at"`
+	// Seconds
+	Duration int64             `json:"duration"`
+	Centroid *elastic.GeoPoint `json:"centroid"`
+}
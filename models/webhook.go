@@ -0,0 +1,114 @@
+package models
+
+import "encoding/json"
+
+// Webhook event types.
+const (
+	WebhookOrderCreated    = "order.created"
+	WebhookOrderUpdated    = "order.updated"
+	WebhookOrderDelivered  = "order.delivered"
+	WebhookOrderReassigned = "order.reassigned"
+	WebhookOrderDeleted    = "order.deleted"
+	// Sent when a courier claims an unassigned order
+	WebhookOrderClaimed = "order.claimed"
+	// Sent with the order after every status transition
+	WebhookOrderStatusChanged = "order.status_changed"
+	// Arrival events are sent with the order once its arrival timestamp is recorded
+	WebhookOrderArrivedAtPickup      = "order.arrived_at_pickup"
+	WebhookOrderPickedUp             = "order.picked_up"
+	WebhookOrderArrivedAtDestination = "order.arrived_at_destination"
+	WebhookCourierActivated          = "courier.activated"
+	WebhookCourierDeactivated        = "courier.deactivated"
+	WebhookCourierDeleted            = "courier.deleted"
+)
+
+// WebhookEventTypes lists every event type a subscription may filter on.
+var WebhookEventTypes = []string{
+	WebhookOrderCreated,
+	WebhookOrderUpdated,
+	WebhookOrderDelivered,
+	WebhookOrderReassigned,
+	WebhookOrderDeleted,
+	WebhookOrderClaimed,
+	WebhookOrderStatusChanged,
+	WebhookOrderArrivedAtPickup,
+	WebhookOrderPickedUp,
+	WebhookOrderArrivedAtDestination,
+	WebhookCourierActivated,
+	WebhookCourierDeactivated,
+	WebhookCourierDeleted,
+}
+
+// WebhookSubscription is an endpoint events are posted to.
+type WebhookSubscription struct {
+	ID  string `json:"id"`
+	URL string `json:"url"`
+	// HMAC-SHA256 key of the X-Webhook-Signature header, only returned when the subscription is created
+	Secret string `json:"secret,omitempty"`
+	// Event types to deliver, empty for every type
+	EventTypes []string `json:"event_types,omitempty"`
+	CreatedAt  int64    `json:"created_at"`
+}
+
+type WebhookSubscriptions []*WebhookSubscription
+
+type WebhookSubscriptionCreate struct {
+	URL string `json:"url" binding:"required"`
+	// Generated when empty
+	Secret     string   `json:"secret"`
+	EventTypes []string `json:"event_types"`
+}
+
+// Wants reports whether the subscription filters let eventType through.
+func (s *WebhookSubscription) Wants(eventType string) bool {
+	if len(s.EventTypes) == 0 {
+		return true
+	}
+	for _, t := range s.EventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// IsWebhookEventType reports whether eventType is one of WebhookEventTypes.
+func IsWebhookEventType(eventType string) bool {
+	for _, t := range WebhookEventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookEvent is the body posted to subscribers.
+type WebhookEvent struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+	// Unix seconds
+	OccurredAt int64 `json:"occurred_at"`
+	// The order or courier the event is about
+	Data json.RawMessage `json:"data"`
+}
+
+// WebhookDeleted is the data of deletion events.
+type WebhookDeleted struct {
+	ID        string `json:"id"`
+	CourierID string `json:"courier_id,omitempty"`
+}
+
+// WebhookDeadLetter is an event that could not be delivered to a subscription.
+type WebhookDeadLetter struct {
+	ID             string        `json:"id"`
+	SubscriptionID string        `json:"subscription_id"`
+	Event          *WebhookEvent `json:"event"`
+	Attempts       int           `json:"attempts"`
+	// Status of the last response, zero if there was none
+	LastStatus int    `json:"last_status,omitempty"`
+	LastError  string `json:"last_error"`
+	// Unix seconds
+	FailedAt int64 `json:"failed_at"`
+}
+
+type WebhookDeadLetters []*WebhookDeadLetter
@@ -0,0 +1,51 @@
+package services
+
+import (
+	"github.com/TeamD2018/geo-rest/models"
+	"github.com/TeamD2018/geo-rest/services/geo"
+	"github.com/olivere/elastic"
+)
+
+// DefaultArrivalRadius is in metres.
+const DefaultArrivalRadius = 50
+
+// RadiusArrivalDetector treats a courier within Radius metres of the order source or destination as arrived.
+//
+// An order goes through arrival at pickup, pick up, when the courier leaves the pickup radius, and arrival
+// at the destination. Orders without a source point skip the pickup steps. Arrival at the destination
+// is not recorded while the courier waits at the pickup, so nearby pickup and destination are not mixed up.
+type RadiusArrivalDetector struct {
+	Radius float64
+}
+
+func NewRadiusArrivalDetector(radius float64) *RadiusArrivalDetector {
+	if radius <= 0 {
+		radius = DefaultArrivalRadius
+	}
+	return &RadiusArrivalDetector{Radius: radius}
+}
+
+func (d *RadiusArrivalDetector) Detect(order *models.Order, point *elastic.GeoPoint, ts int64) []string {
+	if point == nil || order.DeliveredAt != 0 {
+		return nil
+	}
+	events := make([]string, 0)
+	if source := order.Source.Point; source != nil {
+		atPickup := geo.Distance(point, source) <= d.Radius
+		switch {
+		case order.ArrivedAtPickup == 0 && atPickup:
+			order.ArrivedAtPickup = ts
+			events = append(events, models.WebhookOrderArrivedAtPickup)
+		case order.ArrivedAtPickup != 0 && order.PickedUp == 0 && !atPickup:
+			order.PickedUp = ts
+			events = append(events, models.WebhookOrderPickedUp)
+		}
+	}
+	waitingAtPickup := order.ArrivedAtPickup != 0 && order.PickedUp == 0
+	if destination := order.Destination.Point; destination != nil && order.ArrivedAtDestination == 0 && !waitingAtPickup &&
+		geo.Distance(point, destination) <= d.Radius {
+		order.ArrivedAtDestination = ts
+		events = append(events, models.WebhookOrderArrivedAtDestination)
+	}
+	return events
+}
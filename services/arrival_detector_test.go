@@ -0,0 +1,48 @@
+package services
+
+import (
+	"github.com/TeamD2018/geo-rest/models"
+	"github.com/olivere/elastic"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestUnitRadiusArrivalDetector(t *testing.T) {
+	detector := NewRadiusArrivalDetector(50)
+	order := &models.Order{
+		Source:      models.Location{Point: elastic.GeoPointFromLatLon(55.70, 37.60)},
+		Destination: models.Location{Point: elastic.GeoPointFromLatLon(55.71, 37.60)},
+	}
+	// ~11 m from the source
+	atPickup := elastic.GeoPointFromLatLon(55.7001, 37.60)
+	onTheWay := elastic.GeoPointFromLatLon(55.705, 37.60)
+	atDestination := elastic.GeoPointFromLatLon(55.7099, 37.60)
+
+	require.Empty(t, detector.Detect(order, onTheWay, 10))
+	require.Equal(t, []string{models.WebhookOrderArrivedAtPickup}, detector.Detect(order, atPickup, 20))
+	require.Empty(t, detector.Detect(order, atPickup, 30))
+	require.Equal(t, []string{models.WebhookOrderPickedUp}, detector.Detect(order, onTheWay, 40))
+	require.Equal(t, []string{models.WebhookOrderArrivedAtDestination}, detector.Detect(order, atDestination, 50))
+	require.Empty(t, detector.Detect(order, atDestination, 60))
+
+	require.Equal(t, int64(20), order.ArrivedAtPickup)
+	require.Equal(t, int64(40), order.PickedUp)
+	require.Equal(t, int64(50), order.ArrivedAtDestination)
+}
+
+func TestUnitRadiusArrivalDetector_NoPickupWait(t *testing.T) {
+	detector := NewRadiusArrivalDetector(50)
+	// the pickup is next to the destination
+	order := &models.Order{
+		Source:      models.Location{Point: elastic.GeoPointFromLatLon(55.70, 37.60)},
+		Destination: models.Location{Point: elastic.GeoPointFromLatLon(55.7002, 37.60)},
+	}
+	require.Equal(t, []string{models.WebhookOrderArrivedAtPickup}, detector.Detect(order, order.Source.Point, 10))
+	require.Zero(t, order.ArrivedAtDestination)
+
+	withoutSource := &models.Order{Destination: models.Location{Point: elastic.GeoPointFromLatLon(55.71, 37.60)}}
+	require.Equal(t, []string{models.WebhookOrderArrivedAtDestination}, detector.Detect(withoutSource, withoutSource.Destination.Point, 10))
+
+	delivered := &models.Order{DeliveredAt: 5, Destination: withoutSource.Destination}
+	require.Empty(t, detector.Detect(delivered, delivered.Destination.Point, 10))
+}
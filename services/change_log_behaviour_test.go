@@ -0,0 +1,105 @@
+package services
+
+import (
+	"github.com/TeamD2018/geo-rest/models"
+	"github.com/TeamD2018/geo-rest/services/interfaces"
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+// ChangeLogBehaviourSuite checks that Append assigns growing seqs, that Read resumes after a cursor
+// and applies the default limit, and that Trim removes old changes without reusing their seqs.
+type ChangeLogBehaviourSuite struct {
+	suite.Suite
+	newLog func() interfaces.ChangeLog
+	log    interfaces.ChangeLog
+}
+
+func (s *ChangeLogBehaviourSuite) BeforeTest(suiteName, testName string) {
+	s.log = s.newLog()
+}
+
+func (s *ChangeLogBehaviourSuite) append(entity, op, id string) *models.Change {
+	change := &models.Change{
+		Entity:    entity,
+		Op:        op,
+		ID:        id,
+		CourierID: "courier",
+		Ts:        100,
+		Data:      []byte(`{"id":"` + id + `"}`),
+	}
+	s.Require().NoError(s.log.Append(change))
+	return change
+}
+
+func (s *ChangeLogBehaviourSuite) TestAppendAssignsGrowingSeq() {
+	first := s.append(models.ChangeCourier, models.ChangeCreated, "courier")
+	second := s.append(models.ChangeOrder, models.ChangeCreated, "order")
+	s.True(first.Seq > 0)
+	s.True(second.Seq > first.Seq)
+}
+
+func (s *ChangeLogBehaviourSuite) TestReadResumesAfterCursor() {
+	appended := models.Changes{
+		s.append(models.ChangeCourier, models.ChangeCreated, "courier"),
+		s.append(models.ChangeOrder, models.ChangeCreated, "order"),
+		s.append(models.ChangeRoute, models.ChangeCreated, "courier"),
+		s.append(models.ChangeOrder, models.ChangeDeleted, "order"),
+	}
+
+	page, err := s.log.Read(0, 2)
+	s.Require().NoError(err)
+	s.Require().Len(page, 2)
+	s.Equal(appended[0], page[0])
+	s.Equal(appended[1], page[1])
+
+	page, err = s.log.Read(page[1].Seq, 10)
+	s.Require().NoError(err)
+	s.Require().Len(page, 2)
+	s.Equal(appended[2].Seq, page[0].Seq)
+	s.Equal(models.ChangeDeleted, page[1].Op)
+	s.JSONEq(`{"id":"order"}`, string(page[1].Data))
+
+	page, err = s.log.Read(appended[3].Seq, 10)
+	s.NoError(err)
+	s.Empty(page)
+}
+
+func (s *ChangeLogBehaviourSuite) TestReadDefaultLimit() {
+	for i := 0; i < DefaultChangesReturnSize+1; i++ {
+		s.append(models.ChangeCourier, models.ChangeUpdated, "courier")
+	}
+	page, err := s.log.Read(0, 0)
+	s.NoError(err)
+	s.Len(page, DefaultChangesReturnSize)
+}
+
+func (s *ChangeLogBehaviourSuite) TestTrim() {
+	old := s.append(models.ChangeCourier, models.ChangeCreated, "courier")
+	kept := &models.Change{Entity: models.ChangeOrder, Op: models.ChangeCreated, ID: "order", CourierID: "courier", Ts: 200}
+	s.Require().NoError(s.log.Append(kept))
+
+	trimmed, err := s.log.Trim(200)
+	s.Require().NoError(err)
+	s.Equal(1, trimmed)
+
+	page, err := s.log.Read(0, 10)
+	s.Require().NoError(err)
+	s.Require().Len(page, 1)
+	s.Equal(kept.Seq, page[0].Seq)
+	page, err = s.log.Read(old.Seq, 10)
+	s.Require().NoError(err)
+	s.Len(page, 1)
+
+	next := s.append(models.ChangeOrder, models.ChangeDeleted, "order")
+	s.True(next.Seq > kept.Seq)
+	trimmed, err = s.log.Trim(200)
+	s.NoError(err)
+	s.Equal(0, trimmed)
+}
+
+func TestUnitMemoryChangeLog(t *testing.T) {
+	suite.Run(t, &ChangeLogBehaviourSuite{newLog: func() interfaces.ChangeLog {
+		return NewMemoryChangeLog()
+	}})
+}
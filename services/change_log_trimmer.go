@@ -0,0 +1,84 @@
+package services
+
+import (
+	"github.com/TeamD2018/geo-rest/services/interfaces"
+	"go.uber.org/zap"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultChangesRetention    = 7 * 24 * time.Hour
+	DefaultChangesTrimInterval = time.Hour
+)
+
+// ChangeLogTrimmer periodically removes the changes logged more than Retention ago.
+// A client resuming from a removed change misses the changes removed after it and has to read the entities again.
+type ChangeLogTrimmer struct {
+	Changes   interfaces.ChangeLog
+	Logger    *zap.Logger
+	Retention time.Duration
+	Interval  time.Duration
+	Now       func() time.Time
+
+	done      chan struct{}
+	closeOnce sync.Once
+	stopped   sync.WaitGroup
+}
+
+func NewChangeLogTrimmer(changes interfaces.ChangeLog, logger *zap.Logger, retention, interval time.Duration) *ChangeLogTrimmer {
+	if retention <= 0 {
+		retention = DefaultChangesRetention
+	}
+	if interval <= 0 {
+		interval = DefaultChangesTrimInterval
+	}
+	return &ChangeLogTrimmer{
+		Changes:   changes,
+		Logger:    logger,
+		Retention: retention,
+		Interval:  interval,
+		Now:       time.Now,
+		done:      make(chan struct{}),
+	}
+}
+
+// Start runs Trim every Interval until Close.
+func (t *ChangeLogTrimmer) Start() {
+	t.stopped.Add(1)
+	go func() {
+		defer t.stopped.Done()
+		ticker := time.NewTicker(t.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-t.done:
+				return
+			case <-ticker.C:
+				if _, err := t.Trim(); err != nil {
+					t.Logger.Error("fail to trim change log", zap.Error(err))
+				}
+			}
+		}
+	}()
+}
+
+func (t *ChangeLogTrimmer) Close() {
+	t.closeOnce.Do(func() {
+		close(t.done)
+		t.stopped.Wait()
+	})
+}
+
+// Trim removes the changes older than Retention and returns how many were removed.
+func (t *ChangeLogTrimmer) Trim() (int, error) {
+	before := t.Now().Add(-t.Retention).Unix()
+	trimmed, err := t.Changes.Trim(before)
+	if err != nil {
+		return 0, err
+	}
+	if trimmed > 0 {
+		t.Logger.Info("change log trimmed", zap.Int("changes", trimmed), zap.Int64("before", before))
+	}
+	return trimmed, nil
+}
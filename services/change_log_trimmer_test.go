@@ -0,0 +1,27 @@
+package services
+
+import (
+	"github.com/TeamD2018/geo-rest/models"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"testing"
+	"time"
+)
+
+func TestUnitChangeLogTrimmer_Trim(t *testing.T) {
+	changes := NewMemoryChangeLog()
+	now := time.Unix(1000000, 0)
+	for _, ts := range []time.Time{now.Add(-2 * time.Hour), now.Add(-time.Hour - time.Second), now.Add(-time.Minute)} {
+		require.NoError(t, changes.Append(&models.Change{Entity: models.ChangeCourier, Op: models.ChangeUpdated, ID: "courier", Ts: ts.Unix()}))
+	}
+	trimmer := NewChangeLogTrimmer(changes, zap.NewNop(), time.Hour, 0)
+	trimmer.Now = func() time.Time { return now }
+
+	trimmed, err := trimmer.Trim()
+	require.NoError(t, err)
+	require.Equal(t, 2, trimmed)
+	kept, err := changes.Read(0, 10)
+	require.NoError(t, err)
+	require.Len(t, kept, 1)
+	require.Equal(t, uint64(3), kept[0].Seq)
+}
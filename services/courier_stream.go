@@ -0,0 +1,314 @@
+package services
+
+import (
+	"github.com/TeamD2018/geo-rest/models"
+	"github.com/TeamD2018/geo-rest/services/geo"
+	"github.com/TeamD2018/geo-rest/services/interfaces"
+	"github.com/satori/go.uuid"
+	"sync"
+)
+
+const (
+	DefaultStreamBuffer         = 64
+	DefaultStreamMaxSubscribers = 10000
+)
+
+// CourierStreamHub fans courier changes out to subscribers watching a viewport.
+// Publishing never blocks: a subscriber whose buffer is full is dropped and has to reconnect.
+type CourierStreamHub struct {
+	Buffer         int
+	MaxSubscribers int
+
+	mu            sync.RWMutex
+	subscriptions map[string]*courierSubscription
+
+	// last published state of every courier, kept only while there are subscribers:
+	// a new subscriber starts from a snapshot
+	statesMu sync.Mutex
+	states   map[string]courierState
+}
+
+// courierState is the part of a courier the stream reports changes of.
+type courierState struct {
+	located     bool
+	lat, lon    float64
+	active      bool
+	ordersCount int
+}
+
+func NewCourierStreamHub(buffer, maxSubscribers int) *CourierStreamHub {
+	if buffer <= 0 {
+		buffer = DefaultStreamBuffer
+	}
+	if maxSubscribers <= 0 {
+		maxSubscribers = DefaultStreamMaxSubscribers
+	}
+	return &CourierStreamHub{
+		Buffer:         buffer,
+		MaxSubscribers: maxSubscribers,
+		subscriptions:  make(map[string]*courierSubscription),
+		states:         make(map[string]courierState),
+	}
+}
+
+func (h *CourierStreamHub) Subscribe(viewport *models.Viewport) (interfaces.CourierSubscription, error) {
+	s := &courierSubscription{
+		id:       uuid.NewV4().String(),
+		hub:      h,
+		events:   make(chan *models.CourierEvent, h.Buffer),
+		done:     make(chan struct{}),
+		viewport: viewport,
+		inside:   make(map[string]struct{}),
+		holding:  true,
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.subscriptions) >= h.MaxSubscribers {
+		return nil, &models.ErrTooManySubscribers
+	}
+	h.subscriptions[s.id] = s
+	return s, nil
+}
+
+func (h *CourierStreamHub) Subscription(id string) (interfaces.CourierSubscription, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	s, ok := h.subscriptions[id]
+	if !ok {
+		return nil, false
+	}
+	return s, true
+}
+
+func (h *CourierStreamHub) PublishUpdate(courier *models.Courier) {
+	if courier == nil || !h.watched() || !h.changed(courier) {
+		return
+	}
+	h.each(func(s *courierSubscription) bool {
+		return s.update(courier)
+	})
+}
+
+func (h *CourierStreamHub) PublishDelete(courierID string) {
+	h.statesMu.Lock()
+	delete(h.states, courierID)
+	h.statesMu.Unlock()
+	h.each(func(s *courierSubscription) bool {
+		return s.delete(courierID)
+	})
+}
+
+// watched reports whether there is any subscriber.
+func (h *CourierStreamHub) watched() bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.subscriptions) > 0
+}
+
+// changed records the state of courier and reports whether it differs from the recorded one.
+func (h *CourierStreamHub) changed(courier *models.Courier) bool {
+	state := courierState{active: courier.IsActive, ordersCount: courier.OrdersCount}
+	if courier.Location != nil && courier.Location.Point != nil {
+		state.located = true
+		state.lat, state.lon = courier.Location.Point.Lat, courier.Location.Point.Lon
+	}
+	h.statesMu.Lock()
+	defer h.statesMu.Unlock()
+	if previous, ok := h.states[courier.ID]; ok && previous == state {
+		return false
+	}
+	h.states[courier.ID] = state
+	return true
+}
+
+// each calls deliver for every subscription and drops those it fails for.
+func (h *CourierStreamHub) each(deliver func(s *courierSubscription) bool) {
+	var slow []*courierSubscription
+	h.mu.RLock()
+	for _, s := range h.subscriptions {
+		if !deliver(s) {
+			slow = append(slow, s)
+		}
+	}
+	h.mu.RUnlock()
+	for _, s := range slow {
+		s.Close()
+	}
+}
+
+func (h *CourierStreamHub) remove(id string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.subscriptions, id)
+	if len(h.subscriptions) == 0 {
+		h.statesMu.Lock()
+		h.states = make(map[string]courierState)
+		h.statesMu.Unlock()
+	}
+}
+
+type courierSubscription struct {
+	id        string
+	hub       *CourierStreamHub
+	events    chan *models.CourierEvent
+	done      chan struct{}
+	closeOnce sync.Once
+
+	mu       sync.Mutex
+	viewport *models.Viewport
+	// couriers the client was told are inside the viewport
+	inside map[string]struct{}
+	// changes published while the snapshot of the viewport is read, sent after it
+	holding bool
+	held    []heldChange
+}
+
+// heldChange is an updated courier, or the ID of a deleted one.
+type heldChange struct {
+	courier   *models.Courier
+	deletedID string
+}
+
+func (s *courierSubscription) ID() string {
+	return s.id
+}
+
+func (s *courierSubscription) Events() <-chan *models.CourierEvent {
+	return s.events
+}
+
+func (s *courierSubscription) Done() <-chan struct{} {
+	return s.done
+}
+
+func (s *courierSubscription) SetViewport(viewport *models.Viewport) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.viewport = viewport
+	s.holding = true
+	s.held = nil
+}
+
+func (s *courierSubscription) SetSnapshot(viewport *models.Viewport, snapshot models.Couriers) {
+	s.mu.Lock()
+	if viewport != s.viewport || !s.holding {
+		s.mu.Unlock()
+		return
+	}
+	s.inside = make(map[string]struct{}, len(snapshot))
+	couriers := make(models.Couriers, 0, len(snapshot))
+	for _, courier := range snapshot {
+		if InViewport(viewport, courier) {
+			s.inside[courier.ID] = struct{}{}
+			couriers = append(couriers, courier)
+		}
+	}
+	ok := s.send(&models.CourierEvent{Type: models.CourierEventSnapshot, SubscriptionID: s.id, Couriers: couriers})
+	// a held change may be older than the snapshot, the changes published after it are held too and come later
+	held := s.held
+	s.holding, s.held = false, nil
+	for _, change := range held {
+		if !ok {
+			break
+		}
+		if change.courier != nil {
+			ok = s.updateLocked(change.courier)
+		} else {
+			ok = s.deleteLocked(change.deletedID)
+		}
+	}
+	s.mu.Unlock()
+	if !ok {
+		s.Close()
+	}
+}
+
+func (s *courierSubscription) Close() {
+	s.closeOnce.Do(func() {
+		close(s.done)
+		s.hub.remove(s.id)
+	})
+}
+
+func (s *courierSubscription) update(courier *models.Courier) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.holding {
+		return s.hold(heldChange{courier: courier})
+	}
+	return s.updateLocked(courier)
+}
+
+func (s *courierSubscription) updateLocked(courier *models.Courier) bool {
+	_, was := s.inside[courier.ID]
+	if InViewport(s.viewport, courier) {
+		s.inside[courier.ID] = struct{}{}
+		return s.send(&models.CourierEvent{Type: models.CourierEventUpdate, Courier: courier})
+	}
+	if !was {
+		return true
+	}
+	delete(s.inside, courier.ID)
+	return s.send(&models.CourierEvent{Type: models.CourierEventLeave, CourierID: courier.ID})
+}
+
+func (s *courierSubscription) delete(courierID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.holding {
+		return s.hold(heldChange{deletedID: courierID})
+	}
+	return s.deleteLocked(courierID)
+}
+
+func (s *courierSubscription) deleteLocked(courierID string) bool {
+	if _, was := s.inside[courierID]; !was {
+		return true
+	}
+	delete(s.inside, courierID)
+	return s.send(&models.CourierEvent{Type: models.CourierEventDelete, CourierID: courierID})
+}
+
+// hold keeps change until the snapshot is sent and reports whether it fits the buffer of the subscription.
+func (s *courierSubscription) hold(change heldChange) bool {
+	if len(s.held) >= s.hub.Buffer {
+		return false
+	}
+	s.held = append(s.held, change)
+	return true
+}
+
+// send queues event without blocking and reports whether there was room for it.
+func (s *courierSubscription) send(event *models.CourierEvent) bool {
+	select {
+	case <-s.done:
+		return true
+	default:
+	}
+	select {
+	case s.events <- event:
+		return true
+	default:
+		return false
+	}
+}
+
+// InViewport reports whether courier has a location inside viewport and, for active-only viewports, is active.
+func InViewport(viewport *models.Viewport, courier *models.Courier) bool {
+	if viewport == nil || courier.Location == nil || courier.Location.Point == nil {
+		return false
+	}
+	if viewport.ActiveOnly && !courier.IsActive {
+		return false
+	}
+	point := courier.Location.Point
+	switch {
+	case viewport.Circle != nil:
+		return geo.InCircle(point, viewport.Circle)
+	case len(viewport.Polygon) > 0:
+		return geo.InPolygon(point, viewport.Polygon)
+	case viewport.Box != nil:
+		return geo.InBox(point, viewport.Box)
+	}
+	return false
+}
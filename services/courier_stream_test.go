@@ -0,0 +1,191 @@
+package services
+
+import (
+	"github.com/TeamD2018/geo-rest/models"
+	"github.com/TeamD2018/geo-rest/services/interfaces"
+	"github.com/olivere/elastic"
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+type CourierStreamTestSuite struct {
+	suite.Suite
+	hub      *CourierStreamHub
+	viewport *models.Viewport
+}
+
+func (s *CourierStreamTestSuite) BeforeTest(suiteName, testName string) {
+	s.hub = NewCourierStreamHub(4, 2)
+	s.viewport = &models.Viewport{Box: &models.BoxField{
+		TopLeftPoint:     elastic.GeoPointFromLatLon(10, 0),
+		BottomRightPoint: elastic.GeoPointFromLatLon(0, 10),
+	}}
+}
+
+func (s *CourierStreamTestSuite) courier(id string, lat, lon float64) *models.Courier {
+	return &models.Courier{ID: id, Location: &models.Location{Point: elastic.GeoPointFromLatLon(lat, lon)}}
+}
+
+func (s *CourierStreamTestSuite) subscribe(snapshot models.Couriers) interfaces.CourierSubscription {
+	subscription, err := s.hub.Subscribe(s.viewport)
+	s.Require().NoError(err)
+	subscription.SetSnapshot(s.viewport, snapshot)
+	event := s.next(subscription)
+	s.Require().Equal(models.CourierEventSnapshot, event.Type)
+	s.Equal(subscription.ID(), event.SubscriptionID)
+	return subscription
+}
+
+func (s *CourierStreamTestSuite) next(subscription interfaces.CourierSubscription) *models.CourierEvent {
+	select {
+	case event := <-subscription.Events():
+		return event
+	default:
+		s.FailNow("no event")
+		return nil
+	}
+}
+
+func (s *CourierStreamTestSuite) TestSnapshotKeepsCouriersInsideViewport() {
+	subscription, err := s.hub.Subscribe(s.viewport)
+	s.Require().NoError(err)
+	subscription.SetSnapshot(s.viewport, models.Couriers{s.courier("in", 5, 5), s.courier("out", 20, 20)})
+	event := s.next(subscription)
+	if s.Len(event.Couriers, 1) {
+		s.Equal("in", event.Couriers[0].ID)
+	}
+}
+
+func (s *CourierStreamTestSuite) TestHoldsChangesUntilSnapshot() {
+	subscription, err := s.hub.Subscribe(s.viewport)
+	s.Require().NoError(err)
+	s.hub.PublishUpdate(s.courier("courier", 5, 5))
+	s.hub.PublishDelete("gone")
+	s.Empty(subscription.Events())
+
+	subscription.SetSnapshot(s.viewport, models.Couriers{s.courier("gone", 1, 1)})
+	s.Equal(models.CourierEventSnapshot, s.next(subscription).Type)
+	event := s.next(subscription)
+	s.Equal(models.CourierEventUpdate, event.Type)
+	s.Equal("courier", event.Courier.ID)
+	event = s.next(subscription)
+	s.Equal(models.CourierEventDelete, event.Type)
+	s.Equal("gone", event.CourierID)
+	s.Empty(subscription.Events())
+}
+
+func (s *CourierStreamTestSuite) TestUpdateEnterAndLeave() {
+	subscription := s.subscribe(nil)
+
+	s.hub.PublishUpdate(s.courier("courier", 20, 20))
+	s.Empty(subscription.Events())
+
+	s.hub.PublishUpdate(s.courier("courier", 5, 5))
+	event := s.next(subscription)
+	s.Equal(models.CourierEventUpdate, event.Type)
+	s.Equal("courier", event.Courier.ID)
+
+	s.hub.PublishUpdate(s.courier("courier", 20, 20))
+	event = s.next(subscription)
+	s.Equal(models.CourierEventLeave, event.Type)
+	s.Equal("courier", event.CourierID)
+}
+
+func (s *CourierStreamTestSuite) TestSkipsUnchangedCouriers() {
+	subscription := s.subscribe(nil)
+	courier := s.courier("courier", 5, 5)
+	s.hub.PublishUpdate(courier)
+	s.next(subscription)
+
+	courier.Name = "renamed"
+	s.hub.PublishUpdate(courier)
+	s.Empty(subscription.Events())
+
+	courier.OrdersCount = 1
+	s.hub.PublishUpdate(courier)
+	s.Equal(1, s.next(subscription).Courier.OrdersCount)
+}
+
+func (s *CourierStreamTestSuite) TestDeleteOnlyReachesWatchers() {
+	subscription := s.subscribe(models.Couriers{s.courier("courier", 5, 5)})
+	s.hub.PublishDelete("other")
+	s.Empty(subscription.Events())
+	s.hub.PublishDelete("courier")
+	s.Equal(models.CourierEventDelete, s.next(subscription).Type)
+}
+
+func (s *CourierStreamTestSuite) TestActiveOnly() {
+	s.viewport.ActiveOnly = true
+	subscription := s.subscribe(nil)
+	courier := s.courier("courier", 5, 5)
+	s.hub.PublishUpdate(courier)
+	s.Empty(subscription.Events())
+	courier.IsActive = true
+	s.hub.PublishUpdate(courier)
+	s.Equal(models.CourierEventUpdate, s.next(subscription).Type)
+}
+
+func (s *CourierStreamTestSuite) TestSetViewport() {
+	subscription := s.subscribe(models.Couriers{s.courier("courier", 5, 5)})
+	s.viewport = &models.Viewport{Circle: &models.CircleField{Center: elastic.GeoPointFromLatLon(20, 20), Radius: 1000}}
+	subscription.SetViewport(s.viewport)
+	subscription.SetSnapshot(s.viewport, models.Couriers{s.courier("other", 20, 20)})
+	event := s.next(subscription)
+	s.Equal(models.CourierEventSnapshot, event.Type)
+	s.Len(event.Couriers, 1)
+
+	// the courier is outside the new viewport and was never reported in it
+	s.hub.PublishUpdate(s.courier("courier", 6, 6))
+	s.Empty(subscription.Events())
+}
+
+func (s *CourierStreamTestSuite) TestIgnoresSnapshotOfFormerViewport() {
+	subscription := s.subscribe(nil)
+	former := s.viewport
+	subscription.SetViewport(&models.Viewport{Circle: &models.CircleField{Center: elastic.GeoPointFromLatLon(20, 20), Radius: 1000}})
+	subscription.SetSnapshot(former, models.Couriers{s.courier("courier", 5, 5)})
+	s.Empty(subscription.Events())
+}
+
+func (s *CourierStreamTestSuite) TestForgetsStatesWithoutSubscribers() {
+	s.hub.PublishUpdate(s.courier("unwatched", 5, 5))
+	s.Empty(s.hub.states)
+
+	subscription := s.subscribe(nil)
+	s.hub.PublishUpdate(s.courier("courier", 5, 5))
+	s.hub.PublishUpdate(s.courier("deleted", 5, 5))
+	s.hub.PublishDelete("deleted")
+	s.Len(s.hub.states, 1)
+
+	subscription.Close()
+	s.Empty(s.hub.states)
+}
+
+func (s *CourierStreamTestSuite) TestDropsSlowSubscribers() {
+	slow := s.subscribe(nil)
+	for i := 0; i < s.hub.Buffer+1; i++ {
+		s.hub.PublishUpdate(s.courier("courier", 1, float64(i)))
+	}
+	select {
+	case <-slow.Done():
+	default:
+		s.Fail("slow subscriber is kept")
+	}
+	_, ok := s.hub.Subscription(slow.ID())
+	s.False(ok)
+}
+
+func (s *CourierStreamTestSuite) TestMaxSubscribers() {
+	first := s.subscribe(nil)
+	s.subscribe(nil)
+	_, err := s.hub.Subscribe(s.viewport)
+	s.Error(err)
+
+	first.Close()
+	_, err = s.hub.Subscribe(s.viewport)
+	s.NoError(err)
+}
+
+func TestUnitCourierStream(t *testing.T) {
+	suite.Run(t, new(CourierStreamTestSuite))
+}
@@ -191,7 +191,7 @@ func (c *CouriersElasticDAO) Create(courier *models.CourierCreate) (*models.Cour
 func (c *CouriersElasticDAO) Update(courier *models.CourierUpdate) (*models.Courier, error) {
 	id := *courier.ID
 	courier.ID = nil
-	if courier.Location != nil {
+	if courier.Location != nil && courier.LastSeen == nil {
 		now := time.Now().Unix()
 		courier.LastSeen = &now
 	}
@@ -199,7 +199,7 @@ func (c *CouriersElasticDAO) Update(courier *models.CourierUpdate) (*models.Cour
 		Index(c.index).
 		Type("_doc").
 		Id(id).
-		Doc(courier).
+		Doc(courierUpdateDoc(courier)).
 		FetchSource(true).
 		Do(context.Background())
 	if err != nil {
@@ -216,6 +216,72 @@ func (c *CouriersElasticDAO) Update(courier *models.CourierUpdate) (*models.Cour
 	return result, nil
 }
 
+// courierUpdateDoc makes a partial update document.
+// A new point replaces the whole fix, so fix fields missing from the update are reset instead of merged.
+func courierUpdateDoc(courier *models.CourierUpdate) interface{} {
+	if courier.Location == nil || courier.Location.Point == nil {
+		return courier
+	}
+	location := map[string]interface{}{
+		"point":    courier.Location.Point,
+		"accuracy": courier.Location.Accuracy,
+		"speed":    courier.Location.Speed,
+		"bearing":  courier.Location.Bearing,
+		"altitude": courier.Location.Altitude,
+		"provider": courier.Location.Provider,
+	}
+	if courier.Location.Address != nil {
+		location["address"] = courier.Location.Address
+	}
+	doc := map[string]interface{}{"location": location}
+	if courier.Name != nil {
+		doc["name"] = courier.Name
+	}
+	if courier.Phone != nil {
+		doc["phone"] = courier.Phone
+	}
+	if courier.LastSeen != nil {
+		doc["last_seen"] = courier.LastSeen
+	}
+	if courier.IsActive != nil {
+		doc["is_active"] = courier.IsActive
+	}
+	if courier.Stale != nil {
+		doc["stale"] = courier.Stale
+	}
+	return doc
+}
+
+// GetSilent compares last_seen in a script: the field is stored but not indexed, so range queries can not use it.
+func (c *CouriersElasticDAO) GetSilent(before int64, size int) (models.Couriers, error) {
+	silentScript := elastic.NewScript("doc['last_seen'].size() > 0 && doc['last_seen'].value < params.before").
+		Param("before", before)
+	query := elastic.NewBoolQuery().Filter(
+		elastic.NewTermsQuery("is_active", true),
+		elastic.NewScriptQuery(silentScript))
+	size = c.resolveDefaultReturnSize(size)
+	res, err := c.client.Search(c.index).
+		Type("_doc").
+		Size(size).
+		Query(query).
+		Sort("last_seen", true).
+		Do(context.Background())
+	if err != nil {
+		c.l.Error("fail to get silent couriers", zap.Error(err))
+		return nil, err
+	}
+	result := models.Couriers{}
+	for _, item := range res.Hits.Hits {
+		var courier models.Courier
+		if err := json.Unmarshal(*item.Source, &courier); err != nil {
+			return nil, err
+		}
+		courier.ID = item.Id
+		result = append(result, &courier)
+	}
+	return result, nil
+}
+
 func (c *CouriersElasticDAO) Delete(courierID string) error {
 	res, err := c.client.Delete().Index(c.index).Type("_doc").Id(courierID).Do(context.Background())
 	if err != nil {
@@ -245,21 +311,11 @@ func (c *CouriersElasticDAO) Exists(courierID string) (bool, error) {
 func (c *CouriersElasticDAO) EnsureMapping() error {
 	indexName, mapping := c.GetMapping()
 
-	ctx := context.Background()
-	exists, err := c.client.IndexExists(indexName).Do(ctx)
-	if err != nil {
+	if err := ensureIndex(c.client, indexName, mapping); err != nil {
 		c.l.Sugar().Errorw("", zap.Error(err))
 		return err
 	}
 
-	if !exists {
-		_, err := c.client.CreateIndex(indexName).BodyString(mapping).Do(ctx)
-		if err != nil {
-			c.l.Sugar().Errorw("", zap.Error(err))
-			return err
-		}
-	}
-
 	return nil
 }
 
@@ -289,6 +345,24 @@ func (c *CouriersElasticDAO) GetMapping() (indexName string, mapping string) {
 							},
 							"address": {
 								"type": "text"
+							},
+							"accuracy": {
+								"type": "float"
+							},
+							"speed": {
+								"type": "float",
+								"index": false
+							},
+							"bearing": {
+								"type": "float",
+								"index": false
+							},
+							"altitude": {
+								"type": "float",
+								"index": false
+							},
+							"provider": {
+								"type": "keyword"
 							}
 						}
 					},
@@ -303,6 +377,9 @@ func (c *CouriersElasticDAO) GetMapping() (indexName string, mapping string) {
 					"is_active": {
 						"type": "boolean"
 					},
+					"stale": {
+						"type": "boolean"
+					},
 					"suggestions": {
 						"type": "completion",
 						"analyzer": "whitespace"
@@ -0,0 +1,197 @@
+package services
+
+import (
+	"github.com/TeamD2018/geo-rest/models"
+	"github.com/TeamD2018/geo-rest/services/interfaces"
+	"github.com/olivere/elastic"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+	"testing"
+)
+
+// CouriersDAOBehaviourSuite checks courier CRUD, the box, circle and polygon searches with their size limit
+// and the last-seen cutoff of GetSilent.
+type CouriersDAOBehaviourSuite struct {
+	suite.Suite
+	newDAO func() interfaces.ICouriersDAO
+	dao    interfaces.ICouriersDAO
+}
+
+func (s *CouriersDAOBehaviourSuite) BeforeTest(suiteName, testName string) {
+	s.dao = s.newDAO()
+}
+
+func (s *CouriersDAOBehaviourSuite) createAt(name string, lat, lon float64, active bool) *models.Courier {
+	created, err := s.dao.Create(&models.CourierCreate{Name: name, IsActive: active})
+	s.Require().NoError(err)
+	updated, err := s.dao.Update(&models.CourierUpdate{
+		ID:       &created.ID,
+		Location: &models.Location{Point: elastic.GeoPointFromLatLon(lat, lon)},
+	})
+	s.Require().NoError(err)
+	return updated
+}
+
+func (s *CouriersDAOBehaviourSuite) TestCreateAndGetByID() {
+	phone := "79031109865"
+	created, err := s.dao.Create(&models.CourierCreate{Name: "Vasya", Phone: &phone, IsActive: true})
+	if !s.NoError(err) {
+		return
+	}
+	s.NotEmpty(created.ID)
+	got, err := s.dao.GetByID(created.ID)
+	if !s.NoError(err) {
+		return
+	}
+	s.Equal(created, got)
+}
+
+func (s *CouriersDAOBehaviourSuite) TestGetByIDNotFound() {
+	_, err := s.dao.GetByID("550e8400-e29b-41d4-a716-446655440000")
+	s.Error(err)
+}
+
+func (s *CouriersDAOBehaviourSuite) TestUpdateWithLocationSetsLastSeen() {
+	courier := s.createAt("Vasya", 55.7, 37.5, true)
+	s.NotNil(courier.LastSeen)
+	s.Equal(55.7, courier.Location.Point.Lat)
+
+	address := "Moscow"
+	updated, err := s.dao.Update(&models.CourierUpdate{ID: &courier.ID, Location: &models.Location{Address: &address}})
+	if !s.NoError(err) {
+		return
+	}
+	s.Equal(address, *updated.Location.Address)
+	s.Equal(courier.Location.Point, updated.Location.Point)
+}
+
+func (s *CouriersDAOBehaviourSuite) TestUpdateNotFound() {
+	id := "550e8400-e29b-41d4-a716-446655440000"
+	name := "Petya"
+	_, err := s.dao.Update(&models.CourierUpdate{ID: &id, Name: &name})
+	s.Error(err)
+}
+
+func (s *CouriersDAOBehaviourSuite) TestDelete() {
+	created, err := s.dao.Create(&models.CourierCreate{Name: "Vasya"})
+	if !s.NoError(err) {
+		return
+	}
+	s.NoError(s.dao.Delete(created.ID))
+	exists, err := s.dao.Exists(created.ID)
+	s.NoError(err)
+	s.False(exists)
+	s.Error(s.dao.Delete(created.ID))
+}
+
+func (s *CouriersDAOBehaviourSuite) TestGetByBoxField() {
+	inside := s.createAt("Inside", 55.75, 37.61, true)
+	s.createAt("Outside", 59.93, 30.31, true)
+	inactive := s.createAt("Inactive", 55.76, 37.62, false)
+
+	box := &models.BoxField{
+		TopLeftPoint:     elastic.GeoPointFromLatLon(56, 37),
+		BottomRightPoint: elastic.GeoPointFromLatLon(55, 38),
+	}
+	couriers, err := s.dao.GetByBoxField(box, 0, false)
+	if !s.NoError(err) {
+		return
+	}
+	s.ElementsMatch([]string{inside.ID, inactive.ID}, ids(couriers))
+
+	couriers, err = s.dao.GetByBoxField(box, 0, true)
+	if !s.NoError(err) {
+		return
+	}
+	s.Equal([]string{inside.ID}, ids(couriers))
+}
+
+func (s *CouriersDAOBehaviourSuite) TestGetByCircleField() {
+	near := s.createAt("Near", 55.7520, 37.6175, true)
+	s.createAt("Far", 55.80, 37.70, true)
+
+	circle := &models.CircleField{Center: elastic.GeoPointFromLatLon(55.7522, 37.6156), Radius: 500}
+	couriers, err := s.dao.GetByCircleField(circle, 0, false)
+	if !s.NoError(err) {
+		return
+	}
+	s.Equal([]string{near.ID}, ids(couriers))
+}
+
+func (s *CouriersDAOBehaviourSuite) TestGetByPolygon() {
+	inside := s.createAt("Inside", 1, 1, true)
+	s.createAt("Outside", 3, 3, true)
+
+	polygon := models.FlatPolygon{
+		elastic.GeoPointFromLatLon(0, 0),
+		elastic.GeoPointFromLatLon(0, 2),
+		elastic.GeoPointFromLatLon(2, 2),
+		elastic.GeoPointFromLatLon(2, 0),
+	}
+	couriers, err := s.dao.GetByPolygon(polygon, 0, false)
+	if !s.NoError(err) {
+		return
+	}
+	s.Equal([]string{inside.ID}, ids(couriers))
+}
+
+func (s *CouriersDAOBehaviourSuite) TestSizeLimit() {
+	for i := 0; i < 3; i++ {
+		s.createAt("Courier", 1, 1, true)
+	}
+	circle := &models.CircleField{Center: elastic.GeoPointFromLatLon(1, 1), Radius: 10}
+	couriers, err := s.dao.GetByCircleField(circle, 2, false)
+	if !s.NoError(err) {
+		return
+	}
+	s.Len(couriers, 2)
+}
+
+func (s *CouriersDAOBehaviourSuite) seenAt(courier *models.Courier, lastSeen int64) *models.Courier {
+	updated, err := s.dao.Update(&models.CourierUpdate{ID: &courier.ID, LastSeen: &lastSeen})
+	s.Require().NoError(err)
+	return updated
+}
+
+func (s *CouriersDAOBehaviourSuite) TestGetSilent() {
+	older := s.seenAt(s.createAt("Older", 1, 1, true), 100)
+	old := s.seenAt(s.createAt("Old", 1, 1, true), 200)
+	s.seenAt(s.createAt("Fresh", 1, 1, true), 300)
+	s.seenAt(s.createAt("Inactive", 1, 1, false), 100)
+	_, err := s.dao.Create(&models.CourierCreate{Name: "Never seen", IsActive: true})
+	s.Require().NoError(err)
+
+	couriers, err := s.dao.GetSilent(300, 0)
+	if s.NoError(err) {
+		s.Equal([]string{older.ID, old.ID}, ids(couriers))
+	}
+	couriers, err = s.dao.GetSilent(300, 1)
+	if s.NoError(err) {
+		s.Equal([]string{older.ID}, ids(couriers))
+	}
+
+	stale, active := true, false
+	updated, err := s.dao.Update(&models.CourierUpdate{ID: &older.ID, IsActive: &active, Stale: &stale})
+	if s.NoError(err) {
+		s.True(updated.Stale)
+		s.False(updated.IsActive)
+	}
+	couriers, err = s.dao.GetSilent(300, 0)
+	if s.NoError(err) {
+		s.Equal([]string{old.ID}, ids(couriers))
+	}
+}
+
+func ids(couriers models.Couriers) []string {
+	result := make([]string, 0, len(couriers))
+	for _, courier := range couriers {
+		result = append(result, courier.ID)
+	}
+	return result
+}
+
+func TestUnitMemoryCouriersDAO(t *testing.T) {
+	suite.Run(t, &CouriersDAOBehaviourSuite{newDAO: func() interfaces.ICouriersDAO {
+		return NewMemoryCouriersDAO(zap.NewNop(), DefaultCouriersReturnSize)
+	}})
+}
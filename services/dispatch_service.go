@@ -0,0 +1,169 @@
+package services
+
+import (
+	"github.com/TeamD2018/geo-rest/models"
+	"github.com/TeamD2018/geo-rest/services/geo"
+	"github.com/TeamD2018/geo-rest/services/interfaces"
+	"math"
+	"sort"
+	"time"
+)
+
+const (
+	// Metres around the order source searched first
+	DefaultDispatchInitialRadius = 1000
+	DefaultDispatchMaxRadius     = 20000
+	// The radius is multiplied by it until enough candidates are found
+	DefaultDispatchRadiusGrowth = 2
+	DefaultDispatchCandidates   = 10
+	DefaultDispatchMaxOrders    = 5
+	DefaultDispatchMaxIdle      = 10 * time.Minute
+
+	DefaultDispatchDistanceWeight  = 1
+	DefaultDispatchLoadWeight      = 0.5
+	DefaultDispatchFreshnessWeight = 0.25
+)
+
+// DispatchConfig holds the weights of the scoring factors and the caps couriers are skipped beyond.
+type DispatchConfig struct {
+	// Distance is scored relative to MaxRadius
+	DistanceWeight float64
+	// Load is scored relative to MaxOrders
+	LoadWeight float64
+	// Idle time is scored relative to MaxIdle
+	FreshnessWeight float64
+
+	InitialRadius int
+	MaxRadius     int
+	RadiusGrowth  float64
+	// The search stops expanding once this many candidates are found
+	Candidates int
+	// Couriers carrying MaxOrders orders or more are skipped
+	MaxOrders int
+	// Couriers silent for longer are skipped
+	MaxIdle time.Duration
+}
+
+// DispatchService ranks active couriers around the order source by distance, load and last_seen freshness.
+// The search starts within InitialRadius and grows until Candidates couriers fit or MaxRadius is reached.
+type DispatchService struct {
+	Couriers interfaces.ICouriersDAO
+	Tracker  interfaces.OrdersCountTracker
+	Config   DispatchConfig
+	Now      func() time.Time
+}
+
+func NewDispatchService(couriers interfaces.ICouriersDAO, tracker interfaces.OrdersCountTracker,
+	config DispatchConfig) *DispatchService {
+	if config.DistanceWeight < 0 {
+		config.DistanceWeight = DefaultDispatchDistanceWeight
+	}
+	if config.LoadWeight < 0 {
+		config.LoadWeight = DefaultDispatchLoadWeight
+	}
+	if config.FreshnessWeight < 0 {
+		config.FreshnessWeight = DefaultDispatchFreshnessWeight
+	}
+	if config.InitialRadius <= 0 {
+		config.InitialRadius = DefaultDispatchInitialRadius
+	}
+	if config.MaxRadius < config.InitialRadius {
+		config.MaxRadius = config.InitialRadius
+	}
+	if config.RadiusGrowth <= 1 {
+		config.RadiusGrowth = DefaultDispatchRadiusGrowth
+	}
+	if config.Candidates <= 0 {
+		config.Candidates = DefaultDispatchCandidates
+	}
+	if config.MaxOrders <= 0 {
+		config.MaxOrders = DefaultDispatchMaxOrders
+	}
+	if config.MaxIdle <= 0 {
+		config.MaxIdle = DefaultDispatchMaxIdle
+	}
+	return &DispatchService{
+		Couriers: couriers,
+		Tracker:  tracker,
+		Config:   config,
+		Now:      time.Now,
+	}
+}
+
+func (s *DispatchService) Rank(order *models.Order) (*models.DispatchRanking, error) {
+	source := order.Source.Point
+	if source == nil {
+		return nil, models.ErrOrderSourceUnknown.SetParameter(order.ID)
+	}
+	now := s.Now().Unix()
+	ranking := &models.DispatchRanking{OrderID: order.ID, Candidates: []*models.DispatchCandidate{}}
+	for radius := s.Config.InitialRadius; ; radius = s.grow(radius) {
+		ranking.Radius = radius
+		// loaded and silent couriers are skipped, so more couriers are fetched than candidates needed
+		couriers, err := s.Couriers.GetByCircleField(&models.CircleField{Center: source, Radius: radius},
+			s.Config.Candidates*s.Config.MaxOrders, true)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.Tracker.Sync(couriers); err != nil {
+			return nil, err
+		}
+		ranking.Candidates = ranking.Candidates[:0]
+		for _, courier := range couriers {
+			if candidate := s.score(courier, order, now); candidate != nil {
+				ranking.Candidates = append(ranking.Candidates, candidate)
+			}
+		}
+		if len(ranking.Candidates) >= s.Config.Candidates || radius >= s.Config.MaxRadius {
+			break
+		}
+	}
+	sort.SliceStable(ranking.Candidates, func(i, j int) bool {
+		a, b := ranking.Candidates[i], ranking.Candidates[j]
+		if a.Score == b.Score {
+			return a.Courier.ID < b.Courier.ID
+		}
+		return a.Score < b.Score
+	})
+	if len(ranking.Candidates) > s.Config.Candidates {
+		ranking.Candidates = ranking.Candidates[:s.Config.Candidates]
+	}
+	return ranking, nil
+}
+
+func (s *DispatchService) grow(radius int) int {
+	grown := int(math.Ceil(float64(radius) * s.Config.RadiusGrowth))
+	if grown > s.Config.MaxRadius {
+		return s.Config.MaxRadius
+	}
+	return grown
+}
+
+// score returns nil for couriers that can not take the order.
+func (s *DispatchService) score(courier *models.Courier, order *models.Order, now int64) *models.DispatchCandidate {
+	if !courier.IsActive || courier.Location == nil || courier.Location.Point == nil || courier.LastSeen == nil {
+		return nil
+	}
+	if courier.OrdersCount >= s.Config.MaxOrders {
+		return nil
+	}
+	idle := now - *courier.LastSeen
+	if idle < 0 {
+		idle = 0
+	}
+	maxIdle := s.Config.MaxIdle.Seconds()
+	if float64(idle) > maxIdle {
+		return nil
+	}
+	distance := geo.Distance(courier.Location.Point, order.Source.Point)
+	score := s.Config.DistanceWeight*math.Min(distance/float64(s.Config.MaxRadius), 1) +
+		s.Config.LoadWeight*float64(courier.OrdersCount)/float64(s.Config.MaxOrders) +
+		s.Config.FreshnessWeight*float64(idle)/maxIdle
+	return &models.DispatchCandidate{
+		Courier:     courier,
+		Distance:    distance,
+		OrdersCount: courier.OrdersCount,
+		Idle:        idle,
+		Score:       score,
+	}
+}
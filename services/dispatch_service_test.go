@@ -0,0 +1,123 @@
+package services
+
+import (
+	"github.com/TeamD2018/geo-rest/models"
+	"github.com/olivere/elastic"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+	"testing"
+	"time"
+)
+
+type DispatchServiceTestSuite struct {
+	suite.Suite
+	couriers *MemoryCouriersDAO
+	tracker  *MemoryOrdersCountTracker
+	service  *DispatchService
+	order    *models.Order
+	now      time.Time
+}
+
+func (s *DispatchServiceTestSuite) BeforeTest(suiteName, testName string) {
+	s.couriers = NewMemoryCouriersDAO(zap.NewNop(), DefaultCouriersReturnSize)
+	s.tracker = NewMemoryOrdersCountTracker()
+	s.service = NewDispatchService(s.couriers, s.tracker, DispatchConfig{
+		DistanceWeight:  1,
+		LoadWeight:      0.5,
+		FreshnessWeight: 0.25,
+		InitialRadius:   1000,
+		MaxRadius:       16000,
+		RadiusGrowth:    2,
+		Candidates:      3,
+		MaxOrders:       2,
+		MaxIdle:         10 * time.Minute,
+	})
+	s.now = time.Unix(10000, 0)
+	s.service.Now = func() time.Time { return s.now }
+	s.order = &models.Order{ID: "order", Source: models.Location{Point: elastic.GeoPointFromLatLon(55.7, 37.6)}}
+}
+
+// courier places an active courier north of the order source, 0.001 degree is about 111 m.
+func (s *DispatchServiceTestSuite) courier(name string, north float64, idle time.Duration, orders int) *models.Courier {
+	courier, err := s.couriers.Create(&models.CourierCreate{Name: name, IsActive: true})
+	s.Require().NoError(err)
+	lastSeen := s.now.Add(-idle).Unix()
+	courier, err = s.couriers.Update(&models.CourierUpdate{
+		ID:       &courier.ID,
+		Location: &models.Location{Point: elastic.GeoPointFromLatLon(55.7+north, 37.6)},
+		LastSeen: &lastSeen,
+	})
+	s.Require().NoError(err)
+	for i := 0; i < orders; i++ {
+		s.Require().NoError(s.tracker.Inc(courier.ID))
+	}
+	return courier
+}
+
+func (s *DispatchServiceTestSuite) names(ranking *models.DispatchRanking) []string {
+	names := make([]string, 0, len(ranking.Candidates))
+	for _, candidate := range ranking.Candidates {
+		names = append(names, candidate.Courier.Name)
+	}
+	return names
+}
+
+func (s *DispatchServiceTestSuite) TestRank() {
+	s.courier("near", 0.001, 0, 0)
+	s.courier("loaded", 0.001, 0, 1)
+	s.courier("silent", 0.001, 5*time.Minute, 0)
+	s.courier("far", 0.05, 0, 0)
+
+	ranking, err := s.service.Rank(s.order)
+	s.Require().NoError(err)
+	s.Equal("order", ranking.OrderID)
+	s.Equal(1000, ranking.Radius)
+	s.Equal([]string{"near", "silent", "loaded"}, s.names(ranking))
+	best := ranking.Best()
+	s.InDelta(111, best.Distance, 1)
+	s.InDelta(111.0/16000, best.Score, 0.0001)
+	loaded := ranking.Candidates[2]
+	s.Equal(1, loaded.OrdersCount)
+	s.InDelta(best.Score+0.25, loaded.Score, 0.0001)
+	s.Equal(int64(300), ranking.Candidates[1].Idle)
+}
+
+func (s *DispatchServiceTestSuite) TestRankSkipsCouriersOverCaps() {
+	s.courier("full", 0.001, 0, 2)
+	s.courier("gone", 0.001, 11*time.Minute, 0)
+	inactive := s.courier("inactive", 0.001, 0, 0)
+	isActive := false
+	_, err := s.couriers.Update(&models.CourierUpdate{ID: &inactive.ID, IsActive: &isActive})
+	s.Require().NoError(err)
+
+	ranking, err := s.service.Rank(s.order)
+	s.Require().NoError(err)
+	s.Empty(ranking.Candidates)
+	s.Nil(ranking.Best())
+	s.Equal(16000, ranking.Radius)
+}
+
+func (s *DispatchServiceTestSuite) TestRankExpandsRadius() {
+	s.courier("two km", 0.017, 0, 0)
+	s.courier("five km", 0.045, 0, 0)
+
+	ranking, err := s.service.Rank(s.order)
+	s.Require().NoError(err)
+	s.Equal(16000, ranking.Radius)
+	s.Equal([]string{"two km", "five km"}, s.names(ranking))
+
+	s.service.Config.Candidates = 1
+	ranking, err = s.service.Rank(s.order)
+	s.Require().NoError(err)
+	s.Equal(2000, ranking.Radius)
+	s.Equal([]string{"two km"}, s.names(ranking))
+}
+
+func (s *DispatchServiceTestSuite) TestRankUnknownSource() {
+	_, err := s.service.Rank(&models.Order{ID: "order"})
+	s.Equal(models.ErrOrderSourceUnknown.Code, err.(*models.Error).Code)
+}
+
+func TestUnitDispatchService(t *testing.T) {
+	suite.Run(t, new(DispatchServiceTestSuite))
+}
@@ -0,0 +1,184 @@
+// +build elastic
+
+package services
+
+import (
+	"context"
+	"fmt"
+	"github.com/TeamD2018/geo-rest/services/interfaces"
+	"github.com/olivere/elastic"
+	"github.com/ory/dockertest"
+	"github.com/satori/go.uuid"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// refreshingTransport makes every document write visible to search before it returns,
+// so the behaviour suites can read right after writing like they do against the other backends.
+type refreshingTransport struct {
+	next http.RoundTripper
+}
+
+func (rt refreshingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if isDocumentWrite(req) && req.URL.Query().Get("refresh") == "" {
+		query := req.URL.Query()
+		query.Set("refresh", "true")
+		req.URL.RawQuery = query.Encode()
+	}
+	return rt.next.RoundTrip(req)
+}
+
+func isDocumentWrite(req *http.Request) bool {
+	path := req.URL.Path
+	if req.Method == http.MethodGet || req.Method == http.MethodHead ||
+		strings.HasSuffix(path, "/_search") || strings.HasSuffix(path, "/_count") {
+		return false
+	}
+	return strings.Contains(path, "/_doc") ||
+		strings.HasSuffix(path, "/_bulk") ||
+		strings.HasSuffix(path, "/_update_by_query") ||
+		strings.HasSuffix(path, "/_delete_by_query")
+}
+
+// elasticContainer runs one Elasticsearch for a whole test; every suite test gets its own indices.
+type elasticContainer struct {
+	t        *testing.T
+	pool     *dockertest.Pool
+	resource *dockertest.Resource
+	client   *elastic.Client
+}
+
+func startElastic(t *testing.T) *elasticContainer {
+	pool, err := dockertest.NewPool("")
+	require.NoError(t, err, "could not connect to docker")
+
+	resource, err := pool.Run("docker.elastic.co/elasticsearch/elasticsearch", "6.3.2", []string{"discovery.type=single-node"})
+	require.NoError(t, err, "could not start resource")
+
+	var c *elastic.Client
+	err = pool.Retry(func() error {
+		addr := fmt.Sprintf("http://localhost:%s", resource.GetPort("9200/tcp"))
+
+		var err error
+		c, err = elastic.NewClient(
+			elastic.SetSniff(false),
+			elastic.SetURL(addr),
+			elastic.SetHttpClient(&http.Client{Transport: refreshingTransport{next: http.DefaultTransport}}))
+		if err != nil {
+			return err
+		}
+
+		_, _, err = c.Ping(addr).Do(context.Background())
+
+		return err
+	})
+	if err != nil {
+		pool.Purge(resource)
+		require.NoError(t, err, "could not connect to docker")
+	}
+	return &elasticContainer{t: t, pool: pool, resource: resource, client: c}
+}
+
+func (ec *elasticContainer) purge() {
+	if err := ec.pool.Purge(ec.resource); err != nil {
+		ec.t.Logf("could not purge resource: %s", err)
+	}
+}
+
+func (ec *elasticContainer) index(name string) string {
+	return name + "-" + uuid.NewV4().String()
+}
+
+func (ec *elasticContainer) ensure(dao interface{ EnsureMapping() error }) {
+	require.NoError(ec.t, dao.EnsureMapping())
+}
+
+func (ec *elasticContainer) couriersDAO() *CouriersElasticDAO {
+	dao := NewCouriersElasticDAO(ec.client, zap.NewNop(), ec.index(CourierIndex), DefaultCouriersReturnSize)
+	ec.ensure(dao)
+	return dao
+}
+
+func TestIntegrationElasticCouriersDAO(t *testing.T) {
+	ec := startElastic(t)
+	defer ec.purge()
+	suite.Run(t, &CouriersDAOBehaviourSuite{newDAO: func() interfaces.ICouriersDAO {
+		return ec.couriersDAO()
+	}})
+}
+
+func TestIntegrationElasticOrdersDAO(t *testing.T) {
+	ec := startElastic(t)
+	defer ec.purge()
+	suite.Run(t, &OrdersDAOBehaviourSuite{newDAOs: func() (interfaces.ICouriersDAO, interfaces.IOrdersDao) {
+		couriersDao := ec.couriersDAO()
+		ordersDao := NewOrdersElasticDAO(ec.client, zap.NewNop(), couriersDao, ec.index(OrdersIndex))
+		ec.ensure(ordersDao)
+		return couriersDao, ordersDao
+	}})
+}
+
+func TestIntegrationElasticRouteArchive(t *testing.T) {
+	ec := startElastic(t)
+	defer ec.purge()
+	suite.Run(t, &RouteArchiveBehaviourSuite{newArchive: func() interfaces.RouteArchive {
+		archive := NewRouteArchiveElastic(ec.client, zap.NewNop(), ec.index(RouteArchiveIndex))
+		ec.ensure(archive)
+		return archive
+	}})
+}
+
+func TestIntegrationElasticRejectedLocations(t *testing.T) {
+	ec := startElastic(t)
+	defer ec.purge()
+	suite.Run(t, &RejectedLocationsBehaviourSuite{newLog: func() interfaces.RejectedLocationsLog {
+		log := NewRejectedLocationsElastic(ec.client, zap.NewNop(), ec.index(RejectedLocationsIndex))
+		ec.ensure(log)
+		return log
+	}})
+}
+
+func TestIntegrationElasticGeofences(t *testing.T) {
+	ec := startElastic(t)
+	defer ec.purge()
+	suite.Run(t, &GeofencesBehaviourSuite{newStorage: func() (interfaces.GeofenceStore, interfaces.GeofenceEventLog) {
+		store := NewGeofencesElastic(ec.client, zap.NewNop(), ec.index(GeofencesIndex), ec.index(GeofencePresenceIndex))
+		ec.ensure(store)
+		events := NewGeofenceEventsElastic(ec.client, zap.NewNop(), ec.index(GeofenceEventsIndex))
+		ec.ensure(events)
+		return store, events
+	}})
+}
+
+func TestIntegrationElasticWebhooks(t *testing.T) {
+	ec := startElastic(t)
+	defer ec.purge()
+	suite.Run(t, &WebhooksBehaviourSuite{newStore: func() interfaces.WebhookStore {
+		store := NewWebhooksElastic(ec.client, zap.NewNop(), ec.index(WebhooksIndex), ec.index(WebhookDeadLettersIndex))
+		ec.ensure(store)
+		return store
+	}})
+}
+
+func TestIntegrationElasticEnsureMappingOfExistingIndex(t *testing.T) {
+	ec := startElastic(t)
+	defer ec.purge()
+	dao := NewCouriersElasticDAO(ec.client, zap.NewNop(), ec.index(CourierIndex), DefaultCouriersReturnSize)
+	_, err := ec.client.CreateIndex(dao.GetIndex()).
+		BodyString(`{"mappings": {"_doc": {"properties": {"name": {"type": "keyword"}}}}}`).
+		Do(context.Background())
+	require.NoError(t, err)
+
+	ec.ensure(dao)
+
+	mappings, err := ec.client.GetMapping().Index(dao.GetIndex()).Type("_doc").Do(context.Background())
+	require.NoError(t, err)
+	index := mappings[dao.GetIndex()].(map[string]interface{})
+	properties := index["mappings"].(map[string]interface{})["_doc"].(map[string]interface{})["properties"].(map[string]interface{})
+	require.Contains(t, properties, "stale")
+	require.Equal(t, "boolean", properties["stale"].(map[string]interface{})["type"])
+}
@@ -0,0 +1,37 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/olivere/elastic"
+)
+
+// ensureIndex creates the index with the mapping. When the index already exists the properties of the mapping
+// are put to it, so fields added after the index was created are not mapped dynamically by the first document
+// that has them.
+func ensureIndex(client *elastic.Client, indexName string, mapping string) error {
+	ctx := context.Background()
+	exists, err := client.IndexExists(indexName).Do(ctx)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		_, err := client.CreateIndex(indexName).BodyString(mapping).Do(ctx)
+		return err
+	}
+
+	var body struct {
+		Mappings map[string]json.RawMessage `json:"mappings"`
+	}
+	if err := json.Unmarshal([]byte(mapping), &body); err != nil {
+		return err
+	}
+	for docType, properties := range body.Mappings {
+		_, err := client.PutMapping().Index(indexName).Type(docType).BodyString(string(properties)).Do(ctx)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
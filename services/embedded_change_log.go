@@ -0,0 +1,109 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/TeamD2018/geo-rest/models"
+	"github.com/TeamD2018/geo-rest/services/kvstore"
+	"go.uber.org/zap"
+)
+
+const (
+	embeddedChangesBucket = "changes"
+	// keeps the last assigned seq, so it is not reused once old changes are gone
+	embeddedChangeSeqBucket = "change_seq"
+	embeddedChangeSeqKey    = "last"
+)
+
+type EmbeddedChangeLog struct {
+	store *kvstore.Store
+	l     *zap.Logger
+}
+
+func NewEmbeddedChangeLog(store *kvstore.Store, logger *zap.Logger) *EmbeddedChangeLog {
+	return &EmbeddedChangeLog{
+		store: store,
+		l:     logger,
+	}
+}
+
+func (e *EmbeddedChangeLog) Append(change *models.Change) error {
+	return e.store.Update(func(tx *kvstore.Tx) error {
+		var seq uint64
+		if _, err := tx.Get(embeddedChangeSeqBucket, embeddedChangeSeqKey, &seq); err != nil {
+			return err
+		}
+		seq++
+		change.Seq = seq
+		if err := tx.Put(embeddedChangeSeqBucket, embeddedChangeSeqKey, seq); err != nil {
+			return err
+		}
+		return tx.Put(embeddedChangesBucket, changeKey(seq), change)
+	})
+}
+
+func (e *EmbeddedChangeLog) Read(after uint64, limit int) (models.Changes, error) {
+	if limit <= 0 {
+		limit = DefaultChangesReturnSize
+	}
+	from := changeKey(after)
+	changes := make(models.Changes, 0)
+	err := e.store.ForEach(embeddedChangesBucket, func(key string, raw json.RawMessage) error {
+		if key <= from {
+			return nil
+		}
+		var change models.Change
+		if err := json.Unmarshal(raw, &change); err != nil {
+			return err
+		}
+		changes = append(changes, &change)
+		if len(changes) >= limit {
+			return errStopIteration
+		}
+		return nil
+	})
+	if err != nil && err != errStopIteration {
+		e.l.Error("fail to read changes", zap.Error(err))
+		return nil, err
+	}
+	return changes, nil
+}
+
+func (e *EmbeddedChangeLog) Trim(before int64) (int, error) {
+	trimmed := 0
+	err := e.store.Update(func(tx *kvstore.Tx) error {
+		// the bucket must not be modified while it is iterated
+		keys := make([]string, 0)
+		err := tx.ForEach(embeddedChangesBucket, func(key string, raw json.RawMessage) error {
+			var change models.Change
+			if err := json.Unmarshal(raw, &change); err != nil {
+				return err
+			}
+			if change.Ts >= before {
+				return errStopIteration
+			}
+			keys = append(keys, key)
+			return nil
+		})
+		if err != nil && err != errStopIteration {
+			return err
+		}
+		for _, key := range keys {
+			if err := tx.Delete(embeddedChangesBucket, key); err != nil {
+				return err
+			}
+		}
+		trimmed = len(keys)
+		return nil
+	})
+	if err != nil {
+		e.l.Error("fail to trim changes", zap.Error(err))
+		return 0, err
+	}
+	return trimmed, nil
+}
+
+// changeKey sorts keys by seq.
+func changeKey(seq uint64) string {
+	return fmt.Sprintf("%020d", seq)
+}
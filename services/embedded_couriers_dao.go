@@ -0,0 +1,236 @@
+package services
+
+import (
+	"encoding/json"
+	"github.com/TeamD2018/geo-rest/models"
+	"github.com/TeamD2018/geo-rest/services/geo"
+	"github.com/TeamD2018/geo-rest/services/kvstore"
+	"github.com/satori/go.uuid"
+	"go.uber.org/zap"
+	"sync"
+	"time"
+)
+
+const embeddedCouriersBucket = "couriers"
+
+// EmbeddedCouriersDAO keeps couriers in an on-disk kvstore and their locations in an in-memory grid index rebuilt on start.
+type EmbeddedCouriersDAO struct {
+	mu                sync.RWMutex
+	store             *kvstore.Store
+	index             *geo.GridIndex
+	defaultReturnSize int
+	l                 *zap.Logger
+}
+
+func NewEmbeddedCouriersDAO(store *kvstore.Store, logger *zap.Logger, cellSize float64, defaultReturnSize int) (*EmbeddedCouriersDAO, error) {
+	if logger == nil {
+		logger, _ = zap.NewDevelopment()
+	}
+	if defaultReturnSize <= 0 {
+		defaultReturnSize = DefaultCouriersReturnSize
+	}
+	c := &EmbeddedCouriersDAO{
+		store:             store,
+		index:             geo.NewGridIndex(cellSize),
+		defaultReturnSize: defaultReturnSize,
+		l:                 logger,
+	}
+	err := store.ForEach(embeddedCouriersBucket, func(key string, raw json.RawMessage) error {
+		var courier models.Courier
+		if err := json.Unmarshal(raw, &courier); err != nil {
+			return err
+		}
+		if courier.Location != nil {
+			c.index.Set(courier.ID, courier.Location.Point)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *EmbeddedCouriersDAO) GetByID(courierID string) (*models.Courier, error) {
+	var courier models.Courier
+	found, err := c.store.Get(embeddedCouriersBucket, courierID, &courier)
+	if err != nil {
+		c.l.Error("fail to get courier", zap.String("courier_id", courierID), zap.Error(err))
+		return nil, models.ErrUnmarshalJSON.SetParameter(err)
+	}
+	if !found {
+		return nil, models.ErrCourierNotFound.SetParameter(courierID)
+	}
+	return &courier, nil
+}
+
+func (c *EmbeddedCouriersDAO) GetByName(name string, size int) (models.Couriers, error) {
+	result := models.Couriers{}
+	err := c.store.ForEach(embeddedCouriersBucket, func(key string, raw json.RawMessage) error {
+		var courier models.Courier
+		if err := json.Unmarshal(raw, &courier); err != nil {
+			return err
+		}
+		if courier.Name == name {
+			result = append(result, &courier)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c.limit(result, size), nil
+}
+
+func (c *EmbeddedCouriersDAO) GetByBoxField(field *models.BoxField, size int, activeOnly bool) (models.Couriers, error) {
+	c.mu.RLock()
+	ids := c.index.SearchBox(field)
+	c.mu.RUnlock()
+	return c.load(ids, size, activeOnly)
+}
+
+func (c *EmbeddedCouriersDAO) GetByCircleField(field *models.CircleField, size int, activeOnly bool) (models.Couriers, error) {
+	c.mu.RLock()
+	ids := c.index.SearchCircle(field)
+	c.mu.RUnlock()
+	return c.load(ids, size, activeOnly)
+}
+
+func (c *EmbeddedCouriersDAO) GetByPolygon(polygon models.FlatPolygon, size int, activeOnly bool) (models.Couriers, error) {
+	c.mu.RLock()
+	ids := c.index.SearchPolygon(polygon)
+	c.mu.RUnlock()
+	return c.load(ids, size, activeOnly)
+}
+
+func (c *EmbeddedCouriersDAO) Create(courier *models.CourierCreate) (*models.Courier, error) {
+	created := &models.Courier{
+		ID:       uuid.NewV4().String(),
+		Name:     courier.Name,
+		Phone:    courier.Phone,
+		IsActive: courier.IsActive,
+	}
+	if err := c.store.Put(embeddedCouriersBucket, created.ID, created); err != nil {
+		c.l.Error("fail to create courier", zap.Error(err))
+		return nil, err
+	}
+	return created, nil
+}
+
+func (c *EmbeddedCouriersDAO) Update(courier *models.CourierUpdate) (*models.Courier, error) {
+	id := *courier.ID
+	courier.ID = nil
+	if courier.Location != nil && courier.LastSeen == nil {
+		now := time.Now().Unix()
+		courier.LastSeen = &now
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var stored models.Courier
+	err := c.store.Update(func(tx *kvstore.Tx) error {
+		found, err := tx.Get(embeddedCouriersBucket, id, &stored)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return models.ErrCourierNotFound.SetParameter(id)
+		}
+		applyCourierUpdate(&stored, courier)
+		return tx.Put(embeddedCouriersBucket, id, &stored)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if stored.Location != nil {
+		c.index.Set(id, stored.Location.Point)
+	}
+	return &stored, nil
+}
+
+func (c *EmbeddedCouriersDAO) Exists(courierID string) (bool, error) {
+	var courier models.Courier
+	return c.store.Get(embeddedCouriersBucket, courierID, &courier)
+}
+
+func (c *EmbeddedCouriersDAO) Delete(courierID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	err := c.store.Update(func(tx *kvstore.Tx) error {
+		var courier models.Courier
+		found, err := tx.Get(embeddedCouriersBucket, courierID, &courier)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return models.ErrCourierNotFound.SetParameter(courierID)
+		}
+		return tx.Delete(embeddedCouriersBucket, courierID)
+	})
+	if err != nil {
+		return err
+	}
+	c.index.Remove(courierID)
+	return nil
+}
+
+func (c *EmbeddedCouriersDAO) GetSilent(before int64, size int) (models.Couriers, error) {
+	result := models.Couriers{}
+	err := c.store.ForEach(embeddedCouriersBucket, func(key string, raw json.RawMessage) error {
+		var courier models.Courier
+		if err := json.Unmarshal(raw, &courier); err != nil {
+			return err
+		}
+		if isSilent(&courier, before) {
+			result = append(result, &courier)
+		}
+		return nil
+	})
+	if err != nil {
+		c.l.Error("fail to get silent couriers", zap.Error(err))
+		return nil, err
+	}
+	return limitSilent(result, size, c.defaultReturnSize), nil
+}
+
+// All returns every stored courier ordered by id.
+func (c *EmbeddedCouriersDAO) All() models.Couriers {
+	result := models.Couriers{}
+	err := c.store.ForEach(embeddedCouriersBucket, func(key string, raw json.RawMessage) error {
+		var courier models.Courier
+		if err := json.Unmarshal(raw, &courier); err != nil {
+			return err
+		}
+		result = append(result, &courier)
+		return nil
+	})
+	if err != nil {
+		c.l.Error("fail to list couriers", zap.Error(err))
+	}
+	return result
+}
+
+func (c *EmbeddedCouriersDAO) load(ids []string, size int, activeOnly bool) (models.Couriers, error) {
+	result := models.Couriers{}
+	for _, id := range ids {
+		var courier models.Courier
+		found, err := c.store.Get(embeddedCouriersBucket, id, &courier)
+		if err != nil {
+			return nil, err
+		}
+		if !found || (activeOnly && !courier.IsActive) {
+			continue
+		}
+		result = append(result, &courier)
+	}
+	return c.limit(result, size), nil
+}
+
+func (c *EmbeddedCouriersDAO) limit(couriers models.Couriers, size int) models.Couriers {
+	if size <= 0 {
+		size = c.defaultReturnSize
+	}
+	if len(couriers) > size {
+		return couriers[:size]
+	}
+	return couriers
+}
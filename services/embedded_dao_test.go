@@ -0,0 +1,190 @@
+package services
+
+import (
+	"github.com/TeamD2018/geo-rest/models"
+	"github.com/TeamD2018/geo-rest/services/geo"
+	"github.com/TeamD2018/geo-rest/services/interfaces"
+	"github.com/TeamD2018/geo-rest/services/kvstore"
+	"github.com/olivere/elastic"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func openTestStore(t *testing.T, path string) *kvstore.Store {
+	store, err := kvstore.Open(path, false)
+	require.NoError(t, err)
+	return store
+}
+
+func tempStorePath(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "geo-rest")
+	require.NoError(t, err)
+	return filepath.Join(dir, "geo-rest.db")
+}
+
+// testStores hands every test of a suite an empty store, closing the store of the previous test.
+type testStores struct {
+	t     *testing.T
+	path  string
+	store *kvstore.Store
+}
+
+func newTestStores(t *testing.T) *testStores {
+	return &testStores{t: t, path: tempStorePath(t)}
+}
+
+func (ts *testStores) fresh() *kvstore.Store {
+	ts.close()
+	require.NoError(ts.t, os.RemoveAll(ts.path))
+	ts.store = openTestStore(ts.t, ts.path)
+	return ts.store
+}
+
+func (ts *testStores) close() {
+	if ts.store != nil {
+		require.NoError(ts.t, ts.store.Close())
+		ts.store = nil
+	}
+}
+
+func (ts *testStores) remove() {
+	ts.close()
+	os.RemoveAll(filepath.Dir(ts.path))
+}
+
+func TestUnitEmbeddedCouriersDAO(t *testing.T) {
+	stores := newTestStores(t)
+	defer stores.remove()
+	suite.Run(t, &CouriersDAOBehaviourSuite{newDAO: func() interfaces.ICouriersDAO {
+		dao, err := NewEmbeddedCouriersDAO(stores.fresh(), zap.NewNop(), geo.DefaultCellSize, DefaultCouriersReturnSize)
+		require.NoError(t, err)
+		return dao
+	}})
+}
+
+func TestUnitEmbeddedOrdersDAO(t *testing.T) {
+	stores := newTestStores(t)
+	defer stores.remove()
+	suite.Run(t, &OrdersDAOBehaviourSuite{newDAOs: func() (interfaces.ICouriersDAO, interfaces.IOrdersDao) {
+		store := stores.fresh()
+		couriersDao, err := NewEmbeddedCouriersDAO(store, zap.NewNop(), geo.DefaultCellSize, DefaultCouriersReturnSize)
+		require.NoError(t, err)
+		return couriersDao, NewEmbeddedOrdersDAO(store, zap.NewNop(), couriersDao)
+	}})
+}
+
+func TestUnitEmbeddedRouteDAO(t *testing.T) {
+	stores := newTestStores(t)
+	defer stores.remove()
+	suite.Run(t, &RouteDAOBehaviourSuite{newDAO: func() interfaces.GeoRouteInterface {
+		return NewEmbeddedRouteDAO(stores.fresh(), zap.NewNop())
+	}})
+}
+
+func TestUnitEmbeddedRouteArchive(t *testing.T) {
+	stores := newTestStores(t)
+	defer stores.remove()
+	suite.Run(t, &RouteArchiveBehaviourSuite{newArchive: func() interfaces.RouteArchive {
+		return NewEmbeddedRouteArchive(stores.fresh(), zap.NewNop())
+	}})
+}
+
+func TestUnitEmbeddedRejectedLocations(t *testing.T) {
+	stores := newTestStores(t)
+	defer stores.remove()
+	suite.Run(t, &RejectedLocationsBehaviourSuite{newLog: func() interfaces.RejectedLocationsLog {
+		return NewEmbeddedRejectedLocationsLog(stores.fresh(), zap.NewNop())
+	}})
+}
+
+func TestUnitEmbeddedGeofences(t *testing.T) {
+	stores := newTestStores(t)
+	defer stores.remove()
+	suite.Run(t, &GeofencesBehaviourSuite{newStorage: func() (interfaces.GeofenceStore, interfaces.GeofenceEventLog) {
+		store := stores.fresh()
+		return NewEmbeddedGeofenceStore(store, zap.NewNop()), NewEmbeddedGeofenceEventLog(store, zap.NewNop())
+	}})
+}
+
+func TestUnitEmbeddedWebhooks(t *testing.T) {
+	stores := newTestStores(t)
+	defer stores.remove()
+	suite.Run(t, &WebhooksBehaviourSuite{newStore: func() interfaces.WebhookStore {
+		return NewEmbeddedWebhookStore(stores.fresh(), zap.NewNop())
+	}})
+}
+
+func TestUnitEmbeddedChangeLog(t *testing.T) {
+	stores := newTestStores(t)
+	defer stores.remove()
+	suite.Run(t, &ChangeLogBehaviourSuite{newLog: func() interfaces.ChangeLog {
+		return NewEmbeddedChangeLog(stores.fresh(), zap.NewNop())
+	}})
+}
+
+func TestUnitEmbeddedBackendSurvivesRestart(t *testing.T) {
+	path := tempStorePath(t)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	store := openTestStore(t, path)
+	couriersDao, err := NewEmbeddedCouriersDAO(store, zap.NewNop(), geo.DefaultCellSize, DefaultCouriersReturnSize)
+	require.NoError(t, err)
+	ordersDao := NewEmbeddedOrdersDAO(store, zap.NewNop(), couriersDao)
+	routeDao := NewEmbeddedRouteDAO(store, zap.NewNop())
+	tracker := NewEmbeddedOrdersCountTracker(store)
+	changes := NewEmbeddedChangeLog(store, zap.NewNop())
+
+	courier, err := couriersDao.Create(&models.CourierCreate{Name: "Vasya", IsActive: true})
+	require.NoError(t, err)
+	_, err = couriersDao.Update(&models.CourierUpdate{
+		ID:       &courier.ID,
+		Location: &models.Location{Point: elastic.GeoPointFromLatLon(55.75, 37.61)},
+	})
+	require.NoError(t, err)
+	order, err := ordersDao.Create(&models.OrderCreate{CourierID: &courier.ID})
+	require.NoError(t, err)
+	require.NoError(t, routeDao.AddPointToRoute(courier.ID, &models.PointWithTs{Point: elastic.GeoPointFromLatLon(55.75, 37.61), Ts: 2}))
+	require.NoError(t, routeDao.AddPointToRoute(courier.ID, &models.PointWithTs{Point: elastic.GeoPointFromLatLon(55.76, 37.62), Ts: 1}))
+	require.NoError(t, tracker.Inc(courier.ID))
+	require.NoError(t, changes.Append(&models.Change{Entity: models.ChangeOrder, Op: models.ChangeCreated, ID: order.ID}))
+	require.NoError(t, store.Close())
+
+	store = openTestStore(t, path)
+	defer store.Close()
+	couriersDao, err = NewEmbeddedCouriersDAO(store, zap.NewNop(), geo.DefaultCellSize, DefaultCouriersReturnSize)
+	require.NoError(t, err)
+	ordersDao = NewEmbeddedOrdersDAO(store, zap.NewNop(), couriersDao)
+	routeDao = NewEmbeddedRouteDAO(store, zap.NewNop())
+	tracker = NewEmbeddedOrdersCountTracker(store)
+	changes = NewEmbeddedChangeLog(store, zap.NewNop())
+
+	found, err := couriersDao.GetByCircleField(&models.CircleField{Center: elastic.GeoPointFromLatLon(55.75, 37.61), Radius: 100}, 0, true)
+	require.NoError(t, err)
+	require.Len(t, found, 1)
+	require.Equal(t, courier.ID, found[0].ID)
+
+	got, err := ordersDao.Get(order.ID)
+	require.NoError(t, err)
+	require.Equal(t, order, got)
+
+	page, err := routeDao.GetRoute(courier.ID, &models.RouteQuery{})
+	require.NoError(t, err)
+	require.Len(t, page.Points, 2)
+	require.Equal(t, uint64(1), page.Points[0].Ts)
+
+	require.NoError(t, tracker.Sync(found))
+	require.Equal(t, 1, found[0].OrdersCount)
+
+	logged, err := changes.Read(0, 0)
+	require.NoError(t, err)
+	require.Len(t, logged, 1)
+	require.Equal(t, order.ID, logged[0].ID)
+	next := &models.Change{Entity: models.ChangeOrder, Op: models.ChangeDeleted, ID: order.ID}
+	require.NoError(t, changes.Append(next))
+	require.Equal(t, uint64(2), next.Seq)
+}
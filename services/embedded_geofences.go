@@ -0,0 +1,153 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/TeamD2018/geo-rest/models"
+	"github.com/TeamD2018/geo-rest/services/kvstore"
+	"github.com/satori/go.uuid"
+	"go.uber.org/zap"
+)
+
+const (
+	embeddedGeofencesBucket        = "geofences"
+	embeddedGeofencePresenceBucket = "geofence_presence"
+	embeddedGeofenceEventsBucket   = "geofence_events"
+)
+
+type EmbeddedGeofenceStore struct {
+	store *kvstore.Store
+	l     *zap.Logger
+}
+
+func NewEmbeddedGeofenceStore(store *kvstore.Store, logger *zap.Logger) *EmbeddedGeofenceStore {
+	return &EmbeddedGeofenceStore{
+		store: store,
+		l:     logger,
+	}
+}
+
+func (e *EmbeddedGeofenceStore) Create(geofence *models.Geofence) error {
+	geofence.ID = uuid.NewV4().String()
+	if err := e.store.Put(embeddedGeofencesBucket, geofence.ID, geofence); err != nil {
+		geofence.ID = ""
+		return err
+	}
+	return nil
+}
+
+func (e *EmbeddedGeofenceStore) Get(geofenceID string) (*models.Geofence, error) {
+	var geofence models.Geofence
+	found, err := e.store.Get(embeddedGeofencesBucket, geofenceID, &geofence)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, models.ErrEntityNotFound.SetParameter(geofenceID)
+	}
+	return &geofence, nil
+}
+
+func (e *EmbeddedGeofenceStore) Update(geofence *models.Geofence) error {
+	return e.store.Update(func(tx *kvstore.Tx) error {
+		var stored models.Geofence
+		found, err := tx.Get(embeddedGeofencesBucket, geofence.ID, &stored)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return models.ErrEntityNotFound.SetParameter(geofence.ID)
+		}
+		return tx.Put(embeddedGeofencesBucket, geofence.ID, geofence)
+	})
+}
+
+func (e *EmbeddedGeofenceStore) Delete(geofenceID string) error {
+	return e.store.Update(func(tx *kvstore.Tx) error {
+		var stored models.Geofence
+		found, err := tx.Get(embeddedGeofencesBucket, geofenceID, &stored)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return models.ErrEntityNotFound.SetParameter(geofenceID)
+		}
+		return tx.Delete(embeddedGeofencesBucket, geofenceID)
+	})
+}
+
+func (e *EmbeddedGeofenceStore) List() (models.Geofences, error) {
+	geofences := make(models.Geofences, 0)
+	err := e.store.ForEach(embeddedGeofencesBucket, func(key string, raw json.RawMessage) error {
+		var geofence models.Geofence
+		if err := json.Unmarshal(raw, &geofence); err != nil {
+			return err
+		}
+		geofences = append(geofences, &geofence)
+		return nil
+	})
+	if err != nil {
+		e.l.Error("fail to list geofences", zap.Error(err))
+		return nil, err
+	}
+	sortGeofences(geofences)
+	return geofences, nil
+}
+
+func (e *EmbeddedGeofenceStore) Presence(courierID string) (models.GeofencePresences, error) {
+	presence := make(models.GeofencePresences, 0)
+	if _, err := e.store.Get(embeddedGeofencePresenceBucket, courierID, &presence); err != nil {
+		return nil, err
+	}
+	return presence, nil
+}
+
+func (e *EmbeddedGeofenceStore) SetPresence(courierID string, presence models.GeofencePresences) error {
+	if len(presence) == 0 {
+		return e.store.Delete(embeddedGeofencePresenceBucket, courierID)
+	}
+	return e.store.Put(embeddedGeofencePresenceBucket, courierID, presence)
+}
+
+type EmbeddedGeofenceEventLog struct {
+	store *kvstore.Store
+	l     *zap.Logger
+}
+
+func NewEmbeddedGeofenceEventLog(store *kvstore.Store, logger *zap.Logger) *EmbeddedGeofenceEventLog {
+	return &EmbeddedGeofenceEventLog{
+		store: store,
+		l:     logger,
+	}
+}
+
+func (e *EmbeddedGeofenceEventLog) Record(event *models.GeofenceEvent) error {
+	id := uuid.NewV4().String()
+	event.ID = id
+	// keys sort by event time
+	key := fmt.Sprintf("%020d/%s", event.Ts, id)
+	if err := e.store.Put(embeddedGeofenceEventsBucket, key, event); err != nil {
+		event.ID = ""
+		return err
+	}
+	return nil
+}
+
+func (e *EmbeddedGeofenceEventLog) Find(query *models.GeofenceEventQuery) (models.GeofenceEvents, error) {
+	found := make(models.GeofenceEvents, 0)
+	err := e.store.ForEach(embeddedGeofenceEventsBucket, func(key string, raw json.RawMessage) error {
+		var event models.GeofenceEvent
+		if err := json.Unmarshal(raw, &event); err != nil {
+			return err
+		}
+		if query.Matches(&event) {
+			found = append(found, &event)
+		}
+		return nil
+	})
+	if err != nil {
+		e.l.Error("fail to find geofence events", zap.Error(err))
+		return nil, err
+	}
+	return limitGeofenceEvents(found, query.Limit), nil
+}
@@ -0,0 +1,65 @@
+package services
+
+import (
+	"github.com/TeamD2018/geo-rest/models"
+	"github.com/TeamD2018/geo-rest/services/kvstore"
+)
+
+const embeddedOrdersCountBucket = "orders_count"
+
+type EmbeddedOrdersCountTracker struct {
+	store *kvstore.Store
+}
+
+func NewEmbeddedOrdersCountTracker(store *kvstore.Store) *EmbeddedOrdersCountTracker {
+	return &EmbeddedOrdersCountTracker{store: store}
+}
+
+func (oct *EmbeddedOrdersCountTracker) Inc(courierID string) error {
+	_, err := oct.IncAndGet(courierID)
+	return err
+}
+
+func (oct *EmbeddedOrdersCountTracker) Dec(courierID string) error {
+	_, err := oct.DecAndGet(courierID)
+	return err
+}
+
+func (oct *EmbeddedOrdersCountTracker) IncAndGet(courierID string) (int, error) {
+	return oct.add(courierID, 1)
+}
+
+// DecAndGet mirrors the Tarantool upsert: a missing counter starts at zero and is not decremented.
+func (oct *EmbeddedOrdersCountTracker) DecAndGet(courierID string) (int, error) {
+	return oct.add(courierID, -1)
+}
+
+func (oct *EmbeddedOrdersCountTracker) Sync(couriers models.Couriers) error {
+	for _, courier := range couriers {
+		var counter int
+		if _, err := oct.store.Get(embeddedOrdersCountBucket, courier.ID, &counter); err != nil {
+			return err
+		}
+		courier.OrdersCount = counter
+	}
+	return nil
+}
+
+func (oct *EmbeddedOrdersCountTracker) Drop(courierID string) error {
+	return oct.store.Delete(embeddedOrdersCountBucket, courierID)
+}
+
+func (oct *EmbeddedOrdersCountTracker) add(courierID string, delta int) (int, error) {
+	var counter int
+	err := oct.store.Update(func(tx *kvstore.Tx) error {
+		found, err := tx.Get(embeddedOrdersCountBucket, courierID, &counter)
+		if err != nil {
+			return err
+		}
+		if found || delta > 0 {
+			counter += delta
+		}
+		return tx.Put(embeddedOrdersCountBucket, courierID, counter)
+	})
+	return counter, err
+}
@@ -0,0 +1,332 @@
+package services
+
+import (
+	"encoding/json"
+	"github.com/TeamD2018/geo-rest/models"
+	"github.com/TeamD2018/geo-rest/services/geo"
+	"github.com/TeamD2018/geo-rest/services/interfaces"
+	"github.com/TeamD2018/geo-rest/services/kvstore"
+	"github.com/olivere/elastic"
+	"github.com/satori/go.uuid"
+	"go.uber.org/zap"
+	"sort"
+	"time"
+)
+
+const (
+	embeddedOrdersBucket              = "orders"
+	embeddedCourierOrdersBucketPrefix = "courier_orders/"
+)
+
+// EmbeddedOrdersDAO keeps orders in an on-disk kvstore together with a per-courier index of order ids.
+type EmbeddedOrdersDAO struct {
+	store       *kvstore.Store
+	couriersDAO interfaces.ICouriersDAO
+	Logger      *zap.Logger
+}
+
+func NewEmbeddedOrdersDAO(store *kvstore.Store, logger *zap.Logger, couriersDAO interfaces.ICouriersDAO) *EmbeddedOrdersDAO {
+	if logger == nil {
+		logger, _ = zap.NewDevelopment()
+	}
+	return &EmbeddedOrdersDAO{
+		store:       store,
+		couriersDAO: couriersDAO,
+		Logger:      logger,
+	}
+}
+
+func (od *EmbeddedOrdersDAO) Get(orderID string) (*models.Order, error) {
+	var order models.Order
+	found, err := od.store.Get(embeddedOrdersBucket, orderID, &order)
+	if err != nil {
+		return nil, models.ErrUnmarshalJSON
+	}
+	if !found {
+		return nil, models.ErrEntityNotFound.SetParameter(orderID)
+	}
+	return &order, nil
+}
+
+func (od *EmbeddedOrdersDAO) Create(orderCreate *models.OrderCreate) (*models.Order, error) {
+	courierID := ""
+	if orderCreate.CourierID != nil {
+		courierID = *orderCreate.CourierID
+	}
+	// orders without a courier are unassigned until claimed
+	if courierID != "" {
+		exists, err := od.couriersDAO.Exists(courierID)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, models.ErrEntityNotFound.SetParameter(courierID)
+		}
+	}
+	order := &models.Order{
+		ID:          uuid.NewV4().String(),
+		CourierID:   courierID,
+		CreatedAt:   time.Now().Unix(),
+		Destination: orderCreate.Destination,
+		Source:      orderCreate.Source,
+		OrderNumber: orderCreate.OrderNumber,
+	}
+	order.StartStatus()
+	err := od.store.Update(func(tx *kvstore.Tx) error {
+		if err := tx.Put(embeddedOrdersBucket, order.ID, order); err != nil {
+			return err
+		}
+		if order.CourierID == "" {
+			return nil
+		}
+		return tx.Put(courierOrdersBucket(order.CourierID), order.ID, true)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+func (od *EmbeddedOrdersDAO) Update(update *models.OrderUpdate) (*models.Order, error) {
+	id := *update.ID
+	update.ID = nil
+	if update.CourierID != nil {
+		exists, err := od.couriersDAO.Exists(*update.CourierID)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, models.ErrEntityNotFound.SetParameter(*update.CourierID)
+		}
+	}
+	var order models.Order
+	err := od.store.Update(func(tx *kvstore.Tx) error {
+		found, err := tx.Get(embeddedOrdersBucket, id, &order)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return models.ErrEntityNotFound.SetParameter(id)
+		}
+		previousCourierID := order.CourierID
+		applyOrderUpdate(&order, update)
+		if order.CourierID != previousCourierID {
+			if previousCourierID != "" {
+				if err := tx.Delete(courierOrdersBucket(previousCourierID), id); err != nil {
+					return err
+				}
+			}
+			if err := tx.Put(courierOrdersBucket(order.CourierID), id, true); err != nil {
+				return err
+			}
+		}
+		return tx.Put(embeddedOrdersBucket, id, &order)
+	})
+	if err != nil {
+		od.Logger.Sugar().Errorw("order update failed", *update)
+		return nil, err
+	}
+	return &order, nil
+}
+
+func (od *EmbeddedOrdersDAO) Claim(orderID, courierID string) (*models.Order, error) {
+	exists, err := od.couriersDAO.Exists(courierID)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, models.ErrEntityNotFound.SetParameter(courierID)
+	}
+	var order models.Order
+	err = od.store.Update(func(tx *kvstore.Tx) error {
+		found, err := tx.Get(embeddedOrdersBucket, orderID, &order)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return models.ErrEntityNotFound.SetParameter(orderID)
+		}
+		if err := order.Claim(courierID, time.Now().Unix()); err != nil {
+			return err
+		}
+		if err := tx.Put(courierOrdersBucket(courierID), orderID, true); err != nil {
+			return err
+		}
+		return tx.Put(embeddedOrdersBucket, orderID, &order)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+func (od *EmbeddedOrdersDAO) Reassign(orderID, courierID, reason string) (*models.Order, error) {
+	exists, err := od.couriersDAO.Exists(courierID)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, models.ErrEntityNotFound.SetParameter(courierID)
+	}
+	var order models.Order
+	err = od.store.Update(func(tx *kvstore.Tx) error {
+		found, err := tx.Get(embeddedOrdersBucket, orderID, &order)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return models.ErrEntityNotFound.SetParameter(orderID)
+		}
+		previousCourierID := order.CourierID
+		if err := order.Reassign(courierID, reason, time.Now().Unix()); err != nil {
+			return err
+		}
+		if err := tx.Delete(courierOrdersBucket(previousCourierID), orderID); err != nil {
+			return err
+		}
+		if err := tx.Put(courierOrdersBucket(courierID), orderID, true); err != nil {
+			return err
+		}
+		return tx.Put(embeddedOrdersBucket, orderID, &order)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+func (od *EmbeddedOrdersDAO) Transition(orderID, courierID string, change *models.OrderStatusChange) (*models.Order, error) {
+	var order models.Order
+	err := od.store.Update(func(tx *kvstore.Tx) error {
+		found, err := tx.Get(embeddedOrdersBucket, orderID, &order)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return models.ErrEntityNotFound.SetParameter(orderID)
+		}
+		if err := order.TransitionFor(courierID, change); err != nil {
+			return err
+		}
+		return tx.Put(embeddedOrdersBucket, orderID, &order)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+func (od *EmbeddedOrdersDAO) Delete(orderID string) error {
+	return od.store.Update(func(tx *kvstore.Tx) error {
+		var order models.Order
+		found, err := tx.Get(embeddedOrdersBucket, orderID, &order)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return models.ErrEntityNotFound.SetParameter(orderID)
+		}
+		if err := tx.Delete(embeddedOrdersBucket, orderID); err != nil {
+			return err
+		}
+		return tx.Delete(courierOrdersBucket(order.CourierID), orderID)
+	})
+}
+
+func (od *EmbeddedOrdersDAO) DeleteOrdersForCourier(courierID string) error {
+	return od.store.Update(func(tx *kvstore.Tx) error {
+		bucket := courierOrdersBucket(courierID)
+		err := tx.ForEach(bucket, func(orderID string, raw json.RawMessage) error {
+			return tx.Delete(embeddedOrdersBucket, orderID)
+		})
+		if err != nil {
+			return err
+		}
+		return tx.DeleteBucket(bucket)
+	})
+}
+
+func (od *EmbeddedOrdersDAO) GetOrdersForCourier(courierID string, query *models.OrdersQuery) (*models.OrdersPage, error) {
+	orders, err := od.courierOrders(courierID)
+	if err != nil {
+		return nil, err
+	}
+	return pageOrders(orders, query), nil
+}
+
+// courierOrders reads the index of the courier and the orders it lists from one snapshot.
+func (od *EmbeddedOrdersDAO) courierOrders(courierID string) (models.Orders, error) {
+	orders := make(models.Orders, 0)
+	err := od.store.View(func(tx *kvstore.Tx) error {
+		return tx.ForEach(courierOrdersBucket(courierID), func(orderID string, raw json.RawMessage) error {
+			var order models.Order
+			found, err := tx.Get(embeddedOrdersBucket, orderID, &order)
+			if err != nil || !found {
+				return err
+			}
+			orders = append(orders, &order)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
+
+func (od *EmbeddedOrdersDAO) GetByBoxField(field *models.BoxField, search *models.OrderSearch) (models.Orders, error) {
+	return od.search(search, func(point *elastic.GeoPoint) bool {
+		return geo.InBox(point, field)
+	})
+}
+
+func (od *EmbeddedOrdersDAO) GetByCircleField(field *models.CircleField, search *models.OrderSearch) (models.Orders, error) {
+	return od.search(search, func(point *elastic.GeoPoint) bool {
+		return geo.InCircle(point, field)
+	})
+}
+
+func (od *EmbeddedOrdersDAO) GetByPolygon(polygon models.FlatPolygon, search *models.OrderSearch) (models.Orders, error) {
+	return od.search(search, func(point *elastic.GeoPoint) bool {
+		return geo.InPolygon(point, polygon)
+	})
+}
+
+// search scans the orders of the courier when the search names one and every order otherwise.
+func (od *EmbeddedOrdersDAO) search(search *models.OrderSearch, inside func(point *elastic.GeoPoint) bool) (models.Orders, error) {
+	orders := make(models.Orders, 0)
+	match := func(order *models.Order) {
+		if search.Match(order) && inside(search.PointOf(order)) {
+			orders = append(orders, order)
+		}
+	}
+	var err error
+	if search.CourierID != "" {
+		var owned models.Orders
+		owned, err = od.courierOrders(search.CourierID)
+		for _, order := range owned {
+			match(order)
+		}
+	} else {
+		err = od.store.ForEach(embeddedOrdersBucket, func(orderID string, raw json.RawMessage) error {
+			var order models.Order
+			if err := json.Unmarshal(raw, &order); err != nil {
+				return err
+			}
+			match(&order)
+			return nil
+		})
+	}
+	if err != nil {
+		od.Logger.Error("fail to search orders", zap.Error(err))
+		return nil, err
+	}
+	sort.SliceStable(orders, func(i, j int) bool {
+		return orders[i].CreatedAt < orders[j].CreatedAt
+	})
+	return limitOrders(orders, search.Size), nil
+}
+
+func courierOrdersBucket(courierID string) string {
+	return embeddedCourierOrdersBucketPrefix + courierID
+}
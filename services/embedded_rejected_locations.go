@@ -0,0 +1,55 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/TeamD2018/geo-rest/models"
+	"github.com/TeamD2018/geo-rest/services/kvstore"
+	"github.com/satori/go.uuid"
+	"go.uber.org/zap"
+)
+
+const embeddedRejectedLocationsBucket = "rejected_locations"
+
+type EmbeddedRejectedLocationsLog struct {
+	store *kvstore.Store
+	l     *zap.Logger
+}
+
+func NewEmbeddedRejectedLocationsLog(store *kvstore.Store, logger *zap.Logger) *EmbeddedRejectedLocationsLog {
+	return &EmbeddedRejectedLocationsLog{
+		store: store,
+		l:     logger,
+	}
+}
+
+func (e *EmbeddedRejectedLocationsLog) Record(rejected *models.RejectedLocation) error {
+	id := uuid.NewV4().String()
+	rejected.ID = id
+	// keys sort by rejection time
+	key := fmt.Sprintf("%020d/%s", rejected.RejectedAt, id)
+	if err := e.store.Put(embeddedRejectedLocationsBucket, key, rejected); err != nil {
+		rejected.ID = ""
+		return err
+	}
+	return nil
+}
+
+func (e *EmbeddedRejectedLocationsLog) Find(query *models.RejectedLocationQuery) (models.RejectedLocations, error) {
+	found := make(models.RejectedLocations, 0)
+	err := e.store.ForEach(embeddedRejectedLocationsBucket, func(key string, raw json.RawMessage) error {
+		var rejected models.RejectedLocation
+		if err := json.Unmarshal(raw, &rejected); err != nil {
+			return err
+		}
+		if query.Matches(&rejected) {
+			found = append(found, &rejected)
+		}
+		return nil
+	})
+	if err != nil {
+		e.l.Error("fail to find rejected locations", zap.Error(err))
+		return nil, err
+	}
+	return limitRejectedLocations(found, query.Limit), nil
+}
@@ -0,0 +1,122 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/TeamD2018/geo-rest/models"
+	"github.com/TeamD2018/geo-rest/services/kvstore"
+	"go.uber.org/zap"
+	"strconv"
+	"strings"
+)
+
+const embeddedRouteBucketPrefix = "route/"
+
+var errStopIteration = errors.New("stop iteration")
+
+// EmbeddedRouteDAO stores one kvstore key per route point, ordered by timestamp.
+type EmbeddedRouteDAO struct {
+	store *kvstore.Store
+	l     *zap.Logger
+}
+
+func NewEmbeddedRouteDAO(store *kvstore.Store, logger *zap.Logger) *EmbeddedRouteDAO {
+	return &EmbeddedRouteDAO{
+		store: store,
+		l:     logger,
+	}
+}
+
+func (e *EmbeddedRouteDAO) CreateCourier(courierID string) error {
+	return e.store.Update(func(tx *kvstore.Tx) error {
+		return tx.DeleteBucket(routeBucket(courierID))
+	})
+}
+
+func (e *EmbeddedRouteDAO) AddPointToRoute(courierID string, point *models.PointWithTs) error {
+	if point == nil || point.Point == nil {
+		return errors.New("lat or lon not found")
+	}
+	if point.Point.Lat < -90 || point.Point.Lat > 90 || point.Point.Lon < -180 || point.Point.Lon > 180 {
+		return ErrInvalidRoutePoint
+	}
+	if !point.Fix.Valid() {
+		return ErrInvalidFix
+	}
+	return e.store.Update(func(tx *kvstore.Tx) error {
+		bucket := routeBucket(courierID)
+		seq, err := tx.NextSequence(bucket)
+		if err != nil {
+			return err
+		}
+		return tx.Put(bucket, routePointKey(point.Ts, seq), point)
+	})
+}
+
+func (e *EmbeddedRouteDAO) DeleteCourier(courierID string) error {
+	return e.CreateCourier(courierID)
+}
+
+func (e *EmbeddedRouteDAO) GetRoute(courierID string, query *models.RouteQuery) (*models.RoutePage, error) {
+	builder := newRoutePageBuilder(query)
+	err := e.store.ForEachFrom(routeBucket(courierID), routeScanStart(query), func(key string, raw json.RawMessage) error {
+		_, seq, err := parseRoutePointKey(key)
+		if err != nil {
+			return err
+		}
+		var point models.PointWithTs
+		if err := json.Unmarshal(raw, &point); err != nil {
+			return err
+		}
+		if !builder.add(&point, seq) {
+			return errStopIteration
+		}
+		return nil
+	})
+	if err != nil && err != errStopIteration {
+		e.l.Error("fail to get route", zap.String("courier_id", courierID), zap.Error(err))
+		return nil, err
+	}
+	return builder.page, nil
+}
+
+// routeScanStart returns the first key the query can match, so the scan skips the points before since and the cursor.
+func routeScanStart(query *models.RouteQuery) string {
+	if query == nil {
+		return ""
+	}
+	from := ""
+	if query.Since >= 0 {
+		from = routePointKey(uint64(query.Since)+1, 0)
+	}
+	if query.After != nil {
+		if after := routePointKey(query.After.Ts, query.After.Seq); after > from {
+			from = after
+		}
+	}
+	return from
+}
+
+func routeBucket(courierID string) string {
+	return embeddedRouteBucketPrefix + courierID
+}
+
+// routePointKey sorts points by timestamp and keeps points sharing a timestamp in insertion order.
+func routePointKey(ts uint64, seq uint64) string {
+	return fmt.Sprintf("%020d/%010d", ts, seq)
+}
+
+func parseRoutePointKey(key string) (ts uint64, seq uint64, err error) {
+	parts := strings.SplitN(key, "/", 2)
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid route point key %q", key)
+	}
+	if ts, err = strconv.ParseUint(parts[0], 10, 64); err != nil {
+		return 0, 0, err
+	}
+	if seq, err = strconv.ParseUint(parts[1], 10, 64); err != nil {
+		return 0, 0, err
+	}
+	return ts, seq, nil
+}
@@ -0,0 +1,52 @@
+package services
+
+import (
+	"encoding/json"
+	"github.com/TeamD2018/geo-rest/models"
+	"github.com/TeamD2018/geo-rest/services/kvstore"
+	"github.com/satori/go.uuid"
+	"go.uber.org/zap"
+)
+
+const embeddedRouteArchiveBucket = "route_archive"
+
+type EmbeddedRouteArchive struct {
+	store *kvstore.Store
+	l     *zap.Logger
+}
+
+func NewEmbeddedRouteArchive(store *kvstore.Store, logger *zap.Logger) *EmbeddedRouteArchive {
+	return &EmbeddedRouteArchive{
+		store: store,
+		l:     logger,
+	}
+}
+
+func (e *EmbeddedRouteArchive) Save(route *models.ArchivedRoute) error {
+	id := uuid.NewV4().String()
+	route.ID = id
+	if err := e.store.Put(embeddedRouteArchiveBucket, id, route); err != nil {
+		route.ID = ""
+		return err
+	}
+	return nil
+}
+
+func (e *EmbeddedRouteArchive) Find(query *models.RouteArchiveQuery) (models.ArchivedRoutes, error) {
+	routes := make(models.ArchivedRoutes, 0)
+	err := e.store.ForEach(embeddedRouteArchiveBucket, func(key string, raw json.RawMessage) error {
+		var route models.ArchivedRoute
+		if err := json.Unmarshal(raw, &route); err != nil {
+			return err
+		}
+		if query.Matches(&route) {
+			routes = append(routes, &route)
+		}
+		return nil
+	})
+	if err != nil {
+		e.l.Error("fail to find archived routes", zap.Error(err))
+		return nil, err
+	}
+	return limitArchivedRoutes(routes, query.Limit), nil
+}
@@ -0,0 +1,145 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/TeamD2018/geo-rest/models"
+	"github.com/TeamD2018/geo-rest/services/kvstore"
+	"github.com/satori/go.uuid"
+	"go.uber.org/zap"
+	"strings"
+)
+
+const (
+	embeddedWebhooksBucket = "webhooks"
+	// one bucket per subscription, keys sort by failure time
+	embeddedWebhookDeadLettersBucketPrefix = "webhook_dead_letters/"
+)
+
+type EmbeddedWebhookStore struct {
+	store *kvstore.Store
+	l     *zap.Logger
+}
+
+func NewEmbeddedWebhookStore(store *kvstore.Store, logger *zap.Logger) *EmbeddedWebhookStore {
+	return &EmbeddedWebhookStore{
+		store: store,
+		l:     logger,
+	}
+}
+
+func (e *EmbeddedWebhookStore) Create(subscription *models.WebhookSubscription) error {
+	subscription.ID = uuid.NewV4().String()
+	if err := e.store.Put(embeddedWebhooksBucket, subscription.ID, subscription); err != nil {
+		subscription.ID = ""
+		return err
+	}
+	return nil
+}
+
+func (e *EmbeddedWebhookStore) Get(subscriptionID string) (*models.WebhookSubscription, error) {
+	var subscription models.WebhookSubscription
+	found, err := e.store.Get(embeddedWebhooksBucket, subscriptionID, &subscription)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, models.ErrEntityNotFound.SetParameter(subscriptionID)
+	}
+	return &subscription, nil
+}
+
+func (e *EmbeddedWebhookStore) Delete(subscriptionID string) error {
+	return e.store.Update(func(tx *kvstore.Tx) error {
+		var stored models.WebhookSubscription
+		found, err := tx.Get(embeddedWebhooksBucket, subscriptionID, &stored)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return models.ErrEntityNotFound.SetParameter(subscriptionID)
+		}
+		if err := tx.Delete(embeddedWebhooksBucket, subscriptionID); err != nil {
+			return err
+		}
+		return tx.DeleteBucket(embeddedWebhookDeadLettersBucketPrefix + subscriptionID)
+	})
+}
+
+func (e *EmbeddedWebhookStore) List() (models.WebhookSubscriptions, error) {
+	subscriptions := make(models.WebhookSubscriptions, 0)
+	err := e.store.ForEach(embeddedWebhooksBucket, func(key string, raw json.RawMessage) error {
+		var subscription models.WebhookSubscription
+		if err := json.Unmarshal(raw, &subscription); err != nil {
+			return err
+		}
+		subscriptions = append(subscriptions, &subscription)
+		return nil
+	})
+	if err != nil {
+		e.l.Error("fail to list webhooks", zap.Error(err))
+		return nil, err
+	}
+	sortWebhookSubscriptions(subscriptions)
+	return subscriptions, nil
+}
+
+func (e *EmbeddedWebhookStore) AddDeadLetter(deadLetter *models.WebhookDeadLetter) error {
+	id := uuid.NewV4().String()
+	deadLetter.ID = id
+	key := fmt.Sprintf("%020d/%s", deadLetter.FailedAt, id)
+	if err := e.store.Put(embeddedWebhookDeadLettersBucketPrefix+deadLetter.SubscriptionID, key, deadLetter); err != nil {
+		deadLetter.ID = ""
+		return err
+	}
+	return nil
+}
+
+func (e *EmbeddedWebhookStore) GetDeadLetter(subscriptionID, deadLetterID string) (*models.WebhookDeadLetter, error) {
+	_, deadLetter, err := e.findDeadLetter(subscriptionID, deadLetterID)
+	return deadLetter, err
+}
+
+func (e *EmbeddedWebhookStore) DeleteDeadLetter(subscriptionID, deadLetterID string) error {
+	key, _, err := e.findDeadLetter(subscriptionID, deadLetterID)
+	if err != nil {
+		return err
+	}
+	return e.store.Delete(embeddedWebhookDeadLettersBucketPrefix+subscriptionID, key)
+}
+
+func (e *EmbeddedWebhookStore) DeadLetters(subscriptionID string, limit int) (models.WebhookDeadLetters, error) {
+	found := make(models.WebhookDeadLetters, 0)
+	err := e.store.ForEach(embeddedWebhookDeadLettersBucketPrefix+subscriptionID, func(key string, raw json.RawMessage) error {
+		var deadLetter models.WebhookDeadLetter
+		if err := json.Unmarshal(raw, &deadLetter); err != nil {
+			return err
+		}
+		found = append(found, &deadLetter)
+		return nil
+	})
+	if err != nil {
+		e.l.Error("fail to list webhook dead letters", zap.Error(err), zap.String("subscription_id", subscriptionID))
+		return nil, err
+	}
+	return limitWebhookDeadLetters(found, limit), nil
+}
+
+func (e *EmbeddedWebhookStore) findDeadLetter(subscriptionID, deadLetterID string) (string, *models.WebhookDeadLetter, error) {
+	var foundKey string
+	var deadLetter models.WebhookDeadLetter
+	err := e.store.ForEach(embeddedWebhookDeadLettersBucketPrefix+subscriptionID, func(key string, raw json.RawMessage) error {
+		if foundKey != "" || !strings.HasSuffix(key, "/"+deadLetterID) {
+			return nil
+		}
+		foundKey = key
+		return json.Unmarshal(raw, &deadLetter)
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	if foundKey == "" {
+		return "", nil, models.ErrEntityNotFound.SetParameter(deadLetterID)
+	}
+	return foundKey, &deadLetter, nil
+}
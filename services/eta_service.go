@@ -0,0 +1,119 @@
+package services
+
+import (
+	"github.com/TeamD2018/geo-rest/models"
+	"github.com/TeamD2018/geo-rest/services/geo"
+	"github.com/TeamD2018/geo-rest/services/interfaces"
+	"github.com/olivere/elastic"
+	"math"
+	"time"
+)
+
+const (
+	DefaultETASpeedWindow = 15 * time.Minute
+	// m/s, about 18 km/h of a courier in city traffic
+	DefaultETASpeed = 5
+	// m/s, slower recent movement is treated as standing still
+	DefaultETAMinSpeed = 0.5
+	// Roads are longer than straight lines
+	DefaultDetourFactor = 1.3
+)
+
+// StraightLineDistance is the haversine distance multiplied by DetourFactor.
+type StraightLineDistance struct {
+	DetourFactor float64
+}
+
+func NewStraightLineDistance(detourFactor float64) *StraightLineDistance {
+	if detourFactor < 1 {
+		detourFactor = DefaultDetourFactor
+	}
+	return &StraightLineDistance{DetourFactor: detourFactor}
+}
+
+func (d *StraightLineDistance) Distance(from, to *elastic.GeoPoint) (float64, error) {
+	return geo.Distance(from, to) * d.DetourFactor, nil
+}
+
+// ETAService estimates arrival from the current courier location, the distance to the order destination
+// and the average moving speed of the courier over the recent route window.
+type ETAService struct {
+	RouteDAO  interfaces.GeoRouteInterface
+	Distances interfaces.DistanceProvider
+	// Route window the recent speed is measured over
+	SpeedWindow  time.Duration
+	DefaultSpeed float64
+	MinSpeed     float64
+	Now          func() time.Time
+}
+
+func NewETAService(routeDAO interfaces.GeoRouteInterface, distances interfaces.DistanceProvider,
+	speedWindow time.Duration, defaultSpeed, minSpeed float64) *ETAService {
+	if speedWindow <= 0 {
+		speedWindow = DefaultETASpeedWindow
+	}
+	if defaultSpeed <= 0 {
+		defaultSpeed = DefaultETASpeed
+	}
+	if minSpeed <= 0 {
+		minSpeed = DefaultETAMinSpeed
+	}
+	return &ETAService{
+		RouteDAO:     routeDAO,
+		Distances:    distances,
+		SpeedWindow:  speedWindow,
+		DefaultSpeed: defaultSpeed,
+		MinSpeed:     minSpeed,
+		Now:          time.Now,
+	}
+}
+
+// Estimate leaves ETA nil when the courier location or the destination point is unknown.
+// The recent speed is read once for all orders.
+func (s *ETAService) Estimate(courier *models.Courier, orders models.Orders) error {
+	if courier == nil || courier.Location == nil || courier.Location.Point == nil {
+		return nil
+	}
+	pending := make(models.Orders, 0, len(orders))
+	for _, order := range orders {
+		if order.DeliveredAt == 0 && order.Destination.Point != nil {
+			pending = append(pending, order)
+		}
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+	now := s.Now()
+	speed, source, err := s.recentSpeed(courier.ID, now)
+	if err != nil {
+		return err
+	}
+	for _, order := range pending {
+		distance, err := s.Distances.Distance(courier.Location.Point, order.Destination.Point)
+		if err != nil {
+			return err
+		}
+		duration := int64(math.Ceil(distance / speed))
+		order.ETA = &models.ETA{
+			Distance:    distance,
+			Speed:       speed,
+			SpeedSource: source,
+			Duration:    duration,
+			ArrivalAt:   now.Unix() + duration,
+			ComputedAt:  now.Unix(),
+		}
+	}
+	return nil
+}
+
+func (s *ETAService) recentSpeed(courierID string, now time.Time) (float64, string, error) {
+	page, err := s.RouteDAO.GetRoute(courierID, &models.RouteQuery{Since: now.Add(-s.SpeedWindow).Unix()})
+	if err != nil {
+		return 0, "", err
+	}
+	stats := CalculateRouteStats(page.Points, DefaultStopDuration, DefaultStopRadius)
+	if stats.AverageSpeed < s.MinSpeed {
+		return s.DefaultSpeed, models.ETASpeedDefault, nil
+	}
+	return stats.AverageSpeed, models.ETASpeedRoute, nil
+}
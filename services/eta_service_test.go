@@ -0,0 +1,93 @@
+package services
+
+import (
+	"github.com/TeamD2018/geo-rest/models"
+	"github.com/TeamD2018/geo-rest/services/geo"
+	"github.com/olivere/elastic"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+	"testing"
+	"time"
+)
+
+type ETAServiceTestSuite struct {
+	suite.Suite
+	routeDAO *MemoryRouteDAO
+	service  *ETAService
+	courier  *models.Courier
+	now      time.Time
+}
+
+func (s *ETAServiceTestSuite) BeforeTest(suiteName, testName string) {
+	s.routeDAO = NewMemoryRouteDAO(zap.NewNop())
+	s.service = NewETAService(s.routeDAO, NewStraightLineDistance(1), 10*time.Minute, 5, 0.5)
+	s.now = time.Unix(10000, 0)
+	s.service.Now = func() time.Time { return s.now }
+	s.courier = &models.Courier{
+		ID:       "courier",
+		Location: &models.Location{Point: elastic.GeoPointFromLatLon(55.703, 37.6)},
+	}
+	s.Require().NoError(s.routeDAO.CreateCourier(s.courier.ID))
+}
+
+// move adds points going north by 0.001 degree (~111 m) every minute, the last one a minute before now.
+func (s *ETAServiceTestSuite) move(points int) {
+	for i := 0; i < points; i++ {
+		s.Require().NoError(s.routeDAO.AddPointToRoute(s.courier.ID, &models.PointWithTs{
+			Point: elastic.GeoPointFromLatLon(55.703-0.001*float64(points-1-i), 37.6),
+			Ts:    uint64(s.now.Unix() - 60*int64(points-i)),
+		}))
+	}
+}
+
+func (s *ETAServiceTestSuite) order(lat, lon float64) *models.Order {
+	return &models.Order{ID: "order", CourierID: s.courier.ID, Destination: models.Location{Point: elastic.GeoPointFromLatLon(lat, lon)}}
+}
+
+func (s *ETAServiceTestSuite) TestRecentSpeed() {
+	s.move(4)
+	order := s.order(55.713, 37.6)
+	s.Require().NoError(s.service.Estimate(s.courier, models.Orders{order}))
+	s.Require().NotNil(order.ETA)
+
+	distance := geo.Distance(s.courier.Location.Point, order.Destination.Point)
+	s.InDelta(distance, order.ETA.Distance, 0.001)
+	s.Equal(models.ETASpeedRoute, order.ETA.SpeedSource)
+	s.InDelta(111.2/60, order.ETA.Speed, 0.01)
+	s.InDelta(distance/order.ETA.Speed, float64(order.ETA.Duration), 1)
+	s.Equal(s.now.Unix()+order.ETA.Duration, order.ETA.ArrivalAt)
+	s.Equal(s.now.Unix(), order.ETA.ComputedAt)
+}
+
+func (s *ETAServiceTestSuite) TestDefaultSpeedWithoutRecentRoute() {
+	order := s.order(55.713, 37.6)
+	s.Require().NoError(s.service.Estimate(s.courier, models.Orders{order}))
+	s.Require().NotNil(order.ETA)
+	s.Equal(models.ETASpeedDefault, order.ETA.SpeedSource)
+	s.Equal(5.0, order.ETA.Speed)
+}
+
+func (s *ETAServiceTestSuite) TestSkipsDeliveredAndUnknown() {
+	delivered := s.order(55.713, 37.6)
+	delivered.DeliveredAt = 100
+	noDestination := &models.Order{ID: "no destination"}
+	s.Require().NoError(s.service.Estimate(s.courier, models.Orders{delivered, noDestination}))
+	s.Nil(delivered.ETA)
+	s.Nil(noDestination.ETA)
+
+	pending := s.order(55.713, 37.6)
+	s.courier.Location = nil
+	s.Require().NoError(s.service.Estimate(s.courier, models.Orders{pending}))
+	s.Nil(pending.ETA)
+}
+
+func (s *ETAServiceTestSuite) TestDetourFactor() {
+	s.service.Distances = NewStraightLineDistance(2)
+	order := s.order(55.713, 37.6)
+	s.Require().NoError(s.service.Estimate(s.courier, models.Orders{order}))
+	s.InDelta(2*geo.Distance(s.courier.Location.Point, order.Destination.Point), order.ETA.Distance, 0.001)
+}
+
+func TestUnitETAService(t *testing.T) {
+	suite.Run(t, new(ETAServiceTestSuite))
+}
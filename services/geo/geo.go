@@ -0,0 +1,65 @@
+package geo
+
+import (
+	"github.com/TeamD2018/geo-rest/models"
+	"github.com/olivere/elastic"
+	"math"
+)
+
+// EarthRadius is the mean Earth radius in metres, the same one Elasticsearch uses for arc distances.
+const EarthRadius = 6371008.7714
+
+// Distance returns the haversine distance between two points in metres.
+func Distance(a, b *elastic.GeoPoint) float64 {
+	lat1 := toRadians(a.Lat)
+	lat2 := toRadians(b.Lat)
+	dLat := lat2 - lat1
+	dLon := toRadians(b.Lon - a.Lon)
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * EarthRadius * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// InBox reports whether point lies inside the bounding box.
+// Boxes whose left edge is east of the right edge wrap around the antimeridian.
+func InBox(point *elastic.GeoPoint, box *models.BoxField) bool {
+	if point == nil || box == nil || box.TopLeftPoint == nil || box.BottomRightPoint == nil {
+		return false
+	}
+	if point.Lat > box.TopLeftPoint.Lat || point.Lat < box.BottomRightPoint.Lat {
+		return false
+	}
+	left, right := box.TopLeftPoint.Lon, box.BottomRightPoint.Lon
+	if left <= right {
+		return point.Lon >= left && point.Lon <= right
+	}
+	return point.Lon >= left || point.Lon <= right
+}
+
+// InCircle reports whether point lies within circle.Radius metres of circle.Center.
+func InCircle(point *elastic.GeoPoint, circle *models.CircleField) bool {
+	if point == nil || circle == nil || circle.Center == nil {
+		return false
+	}
+	return Distance(point, circle.Center) <= float64(circle.Radius)
+}
+
+// InPolygon reports whether point lies inside polygon using ray casting.
+func InPolygon(point *elastic.GeoPoint, polygon models.FlatPolygon) bool {
+	if point == nil || len(polygon) < 3 {
+		return false
+	}
+	inside := false
+	for i, j := 0, len(polygon)-1; i < len(polygon); j, i = i, i+1 {
+		pi, pj := polygon[i], polygon[j]
+		if (pi.Lat > point.Lat) != (pj.Lat > point.Lat) &&
+			point.Lon < (pj.Lon-pi.Lon)*(point.Lat-pi.Lat)/(pj.Lat-pi.Lat)+pi.Lon {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+func toRadians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
@@ -0,0 +1,162 @@
+package geo
+
+import (
+	"github.com/TeamD2018/geo-rest/models"
+	"github.com/olivere/elastic"
+	"math"
+	"sort"
+)
+
+// DefaultCellSize is the grid cell side in degrees, roughly one kilometre along a meridian.
+const DefaultCellSize = 0.01
+
+type cell struct {
+	lat int
+	lon int
+}
+
+// GridIndex is a spatial index that buckets points into square cells of a fixed size in degrees.
+// It is not safe for concurrent use.
+type GridIndex struct {
+	cellSize float64
+	cells    map[cell]map[string]struct{}
+	points   map[string]*elastic.GeoPoint
+}
+
+func NewGridIndex(cellSize float64) *GridIndex {
+	if cellSize <= 0 {
+		cellSize = DefaultCellSize
+	}
+	return &GridIndex{
+		cellSize: cellSize,
+		cells:    make(map[cell]map[string]struct{}),
+		points:   make(map[string]*elastic.GeoPoint),
+	}
+}
+
+// Set indexes id at point, replacing its previous position. A nil point removes id.
+func (g *GridIndex) Set(id string, point *elastic.GeoPoint) {
+	g.Remove(id)
+	if point == nil {
+		return
+	}
+	c := g.cellOf(point.Lat, point.Lon)
+	ids, ok := g.cells[c]
+	if !ok {
+		ids = make(map[string]struct{})
+		g.cells[c] = ids
+	}
+	ids[id] = struct{}{}
+	g.points[id] = elastic.GeoPointFromLatLon(point.Lat, point.Lon)
+}
+
+func (g *GridIndex) Remove(id string) {
+	point, ok := g.points[id]
+	if !ok {
+		return
+	}
+	c := g.cellOf(point.Lat, point.Lon)
+	delete(g.cells[c], id)
+	if len(g.cells[c]) == 0 {
+		delete(g.cells, c)
+	}
+	delete(g.points, id)
+}
+
+// SearchBox returns the sorted ids of points inside box.
+func (g *GridIndex) SearchBox(box *models.BoxField) []string {
+	if box == nil || box.TopLeftPoint == nil || box.BottomRightPoint == nil {
+		return []string{}
+	}
+	bottom, top := box.BottomRightPoint.Lat, box.TopLeftPoint.Lat
+	left, right := box.TopLeftPoint.Lon, box.BottomRightPoint.Lon
+	var candidates []string
+	if left <= right {
+		candidates = g.candidates(bottom, left, top, right)
+	} else {
+		candidates = append(g.candidates(bottom, left, top, 180), g.candidates(bottom, -180, top, right)...)
+	}
+	return g.match(candidates, func(point *elastic.GeoPoint) bool {
+		return InBox(point, box)
+	})
+}
+
+// SearchCircle returns the sorted ids of points within circle.
+func (g *GridIndex) SearchCircle(circle *models.CircleField) []string {
+	if circle == nil || circle.Center == nil {
+		return []string{}
+	}
+	dLat := float64(circle.Radius) / EarthRadius * 180 / math.Pi
+	bottom, top := circle.Center.Lat-dLat, circle.Center.Lat+dLat
+	var candidates []string
+	cos := math.Cos(toRadians(circle.Center.Lat))
+	if top >= 90 || bottom <= -90 || cos < 1e-6 || dLat/cos >= 180 {
+		candidates = g.candidates(bottom, -180, top, 180)
+	} else {
+		dLon := dLat / cos
+		candidates = g.candidates(bottom, circle.Center.Lon-dLon, top, circle.Center.Lon+dLon)
+	}
+	return g.match(candidates, func(point *elastic.GeoPoint) bool {
+		return InCircle(point, circle)
+	})
+}
+
+// SearchPolygon returns the sorted ids of points inside polygon.
+func (g *GridIndex) SearchPolygon(polygon models.FlatPolygon) []string {
+	if len(polygon) < 3 {
+		return []string{}
+	}
+	bottom, left, top, right := polygon[0].Lat, polygon[0].Lon, polygon[0].Lat, polygon[0].Lon
+	for _, p := range polygon[1:] {
+		bottom, top = math.Min(bottom, p.Lat), math.Max(top, p.Lat)
+		left, right = math.Min(left, p.Lon), math.Max(right, p.Lon)
+	}
+	return g.match(g.candidates(bottom, left, top, right), func(point *elastic.GeoPoint) bool {
+		return InPolygon(point, polygon)
+	})
+}
+
+// candidates returns ids from the cells covering the rectangle, or every id when the rectangle spans more cells than there are points.
+func (g *GridIndex) candidates(bottom, left, top, right float64) []string {
+	from := g.cellOf(bottom, left)
+	to := g.cellOf(top, right)
+	cellsCount := float64(to.lat-from.lat+1) * float64(to.lon-from.lon+1)
+	ids := make([]string, 0)
+	if cellsCount > float64(len(g.cells)) {
+		for c, cellIDs := range g.cells {
+			if c.lat < from.lat || c.lat > to.lat || c.lon < from.lon || c.lon > to.lon {
+				continue
+			}
+			for id := range cellIDs {
+				ids = append(ids, id)
+			}
+		}
+		return ids
+	}
+	for lat := from.lat; lat <= to.lat; lat++ {
+		for lon := from.lon; lon <= to.lon; lon++ {
+			for id := range g.cells[cell{lat: lat, lon: lon}] {
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids
+}
+
+func (g *GridIndex) match(candidates []string, fn func(point *elastic.GeoPoint) bool) []string {
+	ids := make([]string, 0, len(candidates))
+	for _, id := range candidates {
+		if fn(g.points[id]) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func (g *GridIndex) cellOf(lat, lon float64) cell {
+	return cell{
+		lat: int(math.Floor(lat / g.cellSize)),
+		lon: int(math.Floor(lon / g.cellSize)),
+	}
+}
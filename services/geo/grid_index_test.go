@@ -0,0 +1,48 @@
+package geo
+
+import (
+	"github.com/TeamD2018/geo-rest/models"
+	"github.com/olivere/elastic"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestUnitDistance(t *testing.T) {
+	moscow := elastic.GeoPointFromLatLon(55.7558, 37.6173)
+	petersburg := elastic.GeoPointFromLatLon(59.9343, 30.3351)
+	require.InDelta(t, 634000, Distance(moscow, petersburg), 2000)
+}
+
+func TestUnitGridIndex(t *testing.T) {
+	index := NewGridIndex(DefaultCellSize)
+	index.Set("kremlin", elastic.GeoPointFromLatLon(55.7520, 37.6175))
+	index.Set("vdnh", elastic.GeoPointFromLatLon(55.8263, 37.6377))
+	index.Set("fiji", elastic.GeoPointFromLatLon(-17.7, 179.9))
+
+	require.Equal(t, []string{"kremlin"}, index.SearchCircle(&models.CircleField{
+		Center: elastic.GeoPointFromLatLon(55.7522, 37.6156),
+		Radius: 1000,
+	}))
+	require.Equal(t, []string{"kremlin", "vdnh"}, index.SearchBox(&models.BoxField{
+		TopLeftPoint:     elastic.GeoPointFromLatLon(56, 37),
+		BottomRightPoint: elastic.GeoPointFromLatLon(55, 38),
+	}))
+	require.Equal(t, []string{"fiji"}, index.SearchBox(&models.BoxField{
+		TopLeftPoint:     elastic.GeoPointFromLatLon(-17, 179),
+		BottomRightPoint: elastic.GeoPointFromLatLon(-18, -179),
+	}))
+
+	index.Set("kremlin", elastic.GeoPointFromLatLon(55.8263, 37.6377))
+	require.Equal(t, []string{"kremlin", "vdnh"}, index.SearchPolygon(models.FlatPolygon{
+		elastic.GeoPointFromLatLon(55.8, 37.6),
+		elastic.GeoPointFromLatLon(55.8, 37.7),
+		elastic.GeoPointFromLatLon(55.9, 37.7),
+		elastic.GeoPointFromLatLon(55.9, 37.6),
+	}))
+
+	index.Remove("vdnh")
+	require.Empty(t, index.SearchCircle(&models.CircleField{
+		Center: elastic.GeoPointFromLatLon(55.7522, 37.6156),
+		Radius: 1000,
+	}))
+}
@@ -0,0 +1,105 @@
+package geo
+
+import (
+	"container/heap"
+	"github.com/TeamD2018/geo-rest/models"
+	"github.com/olivere/elastic"
+	"math"
+	"sort"
+)
+
+// Simplify reduces a route with the Douglas-Peucker algorithm.
+// Segments are split at their farthest point, largest deviation first, until every dropped point lies within
+// tolerance metres of the simplified line or the result holds maxPoints points.
+// A zero tolerance or maxPoints disables the corresponding limit; maxPoints below 2 is treated as 2.
+// The first and the last points are always kept and the remaining points keep their order.
+func Simplify(points models.Points, tolerance float64, maxPoints int) models.Points {
+	if len(points) <= 2 || (tolerance <= 0 && maxPoints <= 0) {
+		return points
+	}
+	if maxPoints <= 0 || maxPoints > len(points) {
+		maxPoints = len(points)
+	}
+	if maxPoints < 2 {
+		maxPoints = 2
+	}
+	keep := []int{0, len(points) - 1}
+	queue := &segmentQueue{}
+	if s, ok := farthestPoint(points, 0, len(points)-1); ok {
+		heap.Push(queue, s)
+	}
+	for queue.Len() > 0 && len(keep) < maxPoints {
+		s := heap.Pop(queue).(segment)
+		if s.deviation <= tolerance {
+			break
+		}
+		keep = append(keep, s.split)
+		if left, ok := farthestPoint(points, s.from, s.split); ok {
+			heap.Push(queue, left)
+		}
+		if right, ok := farthestPoint(points, s.split, s.to); ok {
+			heap.Push(queue, right)
+		}
+	}
+	sort.Ints(keep)
+	simplified := make(models.Points, 0, len(keep))
+	for _, i := range keep {
+		simplified = append(simplified, points[i])
+	}
+	return simplified
+}
+
+// segment is a part of the route between two kept points with the point farthest from the line between them.
+type segment struct {
+	from, to  int
+	split     int
+	deviation float64
+}
+
+// farthestPoint finds the point between from and to (exclusive) farthest from the from-to segment.
+func farthestPoint(points models.Points, from, to int) (segment, bool) {
+	if to-from < 2 {
+		return segment{}, false
+	}
+	s := segment{from: from, to: to, split: from + 1, deviation: -1}
+	for i := from + 1; i < to; i++ {
+		if d := segmentDistance(points[i].Point, points[from].Point, points[to].Point); d > s.deviation {
+			s.split, s.deviation = i, d
+		}
+	}
+	return s, true
+}
+
+// segmentDistance returns the distance in metres from p to the segment ab on an equirectangular projection
+// centred at a, which is accurate enough for segments of a courier route.
+func segmentDistance(p, a, b *elastic.GeoPoint) float64 {
+	cos := math.Cos(toRadians(a.Lat))
+	project := func(q *elastic.GeoPoint) (float64, float64) {
+		return toRadians(q.Lon-a.Lon) * cos * EarthRadius, toRadians(q.Lat-a.Lat) * EarthRadius
+	}
+	px, py := project(p)
+	bx, by := project(b)
+	length := bx*bx + by*by
+	if length == 0 {
+		return math.Hypot(px, py)
+	}
+	t := math.Max(0, math.Min(1, (px*bx+py*by)/length))
+	return math.Hypot(px-t*bx, py-t*by)
+}
+
+type segmentQueue []segment
+
+func (q segmentQueue) Len() int { return len(q) }
+
+func (q segmentQueue) Less(i, j int) bool { return q[i].deviation > q[j].deviation }
+
+func (q segmentQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *segmentQueue) Push(x interface{}) { *q = append(*q, x.(segment)) }
+
+func (q *segmentQueue) Pop() interface{} {
+	old := *q
+	s := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return s
+}
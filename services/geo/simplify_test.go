@@ -0,0 +1,69 @@
+package geo
+
+import (
+	"github.com/TeamD2018/geo-rest/models"
+	"github.com/olivere/elastic"
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+type SimplifyTestSuite struct {
+	suite.Suite
+}
+
+// zigzag goes east with every odd point ~11 m off the line, except a ~555 m spike at index 5.
+func (s *SimplifyTestSuite) zigzag() models.Points {
+	points := make(models.Points, 0, 11)
+	for i := 0; i <= 10; i++ {
+		lat := 55.7
+		if i%2 == 1 {
+			lat += 0.0001
+		}
+		if i == 5 {
+			lat += 0.005
+		}
+		points = append(points, &models.PointWithTs{
+			Point: elastic.GeoPointFromLatLon(lat, 37.6+float64(i)*0.001),
+			Ts:    uint64(i * 10),
+		})
+	}
+	return points
+}
+
+func (s *SimplifyTestSuite) TestTolerance() {
+	points := Simplify(s.zigzag(), 20, 0)
+	s.Equal([]uint64{0, 40, 50, 60, 100}, s.timestamps(points))
+}
+
+func (s *SimplifyTestSuite) TestMaxPoints() {
+	points := Simplify(s.zigzag(), 0, 3)
+	s.Equal([]uint64{0, 50, 100}, s.timestamps(points))
+}
+
+func (s *SimplifyTestSuite) TestKeepsEndpoints() {
+	points := Simplify(s.zigzag(), 10000, 1)
+	s.Equal([]uint64{0, 100}, s.timestamps(points))
+}
+
+func (s *SimplifyTestSuite) TestZeroLimitsKeepEverything() {
+	s.Len(Simplify(s.zigzag(), 0, 0), 11)
+	s.Len(Simplify(s.zigzag(), 0.1, 0), 11)
+}
+
+func (s *SimplifyTestSuite) TestDuplicatePoints() {
+	point := elastic.GeoPointFromLatLon(55.7, 37.6)
+	points := models.Points{{Point: point, Ts: 1}, {Point: point, Ts: 2}, {Point: point, Ts: 3}}
+	s.Equal([]uint64{1, 3}, s.timestamps(Simplify(points, 1, 0)))
+}
+
+func (s *SimplifyTestSuite) timestamps(points models.Points) []uint64 {
+	ts := make([]uint64, 0, len(points))
+	for _, p := range points {
+		ts = append(ts, p.Ts)
+	}
+	return ts
+}
+
+func TestUnitSimplify(t *testing.T) {
+	suite.Run(t, new(SimplifyTestSuite))
+}
@@ -0,0 +1,241 @@
+package services
+
+import (
+	"github.com/TeamD2018/geo-rest/models"
+	"github.com/TeamD2018/geo-rest/services/geo"
+	"github.com/TeamD2018/geo-rest/services/interfaces"
+	"github.com/olivere/elastic"
+	"go.uber.org/zap"
+	"sync"
+	"time"
+)
+
+const DefaultGeofenceCacheTTL = 10 * time.Second
+
+// GeofenceManager stores geofences and turns courier locations into enter, exit and dwell events.
+//
+// Geofences are cached for CacheTTL; writes made through the manager drop the cache at once,
+// other instances sharing the store see them after the TTL.
+type GeofenceManager struct {
+	Store    interfaces.GeofenceStore
+	Log      interfaces.GeofenceEventLog
+	Logger   *zap.Logger
+	CacheTTL time.Duration
+	Now      func() time.Time
+
+	// serializes read-modify-write of courier presence
+	mu sync.Mutex
+
+	cacheMu  sync.RWMutex
+	cached   models.Geofences
+	loadedAt time.Time
+	// bumped by every write so a list read before it is not cached
+	generation int
+}
+
+func NewGeofenceManager(store interfaces.GeofenceStore, log interfaces.GeofenceEventLog, logger *zap.Logger,
+	cacheTTL time.Duration) *GeofenceManager {
+	if cacheTTL < 0 {
+		cacheTTL = DefaultGeofenceCacheTTL
+	}
+	return &GeofenceManager{
+		Store:    store,
+		Log:      log,
+		Logger:   logger,
+		CacheTTL: cacheTTL,
+		Now:      time.Now,
+	}
+}
+
+func (m *GeofenceManager) Create(create *models.GeofenceCreate) (*models.Geofence, error) {
+	now := m.Now().Unix()
+	geofence := &models.Geofence{
+		Name:      create.Name,
+		Kind:      create.Kind,
+		Polygon:   create.Polygon,
+		Center:    create.Center,
+		Radius:    create.Radius,
+		DwellTime: create.DwellTime,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if !geofence.Valid() {
+		return nil, models.ErrOneOfParameterHaveIncorrectFormat.SetParameter("geofence")
+	}
+	if err := m.Store.Create(geofence); err != nil {
+		return nil, err
+	}
+	m.invalidate()
+	return geofence, nil
+}
+
+func (m *GeofenceManager) Get(geofenceID string) (*models.Geofence, error) {
+	return m.Store.Get(geofenceID)
+}
+
+func (m *GeofenceManager) Update(geofenceID string, update *models.GeofenceUpdate) (*models.Geofence, error) {
+	geofence, err := m.Store.Get(geofenceID)
+	if err != nil {
+		return nil, err
+	}
+	if update.Name != nil {
+		geofence.Name = *update.Name
+	}
+	if update.Kind != nil {
+		geofence.Kind = *update.Kind
+	}
+	if update.DwellTime != nil {
+		geofence.DwellTime = *update.DwellTime
+	}
+	switch {
+	case update.Polygon != nil:
+		geofence.Polygon = update.Polygon
+		geofence.Center = nil
+		geofence.Radius = 0
+	case update.Center != nil:
+		geofence.Polygon = nil
+		geofence.Center = update.Center
+	}
+	if update.Radius != nil {
+		geofence.Radius = *update.Radius
+	}
+	if !geofence.Valid() {
+		return nil, models.ErrOneOfParameterHaveIncorrectFormat.SetParameter("geofence")
+	}
+	geofence.UpdatedAt = m.Now().Unix()
+	if err := m.Store.Update(geofence); err != nil {
+		return nil, err
+	}
+	m.invalidate()
+	return geofence, nil
+}
+
+// Delete removes the geofence. Couriers inside of it leave without an exit event.
+func (m *GeofenceManager) Delete(geofenceID string) error {
+	if err := m.Store.Delete(geofenceID); err != nil {
+		return err
+	}
+	m.invalidate()
+	return nil
+}
+
+func (m *GeofenceManager) List() (models.Geofences, error) {
+	return m.Store.List()
+}
+
+func (m *GeofenceManager) Evaluate(courierID string, point *elastic.GeoPoint, ts int64) (models.GeofenceEvents, error) {
+	geofences, err := m.geofences()
+	if err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	previous, err := m.Store.Presence(courierID)
+	if err != nil {
+		return nil, err
+	}
+	inside := make(map[string]*models.GeofencePresence, len(previous))
+	for _, presence := range previous {
+		inside[presence.GeofenceID] = presence
+	}
+	events := make(models.GeofenceEvents, 0)
+	presence := make(models.GeofencePresences, 0, len(previous))
+	changed := false
+	for _, geofence := range geofences {
+		current, wasInside := inside[geofence.ID]
+		delete(inside, geofence.ID)
+		if !Contains(geofence, point) {
+			if wasInside {
+				events = append(events, newGeofenceEvent(models.GeofenceExit, geofence, courierID, point, ts))
+				changed = true
+			}
+			continue
+		}
+		if !wasInside {
+			current = &models.GeofencePresence{GeofenceID: geofence.ID, EnteredAt: ts}
+			events = append(events, newGeofenceEvent(models.GeofenceEnter, geofence, courierID, point, ts))
+			changed = true
+		}
+		if !current.Dwelled && geofence.DwellTime > 0 && ts-current.EnteredAt >= geofence.DwellTime {
+			current.Dwelled = true
+			events = append(events, newGeofenceEvent(models.GeofenceDwell, geofence, courierID, point, ts))
+			changed = true
+		}
+		presence = append(presence, current)
+	}
+	// whatever is left belongs to deleted geofences
+	if changed || len(inside) > 0 {
+		if err := m.Store.SetPresence(courierID, presence); err != nil {
+			return nil, err
+		}
+	}
+	for _, event := range events {
+		if err := m.Log.Record(event); err != nil {
+			m.Logger.Error("fail to record geofence event", zap.Error(err), zap.Any("event", event))
+		}
+	}
+	return events, nil
+}
+
+func (m *GeofenceManager) Presence(courierID string) (models.GeofencePresences, error) {
+	return m.Store.Presence(courierID)
+}
+
+func (m *GeofenceManager) Forget(courierID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.Store.SetPresence(courierID, nil)
+}
+
+func (m *GeofenceManager) Events(query *models.GeofenceEventQuery) (models.GeofenceEvents, error) {
+	return m.Log.Find(query)
+}
+
+func (m *GeofenceManager) geofences() (models.Geofences, error) {
+	m.cacheMu.RLock()
+	cached, loadedAt, generation := m.cached, m.loadedAt, m.generation
+	m.cacheMu.RUnlock()
+	if cached != nil && m.Now().Sub(loadedAt) < m.CacheTTL {
+		return cached, nil
+	}
+	geofences, err := m.Store.List()
+	if err != nil {
+		return nil, err
+	}
+	m.cacheMu.Lock()
+	if m.generation == generation {
+		m.cached = geofences
+		m.loadedAt = m.Now()
+	}
+	m.cacheMu.Unlock()
+	return geofences, nil
+}
+
+func (m *GeofenceManager) invalidate() {
+	m.cacheMu.Lock()
+	m.cached = nil
+	m.generation++
+	m.cacheMu.Unlock()
+}
+
+// Contains reports whether point lies inside the polygon or the circle of geofence.
+func Contains(geofence *models.Geofence, point *elastic.GeoPoint) bool {
+	if len(geofence.Polygon) > 0 {
+		return geo.InPolygon(point, geofence.Polygon)
+	}
+	if point == nil || geofence.Center == nil {
+		return false
+	}
+	return geo.Distance(point, geofence.Center) <= geofence.Radius
+}
+
+func newGeofenceEvent(eventType string, geofence *models.Geofence, courierID string, point *elastic.GeoPoint, ts int64) *models.GeofenceEvent {
+	return &models.GeofenceEvent{
+		Type:         eventType,
+		GeofenceID:   geofence.ID,
+		GeofenceName: geofence.Name,
+		CourierID:    courierID,
+		Point:        copyGeoPoint(point),
+		Ts:           ts,
+	}
+}
@@ -0,0 +1,153 @@
+package services
+
+import (
+	"github.com/TeamD2018/geo-rest/models"
+	"github.com/olivere/elastic"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+	"testing"
+	"time"
+)
+
+type GeofenceManagerTestSuite struct {
+	suite.Suite
+	store   *MemoryGeofenceStore
+	manager *GeofenceManager
+	now     time.Time
+}
+
+func (s *GeofenceManagerTestSuite) BeforeTest(suiteName, testName string) {
+	s.store = NewMemoryGeofenceStore()
+	s.manager = NewGeofenceManager(s.store, NewMemoryGeofenceEventLog(), zap.NewNop(), time.Minute)
+	s.now = time.Unix(1000, 0)
+	s.manager.Now = func() time.Time { return s.now }
+}
+
+// square is a polygon of one degree side with the south-west corner at the origin.
+func (s *GeofenceManagerTestSuite) square(dwellTime int64) *models.Geofence {
+	geofence, err := s.manager.Create(&models.GeofenceCreate{
+		Name: "square",
+		Polygon: models.FlatPolygon{
+			elastic.GeoPointFromLatLon(0, 0),
+			elastic.GeoPointFromLatLon(0, 1),
+			elastic.GeoPointFromLatLon(1, 1),
+			elastic.GeoPointFromLatLon(1, 0),
+		},
+		DwellTime: dwellTime,
+	})
+	s.Require().NoError(err)
+	return geofence
+}
+
+func (s *GeofenceManagerTestSuite) evaluate(lat, lon float64, ts int64) []string {
+	events, err := s.manager.Evaluate("courier", elastic.GeoPointFromLatLon(lat, lon), ts)
+	s.Require().NoError(err)
+	types := make([]string, 0, len(events))
+	for _, event := range events {
+		types = append(types, event.Type)
+	}
+	return types
+}
+
+func (s *GeofenceManagerTestSuite) TestCreateRejectsInvalidShapes() {
+	for _, create := range []*models.GeofenceCreate{
+		{Name: "empty"},
+		{Name: "circle", Center: elastic.GeoPointFromLatLon(0, 0)},
+		{Name: "line", Polygon: models.FlatPolygon{elastic.GeoPointFromLatLon(0, 0), elastic.GeoPointFromLatLon(1, 1)}},
+		{Name: "both", Center: elastic.GeoPointFromLatLon(0, 0), Radius: 10, Polygon: models.FlatPolygon{
+			elastic.GeoPointFromLatLon(0, 0), elastic.GeoPointFromLatLon(0, 1), elastic.GeoPointFromLatLon(1, 1)}},
+	} {
+		_, err := s.manager.Create(create)
+		s.Error(err, create.Name)
+	}
+}
+
+func (s *GeofenceManagerTestSuite) TestEnterAndExit() {
+	geofence := s.square(0)
+	s.Empty(s.evaluate(2, 2, 10))
+	s.Equal([]string{models.GeofenceEnter}, s.evaluate(0.5, 0.5, 20))
+	s.Empty(s.evaluate(0.6, 0.6, 30))
+
+	presence, err := s.manager.Presence("courier")
+	if s.NoError(err) && s.Len(presence, 1) {
+		s.Equal(geofence.ID, presence[0].GeofenceID)
+		s.Equal(int64(20), presence[0].EnteredAt)
+	}
+
+	s.Equal([]string{models.GeofenceExit}, s.evaluate(2, 2, 40))
+	events, err := s.manager.Events(&models.GeofenceEventQuery{CourierID: "courier"})
+	if s.NoError(err) && s.Len(events, 2) {
+		s.Equal(models.GeofenceExit, events[0].Type)
+		s.Equal("square", events[0].GeofenceName)
+		s.Equal(int64(40), events[0].Ts)
+	}
+}
+
+func (s *GeofenceManagerTestSuite) TestDwellOncePerVisit() {
+	s.square(60)
+	s.Equal([]string{models.GeofenceEnter}, s.evaluate(0.5, 0.5, 100))
+	s.Empty(s.evaluate(0.5, 0.5, 159))
+	s.Equal([]string{models.GeofenceDwell}, s.evaluate(0.5, 0.5, 160))
+	s.Empty(s.evaluate(0.5, 0.5, 500))
+	s.Equal([]string{models.GeofenceExit}, s.evaluate(2, 2, 600))
+	s.Equal([]string{models.GeofenceEnter}, s.evaluate(0.5, 0.5, 700))
+	s.Equal([]string{models.GeofenceDwell}, s.evaluate(0.5, 0.5, 760))
+}
+
+func (s *GeofenceManagerTestSuite) TestCircle() {
+	_, err := s.manager.Create(&models.GeofenceCreate{Name: "client", Center: elastic.GeoPointFromLatLon(0, 0), Radius: 200})
+	s.Require().NoError(err)
+	// about 111 metres north
+	s.Equal([]string{models.GeofenceEnter}, s.evaluate(0.001, 0, 10))
+	// about 333 metres north
+	s.Equal([]string{models.GeofenceExit}, s.evaluate(0.003, 0, 20))
+}
+
+func (s *GeofenceManagerTestSuite) TestUpdateShape() {
+	geofence := s.square(0)
+	s.Equal([]string{models.GeofenceEnter}, s.evaluate(0.5, 0.5, 10))
+	radius := 100.0
+	updated, err := s.manager.Update(geofence.ID, &models.GeofenceUpdate{Center: elastic.GeoPointFromLatLon(5, 5), Radius: &radius})
+	if s.NoError(err) {
+		s.Nil(updated.Polygon)
+	}
+	s.Equal([]string{models.GeofenceExit}, s.evaluate(0.5, 0.5, 20))
+
+	_, err = s.manager.Update(geofence.ID, &models.GeofenceUpdate{Polygon: models.FlatPolygon{elastic.GeoPointFromLatLon(0, 0)}})
+	s.Error(err)
+}
+
+func (s *GeofenceManagerTestSuite) TestDeletedGeofenceIsDroppedSilently() {
+	geofence := s.square(0)
+	s.Equal([]string{models.GeofenceEnter}, s.evaluate(0.5, 0.5, 10))
+	s.Require().NoError(s.manager.Delete(geofence.ID))
+	s.Empty(s.evaluate(2, 2, 20))
+
+	presence, err := s.manager.Presence("courier")
+	if s.NoError(err) {
+		s.Empty(presence)
+	}
+}
+
+func (s *GeofenceManagerTestSuite) TestCacheTTL() {
+	s.square(0)
+	s.Equal([]string{models.GeofenceEnter}, s.evaluate(0.5, 0.5, 10))
+	// written behind the manager, e.g. by another instance
+	other := &models.Geofence{Name: "other", Center: elastic.GeoPointFromLatLon(0.5, 0.5), Radius: 100}
+	s.Require().NoError(s.store.Create(other))
+	s.Empty(s.evaluate(0.5, 0.5, 20))
+
+	s.now = s.now.Add(time.Minute)
+	s.Equal([]string{models.GeofenceEnter}, s.evaluate(0.5, 0.5, 30))
+}
+
+func (s *GeofenceManagerTestSuite) TestForget() {
+	s.square(0)
+	s.evaluate(0.5, 0.5, 10)
+	s.Require().NoError(s.manager.Forget("courier"))
+	s.Equal([]string{models.GeofenceEnter}, s.evaluate(0.5, 0.5, 20))
+}
+
+func TestUnitGeofenceManager(t *testing.T) {
+	suite.Run(t, new(GeofenceManagerTestSuite))
+}
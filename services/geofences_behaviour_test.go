@@ -0,0 +1,143 @@
+package services
+
+import (
+	"github.com/TeamD2018/geo-rest/models"
+	"github.com/TeamD2018/geo-rest/services/interfaces"
+	"github.com/olivere/elastic"
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+// GeofencesBehaviourSuite checks geofence CRUD, replacing a shape on update, courier presence
+// and finding events by courier, type and time window, newest first.
+type GeofencesBehaviourSuite struct {
+	suite.Suite
+	newStorage func() (interfaces.GeofenceStore, interfaces.GeofenceEventLog)
+	store      interfaces.GeofenceStore
+	log        interfaces.GeofenceEventLog
+}
+
+func (s *GeofencesBehaviourSuite) BeforeTest(suiteName, testName string) {
+	s.store, s.log = s.newStorage()
+}
+
+func (s *GeofencesBehaviourSuite) create(name string) *models.Geofence {
+	geofence := &models.Geofence{
+		Name:   name,
+		Center: elastic.GeoPointFromLatLon(55.75, 37.61),
+		Radius: 100,
+	}
+	s.Require().NoError(s.store.Create(geofence))
+	return geofence
+}
+
+func (s *GeofencesBehaviourSuite) record(courierID, eventType string, ts int64) *models.GeofenceEvent {
+	event := &models.GeofenceEvent{
+		Type:       eventType,
+		GeofenceID: "geofence",
+		CourierID:  courierID,
+		Point:      elastic.GeoPointFromLatLon(55.75, 37.61),
+		Ts:         ts,
+	}
+	s.Require().NoError(s.log.Record(event))
+	return event
+}
+
+func (s *GeofencesBehaviourSuite) TestCreateAndGet() {
+	created := s.create("warehouse")
+	s.NotEmpty(created.ID)
+
+	geofence, err := s.store.Get(created.ID)
+	if s.NoError(err) {
+		s.Equal(created, geofence)
+	}
+}
+
+func (s *GeofencesBehaviourSuite) TestGetUnknown() {
+	_, err := s.store.Get("unknown")
+	if s.IsType(&models.Error{}, err) {
+		s.Equal(models.ErrEntityNotFound.Code, err.(*models.Error).Code)
+	}
+}
+
+func (s *GeofencesBehaviourSuite) TestUpdateReplacesShape() {
+	geofence := s.create("warehouse")
+	geofence.Center = nil
+	geofence.Radius = 0
+	geofence.Polygon = models.FlatPolygon{
+		elastic.GeoPointFromLatLon(0, 0),
+		elastic.GeoPointFromLatLon(0, 1),
+		elastic.GeoPointFromLatLon(1, 1),
+	}
+	s.Require().NoError(s.store.Update(geofence))
+
+	updated, err := s.store.Get(geofence.ID)
+	if s.NoError(err) {
+		s.Equal(geofence, updated)
+	}
+	s.Error(s.store.Update(&models.Geofence{ID: "unknown", Name: "unknown"}))
+}
+
+func (s *GeofencesBehaviourSuite) TestDeleteAndList() {
+	zone := s.create("zone")
+	client := s.create("client")
+	s.create("warehouse")
+
+	s.Require().NoError(s.store.Delete(zone.ID))
+	s.Error(s.store.Delete(zone.ID))
+
+	geofences, err := s.store.List()
+	if s.NoError(err) && s.Len(geofences, 2) {
+		s.Equal(client.ID, geofences[0].ID)
+		s.Equal("warehouse", geofences[1].Name)
+	}
+}
+
+func (s *GeofencesBehaviourSuite) TestPresence() {
+	presence, err := s.store.Presence("courier")
+	if s.NoError(err) {
+		s.Empty(presence)
+	}
+	stored := models.GeofencePresences{{GeofenceID: "geofence", EnteredAt: 10, Dwelled: true}}
+	s.Require().NoError(s.store.SetPresence("courier", stored))
+	presence, err = s.store.Presence("courier")
+	if s.NoError(err) {
+		s.Equal(stored, presence)
+	}
+
+	s.Require().NoError(s.store.SetPresence("courier", nil))
+	presence, err = s.store.Presence("courier")
+	if s.NoError(err) {
+		s.Empty(presence)
+	}
+}
+
+func (s *GeofencesBehaviourSuite) TestFindEvents() {
+	s.record("courier", models.GeofenceEnter, 10)
+	early := s.record("courier", models.GeofenceExit, 20)
+	late := s.record("courier", models.GeofenceExit, 30)
+	s.record("other", models.GeofenceExit, 30)
+	s.record("courier", models.GeofenceExit, 40)
+
+	found, err := s.log.Find(&models.GeofenceEventQuery{
+		CourierID: "courier",
+		Type:      models.GeofenceExit,
+		Since:     10,
+		Until:     30,
+	})
+	if s.NoError(err) && s.Len(found, 2) {
+		s.Equal(late, found[0])
+		s.Equal(early.ID, found[1].ID)
+	}
+
+	found, err = s.log.Find(&models.GeofenceEventQuery{Limit: 1})
+	if s.NoError(err) && s.Len(found, 1) {
+		s.Equal(int64(40), found[0].Ts)
+	}
+}
+
+func TestUnitMemoryGeofences(t *testing.T) {
+	suite.Run(t, &GeofencesBehaviourSuite{newStorage: func() (interfaces.GeofenceStore, interfaces.GeofenceEventLog) {
+		return NewMemoryGeofenceStore(), NewMemoryGeofenceEventLog()
+	}})
+}
@@ -0,0 +1,387 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/TeamD2018/geo-rest/models"
+	"github.com/olivere/elastic"
+	"github.com/satori/go.uuid"
+	"go.uber.org/zap"
+)
+
+const (
+	GeofencesIndex        = "geofences"
+	GeofencePresenceIndex = "geofence_presence"
+	GeofenceEventsIndex   = "geofence_events"
+)
+
+const (
+	DefaultGeofenceEventsReturnSize = 50
+	// Upper bound of geofences read at once, they are all evaluated for every location update
+	MaxGeofences = 10000
+)
+
+// GeofencesElastic keeps geofences and courier presence in Elasticsearch.
+// Shapes are not indexed: couriers are matched against them in process.
+type GeofencesElastic struct {
+	Elastic       *elastic.Client
+	index         string
+	presenceIndex string
+	Logger        *zap.Logger
+}
+
+type geofencePresenceDoc struct {
+	Presence models.GeofencePresences `json:"presence"`
+}
+
+func NewGeofencesElastic(client *elastic.Client, logger *zap.Logger, index, presenceIndex string) *GeofencesElastic {
+	if index == "" {
+		index = GeofencesIndex
+	}
+	if presenceIndex == "" {
+		presenceIndex = GeofencePresenceIndex
+	}
+	if logger == nil {
+		logger, _ = zap.NewDevelopment()
+	}
+	return &GeofencesElastic{
+		Elastic:       client,
+		index:         index,
+		presenceIndex: presenceIndex,
+		Logger:        logger,
+	}
+}
+
+func (ge *GeofencesElastic) Create(geofence *models.Geofence) error {
+	geofence.ID = uuid.NewV4().String()
+	_, err := ge.Elastic.Index().
+		Index(ge.index).
+		Type("_doc").
+		Id(geofence.ID).
+		BodyJson(geofence).
+		Refresh("true").
+		Do(context.Background())
+	if err != nil {
+		geofence.ID = ""
+		return err
+	}
+	return nil
+}
+
+func (ge *GeofencesElastic) Get(geofenceID string) (*models.Geofence, error) {
+	res, err := ge.Elastic.Get().
+		Index(ge.index).
+		Type("_doc").
+		Id(geofenceID).
+		Do(context.Background())
+	if err != nil {
+		if elastic.IsNotFound(err) {
+			return nil, models.ErrEntityNotFound.SetParameter(geofenceID)
+		}
+		return nil, err
+	}
+	var geofence models.Geofence
+	if err := json.Unmarshal(*res.Source, &geofence); err != nil {
+		return nil, models.ErrUnmarshalJSON
+	}
+	geofence.ID = res.Id
+	return &geofence, nil
+}
+
+func (ge *GeofencesElastic) Update(geofence *models.Geofence) error {
+	if _, err := ge.Get(geofence.ID); err != nil {
+		return err
+	}
+	_, err := ge.Elastic.Index().
+		Index(ge.index).
+		Type("_doc").
+		Id(geofence.ID).
+		BodyJson(geofence).
+		Refresh("true").
+		Do(context.Background())
+	return err
+}
+
+func (ge *GeofencesElastic) Delete(geofenceID string) error {
+	_, err := ge.Elastic.Delete().
+		Index(ge.index).
+		Type("_doc").
+		Id(geofenceID).
+		Refresh("true").
+		Do(context.Background())
+	if err != nil {
+		if elastic.IsNotFound(err) {
+			return models.ErrEntityNotFound.SetParameter(geofenceID)
+		}
+		return err
+	}
+	return nil
+}
+
+func (ge *GeofencesElastic) List() (models.Geofences, error) {
+	res, err := ge.Elastic.Search(ge.index).
+		Type("_doc").
+		Query(elastic.NewMatchAllQuery()).
+		Sort("name", true).
+		Size(MaxGeofences).
+		Do(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	geofences := make(models.Geofences, 0, len(res.Hits.Hits))
+	for _, hit := range res.Hits.Hits {
+		var geofence models.Geofence
+		if err := json.Unmarshal(*hit.Source, &geofence); err != nil {
+			return nil, models.ErrUnmarshalJSON
+		}
+		geofence.ID = hit.Id
+		geofences = append(geofences, &geofence)
+	}
+	sortGeofences(geofences)
+	return geofences, nil
+}
+
+func (ge *GeofencesElastic) Presence(courierID string) (models.GeofencePresences, error) {
+	res, err := ge.Elastic.Get().
+		Index(ge.presenceIndex).
+		Type("_doc").
+		Id(courierID).
+		Do(context.Background())
+	if err != nil {
+		if elastic.IsNotFound(err) {
+			return models.GeofencePresences{}, nil
+		}
+		return nil, err
+	}
+	var doc geofencePresenceDoc
+	if err := json.Unmarshal(*res.Source, &doc); err != nil {
+		return nil, models.ErrUnmarshalJSON
+	}
+	if doc.Presence == nil {
+		doc.Presence = models.GeofencePresences{}
+	}
+	return doc.Presence, nil
+}
+
+func (ge *GeofencesElastic) SetPresence(courierID string, presence models.GeofencePresences) error {
+	if len(presence) == 0 {
+		_, err := ge.Elastic.Delete().
+			Index(ge.presenceIndex).
+			Type("_doc").
+			Id(courierID).
+			Do(context.Background())
+		if err != nil && !elastic.IsNotFound(err) {
+			return err
+		}
+		return nil
+	}
+	_, err := ge.Elastic.Index().
+		Index(ge.presenceIndex).
+		Type("_doc").
+		Id(courierID).
+		BodyJson(geofencePresenceDoc{Presence: presence}).
+		Do(context.Background())
+	return err
+}
+
+func (ge *GeofencesElastic) EnsureMapping() error {
+	ctx := context.Background()
+	for _, mapping := range []func() (string, string){ge.GetMapping, ge.GetPresenceMapping} {
+		indexName, body := mapping()
+		exists, err := ge.Elastic.IndexExists(indexName).Do(ctx)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			_, err := ge.Elastic.CreateIndex(indexName).BodyString(body).Do(ctx)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (ge *GeofencesElastic) GetIndex() string {
+	return ge.index
+}
+
+func (ge *GeofencesElastic) GetMapping() (indexName string, mapping string) {
+	return ge.index, `{
+  "mappings": {
+    "_doc": {
+      "properties": {
+        "name": {
+          "type": "keyword"
+        },
+        "kind": {
+          "type": "keyword"
+        },
+        "center": {
+          "type": "geo_point"
+        },
+        "radius": {
+          "type": "double"
+        },
+        "polygon": {
+          "type": "object",
+          "enabled": false
+        },
+        "dwell_time": {
+          "type": "long"
+        },
+        "created_at": {
+          "type": "long"
+        },
+        "updated_at": {
+          "type": "long"
+        }
+      }
+    }
+  }
+}`
+}
+
+func (ge *GeofencesElastic) GetPresenceMapping() (indexName string, mapping string) {
+	return ge.presenceIndex, `{
+  "mappings": {
+    "_doc": {
+      "properties": {
+        "presence": {
+          "type": "object",
+          "enabled": false
+        }
+      }
+    }
+  }
+}`
+}
+
+// GeofenceEventsElastic keeps the log of geofence enter, exit and dwell events in Elasticsearch.
+type GeofenceEventsElastic struct {
+	Elastic *elastic.Client
+	index   string
+	Logger  *zap.Logger
+}
+
+func NewGeofenceEventsElastic(client *elastic.Client, logger *zap.Logger, index string) *GeofenceEventsElastic {
+	if index == "" {
+		index = GeofenceEventsIndex
+	}
+	if logger == nil {
+		logger, _ = zap.NewDevelopment()
+	}
+	return &GeofenceEventsElastic{
+		Elastic: client,
+		index:   index,
+		Logger:  logger,
+	}
+}
+
+func (ge *GeofenceEventsElastic) Record(event *models.GeofenceEvent) error {
+	event.ID = uuid.NewV4().String()
+	_, err := ge.Elastic.Index().
+		Index(ge.index).
+		Type("_doc").
+		Id(event.ID).
+		BodyJson(event).
+		Do(context.Background())
+	if err != nil {
+		event.ID = ""
+		return err
+	}
+	return nil
+}
+
+func (ge *GeofenceEventsElastic) Find(query *models.GeofenceEventQuery) (models.GeofenceEvents, error) {
+	filter := elastic.NewBoolQuery()
+	if query.CourierID != "" {
+		filter = filter.Filter(elastic.NewTermQuery("courier_id", query.CourierID))
+	}
+	if query.GeofenceID != "" {
+		filter = filter.Filter(elastic.NewTermQuery("geofence_id", query.GeofenceID))
+	}
+	if query.Type != "" {
+		filter = filter.Filter(elastic.NewTermQuery("type", query.Type))
+	}
+	window := elastic.NewRangeQuery("timestamp").Gte(query.Since)
+	if query.Until > 0 {
+		window = window.Lte(query.Until)
+	}
+	filter = filter.Filter(window)
+	size := query.Limit
+	if size <= 0 {
+		size = DefaultGeofenceEventsReturnSize
+	}
+	res, err := ge.Elastic.Search(ge.index).
+		Type("_doc").
+		Query(filter).
+		Sort("timestamp", false).
+		Size(size).
+		Do(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	found := make(models.GeofenceEvents, 0, len(res.Hits.Hits))
+	for _, hit := range res.Hits.Hits {
+		var event models.GeofenceEvent
+		if err := json.Unmarshal(*hit.Source, &event); err != nil {
+			return nil, models.ErrUnmarshalJSON
+		}
+		event.ID = hit.Id
+		found = append(found, &event)
+	}
+	return found, nil
+}
+
+func (ge *GeofenceEventsElastic) EnsureMapping() error {
+	indexName, mapping := ge.GetMapping()
+
+	ctx := context.Background()
+	exists, err := ge.Elastic.IndexExists(indexName).Do(ctx)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		_, err := ge.Elastic.CreateIndex(indexName).BodyString(mapping).Do(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (ge *GeofenceEventsElastic) GetIndex() string {
+	return ge.index
+}
+
+func (ge *GeofenceEventsElastic) GetMapping() (indexName string, mapping string) {
+	return ge.index, `{
+  "mappings": {
+    "_doc": {
+      "properties": {
+        "type": {
+          "type": "keyword"
+        },
+        "geofence_id": {
+          "type": "keyword"
+        },
+        "geofence_name": {
+          "type": "keyword"
+        },
+        "courier_id": {
+          "type": "keyword"
+        },
+        "point": {
+          "type": "geo_point"
+        },
+        "timestamp": {
+          "type": "long"
+        }
+      }
+    }
+  }
+}`
+}
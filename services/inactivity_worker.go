@@ -0,0 +1,114 @@
+package services
+
+import (
+	"github.com/TeamD2018/geo-rest/models"
+	"github.com/TeamD2018/geo-rest/services/interfaces"
+	"go.uber.org/zap"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultInactivityThreshold = 10 * time.Minute
+	DefaultInactivityInterval  = time.Minute
+	DefaultInactivityBatchSize = 500
+)
+
+// InactivityWorker periodically marks couriers whose last_seen is older than Threshold as stale and inactive.
+// A stale courier is made active again by its next location update, see APIService.
+type InactivityWorker struct {
+	Couriers  interfaces.ICouriersDAO
+	Logger    *zap.Logger
+	Threshold time.Duration
+	Interval  time.Duration
+	BatchSize int
+	// Called with every courier marked stale
+	OnStale func(courier *models.Courier)
+	Now     func() time.Time
+
+	done      chan struct{}
+	closeOnce sync.Once
+	stopped   sync.WaitGroup
+}
+
+func NewInactivityWorker(couriers interfaces.ICouriersDAO, logger *zap.Logger, threshold, interval time.Duration,
+	onStale func(courier *models.Courier)) *InactivityWorker {
+	if threshold <= 0 {
+		threshold = DefaultInactivityThreshold
+	}
+	if interval <= 0 {
+		interval = DefaultInactivityInterval
+	}
+	return &InactivityWorker{
+		Couriers:  couriers,
+		Logger:    logger,
+		Threshold: threshold,
+		Interval:  interval,
+		BatchSize: DefaultInactivityBatchSize,
+		OnStale:   onStale,
+		Now:       time.Now,
+		done:      make(chan struct{}),
+	}
+}
+
+// Start runs Sweep every Interval until Close.
+func (w *InactivityWorker) Start() {
+	w.stopped.Add(1)
+	go func() {
+		defer w.stopped.Done()
+		ticker := time.NewTicker(w.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-w.done:
+				return
+			case <-ticker.C:
+				if _, err := w.Sweep(); err != nil {
+					w.Logger.Error("fail to sweep silent couriers", zap.Error(err))
+				}
+			}
+		}
+	}()
+}
+
+func (w *InactivityWorker) Close() {
+	w.closeOnce.Do(func() {
+		close(w.done)
+		w.stopped.Wait()
+	})
+}
+
+// Sweep marks at most BatchSize couriers silent for longer than Threshold as stale and returns how many were marked.
+// The rest is left to the next sweep: the storage may not show the marked couriers as active yet when queried again
+// right away, and fetching them twice would report them stale twice.
+// A location stored between the lookup and the update is not lost, the courier is made active by the next one.
+func (w *InactivityWorker) Sweep() (int, error) {
+	before := w.Now().Add(-w.Threshold).Unix()
+	couriers, err := w.Couriers.GetSilent(before, w.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+	marked := 0
+	for _, courier := range couriers {
+		if w.markStale(courier) {
+			marked++
+		}
+	}
+	return marked, nil
+}
+
+func (w *InactivityWorker) markStale(courier *models.Courier) bool {
+	inactive, stale := false, true
+	updated, err := w.Couriers.Update(&models.CourierUpdate{ID: &courier.ID, IsActive: &inactive, Stale: &stale})
+	if err != nil {
+		w.Logger.Error("fail to mark courier stale", zap.String("courier_id", courier.ID), zap.Error(err))
+		return false
+	}
+	w.Logger.Info("courier marked stale",
+		zap.String("courier_id", courier.ID),
+		zap.Int64("last_seen", *courier.LastSeen))
+	if w.OnStale != nil {
+		w.OnStale(updated)
+	}
+	return true
+}
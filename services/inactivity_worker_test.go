@@ -0,0 +1,77 @@
+package services
+
+import (
+	"github.com/TeamD2018/geo-rest/models"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"testing"
+	"time"
+)
+
+func TestUnitInactivityWorker_Sweep(t *testing.T) {
+	dao := NewMemoryCouriersDAO(zap.NewNop(), 0)
+	now := time.Unix(10000, 0)
+	seenAt := func(name string, lastSeen int64, active bool) *models.Courier {
+		created, err := dao.Create(&models.CourierCreate{Name: name, IsActive: active})
+		require.NoError(t, err)
+		updated, err := dao.Update(&models.CourierUpdate{ID: &created.ID, LastSeen: &lastSeen})
+		require.NoError(t, err)
+		return updated
+	}
+	silent := seenAt("Silent", now.Add(-time.Hour).Unix(), true)
+	silentToo := seenAt("Silent too", now.Add(-11*time.Minute).Unix(), true)
+	fresh := seenAt("Fresh", now.Add(-time.Minute).Unix(), true)
+	inactive := seenAt("Inactive", now.Add(-time.Hour).Unix(), false)
+
+	marked := make([]string, 0)
+	worker := NewInactivityWorker(dao, zap.NewNop(), 10*time.Minute, 0, func(courier *models.Courier) {
+		require.True(t, courier.Stale)
+		require.False(t, courier.IsActive)
+		marked = append(marked, courier.ID)
+	})
+	worker.Now = func() time.Time { return now }
+	// smaller than the number of silent couriers, so they are marked by consecutive sweeps
+	worker.BatchSize = 1
+
+	count, err := worker.Sweep()
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+	require.Equal(t, []string{silent.ID}, marked)
+	count, err = worker.Sweep()
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+	require.Equal(t, []string{silent.ID, silentToo.ID}, marked)
+
+	got, err := dao.GetByID(fresh.ID)
+	require.NoError(t, err)
+	require.True(t, got.IsActive)
+	got, err = dao.GetByID(inactive.ID)
+	require.NoError(t, err)
+	require.False(t, got.Stale)
+
+	count, err = worker.Sweep()
+	require.NoError(t, err)
+	require.Equal(t, 0, count)
+}
+
+func TestUnitInactivityWorker_StartClose(t *testing.T) {
+	dao := NewMemoryCouriersDAO(zap.NewNop(), 0)
+	lastSeen := time.Now().Add(-time.Hour).Unix()
+	created, err := dao.Create(&models.CourierCreate{Name: "Silent", IsActive: true})
+	require.NoError(t, err)
+	_, err = dao.Update(&models.CourierUpdate{ID: &created.ID, LastSeen: &lastSeen})
+	require.NoError(t, err)
+
+	marked := make(chan string, 1)
+	worker := NewInactivityWorker(dao, zap.NewNop(), time.Minute, 10*time.Millisecond, func(courier *models.Courier) {
+		marked <- courier.ID
+	})
+	worker.Start()
+	defer worker.Close()
+	select {
+	case id := <-marked:
+		require.Equal(t, created.ID, id)
+	case <-time.After(time.Second):
+		t.Fatal("silent courier was not marked stale")
+	}
+}
@@ -0,0 +1,12 @@
+package interfaces
+
+import (
+	"github.com/TeamD2018/geo-rest/models"
+	"github.com/olivere/elastic"
+)
+
+type ArrivalDetector interface {
+	// Detect records arrival timestamps on the order for a courier location at ts and returns
+	// the webhook event types of the recorded arrivals in the order they happened
+	Detect(order *models.Order, point *elastic.GeoPoint, ts int64) []string
+}
@@ -0,0 +1,16 @@
+package interfaces
+
+import "github.com/TeamD2018/geo-rest/models"
+
+// ChangeLog keeps the changes in the order they were appended. Mutations are stored before their change is
+// appended, so changes of concurrent mutations of one entity may be appended in another order than the mutations
+// were applied in.
+type ChangeLog interface {
+	// Append logs the change and assigns its Seq, greater than every Seq assigned before
+	Append(change *models.Change) error
+	// Read returns at most limit changes with Seq greater than after, in Seq order
+	Read(after uint64, limit int) (models.Changes, error)
+	// Trim removes changes logged before the unix seconds in Seq order, up to the first one logged later,
+	// and returns how many. Seqs are not reused, Read after a removed change starts at the oldest kept one
+	Trim(before int64) (int, error)
+}
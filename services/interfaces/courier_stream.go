@@ -0,0 +1,26 @@
+package interfaces
+
+import "github.com/TeamD2018/geo-rest/models"
+
+type CourierStream interface {
+	// Subscribe registers a client watching viewport. Changes are held until SetSnapshot, so the snapshot
+	// is read after Subscribe and changes published in between are not lost
+	Subscribe(viewport *models.Viewport) (CourierSubscription, error)
+	Subscription(id string) (CourierSubscription, bool)
+	// PublishUpdate notifies subscribers if the position, active flag or orders count of the courier changed
+	PublishUpdate(courier *models.Courier)
+	PublishDelete(courierID string)
+}
+
+type CourierSubscription interface {
+	ID() string
+	Events() <-chan *models.CourierEvent
+	// Done is closed once the subscription is closed by the client or dropped for falling behind
+	Done() <-chan struct{}
+	// SetViewport moves the subscription to viewport and holds changes until SetSnapshot
+	SetViewport(viewport *models.Viewport)
+	// SetSnapshot sends what viewport holds, read after Subscribe or SetViewport, followed by the held changes.
+	// It is ignored when the subscription has been moved to another viewport since
+	SetSnapshot(viewport *models.Viewport, snapshot models.Couriers)
+	Close()
+}
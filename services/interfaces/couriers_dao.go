@@ -12,4 +12,6 @@ type ICouriersDAO interface {
 	Update(courier *models.CourierUpdate) (*models.Courier, error)
 	Exists(courierID string) (bool, error)
 	Delete(courierID string) error
+	// GetSilent returns active couriers last seen before the given unix time, the longest silent first
+	GetSilent(before int64, size int) (models.Couriers, error)
 }
@@ -0,0 +1,8 @@
+package interfaces
+
+import "github.com/TeamD2018/geo-rest/models"
+
+type Dispatcher interface {
+	// Rank scores couriers that may take the order, the best first. The order must have a source point
+	Rank(order *models.Order) (*models.DispatchRanking, error)
+}
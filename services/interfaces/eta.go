@@ -0,0 +1,17 @@
+package interfaces
+
+import (
+	"github.com/TeamD2018/geo-rest/models"
+	"github.com/olivere/elastic"
+)
+
+// DistanceProvider measures the way a courier has to go, e.g. straight-line or by a routing engine.
+type DistanceProvider interface {
+	// Distance returns metres from one point to another
+	Distance(from, to *elastic.GeoPoint) (float64, error)
+}
+
+type ETAService interface {
+	// Estimate sets ETA of every undelivered order with a destination point, orders must belong to the courier
+	Estimate(courier *models.Courier, orders models.Orders) error
+}
@@ -8,5 +8,5 @@ type GeoRouteInterface interface {
 	CreateCourier(courierID string) error
 	AddPointToRoute(courierID string, point *models.PointWithTs) error
 	DeleteCourier(courierID string) error
-	GetRoute(courierID string, since int64) ([]*models.PointWithTs, error)
+	GetRoute(courierID string, query *models.RouteQuery) (*models.RoutePage, error)
 }
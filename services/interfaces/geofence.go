@@ -0,0 +1,40 @@
+package interfaces
+
+import (
+	"github.com/TeamD2018/geo-rest/models"
+	"github.com/olivere/elastic"
+)
+
+type GeofenceStore interface {
+	// Create stores the geofence and assigns its ID
+	Create(geofence *models.Geofence) error
+	Get(geofenceID string) (*models.Geofence, error)
+	// Update replaces a stored geofence
+	Update(geofence *models.Geofence) error
+	Delete(geofenceID string) error
+	List() (models.Geofences, error)
+	// Presence returns the geofences the courier was inside of at its last evaluated location
+	Presence(courierID string) (models.GeofencePresences, error)
+	// SetPresence replaces the presence of the courier, an empty one is deleted
+	SetPresence(courierID string, presence models.GeofencePresences) error
+}
+
+type GeofenceEventLog interface {
+	// Record stores the event and assigns its ID
+	Record(event *models.GeofenceEvent) error
+	Find(query *models.GeofenceEventQuery) (models.GeofenceEvents, error)
+}
+
+type GeofenceService interface {
+	Create(create *models.GeofenceCreate) (*models.Geofence, error)
+	Get(geofenceID string) (*models.Geofence, error)
+	Update(geofenceID string, update *models.GeofenceUpdate) (*models.Geofence, error)
+	Delete(geofenceID string) error
+	List() (models.Geofences, error)
+	// Evaluate updates the presence of the courier located at point at ts and returns the recorded events
+	Evaluate(courierID string, point *elastic.GeoPoint, ts int64) (models.GeofenceEvents, error)
+	Presence(courierID string) (models.GeofencePresences, error)
+	// Forget drops the presence of a deleted courier without emitting events
+	Forget(courierID string) error
+	Events(query *models.GeofenceEventQuery) (models.GeofenceEvents, error)
+}
@@ -0,0 +1,9 @@
+package interfaces
+
+import "github.com/TeamD2018/geo-rest/models"
+
+type LocationBatchChecker interface {
+	// Check returns the indexes of samples fit for storage; problems are recorded in result.
+	Check(samples []*models.LocationSample, lastSeen int64, result *models.LocationBatchResult) []int
+	MaxBatchSize() int
+}
@@ -0,0 +1,15 @@
+package interfaces
+
+import "github.com/TeamD2018/geo-rest/models"
+
+type LocationFilter interface {
+	// Filter compares point with previous, the last accepted point of the courier, and returns nil if point may be stored.
+	// Otherwise it returns the rejection, already recorded for audit.
+	Filter(courierID string, previous, point *models.PointWithTs, source string) *models.RejectedLocation
+}
+
+type RejectedLocationsLog interface {
+	// Record stores the rejection and assigns its ID
+	Record(rejected *models.RejectedLocation) error
+	Find(query *models.RejectedLocationQuery) (models.RejectedLocations, error)
+}
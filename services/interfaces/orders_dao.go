@@ -1,18 +1,27 @@
 package interfaces
 
-import (
-	"github.com/TeamD2018/geo-rest/controllers/parameters"
-	"github.com/TeamD2018/geo-rest/models"
-)
+import "github.com/TeamD2018/geo-rest/models"
 
 type IOrdersDao interface {
 	Get(orderID string) (*models.Order, error)
 	Create(order *models.OrderCreate) (*models.Order, error)
 	Update(order *models.OrderUpdate) (*models.Order, error)
 	Delete(orderID string) error
-	GetOrdersForCourier(courierID string,
-		since int64,
-		asc parameters.DirectionFlag,
-		excludeDelivered parameters.DeliveredFlag) (models.Orders, error)
+	// Claim assigns an unassigned order to the courier. Of concurrent claims exactly one succeeds,
+	// the others get models.ErrOrderAlreadyClaimed.
+	Claim(orderID, courierID string) (*models.Order, error)
+	// Reassign hands an active order over to another courier and logs the handover, the previous courier
+	// is the From of the last handover. Finished orders and the current courier get models.ErrOrderNotReassignable.
+	Reassign(orderID, courierID, reason string) (*models.Order, error)
+	// Transition moves an order of the courier along the status state machine and logs change with From set
+	// to the stored status. Concurrent transitions of an order apply one after another, each checked against
+	// the status left by the previous one: moves the state machine rejects get models.ErrInvalidOrderTransition,
+	// orders of another courier models.ErrEntityNotFound.
+	Transition(orderID, courierID string, change *models.OrderStatusChange) (*models.Order, error)
+	GetOrdersForCourier(courierID string, query *models.OrdersQuery) (*models.OrdersPage, error)
 	DeleteOrdersForCourier(courierID string) error
+	// Geo searches match the source or the destination point chosen by search, oldest orders first
+	GetByBoxField(field *models.BoxField, search *models.OrderSearch) (models.Orders, error)
+	GetByCircleField(field *models.CircleField, search *models.OrderSearch) (models.Orders, error)
+	GetByPolygon(polygon models.FlatPolygon, search *models.OrderSearch) (models.Orders, error)
 }
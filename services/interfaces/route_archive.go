@@ -0,0 +1,9 @@
+package interfaces
+
+import "github.com/TeamD2018/geo-rest/models"
+
+type RouteArchive interface {
+	// Save stores the route and assigns its ID
+	Save(route *models.ArchivedRoute) error
+	Find(query *models.RouteArchiveQuery) (models.ArchivedRoutes, error)
+}
@@ -0,0 +1,10 @@
+package interfaces
+
+import (
+	"github.com/TeamD2018/geo-rest/models"
+	"time"
+)
+
+type RouteStatsService interface {
+	Stats(courierID string, query *models.RouteQuery, stopDuration time.Duration, stopRadius float64) (*models.RouteStats, error)
+}
@@ -0,0 +1,30 @@
+package interfaces
+
+import "github.com/TeamD2018/geo-rest/models"
+
+type WebhookStore interface {
+	// Create stores the subscription and assigns its ID
+	Create(subscription *models.WebhookSubscription) error
+	Get(subscriptionID string) (*models.WebhookSubscription, error)
+	// Delete removes the subscription together with its dead letters
+	Delete(subscriptionID string) error
+	List() (models.WebhookSubscriptions, error)
+	// AddDeadLetter stores the failed delivery and assigns its ID
+	AddDeadLetter(deadLetter *models.WebhookDeadLetter) error
+	GetDeadLetter(subscriptionID, deadLetterID string) (*models.WebhookDeadLetter, error)
+	DeleteDeadLetter(subscriptionID, deadLetterID string) error
+	// DeadLetters returns failed deliveries of the subscription, latest first
+	DeadLetters(subscriptionID string, limit int) (models.WebhookDeadLetters, error)
+}
+
+type WebhookService interface {
+	Create(create *models.WebhookSubscriptionCreate) (*models.WebhookSubscription, error)
+	Get(subscriptionID string) (*models.WebhookSubscription, error)
+	Delete(subscriptionID string) error
+	List() (models.WebhookSubscriptions, error)
+	DeadLetters(subscriptionID string, limit int) (models.WebhookDeadLetters, error)
+	// Redeliver queues a dead letter again and removes it from the list
+	Redeliver(subscriptionID, deadLetterID string) error
+	// Dispatch queues an event for every subscription that wants it; it never blocks on delivery
+	Dispatch(eventType string, data interface{})
+}
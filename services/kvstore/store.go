@@ -0,0 +1,170 @@
+package kvstore
+
+import (
+	"encoding/json"
+	"go.etcd.io/bbolt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// openTimeout bounds the wait for the file lock held by another process.
+const openTimeout = 5 * time.Second
+
+// Store is an embedded key-value store on top of a bbolt file. Values are stored as JSON.
+// Buckets are created by the first Put, reading a missing bucket finds nothing.
+type Store struct {
+	db *bbolt.DB
+}
+
+// Open opens the store at path, creating the file if needed.
+// Without syncWrites commits are not fsynced, a crash may lose the latest updates but never corrupts the file.
+func Open(path string, syncWrites bool) (*Store, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	db, err := bbolt.Open(path, 0644, &bbolt.Options{Timeout: openTimeout, NoSync: !syncWrites})
+	if err != nil {
+		return nil, err
+	}
+	return &Store{db: db}, nil
+}
+
+// Close waits for running transactions and closes the file.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Get unmarshals the value stored under key into value and reports whether it was found.
+func (s *Store) Get(bucket, key string, value interface{}) (found bool, err error) {
+	err = s.View(func(tx *Tx) error {
+		found, err = tx.Get(bucket, key, value)
+		return err
+	})
+	return found, err
+}
+
+// ForEach calls fn for every key of bucket in ascending key order until fn returns an error.
+// fn runs inside a read transaction and must not call the store.
+func (s *Store) ForEach(bucket string, fn func(key string, raw json.RawMessage) error) error {
+	return s.View(func(tx *Tx) error {
+		return tx.ForEach(bucket, fn)
+	})
+}
+
+// ForEachFrom is ForEach starting at the first key not less than from.
+func (s *Store) ForEachFrom(bucket, from string, fn func(key string, raw json.RawMessage) error) error {
+	return s.View(func(tx *Tx) error {
+		return tx.ForEachFrom(bucket, from, fn)
+	})
+}
+
+func (s *Store) Put(bucket, key string, value interface{}) error {
+	return s.Update(func(tx *Tx) error {
+		return tx.Put(bucket, key, value)
+	})
+}
+
+func (s *Store) Delete(bucket, key string) error {
+	return s.Update(func(tx *Tx) error {
+		return tx.Delete(bucket, key)
+	})
+}
+
+// View runs fn in a read-only transaction that sees a consistent snapshot of the store.
+func (s *Store) View(fn func(tx *Tx) error) error {
+	return s.db.View(func(tx *bbolt.Tx) error {
+		return fn(&Tx{tx: tx})
+	})
+}
+
+// Update runs fn exclusively and commits all of its writes atomically.
+// Nothing is written if fn returns an error.
+func (s *Store) Update(fn func(tx *Tx) error) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		return fn(&Tx{tx: tx})
+	})
+}
+
+// Tx is a transaction. Reads observe the writes made earlier in the same transaction.
+// Raw values passed to ForEach callbacks are only valid until the callback returns.
+type Tx struct {
+	tx *bbolt.Tx
+}
+
+func (tx *Tx) Get(bucket, key string, value interface{}) (bool, error) {
+	b := tx.tx.Bucket([]byte(bucket))
+	if b == nil {
+		return false, nil
+	}
+	raw := b.Get([]byte(key))
+	if raw == nil {
+		return false, nil
+	}
+	return true, json.Unmarshal(raw, value)
+}
+
+// ForEach calls fn for every key of bucket in ascending key order until fn returns an error.
+// fn must not modify bucket.
+func (tx *Tx) ForEach(bucket string, fn func(key string, raw json.RawMessage) error) error {
+	return tx.ForEachFrom(bucket, "", fn)
+}
+
+// ForEachFrom is ForEach starting at the first key not less than from.
+func (tx *Tx) ForEachFrom(bucket, from string, fn func(key string, raw json.RawMessage) error) error {
+	b := tx.tx.Bucket([]byte(bucket))
+	if b == nil {
+		return nil
+	}
+	cursor := b.Cursor()
+	var key, raw []byte
+	if from == "" {
+		key, raw = cursor.First()
+	} else {
+		key, raw = cursor.Seek([]byte(from))
+	}
+	for ; key != nil; key, raw = cursor.Next() {
+		if err := fn(string(key), raw); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (tx *Tx) Put(bucket, key string, value interface{}) error {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	b, err := tx.tx.CreateBucketIfNotExists([]byte(bucket))
+	if err != nil {
+		return err
+	}
+	return b.Put([]byte(key), raw)
+}
+
+func (tx *Tx) Delete(bucket, key string) error {
+	b := tx.tx.Bucket([]byte(bucket))
+	if b == nil {
+		return nil
+	}
+	return b.Delete([]byte(key))
+}
+
+func (tx *Tx) DeleteBucket(bucket string) error {
+	err := tx.tx.DeleteBucket([]byte(bucket))
+	if err == bbolt.ErrBucketNotFound {
+		return nil
+	}
+	return err
+}
+
+// NextSequence returns the next value of the counter of bucket, starting at 1.
+// The counter goes away with the bucket.
+func (tx *Tx) NextSequence(bucket string) (uint64, error) {
+	b, err := tx.tx.CreateBucketIfNotExists([]byte(bucket))
+	if err != nil {
+		return 0, err
+	}
+	return b.NextSequence()
+}
@@ -0,0 +1,102 @@
+package kvstore
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func tempPath(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "kvstore")
+	require.NoError(t, err)
+	return filepath.Join(dir, "data.db"), func() { os.RemoveAll(dir) }
+}
+
+func TestUnitStoreReopen(t *testing.T) {
+	path, cleanup := tempPath(t)
+	defer cleanup()
+
+	store, err := Open(path, true)
+	require.NoError(t, err)
+	require.NoError(t, store.Put("bucket", "a", 1))
+	require.NoError(t, store.Put("bucket", "b", 2))
+	require.NoError(t, store.Delete("bucket", "a"))
+	require.NoError(t, store.Close())
+
+	store, err = Open(path, false)
+	require.NoError(t, err)
+	defer store.Close()
+	var value int
+	found, err := store.Get("bucket", "a", &value)
+	require.NoError(t, err)
+	require.False(t, found)
+	found, err = store.Get("bucket", "b", &value)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, 2, value)
+}
+
+func TestUnitStoreFailedUpdateIsNotApplied(t *testing.T) {
+	path, cleanup := tempPath(t)
+	defer cleanup()
+
+	store, err := Open(path, false)
+	require.NoError(t, err)
+	defer store.Close()
+	err = store.Update(func(tx *Tx) error {
+		require.NoError(t, tx.Put("bucket", "a", 1))
+		return errors.New("rollback")
+	})
+	require.Error(t, err)
+	found, err := store.Get("bucket", "a", new(int))
+	require.NoError(t, err)
+	require.False(t, found)
+}
+
+func TestUnitStoreForEachFrom(t *testing.T) {
+	path, cleanup := tempPath(t)
+	defer cleanup()
+
+	store, err := Open(path, false)
+	require.NoError(t, err)
+	defer store.Close()
+	for _, key := range []string{"c", "a", "d", "b"} {
+		require.NoError(t, store.Put("bucket", key, key))
+	}
+	keys := make([]string, 0)
+	require.NoError(t, store.ForEachFrom("bucket", "b", func(key string, raw json.RawMessage) error {
+		keys = append(keys, key)
+		return nil
+	}))
+	require.Equal(t, []string{"b", "c", "d"}, keys)
+	require.NoError(t, store.ForEach("missing", func(key string, raw json.RawMessage) error {
+		return errors.New("missing bucket is not empty")
+	}))
+}
+
+func TestUnitStoreSequenceIsDroppedWithBucket(t *testing.T) {
+	path, cleanup := tempPath(t)
+	defer cleanup()
+
+	store, err := Open(path, false)
+	require.NoError(t, err)
+	defer store.Close()
+	next := func() uint64 {
+		var seq uint64
+		require.NoError(t, store.Update(func(tx *Tx) error {
+			seq, err = tx.NextSequence("bucket")
+			return err
+		}))
+		return seq
+	}
+	require.Equal(t, uint64(1), next())
+	require.Equal(t, uint64(2), next())
+	require.NoError(t, store.Update(func(tx *Tx) error {
+		return tx.DeleteBucket("bucket")
+	}))
+	require.Equal(t, uint64(1), next())
+}
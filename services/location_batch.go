@@ -0,0 +1,66 @@
+package services
+
+import (
+	"github.com/TeamD2018/geo-rest/models"
+	"time"
+)
+
+const (
+	DefaultMaxClockSkew      = time.Minute
+	DefaultMaxLocationsBatch = 1000
+)
+
+// LocationBatchPolicy validates location batches sent by courier devices.
+type LocationBatchPolicy struct {
+	// How far ahead of the server clock a device timestamp may be
+	MaxClockSkew time.Duration
+	MaxSize      int
+	Now          func() time.Time
+}
+
+func NewLocationBatchPolicy(maxClockSkew time.Duration, maxSize int) *LocationBatchPolicy {
+	if maxClockSkew <= 0 {
+		maxClockSkew = DefaultMaxClockSkew
+	}
+	if maxSize <= 0 {
+		maxSize = DefaultMaxLocationsBatch
+	}
+	return &LocationBatchPolicy{
+		MaxClockSkew: maxClockSkew,
+		MaxSize:      maxSize,
+		Now:          time.Now,
+	}
+}
+
+func (p *LocationBatchPolicy) MaxBatchSize() int {
+	return p.MaxSize
+}
+
+// Check rejects samples with invalid points or fixes, timestamps in the future and timestamps not greater than
+// the previous accepted sample. Samples older than lastSeen are accepted but flagged.
+func (p *LocationBatchPolicy) Check(samples []*models.LocationSample, lastSeen int64, result *models.LocationBatchResult) []int {
+	deadline := uint64(p.Now().Add(p.MaxClockSkew).Unix())
+	accepted := make([]int, 0, len(samples))
+	var previous uint64
+	for i, sample := range samples {
+		switch {
+		case sample == nil || sample.Point == nil || sample.Ts == 0 ||
+			sample.Point.Lat < -90 || sample.Point.Lat > 90 || sample.Point.Lon < -180 || sample.Point.Lon > 180 ||
+			!sample.Fix.Valid():
+			result.Reject(i, models.SampleInvalidPoint)
+			continue
+		case sample.Ts > deadline:
+			result.Reject(i, models.SampleFutureTimestamp)
+			continue
+		case len(accepted) > 0 && sample.Ts <= previous:
+			result.Reject(i, models.SampleOutOfOrder)
+			continue
+		}
+		if lastSeen > 0 && sample.Ts < uint64(lastSeen) {
+			result.Flag(i, models.SampleOlderThanCurrent)
+		}
+		accepted = append(accepted, i)
+		previous = sample.Ts
+	}
+	return accepted
+}
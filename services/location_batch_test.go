@@ -0,0 +1,77 @@
+package services
+
+import (
+	"github.com/TeamD2018/geo-rest/models"
+	"github.com/olivere/elastic"
+	"github.com/stretchr/testify/suite"
+	"testing"
+	"time"
+)
+
+type LocationBatchPolicyTestSuite struct {
+	suite.Suite
+	policy *LocationBatchPolicy
+}
+
+func (s *LocationBatchPolicyTestSuite) BeforeTest(suiteName, testName string) {
+	s.policy = NewLocationBatchPolicy(time.Minute, 0)
+	s.policy.Now = func() time.Time { return time.Unix(1000, 0) }
+}
+
+func (s *LocationBatchPolicyTestSuite) sample(lat float64, ts uint64) *models.LocationSample {
+	return &models.LocationSample{Point: elastic.GeoPointFromLatLon(lat, 0), Ts: ts}
+}
+
+func (s *LocationBatchPolicyTestSuite) TestAcceptsOrderedBatch() {
+	result := &models.LocationBatchResult{}
+	accepted := s.policy.Check([]*models.LocationSample{s.sample(1, 10), s.sample(2, 20), s.sample(3, 1060)}, 0, result)
+	s.Equal([]int{0, 1, 2}, accepted)
+	s.Empty(result.Rejected)
+	s.Empty(result.Flagged)
+}
+
+func (s *LocationBatchPolicyTestSuite) TestRejects() {
+	result := &models.LocationBatchResult{}
+	accepted := s.policy.Check([]*models.LocationSample{
+		s.sample(1, 20),
+		s.sample(2, 20),
+		s.sample(91, 30),
+		nil,
+		s.sample(3, 1061),
+		s.sample(4, 10),
+		s.sample(5, 40),
+	}, 0, result)
+	s.Equal([]int{0, 6}, accepted)
+	s.Equal([]*models.LocationSampleIssue{
+		{Index: 1, Reason: models.SampleOutOfOrder},
+		{Index: 2, Reason: models.SampleInvalidPoint},
+		{Index: 3, Reason: models.SampleInvalidPoint},
+		{Index: 4, Reason: models.SampleFutureTimestamp},
+		{Index: 5, Reason: models.SampleOutOfOrder},
+	}, result.Rejected)
+}
+
+func (s *LocationBatchPolicyTestSuite) TestRejectsInvalidFix() {
+	result := &models.LocationBatchResult{}
+	accuracy, provider := -1.0, "satellite"
+	withAccuracy, withProvider := s.sample(1, 10), s.sample(2, 20)
+	withAccuracy.Accuracy = &accuracy
+	withProvider.Provider = &provider
+	accepted := s.policy.Check([]*models.LocationSample{withAccuracy, withProvider, s.sample(3, 30)}, 0, result)
+	s.Equal([]int{2}, accepted)
+	s.Equal([]*models.LocationSampleIssue{
+		{Index: 0, Reason: models.SampleInvalidPoint},
+		{Index: 1, Reason: models.SampleInvalidPoint},
+	}, result.Rejected)
+}
+
+func (s *LocationBatchPolicyTestSuite) TestFlagsSamplesOlderThanLastSeen() {
+	result := &models.LocationBatchResult{}
+	accepted := s.policy.Check([]*models.LocationSample{s.sample(1, 10), s.sample(2, 30)}, 20, result)
+	s.Equal([]int{0, 1}, accepted)
+	s.Equal([]*models.LocationSampleIssue{{Index: 0, Reason: models.SampleOlderThanCurrent}}, result.Flagged)
+}
+
+func TestUnitLocationBatchPolicy(t *testing.T) {
+	suite.Run(t, new(LocationBatchPolicyTestSuite))
+}
@@ -0,0 +1,148 @@
+package services
+
+import (
+	"github.com/TeamD2018/geo-rest/models"
+	"github.com/TeamD2018/geo-rest/services/geo"
+	"github.com/TeamD2018/geo-rest/services/interfaces"
+	"go.uber.org/zap"
+	"sync"
+	"time"
+)
+
+const (
+	// About 250 km/h
+	DefaultMaxCourierSpeed   = 70.0
+	DefaultDuplicateDistance = 5.0
+	DefaultDuplicateInterval = 10 * time.Second
+)
+
+// Modes of the location filter for points implying an impossible speed.
+const (
+	LocationFilterReject     = "reject"
+	LocationFilterQuarantine = "quarantine"
+)
+
+// LocationFilter keeps GPS outliers and duplicates out of courier locations and routes.
+// Every rejected point is recorded in Log.
+//
+// In quarantine mode a point implying an impossible speed is held as a candidate. If the next point is
+// reachable from the candidate but not from the previous location, the previous location was the outlier
+// and the next point is let through. Candidates live in process memory.
+type LocationFilter struct {
+	// Metres per second
+	MaxSpeed float64
+	// Points closer than DuplicateDistance metres within DuplicateInterval of the previous one are near duplicates
+	DuplicateDistance float64
+	DuplicateInterval time.Duration
+	Quarantine        bool
+	Log               interfaces.RejectedLocationsLog
+	Logger            *zap.Logger
+	Now               func() time.Time
+
+	mu         sync.Mutex
+	candidates map[string]*models.PointWithTs
+}
+
+func NewLocationFilter(log interfaces.RejectedLocationsLog, logger *zap.Logger, mode string,
+	maxSpeed, duplicateDistance float64, duplicateInterval time.Duration) *LocationFilter {
+	if maxSpeed <= 0 {
+		maxSpeed = DefaultMaxCourierSpeed
+	}
+	if duplicateDistance < 0 {
+		duplicateDistance = DefaultDuplicateDistance
+	}
+	if duplicateInterval < 0 {
+		duplicateInterval = DefaultDuplicateInterval
+	}
+	return &LocationFilter{
+		MaxSpeed:          maxSpeed,
+		DuplicateDistance: duplicateDistance,
+		DuplicateInterval: duplicateInterval,
+		Quarantine:        mode == LocationFilterQuarantine,
+		Log:               log,
+		Logger:            logger,
+		Now:               time.Now,
+		candidates:        make(map[string]*models.PointWithTs),
+	}
+}
+
+func (f *LocationFilter) Filter(courierID string, previous, point *models.PointWithTs, source string) *models.RejectedLocation {
+	rejected := f.check(courierID, previous, point)
+	if rejected == nil {
+		return nil
+	}
+	rejected.CourierID = courierID
+	rejected.Point = point
+	rejected.Previous = previous
+	rejected.Source = source
+	rejected.RejectedAt = f.Now().Unix()
+	if f.Log != nil {
+		if err := f.Log.Record(rejected); err != nil {
+			f.Logger.Error("fail to record rejected location", zap.Error(err), zap.String("courier_id", courierID))
+		}
+	}
+	return rejected
+}
+
+func (f *LocationFilter) check(courierID string, previous, point *models.PointWithTs) *models.RejectedLocation {
+	if previous == nil || previous.Point == nil {
+		f.dropCandidate(courierID)
+		return nil
+	}
+	distance, elapsed := separation(previous, point)
+	if point.Point.Lat == previous.Point.Lat && point.Point.Lon == previous.Point.Lon && point.Ts == previous.Ts {
+		return &models.RejectedLocation{Reason: models.OutlierDuplicate}
+	}
+	if distance <= f.DuplicateDistance && elapsed < f.DuplicateInterval.Seconds() {
+		return &models.RejectedLocation{Reason: models.OutlierNearDuplicate, Distance: distance}
+	}
+	speed := distance / elapsed
+	if speed <= f.MaxSpeed {
+		f.dropCandidate(courierID)
+		return nil
+	}
+	if !f.Quarantine {
+		return &models.RejectedLocation{Reason: models.OutlierImpossibleSpeed, Distance: distance, Speed: speed}
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if candidate, ok := f.candidates[courierID]; ok && candidate.Ts < point.Ts {
+		if d, t := separation(candidate, point); d/t <= f.MaxSpeed {
+			delete(f.candidates, courierID)
+			return nil
+		}
+	}
+	f.candidates[courierID] = copyPointWithTs(point)
+	return &models.RejectedLocation{Reason: models.OutlierImpossibleSpeed, Distance: distance, Speed: speed, Quarantined: true}
+}
+
+func (f *LocationFilter) dropCandidate(courierID string) {
+	if !f.Quarantine {
+		return
+	}
+	f.mu.Lock()
+	delete(f.candidates, courierID)
+	f.mu.Unlock()
+}
+
+// separation returns the distance between points less both accuracies, and the seconds between them, at least one.
+func separation(a, b *models.PointWithTs) (distance float64, elapsed float64) {
+	distance = geo.Distance(a.Point, b.Point)
+	if a.Accuracy != nil {
+		distance -= *a.Accuracy
+	}
+	if b.Accuracy != nil {
+		distance -= *b.Accuracy
+	}
+	if distance < 0 {
+		distance = 0
+	}
+	elapsed = float64(b.Ts) - float64(a.Ts)
+	if elapsed < 0 {
+		elapsed = -elapsed
+	}
+	if elapsed < 1 {
+		elapsed = 1
+	}
+	return distance, elapsed
+}
@@ -0,0 +1,100 @@
+package services
+
+import (
+	"github.com/TeamD2018/geo-rest/models"
+	"github.com/olivere/elastic"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+	"testing"
+	"time"
+)
+
+type LocationFilterTestSuite struct {
+	suite.Suite
+	log    *MemoryRejectedLocationsLog
+	filter *LocationFilter
+}
+
+func (s *LocationFilterTestSuite) BeforeTest(suiteName, testName string) {
+	s.log = NewMemoryRejectedLocationsLog()
+	s.filter = NewLocationFilter(s.log, zap.NewNop(), LocationFilterReject, 30, 5, 10*time.Second)
+	s.filter.Now = func() time.Time { return time.Unix(5000, 0) }
+}
+
+// point is lat degrees north of the equator, about 111 km per degree.
+func (s *LocationFilterTestSuite) point(lat float64, ts uint64) *models.PointWithTs {
+	return &models.PointWithTs{Point: elastic.GeoPointFromLatLon(lat, 0), Ts: ts}
+}
+
+func (s *LocationFilterTestSuite) TestAcceptsFirstPoint() {
+	s.Nil(s.filter.Filter("courier", nil, s.point(0, 100), models.LocationSourceUpdate))
+}
+
+func (s *LocationFilterTestSuite) TestAcceptsPlausibleMove() {
+	// 111 metres in 10 seconds
+	s.Nil(s.filter.Filter("courier", s.point(0, 100), s.point(0.001, 110), models.LocationSourceUpdate))
+}
+
+func (s *LocationFilterTestSuite) TestRejectsImpossibleSpeed() {
+	previous := s.point(0, 100)
+	point := s.point(0.1, 110)
+	rejected := s.filter.Filter("courier", previous, point, models.LocationSourceBatch)
+	if !s.NotNil(rejected) {
+		return
+	}
+	s.Equal(models.OutlierImpossibleSpeed, rejected.Reason)
+	s.InDelta(1112, rejected.Speed, 1)
+	s.False(rejected.Quarantined)
+
+	found, err := s.log.Find(&models.RejectedLocationQuery{CourierID: "courier"})
+	s.NoError(err)
+	if s.Len(found, 1) {
+		s.Equal(point, found[0].Point)
+		s.Equal(previous, found[0].Previous)
+		s.Equal(models.LocationSourceBatch, found[0].Source)
+		s.Equal(int64(5000), found[0].RejectedAt)
+	}
+}
+
+func (s *LocationFilterTestSuite) TestAccuracyAbsorbsJitter() {
+	accuracy := 100.0
+	point := s.point(0.001, 101)
+	point.Accuracy = &accuracy
+	s.Nil(s.filter.Filter("courier", s.point(0, 100), point, models.LocationSourceUpdate))
+}
+
+func (s *LocationFilterTestSuite) TestRejectsDuplicates() {
+	rejected := s.filter.Filter("courier", s.point(0, 100), s.point(0, 100), models.LocationSourceUpdate)
+	if s.NotNil(rejected) {
+		s.Equal(models.OutlierDuplicate, rejected.Reason)
+	}
+	rejected = s.filter.Filter("courier", s.point(0, 100), s.point(0.00001, 105), models.LocationSourceUpdate)
+	if s.NotNil(rejected) {
+		s.Equal(models.OutlierNearDuplicate, rejected.Reason)
+	}
+	s.Nil(s.filter.Filter("courier", s.point(0, 100), s.point(0.00001, 110), models.LocationSourceUpdate))
+}
+
+func (s *LocationFilterTestSuite) TestQuarantineLetsConfirmedJumpThrough() {
+	s.filter.Quarantine = true
+	previous := s.point(0, 100)
+
+	rejected := s.filter.Filter("courier", previous, s.point(1, 110), models.LocationSourceUpdate)
+	if s.NotNil(rejected) {
+		s.True(rejected.Quarantined)
+	}
+	// far from the previous location, but close to the quarantined point
+	s.Nil(s.filter.Filter("courier", previous, s.point(1.001, 120), models.LocationSourceUpdate))
+	// the candidate was used up
+	s.NotNil(s.filter.Filter("courier", previous, s.point(1.002, 130), models.LocationSourceUpdate))
+}
+
+func (s *LocationFilterTestSuite) TestRejectModeKeepsNoCandidates() {
+	previous := s.point(0, 100)
+	s.NotNil(s.filter.Filter("courier", previous, s.point(1, 110), models.LocationSourceUpdate))
+	s.NotNil(s.filter.Filter("courier", previous, s.point(1.001, 120), models.LocationSourceUpdate))
+}
+
+func TestUnitLocationFilter(t *testing.T) {
+	suite.Run(t, new(LocationFilterTestSuite))
+}
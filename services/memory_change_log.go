@@ -0,0 +1,65 @@
+package services
+
+import (
+	"github.com/TeamD2018/geo-rest/models"
+	"sort"
+	"sync"
+)
+
+const DefaultChangesReturnSize = 100
+
+type MemoryChangeLog struct {
+	mu      sync.RWMutex
+	changes []*models.Change
+	seq     uint64
+}
+
+func NewMemoryChangeLog() *MemoryChangeLog {
+	return &MemoryChangeLog{
+		changes: make([]*models.Change, 0),
+	}
+}
+
+func (m *MemoryChangeLog) Append(change *models.Change) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.seq++
+	change.Seq = m.seq
+	m.changes = append(m.changes, copyChange(change))
+	return nil
+}
+
+func (m *MemoryChangeLog) Read(after uint64, limit int) (models.Changes, error) {
+	if limit <= 0 {
+		limit = DefaultChangesReturnSize
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	start := sort.Search(len(m.changes), func(i int) bool {
+		return m.changes[i].Seq > after
+	})
+	changes := make(models.Changes, 0)
+	for i := start; i < len(m.changes) && len(changes) < limit; i++ {
+		changes = append(changes, copyChange(m.changes[i]))
+	}
+	return changes, nil
+}
+
+func (m *MemoryChangeLog) Trim(before int64) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	trimmed := 0
+	for trimmed < len(m.changes) && m.changes[trimmed].Ts < before {
+		trimmed++
+	}
+	m.changes = append(make([]*models.Change, 0, len(m.changes)-trimmed), m.changes[trimmed:]...)
+	return trimmed, nil
+}
+
+func copyChange(change *models.Change) *models.Change {
+	copied := *change
+	if change.Data != nil {
+		copied.Data = append([]byte{}, change.Data...)
+	}
+	return &copied
+}
@@ -0,0 +1,238 @@
+package services
+
+import (
+	"github.com/TeamD2018/geo-rest/models"
+	"github.com/TeamD2018/geo-rest/services/geo"
+	"github.com/olivere/elastic"
+	"github.com/satori/go.uuid"
+	"go.uber.org/zap"
+	"sort"
+	"sync"
+	"time"
+)
+
+type MemoryCouriersDAO struct {
+	mu                sync.RWMutex
+	couriers          map[string]*models.Courier
+	defaultReturnSize int
+	l                 *zap.Logger
+}
+
+func NewMemoryCouriersDAO(logger *zap.Logger, defaultReturnSize int) *MemoryCouriersDAO {
+	if logger == nil {
+		logger, _ = zap.NewDevelopment()
+	}
+	if defaultReturnSize <= 0 {
+		defaultReturnSize = DefaultCouriersReturnSize
+	}
+	return &MemoryCouriersDAO{
+		couriers:          make(map[string]*models.Courier),
+		defaultReturnSize: defaultReturnSize,
+		l:                 logger,
+	}
+}
+
+func (c *MemoryCouriersDAO) GetByID(courierID string) (*models.Courier, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	courier, ok := c.couriers[courierID]
+	if !ok {
+		return nil, models.ErrCourierNotFound.SetParameter(courierID)
+	}
+	return copyCourier(courier), nil
+}
+
+func (c *MemoryCouriersDAO) GetByName(name string, size int) (models.Couriers, error) {
+	return c.filter(size, false, func(courier *models.Courier) bool {
+		return courier.Name == name
+	}), nil
+}
+
+func (c *MemoryCouriersDAO) GetByBoxField(field *models.BoxField, size int, activeOnly bool) (models.Couriers, error) {
+	return c.filter(size, activeOnly, func(courier *models.Courier) bool {
+		return courier.Location != nil && geo.InBox(courier.Location.Point, field)
+	}), nil
+}
+
+func (c *MemoryCouriersDAO) GetByCircleField(field *models.CircleField, size int, activeOnly bool) (models.Couriers, error) {
+	return c.filter(size, activeOnly, func(courier *models.Courier) bool {
+		return courier.Location != nil && geo.InCircle(courier.Location.Point, field)
+	}), nil
+}
+
+func (c *MemoryCouriersDAO) GetByPolygon(polygon models.FlatPolygon, size int, activeOnly bool) (models.Couriers, error) {
+	return c.filter(size, activeOnly, func(courier *models.Courier) bool {
+		return courier.Location != nil && geo.InPolygon(courier.Location.Point, polygon)
+	}), nil
+}
+
+func (c *MemoryCouriersDAO) Create(courier *models.CourierCreate) (*models.Courier, error) {
+	created := &models.Courier{
+		ID:       uuid.NewV4().String(),
+		Name:     courier.Name,
+		Phone:    courier.Phone,
+		IsActive: courier.IsActive,
+	}
+	c.mu.Lock()
+	c.couriers[created.ID] = created
+	c.mu.Unlock()
+	return copyCourier(created), nil
+}
+
+func (c *MemoryCouriersDAO) Update(courier *models.CourierUpdate) (*models.Courier, error) {
+	id := *courier.ID
+	courier.ID = nil
+	if courier.Location != nil && courier.LastSeen == nil {
+		now := time.Now().Unix()
+		courier.LastSeen = &now
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stored, ok := c.couriers[id]
+	if !ok {
+		return nil, models.ErrCourierNotFound.SetParameter(id)
+	}
+	applyCourierUpdate(stored, courier)
+	return copyCourier(stored), nil
+}
+
+func (c *MemoryCouriersDAO) Exists(courierID string) (bool, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	_, ok := c.couriers[courierID]
+	return ok, nil
+}
+
+func (c *MemoryCouriersDAO) Delete(courierID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.couriers[courierID]; !ok {
+		return models.ErrCourierNotFound.SetParameter(courierID)
+	}
+	delete(c.couriers, courierID)
+	return nil
+}
+
+func (c *MemoryCouriersDAO) GetSilent(before int64, size int) (models.Couriers, error) {
+	return limitSilent(c.collect(true, func(courier *models.Courier) bool {
+		return isSilent(courier, before)
+	}), size, c.defaultReturnSize), nil
+}
+
+// All returns every stored courier ordered by id.
+func (c *MemoryCouriersDAO) All() models.Couriers {
+	return c.collect(false, func(*models.Courier) bool {
+		return true
+	})
+}
+
+func (c *MemoryCouriersDAO) filter(size int, activeOnly bool, match func(courier *models.Courier) bool) models.Couriers {
+	if size <= 0 {
+		size = c.defaultReturnSize
+	}
+	result := c.collect(activeOnly, match)
+	if len(result) > size {
+		result = result[:size]
+	}
+	return result
+}
+
+func (c *MemoryCouriersDAO) collect(activeOnly bool, match func(courier *models.Courier) bool) models.Couriers {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	result := models.Couriers{}
+	for _, courier := range c.couriers {
+		if activeOnly && !courier.IsActive {
+			continue
+		}
+		if match(courier) {
+			result = append(result, copyCourier(courier))
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ID < result[j].ID
+	})
+	return result
+}
+
+// applyCourierUpdate merges non-nil fields of update into courier the way a partial Elasticsearch update does.
+func applyCourierUpdate(courier *models.Courier, update *models.CourierUpdate) {
+	if update.Name != nil {
+		courier.Name = *update.Name
+	}
+	if update.Location != nil {
+		courier.Location = mergeLocation(courier.Location, update.Location)
+	}
+	if update.Phone != nil {
+		courier.Phone = update.Phone
+	}
+	if update.LastSeen != nil {
+		lastSeen := *update.LastSeen
+		courier.LastSeen = &lastSeen
+	}
+	if update.IsActive != nil {
+		courier.IsActive = *update.IsActive
+	}
+	if update.Stale != nil {
+		courier.Stale = *update.Stale
+	}
+}
+
+// isSilent reports whether an active courier was last seen before the given unix time.
+// Couriers that never sent a location are not silent.
+func isSilent(courier *models.Courier, before int64) bool {
+	return courier.IsActive && courier.LastSeen != nil && *courier.LastSeen < before
+}
+
+// limitSilent orders silent couriers by last seen and keeps at most size of them.
+func limitSilent(couriers models.Couriers, size int, defaultSize int) models.Couriers {
+	if size <= 0 {
+		size = defaultSize
+	}
+	sort.SliceStable(couriers, func(i, j int) bool {
+		return *couriers[i].LastSeen < *couriers[j].LastSeen
+	})
+	if len(couriers) > size {
+		couriers = couriers[:size]
+	}
+	return couriers
+}
+
+func mergeLocation(dst *models.Location, src *models.Location) *models.Location {
+	merged := copyLocation(dst)
+	if merged == nil {
+		merged = &models.Location{}
+	}
+	if src.Point != nil {
+		merged.Point = elastic.GeoPointFromLatLon(src.Point.Lat, src.Point.Lon)
+		// a new position comes with its own fix
+		merged.Fix = src.Fix.Copy()
+	}
+	if src.Address != nil {
+		address := *src.Address
+		merged.Address = &address
+	}
+	return merged
+}
+
+func copyCourier(courier *models.Courier) *models.Courier {
+	copied := *courier
+	copied.Location = copyLocation(courier.Location)
+	if courier.LastSeen != nil {
+		lastSeen := *courier.LastSeen
+		copied.LastSeen = &lastSeen
+	}
+	return &copied
+}
+
+func copyLocation(location *models.Location) *models.Location {
+	if location == nil {
+		return nil
+	}
+	copied := *location
+	if location.Point != nil {
+		copied.Point = elastic.GeoPointFromLatLon(location.Point.Lat, location.Point.Lon)
+	}
+	copied.Fix = location.Fix.Copy()
+	return &copied
+}
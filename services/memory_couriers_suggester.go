@@ -0,0 +1,61 @@
+package services
+
+import (
+	"github.com/TeamD2018/geo-rest/controllers/parameters"
+	"github.com/TeamD2018/geo-rest/models"
+	"github.com/TeamD2018/geo-rest/services/interfaces"
+	"strings"
+)
+
+type courierLister interface {
+	All() models.Couriers
+}
+
+// MemoryCouriersSuggester suggests couriers by name part or phone prefix by scanning every courier.
+// Fuzziness is accepted for interface compatibility but matching is always exact.
+type MemoryCouriersSuggester struct {
+	couriersDAO courierLister
+	fuzziness   int
+	threshold   int
+}
+
+func NewMemoryCouriersSuggester(couriersDAO courierLister) *MemoryCouriersSuggester {
+	return &MemoryCouriersSuggester{
+		couriersDAO: couriersDAO,
+		fuzziness:   CouriersDefaultFuzziness,
+		threshold:   CouriersDefaultFuzzinessThreshold,
+	}
+}
+
+func (cs *MemoryCouriersSuggester) Suggest(field string, suggestion *parameters.Suggestion) (models.Couriers, error) {
+	prefix := strings.ToLower(suggestion.Prefix)
+	found := make(models.Couriers, 0)
+	for _, courier := range cs.couriersDAO.All() {
+		if suggestion.Limit > 0 && len(found) >= suggestion.Limit {
+			break
+		}
+		if cs.matches(courier, prefix) {
+			found = append(found, courier)
+		}
+	}
+	return found, nil
+}
+
+func (cs *MemoryCouriersSuggester) SetFuzziness(fuzziness int) interfaces.CourierSuggester {
+	cs.fuzziness = fuzziness
+	return cs
+}
+
+func (cs *MemoryCouriersSuggester) SetFuzzinessThreshold(threshold int) interfaces.CourierSuggester {
+	cs.threshold = threshold
+	return cs
+}
+
+func (cs *MemoryCouriersSuggester) matches(courier *models.Courier, prefix string) bool {
+	for _, part := range strings.Split(courier.Name, " ") {
+		if strings.HasPrefix(strings.ToLower(part), prefix) {
+			return true
+		}
+	}
+	return courier.Phone != nil && strings.HasPrefix(*courier.Phone, prefix)
+}
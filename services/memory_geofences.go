@@ -0,0 +1,181 @@
+package services
+
+import (
+	"github.com/TeamD2018/geo-rest/models"
+	"github.com/olivere/elastic"
+	"github.com/satori/go.uuid"
+	"sort"
+	"sync"
+)
+
+type MemoryGeofenceStore struct {
+	mu        sync.RWMutex
+	geofences map[string]*models.Geofence
+	presence  map[string]models.GeofencePresences
+}
+
+func NewMemoryGeofenceStore() *MemoryGeofenceStore {
+	return &MemoryGeofenceStore{
+		geofences: make(map[string]*models.Geofence),
+		presence:  make(map[string]models.GeofencePresences),
+	}
+}
+
+func (m *MemoryGeofenceStore) Create(geofence *models.Geofence) error {
+	geofence.ID = uuid.NewV4().String()
+	m.mu.Lock()
+	m.geofences[geofence.ID] = copyGeofence(geofence)
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *MemoryGeofenceStore) Get(geofenceID string) (*models.Geofence, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	geofence, ok := m.geofences[geofenceID]
+	if !ok {
+		return nil, models.ErrEntityNotFound.SetParameter(geofenceID)
+	}
+	return copyGeofence(geofence), nil
+}
+
+func (m *MemoryGeofenceStore) Update(geofence *models.Geofence) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.geofences[geofence.ID]; !ok {
+		return models.ErrEntityNotFound.SetParameter(geofence.ID)
+	}
+	m.geofences[geofence.ID] = copyGeofence(geofence)
+	return nil
+}
+
+func (m *MemoryGeofenceStore) Delete(geofenceID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.geofences[geofenceID]; !ok {
+		return models.ErrEntityNotFound.SetParameter(geofenceID)
+	}
+	delete(m.geofences, geofenceID)
+	return nil
+}
+
+func (m *MemoryGeofenceStore) List() (models.Geofences, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	geofences := make(models.Geofences, 0, len(m.geofences))
+	for _, geofence := range m.geofences {
+		geofences = append(geofences, copyGeofence(geofence))
+	}
+	sortGeofences(geofences)
+	return geofences, nil
+}
+
+func (m *MemoryGeofenceStore) Presence(courierID string) (models.GeofencePresences, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return copyGeofencePresences(m.presence[courierID]), nil
+}
+
+func (m *MemoryGeofenceStore) SetPresence(courierID string, presence models.GeofencePresences) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(presence) == 0 {
+		delete(m.presence, courierID)
+		return nil
+	}
+	m.presence[courierID] = copyGeofencePresences(presence)
+	return nil
+}
+
+type MemoryGeofenceEventLog struct {
+	mu     sync.RWMutex
+	events []*models.GeofenceEvent
+}
+
+func NewMemoryGeofenceEventLog() *MemoryGeofenceEventLog {
+	return &MemoryGeofenceEventLog{
+		events: make([]*models.GeofenceEvent, 0),
+	}
+}
+
+func (m *MemoryGeofenceEventLog) Record(event *models.GeofenceEvent) error {
+	event.ID = uuid.NewV4().String()
+	m.mu.Lock()
+	m.events = append(m.events, copyGeofenceEvent(event))
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *MemoryGeofenceEventLog) Find(query *models.GeofenceEventQuery) (models.GeofenceEvents, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	found := make(models.GeofenceEvents, 0)
+	for _, event := range m.events {
+		if query.Matches(event) {
+			found = append(found, copyGeofenceEvent(event))
+		}
+	}
+	return limitGeofenceEvents(found, query.Limit), nil
+}
+
+// sortGeofences orders geofences by name, then by ID.
+func sortGeofences(geofences models.Geofences) {
+	sort.Slice(geofences, func(i, j int) bool {
+		if geofences[i].Name != geofences[j].Name {
+			return geofences[i].Name < geofences[j].Name
+		}
+		return geofences[i].ID < geofences[j].ID
+	})
+}
+
+// limitGeofenceEvents orders events given in recording order latest first and keeps at most limit of them.
+func limitGeofenceEvents(events models.GeofenceEvents, limit int) models.GeofenceEvents {
+	for i, j := 0, len(events)-1; i < j; i, j = i+1, j-1 {
+		events[i], events[j] = events[j], events[i]
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Ts > events[j].Ts
+	})
+	if limit <= 0 {
+		limit = DefaultGeofenceEventsReturnSize
+	}
+	if len(events) > limit {
+		events = events[:limit]
+	}
+	return events
+}
+
+func copyGeofence(geofence *models.Geofence) *models.Geofence {
+	copied := *geofence
+	if geofence.Polygon != nil {
+		copied.Polygon = make(models.FlatPolygon, len(geofence.Polygon))
+		for i, point := range geofence.Polygon {
+			copied.Polygon[i] = copyGeoPoint(point)
+		}
+	}
+	copied.Center = copyGeoPoint(geofence.Center)
+	return &copied
+}
+
+func copyGeofenceEvent(event *models.GeofenceEvent) *models.GeofenceEvent {
+	copied := *event
+	copied.Point = copyGeoPoint(event.Point)
+	return &copied
+}
+
+func copyGeofencePresences(presence models.GeofencePresences) models.GeofencePresences {
+	copied := make(models.GeofencePresences, len(presence))
+	for i, p := range presence {
+		c := *p
+		copied[i] = &c
+	}
+	return copied
+}
+
+func copyGeoPoint(point *elastic.GeoPoint) *elastic.GeoPoint {
+	if point == nil {
+		return nil
+	}
+	copied := *point
+	return &copied
+}
@@ -0,0 +1,62 @@
+package services
+
+import (
+	"github.com/TeamD2018/geo-rest/models"
+	"sync"
+)
+
+type MemoryOrdersCountTracker struct {
+	mu       sync.Mutex
+	counters map[string]int
+}
+
+func NewMemoryOrdersCountTracker() *MemoryOrdersCountTracker {
+	return &MemoryOrdersCountTracker{
+		counters: make(map[string]int),
+	}
+}
+
+func (oct *MemoryOrdersCountTracker) Inc(courierID string) error {
+	_, err := oct.IncAndGet(courierID)
+	return err
+}
+
+func (oct *MemoryOrdersCountTracker) Dec(courierID string) error {
+	_, err := oct.DecAndGet(courierID)
+	return err
+}
+
+func (oct *MemoryOrdersCountTracker) IncAndGet(courierID string) (int, error) {
+	oct.mu.Lock()
+	defer oct.mu.Unlock()
+	oct.counters[courierID]++
+	return oct.counters[courierID], nil
+}
+
+// DecAndGet mirrors the Tarantool upsert: a missing counter starts at zero and is not decremented.
+func (oct *MemoryOrdersCountTracker) DecAndGet(courierID string) (int, error) {
+	oct.mu.Lock()
+	defer oct.mu.Unlock()
+	if counter, ok := oct.counters[courierID]; ok {
+		oct.counters[courierID] = counter - 1
+	} else {
+		oct.counters[courierID] = 0
+	}
+	return oct.counters[courierID], nil
+}
+
+func (oct *MemoryOrdersCountTracker) Sync(couriers models.Couriers) error {
+	oct.mu.Lock()
+	defer oct.mu.Unlock()
+	for _, courier := range couriers {
+		courier.OrdersCount = oct.counters[courier.ID]
+	}
+	return nil
+}
+
+func (oct *MemoryOrdersCountTracker) Drop(courierID string) error {
+	oct.mu.Lock()
+	delete(oct.counters, courierID)
+	oct.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,289 @@
+package services
+
+import (
+	"github.com/TeamD2018/geo-rest/models"
+	"github.com/TeamD2018/geo-rest/services/geo"
+	"github.com/TeamD2018/geo-rest/services/interfaces"
+	"github.com/satori/go.uuid"
+	"go.uber.org/zap"
+	"sort"
+	"sync"
+	"time"
+)
+
+type MemoryOrdersDAO struct {
+	mu          sync.RWMutex
+	orders      map[string]*models.Order
+	couriersDAO interfaces.ICouriersDAO
+	Logger      *zap.Logger
+}
+
+func NewMemoryOrdersDAO(logger *zap.Logger, couriersDAO interfaces.ICouriersDAO) *MemoryOrdersDAO {
+	if logger == nil {
+		logger, _ = zap.NewDevelopment()
+	}
+	return &MemoryOrdersDAO{
+		orders:      make(map[string]*models.Order),
+		couriersDAO: couriersDAO,
+		Logger:      logger,
+	}
+}
+
+func (od *MemoryOrdersDAO) Get(orderID string) (*models.Order, error) {
+	od.mu.RLock()
+	defer od.mu.RUnlock()
+	order, ok := od.orders[orderID]
+	if !ok {
+		return nil, models.ErrEntityNotFound.SetParameter(orderID)
+	}
+	return copyOrder(order), nil
+}
+
+func (od *MemoryOrdersDAO) Create(orderCreate *models.OrderCreate) (*models.Order, error) {
+	courierID := ""
+	if orderCreate.CourierID != nil {
+		courierID = *orderCreate.CourierID
+	}
+	// orders without a courier are unassigned until claimed
+	if courierID != "" {
+		exists, err := od.couriersDAO.Exists(courierID)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, models.ErrEntityNotFound.SetParameter(courierID)
+		}
+	}
+	order := &models.Order{
+		ID:          uuid.NewV4().String(),
+		CourierID:   courierID,
+		CreatedAt:   time.Now().Unix(),
+		Destination: orderCreate.Destination,
+		Source:      orderCreate.Source,
+		OrderNumber: orderCreate.OrderNumber,
+	}
+	order.StartStatus()
+	order = copyOrder(order)
+	od.mu.Lock()
+	od.orders[order.ID] = order
+	od.mu.Unlock()
+	return copyOrder(order), nil
+}
+
+func (od *MemoryOrdersDAO) Update(update *models.OrderUpdate) (*models.Order, error) {
+	id := *update.ID
+	update.ID = nil
+	if update.CourierID != nil {
+		exists, err := od.couriersDAO.Exists(*update.CourierID)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, models.ErrEntityNotFound.SetParameter(*update.CourierID)
+		}
+	}
+	od.mu.Lock()
+	defer od.mu.Unlock()
+	order, ok := od.orders[id]
+	if !ok {
+		return nil, models.ErrEntityNotFound.SetParameter(id)
+	}
+	applyOrderUpdate(order, update)
+	return copyOrder(order), nil
+}
+
+func (od *MemoryOrdersDAO) Claim(orderID, courierID string) (*models.Order, error) {
+	exists, err := od.couriersDAO.Exists(courierID)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, models.ErrEntityNotFound.SetParameter(courierID)
+	}
+	od.mu.Lock()
+	defer od.mu.Unlock()
+	order, ok := od.orders[orderID]
+	if !ok {
+		return nil, models.ErrEntityNotFound.SetParameter(orderID)
+	}
+	if err := order.Claim(courierID, time.Now().Unix()); err != nil {
+		return nil, err
+	}
+	return copyOrder(order), nil
+}
+
+func (od *MemoryOrdersDAO) Reassign(orderID, courierID, reason string) (*models.Order, error) {
+	exists, err := od.couriersDAO.Exists(courierID)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, models.ErrEntityNotFound.SetParameter(courierID)
+	}
+	od.mu.Lock()
+	defer od.mu.Unlock()
+	order, ok := od.orders[orderID]
+	if !ok {
+		return nil, models.ErrEntityNotFound.SetParameter(orderID)
+	}
+	if err := order.Reassign(courierID, reason, time.Now().Unix()); err != nil {
+		return nil, err
+	}
+	return copyOrder(order), nil
+}
+
+func (od *MemoryOrdersDAO) Transition(orderID, courierID string, change *models.OrderStatusChange) (*models.Order, error) {
+	od.mu.Lock()
+	defer od.mu.Unlock()
+	order, ok := od.orders[orderID]
+	if !ok {
+		return nil, models.ErrEntityNotFound.SetParameter(orderID)
+	}
+	if err := order.TransitionFor(courierID, change); err != nil {
+		return nil, err
+	}
+	return copyOrder(order), nil
+}
+
+func (od *MemoryOrdersDAO) Delete(orderID string) error {
+	od.mu.Lock()
+	defer od.mu.Unlock()
+	if _, ok := od.orders[orderID]; !ok {
+		return models.ErrEntityNotFound.SetParameter(orderID)
+	}
+	delete(od.orders, orderID)
+	return nil
+}
+
+func (od *MemoryOrdersDAO) DeleteOrdersForCourier(courierID string) error {
+	od.mu.Lock()
+	defer od.mu.Unlock()
+	for id, order := range od.orders {
+		if order.CourierID == courierID {
+			delete(od.orders, id)
+		}
+	}
+	return nil
+}
+
+func (od *MemoryOrdersDAO) GetOrdersForCourier(courierID string, query *models.OrdersQuery) (*models.OrdersPage, error) {
+	return pageOrders(od.collect(func(order *models.Order) bool {
+		return order.CourierID == courierID
+	}), query), nil
+}
+
+func (od *MemoryOrdersDAO) GetByBoxField(field *models.BoxField, search *models.OrderSearch) (models.Orders, error) {
+	return limitOrders(od.collect(func(order *models.Order) bool {
+		return search.Match(order) && geo.InBox(search.PointOf(order), field)
+	}), search.Size), nil
+}
+
+func (od *MemoryOrdersDAO) GetByCircleField(field *models.CircleField, search *models.OrderSearch) (models.Orders, error) {
+	return limitOrders(od.collect(func(order *models.Order) bool {
+		return search.Match(order) && geo.InCircle(search.PointOf(order), field)
+	}), search.Size), nil
+}
+
+func (od *MemoryOrdersDAO) GetByPolygon(polygon models.FlatPolygon, search *models.OrderSearch) (models.Orders, error) {
+	return limitOrders(od.collect(func(order *models.Order) bool {
+		return search.Match(order) && geo.InPolygon(search.PointOf(order), polygon)
+	}), search.Size), nil
+}
+
+func (od *MemoryOrdersDAO) collect(match func(order *models.Order) bool) models.Orders {
+	od.mu.RLock()
+	defer od.mu.RUnlock()
+	orders := make(models.Orders, 0)
+	for _, order := range od.orders {
+		if match(order) {
+			orders = append(orders, copyOrder(order))
+		}
+	}
+	sort.Slice(orders, func(i, j int) bool {
+		if orders[i].CreatedAt != orders[j].CreatedAt {
+			return orders[i].CreatedAt < orders[j].CreatedAt
+		}
+		return orders[i].ID < orders[j].ID
+	})
+	return orders
+}
+
+func limitOrders(orders models.Orders, size int) models.Orders {
+	if size <= 0 {
+		size = DefaultOrdersReturnSize
+	}
+	if len(orders) > size {
+		return orders[:size]
+	}
+	return orders
+}
+
+// applyOrderUpdate merges non-nil fields of update into order the way a partial Elasticsearch update does.
+func applyOrderUpdate(order *models.Order, update *models.OrderUpdate) {
+	if update.CourierID != nil {
+		order.CourierID = *update.CourierID
+	}
+	if update.CreatedAt != nil {
+		order.CreatedAt = *update.CreatedAt
+	}
+	if update.DeliveredAt != nil {
+		order.DeliveredAt = *update.DeliveredAt
+	}
+	if update.ReportedDeliveredAt != nil {
+		order.ReportedDeliveredAt = *update.ReportedDeliveredAt
+	}
+	if update.Destination != nil {
+		order.Destination = *mergeLocation(&order.Destination, update.Destination)
+	}
+	if update.Source != nil {
+		order.Source = *mergeLocation(&order.Source, update.Source)
+	}
+	if update.ArrivedAtPickup != nil {
+		order.ArrivedAtPickup = *update.ArrivedAtPickup
+	}
+	if update.PickedUp != nil {
+		order.PickedUp = *update.PickedUp
+	}
+	if update.ArrivedAtDestination != nil {
+		order.ArrivedAtDestination = *update.ArrivedAtDestination
+	}
+	if update.Status != nil {
+		order.Status = *update.Status
+	}
+	if update.StatusHistory != nil {
+		order.StatusHistory = copyOrderStatusHistory(update.StatusHistory)
+	}
+}
+
+func copyOrder(order *models.Order) *models.Order {
+	copied := *order
+	copied.Destination = *copyLocation(&order.Destination)
+	copied.Source = *copyLocation(&order.Source)
+	copied.StatusHistory = copyOrderStatusHistory(order.StatusHistory)
+	copied.Handovers = copyOrderHandovers(order.Handovers)
+	return &copied
+}
+
+func copyOrderHandovers(handovers []*models.OrderHandover) []*models.OrderHandover {
+	if handovers == nil {
+		return nil
+	}
+	copied := make([]*models.OrderHandover, 0, len(handovers))
+	for _, handover := range handovers {
+		h := *handover
+		copied = append(copied, &h)
+	}
+	return copied
+}
+
+func copyOrderStatusHistory(history []*models.OrderStatusChange) []*models.OrderStatusChange {
+	if history == nil {
+		return nil
+	}
+	copied := make([]*models.OrderStatusChange, 0, len(history))
+	for _, change := range history {
+		c := *change
+		copied = append(copied, &c)
+	}
+	return copied
+}
@@ -0,0 +1,68 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/TeamD2018/geo-rest/models"
+	"github.com/olivere/elastic"
+	"io"
+	"strconv"
+	"sync"
+)
+
+// MemoryRegionResolver serves OSM polygons that were saved to it or loaded from a file.
+type MemoryRegionResolver struct {
+	mu      sync.RWMutex
+	regions map[int]models.FlatPolygon
+}
+
+func NewMemoryRegionResolver() *MemoryRegionResolver {
+	return &MemoryRegionResolver{
+		regions: make(map[int]models.FlatPolygon),
+	}
+}
+
+func (r *MemoryRegionResolver) ResolveRegion(entity *models.OSMEntity) (models.FlatPolygon, error) {
+	if entity == nil {
+		return nil, errors.New("entity is nil")
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	polygon, ok := r.regions[entity.OSMID]
+	if !ok {
+		return nil, models.ErrEntityNotFound
+	}
+	return polygon, nil
+}
+
+func (r *MemoryRegionResolver) SaveToCache(osmID int, polygon models.FlatPolygon) error {
+	if polygon == nil {
+		return errors.New("nil polygon")
+	}
+	r.mu.Lock()
+	r.regions[osmID] = polygon
+	r.mu.Unlock()
+	return nil
+}
+
+// Load reads regions from a JSON object that maps OSM ids to arrays of [lat, lon] pairs.
+func (r *MemoryRegionResolver) Load(reader io.Reader) error {
+	raw := make(map[string][][2]float64)
+	if err := json.NewDecoder(reader).Decode(&raw); err != nil {
+		return err
+	}
+	for rawID, points := range raw {
+		osmID, err := strconv.Atoi(rawID)
+		if err != nil {
+			return err
+		}
+		polygon := make(models.FlatPolygon, len(points))
+		for i, p := range points {
+			polygon[i] = elastic.GeoPointFromLatLon(p[0], p[1])
+		}
+		if err := r.SaveToCache(osmID, polygon); err != nil {
+			return err
+		}
+	}
+	return nil
+}
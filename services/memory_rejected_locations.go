@@ -0,0 +1,67 @@
+package services
+
+import (
+	"github.com/TeamD2018/geo-rest/models"
+	"github.com/satori/go.uuid"
+	"sort"
+	"sync"
+)
+
+type MemoryRejectedLocationsLog struct {
+	mu       sync.RWMutex
+	rejected []*models.RejectedLocation
+}
+
+func NewMemoryRejectedLocationsLog() *MemoryRejectedLocationsLog {
+	return &MemoryRejectedLocationsLog{
+		rejected: make([]*models.RejectedLocation, 0),
+	}
+}
+
+func (m *MemoryRejectedLocationsLog) Record(rejected *models.RejectedLocation) error {
+	rejected.ID = uuid.NewV4().String()
+	m.mu.Lock()
+	m.rejected = append(m.rejected, copyRejectedLocation(rejected))
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *MemoryRejectedLocationsLog) Find(query *models.RejectedLocationQuery) (models.RejectedLocations, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	found := make(models.RejectedLocations, 0)
+	for _, rejected := range m.rejected {
+		if query.Matches(rejected) {
+			found = append(found, copyRejectedLocation(rejected))
+		}
+	}
+	return limitRejectedLocations(found, query.Limit), nil
+}
+
+// limitRejectedLocations orders records given in recording order latest first and keeps at most limit of them.
+func limitRejectedLocations(rejected models.RejectedLocations, limit int) models.RejectedLocations {
+	for i, j := 0, len(rejected)-1; i < j; i, j = i+1, j-1 {
+		rejected[i], rejected[j] = rejected[j], rejected[i]
+	}
+	sort.SliceStable(rejected, func(i, j int) bool {
+		return rejected[i].RejectedAt > rejected[j].RejectedAt
+	})
+	if limit <= 0 {
+		limit = DefaultRejectedLocationsReturnSize
+	}
+	if len(rejected) > limit {
+		rejected = rejected[:limit]
+	}
+	return rejected
+}
+
+func copyRejectedLocation(rejected *models.RejectedLocation) *models.RejectedLocation {
+	copied := *rejected
+	if rejected.Point != nil {
+		copied.Point = copyPointWithTs(rejected.Point)
+	}
+	if rejected.Previous != nil {
+		copied.Previous = copyPointWithTs(rejected.Previous)
+	}
+	return &copied
+}
@@ -0,0 +1,62 @@
+package services
+
+import (
+	"context"
+	"github.com/TeamD2018/geo-rest/models"
+	"github.com/olivere/elastic"
+	"github.com/pkg/errors"
+	"sync"
+)
+
+// MemoryResolver is a geocoding cache without an upstream geocoder.
+// Locations that arrive with both a point and an address are remembered and used to resolve later requests.
+type MemoryResolver struct {
+	mu        sync.RWMutex
+	byAddress map[string]elastic.GeoPoint
+	byPoint   map[elastic.GeoPoint]string
+}
+
+func NewMemoryResolver() *MemoryResolver {
+	return &MemoryResolver{
+		byAddress: make(map[string]elastic.GeoPoint),
+		byPoint:   make(map[elastic.GeoPoint]string),
+	}
+}
+
+func (r *MemoryResolver) Resolve(location *models.Location, ctx context.Context) error {
+	if location == nil {
+		return errors.New("location is nil")
+	}
+	if location.Point != nil && location.Address != nil {
+		return r.SaveToCache(location)
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if location.Address != nil {
+		point, ok := r.byAddress[*location.Address]
+		if !ok {
+			return models.ErrEntityNotFound
+		}
+		location.Point = elastic.GeoPointFromLatLon(point.Lat, point.Lon)
+		return nil
+	}
+	if location.Point != nil {
+		address, ok := r.byPoint[*location.Point]
+		if !ok {
+			return models.ErrEntityNotFound
+		}
+		location.Address = &address
+	}
+	return nil
+}
+
+func (r *MemoryResolver) SaveToCache(location *models.Location) error {
+	if location == nil || location.Point == nil || location.Address == nil {
+		return errors.New("location is incomplete")
+	}
+	r.mu.Lock()
+	r.byAddress[*location.Address] = *location.Point
+	r.byPoint[*location.Point] = *location.Address
+	r.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,90 @@
+package services
+
+import (
+	"errors"
+	"github.com/TeamD2018/geo-rest/models"
+	"github.com/olivere/elastic"
+	"go.uber.org/zap"
+	"sort"
+	"sync"
+)
+
+var ErrInvalidRoutePoint = errors.New("lat or lon have invalid format (-90 < lat < 90, -180 < lon < 180)")
+
+var ErrInvalidFix = errors.New("accuracy, speed, bearing or provider have invalid value")
+
+type MemoryRouteDAO struct {
+	mu     sync.RWMutex
+	routes map[string][]memoryRoutePoint
+	seq    uint64
+	l      *zap.Logger
+}
+
+type memoryRoutePoint struct {
+	seq   uint64
+	point *models.PointWithTs
+}
+
+func NewMemoryRouteDAO(logger *zap.Logger) *MemoryRouteDAO {
+	return &MemoryRouteDAO{
+		routes: make(map[string][]memoryRoutePoint),
+		l:      logger,
+	}
+}
+
+func (m *MemoryRouteDAO) CreateCourier(courierID string) error {
+	m.mu.Lock()
+	m.routes[courierID] = []memoryRoutePoint{}
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *MemoryRouteDAO) AddPointToRoute(courierID string, point *models.PointWithTs) error {
+	if point == nil || point.Point == nil {
+		return errors.New("lat or lon not found")
+	}
+	if point.Point.Lat < -90 || point.Point.Lat > 90 || point.Point.Lon < -180 || point.Point.Lon > 180 {
+		return ErrInvalidRoutePoint
+	}
+	if !point.Fix.Valid() {
+		return ErrInvalidFix
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.seq++
+	route := m.routes[courierID]
+	i := sort.Search(len(route), func(i int) bool {
+		return route[i].point.Ts > point.Ts
+	})
+	route = append(route, memoryRoutePoint{})
+	copy(route[i+1:], route[i:])
+	route[i] = memoryRoutePoint{seq: m.seq, point: copyPointWithTs(point)}
+	m.routes[courierID] = route
+	return nil
+}
+
+func (m *MemoryRouteDAO) DeleteCourier(courierID string) error {
+	m.mu.Lock()
+	delete(m.routes, courierID)
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *MemoryRouteDAO) GetRoute(courierID string, query *models.RouteQuery) (*models.RoutePage, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	builder := newRoutePageBuilder(query)
+	for _, p := range m.routes[courierID] {
+		if !builder.add(copyPointWithTs(p.point), p.seq) {
+			break
+		}
+	}
+	return builder.page, nil
+}
+
+func copyPointWithTs(point *models.PointWithTs) *models.PointWithTs {
+	copied := *point
+	copied.Point = elastic.GeoPointFromLatLon(point.Point.Lat, point.Point.Lon)
+	copied.Fix = point.Fix.Copy()
+	return &copied
+}
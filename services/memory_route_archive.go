@@ -0,0 +1,65 @@
+package services
+
+import (
+	"github.com/TeamD2018/geo-rest/models"
+	"github.com/satori/go.uuid"
+	"sort"
+	"sync"
+)
+
+type MemoryRouteArchive struct {
+	mu     sync.RWMutex
+	routes map[string]*models.ArchivedRoute
+}
+
+func NewMemoryRouteArchive() *MemoryRouteArchive {
+	return &MemoryRouteArchive{
+		routes: make(map[string]*models.ArchivedRoute),
+	}
+}
+
+func (m *MemoryRouteArchive) Save(route *models.ArchivedRoute) error {
+	route.ID = uuid.NewV4().String()
+	m.mu.Lock()
+	m.routes[route.ID] = copyArchivedRoute(route)
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *MemoryRouteArchive) Find(query *models.RouteArchiveQuery) (models.ArchivedRoutes, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	routes := make(models.ArchivedRoutes, 0)
+	for _, route := range m.routes {
+		if query.Matches(route) {
+			routes = append(routes, copyArchivedRoute(route))
+		}
+	}
+	return limitArchivedRoutes(routes, query.Limit), nil
+}
+
+// limitArchivedRoutes orders routes latest first and keeps at most limit of them.
+func limitArchivedRoutes(routes models.ArchivedRoutes, limit int) models.ArchivedRoutes {
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].StartedAt != routes[j].StartedAt {
+			return routes[i].StartedAt > routes[j].StartedAt
+		}
+		return routes[i].ID < routes[j].ID
+	})
+	if limit <= 0 {
+		limit = DefaultRouteArchiveReturnSize
+	}
+	if len(routes) > limit {
+		routes = routes[:limit]
+	}
+	return routes
+}
+
+func copyArchivedRoute(route *models.ArchivedRoute) *models.ArchivedRoute {
+	copied := *route
+	copied.GeoHistory = make(models.Points, 0, len(route.GeoHistory))
+	for _, p := range route.GeoHistory {
+		copied.GeoHistory = append(copied.GeoHistory, copyPointWithTs(p))
+	}
+	return &copied
+}
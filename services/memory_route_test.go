@@ -0,0 +1,40 @@
+package services
+
+import (
+	"github.com/TeamD2018/geo-rest/models"
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+type MemoryRouteTestSuite struct {
+	suite.Suite
+	tracker *MemoryOrdersCountTracker
+}
+
+func (s *MemoryRouteTestSuite) BeforeTest(suiteName, testName string) {
+	s.tracker = NewMemoryOrdersCountTracker()
+}
+
+func (s *MemoryRouteTestSuite) TestOrdersCountTracker() {
+	count, err := s.tracker.IncAndGet("courier")
+	s.NoError(err)
+	s.Equal(1, count)
+	s.NoError(s.tracker.Inc("courier"))
+	count, err = s.tracker.DecAndGet("courier")
+	s.NoError(err)
+	s.Equal(1, count)
+
+	couriers := models.Couriers{{ID: "courier"}, {ID: "unknown"}}
+	s.NoError(s.tracker.Sync(couriers))
+	s.Equal(1, couriers[0].OrdersCount)
+	s.Equal(0, couriers[1].OrdersCount)
+
+	s.NoError(s.tracker.Drop("courier"))
+	count, err = s.tracker.DecAndGet("courier")
+	s.NoError(err)
+	s.Equal(0, count)
+}
+
+func TestUnitMemoryRoute(t *testing.T) {
+	suite.Run(t, new(MemoryRouteTestSuite))
+}
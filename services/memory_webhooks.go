@@ -0,0 +1,148 @@
+package services
+
+import (
+	"github.com/TeamD2018/geo-rest/models"
+	"github.com/satori/go.uuid"
+	"sort"
+	"sync"
+)
+
+type MemoryWebhookStore struct {
+	mu            sync.RWMutex
+	subscriptions map[string]*models.WebhookSubscription
+	deadLetters   map[string][]*models.WebhookDeadLetter
+}
+
+func NewMemoryWebhookStore() *MemoryWebhookStore {
+	return &MemoryWebhookStore{
+		subscriptions: make(map[string]*models.WebhookSubscription),
+		deadLetters:   make(map[string][]*models.WebhookDeadLetter),
+	}
+}
+
+func (m *MemoryWebhookStore) Create(subscription *models.WebhookSubscription) error {
+	subscription.ID = uuid.NewV4().String()
+	m.mu.Lock()
+	m.subscriptions[subscription.ID] = copyWebhookSubscription(subscription)
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *MemoryWebhookStore) Get(subscriptionID string) (*models.WebhookSubscription, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	subscription, ok := m.subscriptions[subscriptionID]
+	if !ok {
+		return nil, models.ErrEntityNotFound.SetParameter(subscriptionID)
+	}
+	return copyWebhookSubscription(subscription), nil
+}
+
+func (m *MemoryWebhookStore) Delete(subscriptionID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.subscriptions[subscriptionID]; !ok {
+		return models.ErrEntityNotFound.SetParameter(subscriptionID)
+	}
+	delete(m.subscriptions, subscriptionID)
+	delete(m.deadLetters, subscriptionID)
+	return nil
+}
+
+func (m *MemoryWebhookStore) List() (models.WebhookSubscriptions, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	subscriptions := make(models.WebhookSubscriptions, 0, len(m.subscriptions))
+	for _, subscription := range m.subscriptions {
+		subscriptions = append(subscriptions, copyWebhookSubscription(subscription))
+	}
+	sortWebhookSubscriptions(subscriptions)
+	return subscriptions, nil
+}
+
+func (m *MemoryWebhookStore) AddDeadLetter(deadLetter *models.WebhookDeadLetter) error {
+	deadLetter.ID = uuid.NewV4().String()
+	m.mu.Lock()
+	m.deadLetters[deadLetter.SubscriptionID] = append(m.deadLetters[deadLetter.SubscriptionID], copyWebhookDeadLetter(deadLetter))
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *MemoryWebhookStore) GetDeadLetter(subscriptionID, deadLetterID string) (*models.WebhookDeadLetter, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, deadLetter := range m.deadLetters[subscriptionID] {
+		if deadLetter.ID == deadLetterID {
+			return copyWebhookDeadLetter(deadLetter), nil
+		}
+	}
+	return nil, models.ErrEntityNotFound.SetParameter(deadLetterID)
+}
+
+func (m *MemoryWebhookStore) DeleteDeadLetter(subscriptionID, deadLetterID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	deadLetters := m.deadLetters[subscriptionID]
+	for i, deadLetter := range deadLetters {
+		if deadLetter.ID == deadLetterID {
+			m.deadLetters[subscriptionID] = append(deadLetters[:i:i], deadLetters[i+1:]...)
+			return nil
+		}
+	}
+	return models.ErrEntityNotFound.SetParameter(deadLetterID)
+}
+
+func (m *MemoryWebhookStore) DeadLetters(subscriptionID string, limit int) (models.WebhookDeadLetters, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	found := make(models.WebhookDeadLetters, 0, len(m.deadLetters[subscriptionID]))
+	for _, deadLetter := range m.deadLetters[subscriptionID] {
+		found = append(found, copyWebhookDeadLetter(deadLetter))
+	}
+	return limitWebhookDeadLetters(found, limit), nil
+}
+
+// sortWebhookSubscriptions orders subscriptions by creation time, then by ID.
+func sortWebhookSubscriptions(subscriptions models.WebhookSubscriptions) {
+	sort.Slice(subscriptions, func(i, j int) bool {
+		if subscriptions[i].CreatedAt != subscriptions[j].CreatedAt {
+			return subscriptions[i].CreatedAt < subscriptions[j].CreatedAt
+		}
+		return subscriptions[i].ID < subscriptions[j].ID
+	})
+}
+
+// limitWebhookDeadLetters orders dead letters given in recording order latest first and keeps at most limit of them.
+func limitWebhookDeadLetters(deadLetters models.WebhookDeadLetters, limit int) models.WebhookDeadLetters {
+	for i, j := 0, len(deadLetters)-1; i < j; i, j = i+1, j-1 {
+		deadLetters[i], deadLetters[j] = deadLetters[j], deadLetters[i]
+	}
+	sort.SliceStable(deadLetters, func(i, j int) bool {
+		return deadLetters[i].FailedAt > deadLetters[j].FailedAt
+	})
+	if limit <= 0 {
+		limit = DefaultWebhookDeadLettersReturnSize
+	}
+	if len(deadLetters) > limit {
+		deadLetters = deadLetters[:limit]
+	}
+	return deadLetters
+}
+
+func copyWebhookSubscription(subscription *models.WebhookSubscription) *models.WebhookSubscription {
+	copied := *subscription
+	if subscription.EventTypes != nil {
+		copied.EventTypes = append([]string{}, subscription.EventTypes...)
+	}
+	return &copied
+}
+
+func copyWebhookDeadLetter(deadLetter *models.WebhookDeadLetter) *models.WebhookDeadLetter {
+	copied := *deadLetter
+	if deadLetter.Event != nil {
+		event := *deadLetter.Event
+		event.Data = append([]byte{}, deadLetter.Event.Data...)
+		copied.Event = &event
+	}
+	return &copied
+}
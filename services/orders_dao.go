@@ -3,17 +3,19 @@ package services
 import (
 	"context"
 	"encoding/json"
-	"github.com/TeamD2018/geo-rest/controllers/parameters"
+	"fmt"
 	"github.com/TeamD2018/geo-rest/models"
 	"github.com/TeamD2018/geo-rest/services/interfaces"
 	"github.com/olivere/elastic"
 	"github.com/satori/go.uuid"
 	"go.uber.org/zap"
+	"math"
 	"strconv"
 	"time"
 )
 
 const OrdersIndex = "order"
+const DefaultOrdersReturnSize = 200
 
 type OrdersElasticDAO struct {
 	Elastic     *elastic.Client
@@ -64,20 +66,28 @@ func (od *OrdersElasticDAO) Get(orderID string) (*models.Order, error) {
 
 func (od *OrdersElasticDAO) Create(orderCreate *models.OrderCreate) (*models.Order, error) {
 	db := od.Elastic
-	exists, err := od.couriersDAO.Exists(*orderCreate.CourierID)
-	if err != nil {
-		return nil, err
+	courierID := ""
+	if orderCreate.CourierID != nil {
+		courierID = *orderCreate.CourierID
 	}
-	if !exists {
-		return nil, models.ErrEntityNotFound.SetParameter(*orderCreate.CourierID)
+	// orders without a courier are unassigned until claimed
+	if courierID != "" {
+		exists, err := od.couriersDAO.Exists(courierID)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, models.ErrEntityNotFound.SetParameter(courierID)
+		}
 	}
 	var order orderWrapper
 	order.Source = orderCreate.Source
 	order.Destination = orderCreate.Destination
-	order.CourierID = *orderCreate.CourierID
+	order.CourierID = courierID
 	order.OrderNumber = orderCreate.OrderNumber
 	order.CreatedAt = time.Now().Unix()
 	order.Suggestions = elastic.NewSuggestField(strconv.Itoa(order.OrderNumber))
+	order.StartStatus()
 	id := uuid.NewV4().String()
 	ret, err := db.Index().
 		Index(od.index).
@@ -124,6 +134,192 @@ func (od *OrdersElasticDAO) Update(update *models.OrderUpdate) (*models.Order, e
 	return &order, nil
 }
 
+// claimOrderScript assigns the order only while it is unassigned, any other order is left untouched.
+const claimOrderScript = `if (ctx._source.status == params.unassigned) {
+  ctx._source.courier_id = params.courier_id;
+  ctx._source.status = params.assigned;
+  if (ctx._source.status_history == null) {
+    ctx._source.status_history = [];
+  }
+  ctx._source.status_history.add(params.change);
+} else {
+  ctx.op = 'none';
+}`
+
+func (od *OrdersElasticDAO) Claim(orderID, courierID string) (*models.Order, error) {
+	exists, err := od.couriersDAO.Exists(courierID)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, models.ErrEntityNotFound.SetParameter(courierID)
+	}
+	change := &models.OrderStatusChange{
+		From:   models.OrderUnassigned,
+		To:     models.OrderAssigned,
+		At:     time.Now().Unix(),
+		Actor:  courierID,
+		Reason: models.OrderClaimReason,
+	}
+	script := elastic.NewScript(claimOrderScript).Params(map[string]interface{}{
+		"unassigned": models.OrderUnassigned,
+		"assigned":   models.OrderAssigned,
+		"courier_id": courierID,
+		"change":     change,
+	})
+	// the script runs against the latest version of the order, so concurrent claims can not both see it unassigned
+	res, err := od.Elastic.Update().
+		Index(od.index).
+		Type("_doc").
+		Id(orderID).
+		Script(script).
+		RetryOnConflict(3).
+		FetchSource(true).
+		Do(context.Background())
+	if err != nil {
+		if elastic.IsNotFound(err) {
+			return nil, models.ErrEntityNotFound.SetParameter(orderID)
+		}
+		return nil, err
+	}
+	if res.Result == "noop" {
+		return nil, models.ErrOrderAlreadyClaimed.SetParameter(orderID)
+	}
+	var order models.Order
+	if err := json.Unmarshal(*res.GetResult.Source, &order); err != nil {
+		return nil, err
+	}
+	order.ID = res.Id
+	return &order, nil
+}
+
+// reassignOrderScript hands the order over only while it is active and carried by another courier.
+// Orders created before statuses were stored are active until delivered.
+const reassignOrderScript = `String status = ctx._source.status;
+if (status == null) {
+  status = ctx._source.delivered_at == null || ctx._source.delivered_at == 0 ? params.assigned : params.delivered;
+}
+if (params.active.contains(status) && ctx._source.courier_id != params.courier_id) {
+  params.handover.from = ctx._source.courier_id;
+  if (ctx._source.handovers == null) {
+    ctx._source.handovers = [];
+  }
+  ctx._source.handovers.add(params.handover);
+  ctx._source.courier_id = params.courier_id;
+} else {
+  ctx.op = 'none';
+}`
+
+func (od *OrdersElasticDAO) Reassign(orderID, courierID, reason string) (*models.Order, error) {
+	exists, err := od.couriersDAO.Exists(courierID)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, models.ErrEntityNotFound.SetParameter(courierID)
+	}
+	handover := map[string]interface{}{
+		"to":     courierID,
+		"at":     time.Now().Unix(),
+		"reason": reason,
+	}
+	script := elastic.NewScript(reassignOrderScript).Params(map[string]interface{}{
+		"active":     models.ActiveOrderStatuses(),
+		"assigned":   models.OrderAssigned,
+		"delivered":  models.OrderDelivered,
+		"courier_id": courierID,
+		"handover":   handover,
+	})
+	// the previous courier is read by the script, so concurrent handovers can not both move the order away from it
+	res, err := od.Elastic.Update().
+		Index(od.index).
+		Type("_doc").
+		Id(orderID).
+		Script(script).
+		RetryOnConflict(3).
+		FetchSource(true).
+		Do(context.Background())
+	if err != nil {
+		if elastic.IsNotFound(err) {
+			return nil, models.ErrEntityNotFound.SetParameter(orderID)
+		}
+		return nil, err
+	}
+	if res.Result == "noop" {
+		return nil, models.ErrOrderNotReassignable.SetParameter(orderID)
+	}
+	var order models.Order
+	if err := json.Unmarshal(*res.GetResult.Source, &order); err != nil {
+		return nil, err
+	}
+	order.ID = res.Id
+	return &order, nil
+}
+
+// transitionOrderScript moves an order of the courier only if the state machine allows it from the stored status,
+// any other order is left untouched. Orders created before statuses were stored are active until delivered.
+const transitionOrderScript = `String status = ctx._source.status;
+if (status == null) {
+  status = ctx._source.delivered_at == null || ctx._source.delivered_at == 0 ? params.assigned : params.delivered;
+}
+if (ctx._source.courier_id == params.courier_id && params.transitions.containsKey(status) &&
+    params.transitions[status].contains(params.change.to)) {
+  params.change.from = status;
+  ctx._source.status = params.change.to;
+  if (ctx._source.status_history == null) {
+    ctx._source.status_history = [];
+  }
+  ctx._source.status_history.add(params.change);
+  if (params.change.to == params.delivered && (ctx._source.delivered_at == null || ctx._source.delivered_at == 0)) {
+    ctx._source.delivered_at = params.change.at;
+  }
+} else {
+  ctx.op = 'none';
+}`
+
+func (od *OrdersElasticDAO) Transition(orderID, courierID string, change *models.OrderStatusChange) (*models.Order, error) {
+	script := elastic.NewScript(transitionOrderScript).Params(map[string]interface{}{
+		"transitions": models.OrderTransitions(),
+		"assigned":    models.OrderAssigned,
+		"delivered":   models.OrderDelivered,
+		"courier_id":  courierID,
+		"change": map[string]interface{}{
+			"to":     change.To,
+			"at":     change.At,
+			"actor":  change.Actor,
+			"reason": change.Reason,
+		},
+	})
+	// the status is checked by the script against the latest version of the order, so concurrent transitions
+	// can not both leave the same status
+	res, err := od.Elastic.Update().
+		Index(od.index).
+		Type("_doc").
+		Id(orderID).
+		Script(script).
+		RetryOnConflict(3).
+		FetchSource(true).
+		Do(context.Background())
+	if err != nil {
+		if elastic.IsNotFound(err) {
+			return nil, models.ErrEntityNotFound.SetParameter(orderID)
+		}
+		return nil, err
+	}
+	var order models.Order
+	if err := json.Unmarshal(*res.GetResult.Source, &order); err != nil {
+		return nil, err
+	}
+	order.ID = res.Id
+	if res.Result == "noop" {
+		if order.CourierID != courierID {
+			return nil, models.ErrEntityNotFound.SetParameter(orderID)
+		}
+		return nil, models.ErrInvalidOrderTransition.SetParameter(change.To)
+	}
+	return &order, nil
+}
+
 func (od *OrdersElasticDAO) Delete(orderID string) error {
 	db := od.Elastic
 	_, err := db.Delete().
@@ -156,58 +352,204 @@ func (od *OrdersElasticDAO) DeleteOrdersForCourier(courierID string) error {
 	return nil
 }
 
-func (od *OrdersElasticDAO) GetOrdersForCourier(
-	courierID string,
-	since int64,
-	isLowerThreshold parameters.DirectionFlag,
-	excludeDelivered parameters.DeliveredFlag) (models.Orders, error) {
-
-	db := od.Elastic
+func (od *OrdersElasticDAO) GetOrdersForCourier(courierID string, query *models.OrdersQuery) (*models.OrdersPage, error) {
+	if query == nil {
+		query = &models.OrdersQuery{}
+	}
 	courierIDQuery := elastic.NewTermQuery("courier_id", courierID)
 	var sinceRangeQuery elastic.Query
-	if isLowerThreshold {
-		sinceRangeQuery = elastic.NewRangeQuery("created_at").Gte(since).IncludeUpper(false)
+	if query.SinceIsLower {
+		sinceRangeQuery = elastic.NewRangeQuery("created_at").Gte(query.Since).IncludeUpper(false)
 	} else {
-		sinceRangeQuery = elastic.NewRangeQuery("created_at").Lte(since).IncludeLower(false)
+		sinceRangeQuery = elastic.NewRangeQuery("created_at").Lte(query.Since).IncludeLower(false)
 	}
 	ordersQuery := elastic.NewBoolQuery().Filter(courierIDQuery, sinceRangeQuery)
-	if excludeDelivered {
+	if query.ExcludeDelivered {
 		ordersQuery = ordersQuery.MustNot(elastic.NewExistsQuery("delivered_at"))
 	}
-	res, err := db.Search(od.index).Type("_doc").Query(ordersQuery).Do(context.Background())
+	if len(query.Statuses) > 0 {
+		ordersQuery = ordersQuery.Filter(orderStatusQuery(query.Statuses))
+	}
+	if query.Limit > 0 {
+		return od.ordersPage(ordersQuery, query, query.After, query.Limit)
+	}
+	// without a limit every order is read page by page, a single search returns at most its size
+	page := &models.OrdersPage{Orders: models.Orders{}}
+	after := query.After
+	for {
+		next, err := od.ordersPage(ordersQuery, query, after, DefaultOrdersReturnSize)
+		if err != nil {
+			return nil, err
+		}
+		page.Orders = append(page.Orders, next.Orders...)
+		page.Total = next.Total
+		if next.Next == nil {
+			return page, nil
+		}
+		// a cursor that does not move would read the same page forever
+		if after != nil && next.Next.Value == after.Value && next.Next.ID == after.ID {
+			return nil, fmt.Errorf("orders of courier %s: paging did not move past %s", courierID, after)
+		}
+		after = next.Next
+	}
+}
+
+// ordersPage reads up to size orders after the cursor, one more order is requested to tell whether the page is the last.
+func (od *OrdersElasticDAO) ordersPage(ordersQuery elastic.Query, query *models.OrdersQuery,
+	after *models.OrdersCursor, size int) (*models.OrdersPage, error) {
+	asc := !query.Desc
+	var sorter elastic.Sorter
+	switch query.Sort {
+	case models.OrdersSortOrderNumber:
+		sorter = elastic.NewFieldSort("order_number").Order(asc)
+	case models.OrdersSortDistance:
+		sorter = elastic.NewGeoDistanceSort("destination.point").
+			Point(query.From.Lat, query.From.Lon).
+			Unit("m").
+			Order(asc)
+	default:
+		sorter = elastic.NewFieldSort("created_at").Order(asc)
+	}
+	search := od.Elastic.Search(od.index).
+		Type("_doc").
+		Query(ordersQuery).
+		SortBy(sorter, elastic.NewFieldSort("_id").Order(asc)).
+		Size(size + 1)
+	if after != nil {
+		search = search.SearchAfter(ordersSearchAfter(after)...)
+	}
+	res, err := search.Do(context.Background())
 	if err != nil {
 		return nil, err
 	}
-	orders := make(models.Orders, 0, res.Hits.TotalHits)
-	for _, hit := range res.Hits.Hits {
+	page := &models.OrdersPage{Orders: make(models.Orders, 0, len(res.Hits.Hits)), Total: res.Hits.TotalHits}
+	for i, hit := range res.Hits.Hits {
+		if i == size {
+			last := res.Hits.Hits[i-1]
+			page.Next = &models.OrdersCursor{Value: orderSortValue(last.Sort), ID: last.Id, Raw: last.Sort}
+			break
+		}
 		var order models.Order
 		if err := json.Unmarshal(*hit.Source, &order); err != nil {
 			return nil, err
 		}
 		order.ID = hit.Id
-		orders = append(orders, &order)
+		page.Orders = append(page.Orders, &order)
 	}
-	return orders, nil
+	return page, nil
+}
 
+// orderSortValue reads the first sort value of a hit. Orders without a destination are sorted by an infinite distance,
+// which may come back as a string.
+func orderSortValue(sort []interface{}) float64 {
+	if len(sort) > 0 {
+		switch value := sort[0].(type) {
+		case float64:
+			return value
+		case json.Number:
+			if parsed, err := value.Float64(); err == nil {
+				return parsed
+			}
+		case string:
+			if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+				return parsed
+			}
+		}
+	}
+	return math.Inf(1)
 }
 
-func (od *OrdersElasticDAO) EnsureMapping() error {
-	indexName, mapping := od.GetMapping()
+// ordersSearchAfter returns the search_after values of the cursor: the sort values of the hit when the cursor comes
+// from a search, the cursor value and id otherwise. Infinite values, which JSON has no number for, are sent as strings.
+func ordersSearchAfter(cursor *models.OrdersCursor) []interface{} {
+	if len(cursor.Raw) > 0 {
+		return cursor.Raw
+	}
+	var value interface{} = cursor.Value
+	switch {
+	case math.IsInf(cursor.Value, 1):
+		value = "Infinity"
+	case math.IsInf(cursor.Value, -1):
+		value = "-Infinity"
+	}
+	return []interface{}{value, cursor.ID}
+}
 
-	ctx := context.Background()
-	exists, err := od.Elastic.IndexExists(indexName).Do(ctx)
-	if err != nil {
-		return err
+func (od *OrdersElasticDAO) GetByBoxField(field *models.BoxField, search *models.OrderSearch) (models.Orders, error) {
+	boundingboxQuery := elastic.NewGeoBoundingBoxQuery(orderPointField(search)).
+		TopLeftFromGeoPoint(field.TopLeftPoint).
+		BottomRightFromGeoPoint(field.BottomRightPoint)
+	return od.search(boundingboxQuery, search)
+}
+
+func (od *OrdersElasticDAO) GetByCircleField(field *models.CircleField, search *models.OrderSearch) (models.Orders, error) {
+	geodistanceQuery := elastic.NewGeoDistanceQuery(orderPointField(search)).
+		GeoPoint(field.Center).
+		Distance(fmt.Sprintf("%dm", field.Radius))
+	return od.search(geodistanceQuery, search)
+}
+
+func (od *OrdersElasticDAO) GetByPolygon(polygon models.FlatPolygon, search *models.OrderSearch) (models.Orders, error) {
+	polygonQuery := elastic.NewGeoPolygonQuery(orderPointField(search))
+	for _, p := range polygon {
+		polygonQuery.AddGeoPoint(p)
 	}
+	return od.search(polygonQuery, search)
+}
 
-	if !exists {
-		_, err := od.Elastic.CreateIndex(indexName).BodyString(mapping).Do(ctx)
-		if err != nil {
-			return err
+func (od *OrdersElasticDAO) search(geoQuery elastic.Query, search *models.OrderSearch) (models.Orders, error) {
+	query := elastic.NewBoolQuery().Filter(geoQuery)
+	if search.CourierID != "" {
+		query = query.Filter(elastic.NewTermQuery("courier_id", search.CourierID))
+	}
+	if search.Since != 0 || search.Until != 0 {
+		createdAt := elastic.NewRangeQuery("created_at")
+		if search.Since != 0 {
+			createdAt = createdAt.Gte(search.Since)
 		}
+		if search.Until != 0 {
+			createdAt = createdAt.Lte(search.Until)
+		}
+		query = query.Filter(createdAt)
+	}
+	if len(search.Statuses) > 0 {
+		query = query.Filter(orderStatusQuery(search.Statuses))
 	}
+	size := search.Size
+	if size <= 0 {
+		size = DefaultOrdersReturnSize
+	}
+	res, err := od.Elastic.Search(od.index).
+		Type("_doc").
+		Size(size).
+		Sort("created_at", true).
+		Query(query).
+		Do(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	orders := make(models.Orders, 0, len(res.Hits.Hits))
+	for _, hit := range res.Hits.Hits {
+		var order models.Order
+		if err := json.Unmarshal(*hit.Source, &order); err != nil {
+			return nil, err
+		}
+		order.ID = hit.Id
+		orders = append(orders, &order)
+	}
+	return orders, nil
+}
 
-	return nil
+func orderPointField(search *models.OrderSearch) string {
+	if search.Point == models.OrderPointSource {
+		return "source.point"
+	}
+	return "destination.point"
+}
+
+func (od *OrdersElasticDAO) EnsureMapping() error {
+	indexName, mapping := od.GetMapping()
+	return ensureIndex(od.Elastic, indexName, mapping)
 }
 
 func (od *OrdersElasticDAO) GetIndex() string {
@@ -304,6 +646,29 @@ func (od *OrdersElasticDAO) GetMapping() (indexName string, mapping string) {
         },
         "delivered_at": {
           "type": "long"
+        },
+        "reported_delivered_at": {
+          "type": "long"
+        },
+        "status": {
+          "type": "keyword"
+        },
+        "status_history": {
+          "type": "object",
+          "enabled": false
+        },
+        "handovers": {
+          "type": "object",
+          "enabled": false
+        },
+        "arrived_at_pickup": {
+          "type": "long"
+        },
+        "picked_up": {
+          "type": "long"
+        },
+        "arrived_at_destination": {
+          "type": "long"
         },
 		"order_number": {
           "type": "integer"
@@ -321,6 +686,12 @@ func (od *OrdersElasticDAO) GetMapping() (indexName string, mapping string) {
               "type": "text",
               "analyzer": "autocomplete",
               "search_analyzer": "autocomplete_search"
+            },
+            "accuracy": {
+              "type": "float"
+            },
+            "provider": {
+              "type": "keyword"
             }
           }
         },
@@ -333,6 +704,12 @@ func (od *OrdersElasticDAO) GetMapping() (indexName string, mapping string) {
               "type": "text",
               "analyzer": "autocomplete",
               "search_analyzer": "autocomplete_search"
+            },
+            "accuracy": {
+              "type": "float"
+            },
+            "provider": {
+              "type": "keyword"
             }
           }
         }
@@ -342,6 +719,23 @@ func (od *OrdersElasticDAO) GetMapping() (indexName string, mapping string) {
 }`
 }
 
+// orderStatusQuery matches orders in one of statuses. Orders stored without a status match by delivered_at.
+func orderStatusQuery(statuses []string) elastic.Query {
+	terms := make([]interface{}, 0, len(statuses))
+	query := elastic.NewBoolQuery().MinimumNumberShouldMatch(1)
+	for _, status := range statuses {
+		terms = append(terms, status)
+		noStatus := elastic.NewBoolQuery().MustNot(elastic.NewExistsQuery("status"))
+		switch status {
+		case models.OrderAssigned:
+			query = query.Should(noStatus.MustNot(elastic.NewExistsQuery("delivered_at")))
+		case models.OrderDelivered:
+			query = query.Should(noStatus.Filter(elastic.NewExistsQuery("delivered_at")))
+		}
+	}
+	return query.Should(elastic.NewTermsQuery("status", terms...))
+}
+
 type orderWrapper struct {
 	Suggestions *elastic.SuggestField `json:"order_suggestions,omitempty"`
 	models.Order
@@ -0,0 +1,415 @@
+package services
+
+import (
+	"github.com/TeamD2018/geo-rest/models"
+	"github.com/TeamD2018/geo-rest/services/interfaces"
+	"github.com/olivere/elastic"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+	"testing"
+)
+
+// OrdersDAOBehaviourSuite checks order CRUD, paging of the orders of a courier, status transitions and their
+// check against the stored status, geo search, claiming, reassignment and deleting all orders of a courier.
+type OrdersDAOBehaviourSuite struct {
+	suite.Suite
+	newDAOs     func() (interfaces.ICouriersDAO, interfaces.IOrdersDao)
+	couriersDao interfaces.ICouriersDAO
+	ordersDao   interfaces.IOrdersDao
+	testCourier *models.Courier
+}
+
+func (s *OrdersDAOBehaviourSuite) BeforeTest(suiteName, testName string) {
+	s.couriersDao, s.ordersDao = s.newDAOs()
+	courier, err := s.couriersDao.Create(&models.CourierCreate{Name: "Test"})
+	s.Require().NoError(err)
+	s.testCourier = courier
+}
+
+func (s *OrdersDAOBehaviourSuite) create() *models.Order {
+	order, err := s.ordersDao.Create(&models.OrderCreate{
+		CourierID:   &s.testCourier.ID,
+		Destination: models.Location{Point: elastic.GeoPointFromLatLon(1, 1)},
+		Source:      models.Location{Point: elastic.GeoPointFromLatLon(2, 2)},
+	})
+	s.Require().NoError(err)
+	return order
+}
+
+func (s *OrdersDAOBehaviourSuite) TestCreateAndGet() {
+	order := s.create()
+	s.NotEmpty(order.ID)
+	s.NotZero(order.CreatedAt)
+	got, err := s.ordersDao.Get(order.ID)
+	if !s.NoError(err) {
+		return
+	}
+	s.Equal(order, got)
+}
+
+func (s *OrdersDAOBehaviourSuite) TestCreateForUnknownCourier() {
+	courierID := "550e8400-e29b-41d4-a716-446655440000"
+	_, err := s.ordersDao.Create(&models.OrderCreate{CourierID: &courierID})
+	s.Error(err)
+}
+
+func (s *OrdersDAOBehaviourSuite) TestUpdate() {
+	order := s.create()
+	address := "Moscow"
+	deliveredAt := order.CreatedAt + 10
+	updated, err := s.ordersDao.Update(&models.OrderUpdate{
+		ID:          &order.ID,
+		DeliveredAt: &deliveredAt,
+		Destination: &models.Location{Address: &address},
+	})
+	if !s.NoError(err) {
+		return
+	}
+	s.Equal(deliveredAt, updated.DeliveredAt)
+	s.Equal(address, *updated.Destination.Address)
+	s.Equal(order.Destination.Point, updated.Destination.Point)
+}
+
+func (s *OrdersDAOBehaviourSuite) TestUpdateNotFound() {
+	id := "660e8400-e29b-41d4-a716-446655440000"
+	deliveredAt := int64(1)
+	_, err := s.ordersDao.Update(&models.OrderUpdate{ID: &id, DeliveredAt: &deliveredAt})
+	s.Error(err)
+}
+
+func (s *OrdersDAOBehaviourSuite) TestDelete() {
+	order := s.create()
+	s.NoError(s.ordersDao.Delete(order.ID))
+	_, err := s.ordersDao.Get(order.ID)
+	s.Error(err)
+	s.Error(s.ordersDao.Delete(order.ID))
+}
+
+func (s *OrdersDAOBehaviourSuite) TestGetOrdersForCourier() {
+	first := s.create()
+	second := s.create()
+	deliveredAt := second.CreatedAt + 1
+	_, err := s.ordersDao.Update(&models.OrderUpdate{ID: &second.ID, DeliveredAt: &deliveredAt})
+	s.Require().NoError(err)
+
+	page, err := s.ordersDao.GetOrdersForCourier(s.testCourier.ID, &models.OrdersQuery{SinceIsLower: true})
+	if !s.NoError(err) {
+		return
+	}
+	s.Len(page.Orders, 2)
+
+	page, err = s.ordersDao.GetOrdersForCourier(s.testCourier.ID, &models.OrdersQuery{SinceIsLower: true, ExcludeDelivered: true})
+	if !s.NoError(err) {
+		return
+	}
+	if s.Len(page.Orders, 1) {
+		s.Equal(first.ID, page.Orders[0].ID)
+	}
+
+	page, err = s.ordersDao.GetOrdersForCourier(s.testCourier.ID, &models.OrdersQuery{Since: first.CreatedAt + 1, SinceIsLower: true})
+	if !s.NoError(err) {
+		return
+	}
+	s.Empty(page.Orders)
+
+	page, err = s.ordersDao.GetOrdersForCourier(s.testCourier.ID, &models.OrdersQuery{Since: first.CreatedAt + 1})
+	if !s.NoError(err) {
+		return
+	}
+	s.Len(page.Orders, 2)
+}
+
+func (s *OrdersDAOBehaviourSuite) TestGetOrdersForCourierPages() {
+	ids := make([]string, 0, 3)
+	for number := 1; number <= 3; number++ {
+		order, err := s.ordersDao.Create(&models.OrderCreate{
+			CourierID:   &s.testCourier.ID,
+			OrderNumber: number,
+			// the first order is the farthest from the origin
+			Destination: models.Location{Point: elastic.GeoPointFromLatLon(float64(4-number), 0)},
+		})
+		s.Require().NoError(err)
+		ids = append(ids, order.ID)
+	}
+
+	query := &models.OrdersQuery{SinceIsLower: true, Sort: models.OrdersSortOrderNumber, Desc: true, Limit: 2}
+	page, err := s.ordersDao.GetOrdersForCourier(s.testCourier.ID, query)
+	s.Require().NoError(err)
+	s.EqualValues(3, page.Total)
+	s.Require().Len(page.Orders, 2)
+	s.Equal(ids[2], page.Orders[0].ID)
+	s.Equal(ids[1], page.Orders[1].ID)
+	s.Require().NotNil(page.Next)
+
+	query.After = page.Next
+	page, err = s.ordersDao.GetOrdersForCourier(s.testCourier.ID, query)
+	s.Require().NoError(err)
+	s.EqualValues(3, page.Total)
+	if s.Len(page.Orders, 1) {
+		s.Equal(ids[0], page.Orders[0].ID)
+	}
+	s.Nil(page.Next)
+
+	page, err = s.ordersDao.GetOrdersForCourier(s.testCourier.ID, &models.OrdersQuery{
+		SinceIsLower: true,
+		Sort:         models.OrdersSortDistance,
+		From:         elastic.GeoPointFromLatLon(0, 0),
+	})
+	s.Require().NoError(err)
+	if s.Len(page.Orders, 3) {
+		s.Equal([]string{ids[2], ids[1], ids[0]}, []string{page.Orders[0].ID, page.Orders[1].ID, page.Orders[2].ID})
+	}
+}
+
+func (s *OrdersDAOBehaviourSuite) TestGetOrdersForCourierPagesPastUnknownDistances() {
+	near, err := s.ordersDao.Create(&models.OrderCreate{
+		CourierID:   &s.testCourier.ID,
+		Destination: models.Location{Point: elastic.GeoPointFromLatLon(1, 0)},
+	})
+	s.Require().NoError(err)
+	for i := 0; i < 3; i++ {
+		s.create()
+	}
+
+	query := &models.OrdersQuery{SinceIsLower: true, Sort: models.OrdersSortDistance, From: elastic.GeoPointFromLatLon(0, 0), Limit: 1}
+	seen := make(map[string]bool)
+	var first string
+	// cursors go through their string form like they do through the API
+	for pages := 0; pages < 10; pages++ {
+		page, err := s.ordersDao.GetOrdersForCourier(s.testCourier.ID, query)
+		s.Require().NoError(err)
+		s.Require().Len(page.Orders, 1)
+		if first == "" {
+			first = page.Orders[0].ID
+		}
+		seen[page.Orders[0].ID] = true
+		if page.Next == nil {
+			break
+		}
+		query.After, err = models.ParseOrdersCursor(page.Next.String())
+		s.Require().NoError(err)
+	}
+	s.Equal(near.ID, first)
+	s.Len(seen, 4)
+
+	query.Limit, query.After = 0, nil
+	page, err := s.ordersDao.GetOrdersForCourier(s.testCourier.ID, query)
+	s.Require().NoError(err)
+	s.Len(page.Orders, 4)
+}
+
+func (s *OrdersDAOBehaviourSuite) TestStatusTransitions() {
+	first := s.create()
+	second := s.create()
+	s.Equal(models.OrderAssigned, first.Status)
+	s.Len(first.StatusHistory, 1)
+
+	s.Require().NoError(second.ApplyTransition(models.OrderPickedUp, "courier", "", second.CreatedAt+1))
+	_, err := s.ordersDao.Update(second.StatusUpdate())
+	s.Require().NoError(err)
+	got, err := s.ordersDao.Get(second.ID)
+	s.Require().NoError(err)
+	s.Equal(models.OrderPickedUp, got.Status)
+	if s.Len(got.StatusHistory, 2) {
+		s.Equal(models.OrderStatusChange{From: models.OrderAssigned, To: models.OrderPickedUp, At: second.CreatedAt + 1, Actor: "courier"},
+			*got.StatusHistory[1])
+	}
+	s.Error(got.ApplyTransition(models.OrderAssigned, "courier", "", second.CreatedAt+2))
+
+	page, err := s.ordersDao.GetOrdersForCourier(s.testCourier.ID, &models.OrdersQuery{SinceIsLower: true, Statuses: []string{models.OrderPickedUp}})
+	s.Require().NoError(err)
+	if s.Len(page.Orders, 1) {
+		s.Equal(second.ID, page.Orders[0].ID)
+	}
+	page, err = s.ordersDao.GetOrdersForCourier(s.testCourier.ID, &models.OrdersQuery{SinceIsLower: true, Statuses: []string{models.OrderAssigned, models.OrderPickedUp}})
+	s.Require().NoError(err)
+	s.Len(page.Orders, 2)
+	page, err = s.ordersDao.GetOrdersForCourier(s.testCourier.ID, &models.OrdersQuery{SinceIsLower: true, Statuses: []string{models.OrderDelivered}})
+	s.Require().NoError(err)
+	s.Empty(page.Orders)
+}
+
+func (s *OrdersDAOBehaviourSuite) TestTransition() {
+	order := s.create()
+	delivery := &models.OrderStatusChange{To: models.OrderDelivered, At: order.CreatedAt + 1, Actor: "courier"}
+
+	delivered, err := s.ordersDao.Transition(order.ID, s.testCourier.ID, delivery)
+	s.Require().NoError(err)
+	s.Equal(models.OrderDelivered, delivered.Status)
+	s.Equal(order.CreatedAt+1, delivered.DeliveredAt)
+	s.Equal(order.CreatedAt+1, delivered.DeliveryTime())
+	if s.Len(delivered.StatusHistory, 2) {
+		s.Equal(models.OrderStatusChange{From: models.OrderAssigned, To: models.OrderDelivered, At: order.CreatedAt + 1, Actor: "courier"},
+			*delivered.StatusHistory[1])
+	}
+
+	// the second delivery sees the status left by the first one
+	_, err = s.ordersDao.Transition(order.ID, s.testCourier.ID, delivery)
+	s.Error(err)
+	got, err := s.ordersDao.Get(order.ID)
+	s.Require().NoError(err)
+	s.Len(got.StatusHistory, 2)
+
+	other := s.create()
+	_, err = s.ordersDao.Transition(other.ID, "770e8400-e29b-41d4-a716-446655440000", delivery)
+	s.Error(err)
+	got, err = s.ordersDao.Get(other.ID)
+	s.Require().NoError(err)
+	s.Equal(models.OrderAssigned, got.Status)
+
+	_, err = s.ordersDao.Transition("660e8400-e29b-41d4-a716-446655440000", s.testCourier.ID, delivery)
+	s.Error(err)
+}
+
+func (s *OrdersDAOBehaviourSuite) TestGeoSearch() {
+	near := s.create()
+	other, err := s.couriersDao.Create(&models.CourierCreate{Name: "Other"})
+	s.Require().NoError(err)
+	far, err := s.ordersDao.Create(&models.OrderCreate{
+		CourierID:   &other.ID,
+		Destination: models.Location{Point: elastic.GeoPointFromLatLon(1.001, 1.001)},
+		Source:      models.Location{Point: elastic.GeoPointFromLatLon(10, 10)},
+	})
+	s.Require().NoError(err)
+	box := &models.BoxField{
+		TopLeftPoint:     elastic.GeoPointFromLatLon(1.5, 0.5),
+		BottomRightPoint: elastic.GeoPointFromLatLon(0.5, 1.5),
+	}
+
+	orders, err := s.ordersDao.GetByBoxField(box, &models.OrderSearch{})
+	s.Require().NoError(err)
+	s.Len(orders, 2)
+
+	orders, err = s.ordersDao.GetByBoxField(box, &models.OrderSearch{CourierID: other.ID})
+	s.Require().NoError(err)
+	if s.Len(orders, 1) {
+		s.Equal(far.ID, orders[0].ID)
+	}
+
+	orders, err = s.ordersDao.GetByCircleField(&models.CircleField{Center: elastic.GeoPointFromLatLon(2, 2), Radius: 1000},
+		&models.OrderSearch{Point: models.OrderPointSource})
+	s.Require().NoError(err)
+	if s.Len(orders, 1) {
+		s.Equal(near.ID, orders[0].ID)
+	}
+
+	polygon := models.FlatPolygon{
+		elastic.GeoPointFromLatLon(0, 0),
+		elastic.GeoPointFromLatLon(0, 3),
+		elastic.GeoPointFromLatLon(3, 3),
+		elastic.GeoPointFromLatLon(3, 0),
+	}
+	orders, err = s.ordersDao.GetByPolygon(polygon, &models.OrderSearch{Point: models.OrderPointSource})
+	s.Require().NoError(err)
+	if s.Len(orders, 1) {
+		s.Equal(near.ID, orders[0].ID)
+	}
+
+	orders, err = s.ordersDao.GetByBoxField(box, &models.OrderSearch{Statuses: []string{models.OrderDelivered}})
+	s.Require().NoError(err)
+	s.Empty(orders)
+
+	orders, err = s.ordersDao.GetByBoxField(box, &models.OrderSearch{Until: near.CreatedAt - 1})
+	s.Require().NoError(err)
+	s.Empty(orders)
+
+	orders, err = s.ordersDao.GetByBoxField(box, &models.OrderSearch{Size: 1})
+	s.Require().NoError(err)
+	s.Len(orders, 1)
+}
+
+func (s *OrdersDAOBehaviourSuite) TestClaim() {
+	open, err := s.ordersDao.Create(&models.OrderCreate{
+		Destination: models.Location{Point: elastic.GeoPointFromLatLon(1, 1)},
+		Source:      models.Location{Point: elastic.GeoPointFromLatLon(2, 2)},
+	})
+	s.Require().NoError(err)
+	s.Equal(models.OrderUnassigned, open.Status)
+	s.Empty(open.CourierID)
+
+	orders, err := s.ordersDao.GetByCircleField(
+		&models.CircleField{Center: elastic.GeoPointFromLatLon(2, 2), Radius: 1000},
+		&models.OrderSearch{Point: models.OrderPointSource, Statuses: []string{models.OrderUnassigned}})
+	s.Require().NoError(err)
+	if s.Len(orders, 1) {
+		s.Equal(open.ID, orders[0].ID)
+	}
+
+	claimed, err := s.ordersDao.Claim(open.ID, s.testCourier.ID)
+	s.Require().NoError(err)
+	s.Equal(s.testCourier.ID, claimed.CourierID)
+	s.Equal(models.OrderAssigned, claimed.Status)
+	if s.Len(claimed.StatusHistory, 2) {
+		s.Equal(models.OrderClaimReason, claimed.StatusHistory[1].Reason)
+	}
+
+	other, err := s.couriersDao.Create(&models.CourierCreate{Name: "Other"})
+	s.Require().NoError(err)
+	_, err = s.ordersDao.Claim(open.ID, other.ID)
+	s.Equal(models.ErrOrderAlreadyClaimed.Code, err.(*models.Error).Code)
+
+	page, err := s.ordersDao.GetOrdersForCourier(s.testCourier.ID, &models.OrdersQuery{SinceIsLower: true})
+	s.Require().NoError(err)
+	if s.Len(page.Orders, 1) {
+		s.Equal(open.ID, page.Orders[0].ID)
+	}
+	page, err = s.ordersDao.GetOrdersForCourier(other.ID, &models.OrdersQuery{SinceIsLower: true})
+	s.Require().NoError(err)
+	s.Empty(page.Orders)
+}
+
+func (s *OrdersDAOBehaviourSuite) TestReassign() {
+	order := s.create()
+	other, err := s.couriersDao.Create(&models.CourierCreate{Name: "Other"})
+	s.Require().NoError(err)
+
+	reassigned, err := s.ordersDao.Reassign(order.ID, other.ID, "vehicle broke down")
+	s.Require().NoError(err)
+	s.Equal(other.ID, reassigned.CourierID)
+	s.Equal(models.OrderAssigned, reassigned.Status)
+	if s.Len(reassigned.Handovers, 1) {
+		handover := reassigned.Handovers[0]
+		s.Equal(s.testCourier.ID, handover.From)
+		s.Equal(other.ID, handover.To)
+		s.Equal("vehicle broke down", handover.Reason)
+		s.NotZero(handover.At)
+	}
+	got, err := s.ordersDao.Get(order.ID)
+	s.Require().NoError(err)
+	s.Equal(reassigned.Handovers, got.Handovers)
+
+	page, err := s.ordersDao.GetOrdersForCourier(other.ID, &models.OrdersQuery{SinceIsLower: true})
+	s.Require().NoError(err)
+	s.Len(page.Orders, 1)
+	page, err = s.ordersDao.GetOrdersForCourier(s.testCourier.ID, &models.OrdersQuery{SinceIsLower: true})
+	s.Require().NoError(err)
+	s.Empty(page.Orders)
+
+	_, err = s.ordersDao.Reassign(order.ID, other.ID, "")
+	s.Equal(models.ErrOrderNotReassignable.Code, err.(*models.Error).Code)
+	_, err = s.ordersDao.Reassign(order.ID, "unknown", "")
+	s.Error(err)
+
+	reassigned, err = s.ordersDao.Reassign(order.ID, s.testCourier.ID, "")
+	s.Require().NoError(err)
+	s.Len(reassigned.Handovers, 2)
+	s.Require().NoError(reassigned.ApplyTransition(models.OrderCancelled, "dispatcher", "", reassigned.CreatedAt+1))
+	_, err = s.ordersDao.Update(reassigned.StatusUpdate())
+	s.Require().NoError(err)
+	_, err = s.ordersDao.Reassign(order.ID, other.ID, "")
+	s.Equal(models.ErrOrderNotReassignable.Code, err.(*models.Error).Code)
+}
+
+func (s *OrdersDAOBehaviourSuite) TestDeleteOrdersForCourier() {
+	order := s.create()
+	s.NoError(s.ordersDao.DeleteOrdersForCourier(s.testCourier.ID))
+	_, err := s.ordersDao.Get(order.ID)
+	s.Error(err)
+}
+
+func TestUnitMemoryOrdersDAO(t *testing.T) {
+	suite.Run(t, &OrdersDAOBehaviourSuite{newDAOs: func() (interfaces.ICouriersDAO, interfaces.IOrdersDao) {
+		couriersDao := NewMemoryCouriersDAO(zap.NewNop(), DefaultCouriersReturnSize)
+		return couriersDao, NewMemoryOrdersDAO(zap.NewNop(), couriersDao)
+	}})
+}
@@ -6,7 +6,6 @@ import (
 	"context"
 	"fmt"
 	"github.com/TeamD2018/geo-rest/controllers/mocks"
-	"github.com/TeamD2018/geo-rest/controllers/parameters"
 	"github.com/TeamD2018/geo-rest/models"
 	"github.com/olivere/elastic"
 	"github.com/ory/dockertest"
@@ -104,36 +103,30 @@ func (s OrdersTestSuite) TestOrdersDao_Get() {
 }
 
 func (s OrdersTestSuite) TestOrdersElasticDAO_GetOrdersForCourier() {
-	orders, err := s.ordersDao.GetOrdersForCourier(s.testCourier.ID,
-		s.testOrder.CreatedAt,
-		parameters.WithUpperThreshold,
-		parameters.IncludeDelivered)
+	page, err := s.ordersDao.GetOrdersForCourier(s.testCourier.ID, &models.OrdersQuery{Since: s.testOrder.CreatedAt})
 	s.Assert().NoError(err)
-	if s.Assert().Len(orders, 1) {
-		s.Assert().Equal(orders[0].ID, s.testOrder.ID)
+	if s.Assert().Len(page.Orders, 1) {
+		s.Assert().Equal(page.Orders[0].ID, s.testOrder.ID)
 	}
 }
 
 func (s OrdersTestSuite) TestOrdersElasticDAO_GetOrdersForCourier_NoCourier() {
-	orders, err := s.ordersDao.GetOrdersForCourier("550e8400-e29b-41d4-a716-446655440000",
-		s.testOrder.CreatedAt,
-		parameters.WithUpperThreshold,
-		parameters.IncludeDelivered)
+	page, err := s.ordersDao.GetOrdersForCourier("550e8400-e29b-41d4-a716-446655440000", &models.OrdersQuery{Since: s.testOrder.CreatedAt})
 	s.Assert().NoError(err)
-	s.Assert().Empty(orders)
+	s.Assert().Empty(page.Orders)
 }
 
 func (s OrdersTestSuite) TestOrdersElasticDAO_GetOrdersForCourier_TimeThreshold() {
-	orders, err := s.ordersDao.GetOrdersForCourier(s.testCourier.ID, 0, parameters.WithUpperThreshold, parameters.IncludeDelivered)
+	page, err := s.ordersDao.GetOrdersForCourier(s.testCourier.ID, &models.OrdersQuery{})
 	s.Assert().NoError(err)
-	s.Assert().Empty(orders)
+	s.Assert().Empty(page.Orders)
 }
 
 func (s OrdersTestSuite) TestOrdersElasticDAO_GetOrdersForCourier_WithLowerThreshold() {
-	orders, err := s.ordersDao.GetOrdersForCourier(s.testCourier.ID, s.testOrder.CreatedAt-10, parameters.WithLowerThreshold, parameters.IncludeDelivered)
+	page, err := s.ordersDao.GetOrdersForCourier(s.testCourier.ID, &models.OrdersQuery{Since: s.testOrder.CreatedAt - 10, SinceIsLower: true})
 	s.Assert().NoError(err)
-	if s.Assert().Len(orders, 1) {
-		s.Assert().Equal(orders[0].ID, s.testOrder.ID)
+	if s.Assert().Len(page.Orders, 1) {
+		s.Assert().Equal(page.Orders[0].ID, s.testOrder.ID)
 	}
 }
 
@@ -151,13 +144,9 @@ func (s OrdersTestSuite) TestOrdersElasticDAO_GetOrdersForCourier_ExcludeDeliver
 		}
 	}
 	s.ordersDao.Elastic.Refresh(s.ordersDao.index).Do(context.Background())
-	orders, err := s.ordersDao.GetOrdersForCourier(
-		s.testCourier.ID,
-		0,
-		parameters.WithLowerThreshold,
-		parameters.ExcludeDelivered)
+	page, err := s.ordersDao.GetOrdersForCourier(s.testCourier.ID, &models.OrdersQuery{SinceIsLower: true, ExcludeDelivered: true})
 	s.NoError(err)
-	s.Empty(orders)
+	s.Empty(page.Orders)
 }
 
 func (s OrdersTestSuite) TestOrdersElasticDAO_Update_OK() {
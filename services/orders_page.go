@@ -0,0 +1,74 @@
+package services
+
+import (
+	"github.com/TeamD2018/geo-rest/models"
+	"github.com/TeamD2018/geo-rest/services/geo"
+	"math"
+	"sort"
+)
+
+// pageOrders filters, sorts and pages the orders of a courier the way OrdersElasticDAO does.
+func pageOrders(orders models.Orders, query *models.OrdersQuery) *models.OrdersPage {
+	if query == nil {
+		query = &models.OrdersQuery{}
+	}
+	type sortedOrder struct {
+		order *models.Order
+		value float64
+	}
+	matched := make([]sortedOrder, 0, len(orders))
+	for _, order := range orders {
+		if matchOrdersQuery(order, query) {
+			matched = append(matched, sortedOrder{order: order, value: ordersSortValue(order, query)})
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		a, b := matched[i], matched[j]
+		if a.value == b.value {
+			return (a.order.ID < b.order.ID) != query.Desc
+		}
+		return (a.value < b.value) != query.Desc
+	})
+	page := &models.OrdersPage{Orders: models.Orders{}, Total: int64(len(matched))}
+	var last models.OrdersCursor
+	for _, m := range matched {
+		if query.After != nil && query.After.Passed(m.value, m.order.ID, query.Desc) {
+			continue
+		}
+		if query.Limit > 0 && len(page.Orders) == query.Limit {
+			next := last
+			page.Next = &next
+			break
+		}
+		page.Orders = append(page.Orders, m.order)
+		last = models.OrdersCursor{Value: m.value, ID: m.order.ID}
+	}
+	return page
+}
+
+func matchOrdersQuery(order *models.Order, query *models.OrdersQuery) bool {
+	if query.SinceIsLower && order.CreatedAt < query.Since {
+		return false
+	}
+	if !query.SinceIsLower && order.CreatedAt > query.Since {
+		return false
+	}
+	if query.ExcludeDelivered && order.DeliveredAt != 0 {
+		return false
+	}
+	return order.HasStatus(query.Statuses)
+}
+
+// ordersSortValue returns the value the order is sorted by. Orders without a distance get an infinite one.
+func ordersSortValue(order *models.Order, query *models.OrdersQuery) float64 {
+	switch query.Sort {
+	case models.OrdersSortOrderNumber:
+		return float64(order.OrderNumber)
+	case models.OrdersSortDistance:
+		if query.From == nil || order.Destination.Point == nil {
+			return math.Inf(1)
+		}
+		return geo.Distance(query.From, order.Destination.Point)
+	}
+	return float64(order.CreatedAt)
+}
@@ -0,0 +1,90 @@
+package services
+
+import (
+	"github.com/TeamD2018/geo-rest/models"
+	"github.com/TeamD2018/geo-rest/services/interfaces"
+	"github.com/olivere/elastic"
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+// RejectedLocationsBehaviourSuite checks that rejected fixes are found by courier and by reason within a time window,
+// and that Find honours its limit.
+type RejectedLocationsBehaviourSuite struct {
+	suite.Suite
+	newLog func() interfaces.RejectedLocationsLog
+	log    interfaces.RejectedLocationsLog
+}
+
+func (s *RejectedLocationsBehaviourSuite) BeforeTest(suiteName, testName string) {
+	s.log = s.newLog()
+}
+
+func (s *RejectedLocationsBehaviourSuite) record(courierID, reason string, rejectedAt int64) *models.RejectedLocation {
+	rejected := &models.RejectedLocation{
+		CourierID:  courierID,
+		Point:      &models.PointWithTs{Point: elastic.GeoPointFromLatLon(55.75, 37.61), Ts: uint64(rejectedAt)},
+		Reason:     reason,
+		Source:     models.LocationSourceUpdate,
+		RejectedAt: rejectedAt,
+	}
+	s.Require().NoError(s.log.Record(rejected))
+	return rejected
+}
+
+func (s *RejectedLocationsBehaviourSuite) ids(rejected models.RejectedLocations) []string {
+	ids := make([]string, 0, len(rejected))
+	for _, r := range rejected {
+		ids = append(ids, r.ID)
+	}
+	return ids
+}
+
+func (s *RejectedLocationsBehaviourSuite) TestRecordAndFindByCourier() {
+	recorded := s.record("courier", models.OutlierImpossibleSpeed, 10)
+	s.record("other", models.OutlierImpossibleSpeed, 10)
+	s.NotEmpty(recorded.ID)
+
+	found, err := s.log.Find(&models.RejectedLocationQuery{CourierID: "courier"})
+	if !s.NoError(err) {
+		return
+	}
+	if s.Len(found, 1) {
+		s.Equal(recorded, found[0])
+	}
+}
+
+func (s *RejectedLocationsBehaviourSuite) TestFindByReasonAndWindow() {
+	s.record("courier", models.OutlierDuplicate, 10)
+	early := s.record("courier", models.OutlierImpossibleSpeed, 20)
+	late := s.record("courier", models.OutlierImpossibleSpeed, 30)
+	s.record("courier", models.OutlierImpossibleSpeed, 40)
+
+	found, err := s.log.Find(&models.RejectedLocationQuery{
+		CourierID: "courier",
+		Reason:    models.OutlierImpossibleSpeed,
+		Since:     10,
+		Until:     30,
+	})
+	if !s.NoError(err) {
+		return
+	}
+	s.Equal([]string{late.ID, early.ID}, s.ids(found))
+}
+
+func (s *RejectedLocationsBehaviourSuite) TestFindLimit() {
+	s.record("courier", models.OutlierNearDuplicate, 10)
+	latest := s.record("other", models.OutlierNearDuplicate, 20)
+
+	found, err := s.log.Find(&models.RejectedLocationQuery{Limit: 1})
+	if !s.NoError(err) {
+		return
+	}
+	s.Equal([]string{latest.ID}, s.ids(found))
+}
+
+func TestUnitMemoryRejectedLocations(t *testing.T) {
+	suite.Run(t, &RejectedLocationsBehaviourSuite{newLog: func() interfaces.RejectedLocationsLog {
+		return NewMemoryRejectedLocationsLog()
+	}})
+}
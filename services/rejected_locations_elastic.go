@@ -0,0 +1,151 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/TeamD2018/geo-rest/models"
+	"github.com/olivere/elastic"
+	"github.com/satori/go.uuid"
+	"go.uber.org/zap"
+)
+
+const RejectedLocationsIndex = "rejected_locations"
+
+const DefaultRejectedLocationsReturnSize = 50
+
+// RejectedLocationsElastic keeps the audit log of filtered out points in Elasticsearch.
+type RejectedLocationsElastic struct {
+	Elastic *elastic.Client
+	index   string
+	Logger  *zap.Logger
+}
+
+func NewRejectedLocationsElastic(client *elastic.Client, logger *zap.Logger, index string) *RejectedLocationsElastic {
+	if index == "" {
+		index = RejectedLocationsIndex
+	}
+	if logger == nil {
+		logger, _ = zap.NewDevelopment()
+	}
+	return &RejectedLocationsElastic{
+		Elastic: client,
+		index:   index,
+		Logger:  logger,
+	}
+}
+
+func (rl *RejectedLocationsElastic) Record(rejected *models.RejectedLocation) error {
+	rejected.ID = uuid.NewV4().String()
+	_, err := rl.Elastic.Index().
+		Index(rl.index).
+		Type("_doc").
+		Id(rejected.ID).
+		BodyJson(rejected).
+		Do(context.Background())
+	if err != nil {
+		rejected.ID = ""
+		return err
+	}
+	return nil
+}
+
+func (rl *RejectedLocationsElastic) Find(query *models.RejectedLocationQuery) (models.RejectedLocations, error) {
+	filter := elastic.NewBoolQuery()
+	if query.CourierID != "" {
+		filter = filter.Filter(elastic.NewTermQuery("courier_id", query.CourierID))
+	}
+	if query.Reason != "" {
+		filter = filter.Filter(elastic.NewTermQuery("reason", query.Reason))
+	}
+	window := elastic.NewRangeQuery("rejected_at").Gte(query.Since)
+	if query.Until > 0 {
+		window = window.Lte(query.Until)
+	}
+	filter = filter.Filter(window)
+	size := query.Limit
+	if size <= 0 {
+		size = DefaultRejectedLocationsReturnSize
+	}
+	res, err := rl.Elastic.Search(rl.index).
+		Type("_doc").
+		Query(filter).
+		Sort("rejected_at", false).
+		Size(size).
+		Do(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	found := make(models.RejectedLocations, 0, len(res.Hits.Hits))
+	for _, hit := range res.Hits.Hits {
+		var rejected models.RejectedLocation
+		if err := json.Unmarshal(*hit.Source, &rejected); err != nil {
+			return nil, models.ErrUnmarshalJSON
+		}
+		rejected.ID = hit.Id
+		found = append(found, &rejected)
+	}
+	return found, nil
+}
+
+func (rl *RejectedLocationsElastic) EnsureMapping() error {
+	indexName, mapping := rl.GetMapping()
+
+	ctx := context.Background()
+	exists, err := rl.Elastic.IndexExists(indexName).Do(ctx)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		_, err := rl.Elastic.CreateIndex(indexName).BodyString(mapping).Do(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (rl *RejectedLocationsElastic) GetIndex() string {
+	return rl.index
+}
+
+func (rl *RejectedLocationsElastic) GetMapping() (indexName string, mapping string) {
+	return rl.index, `{
+  "mappings": {
+    "_doc": {
+      "properties": {
+        "courier_id": {
+          "type": "keyword"
+        },
+        "reason": {
+          "type": "keyword"
+        },
+        "source": {
+          "type": "keyword"
+        },
+        "quarantined": {
+          "type": "boolean"
+        },
+        "distance": {
+          "type": "double"
+        },
+        "speed": {
+          "type": "double"
+        },
+        "rejected_at": {
+          "type": "long"
+        },
+        "point": {
+          "type": "object",
+          "enabled": false
+        },
+        "previous": {
+          "type": "object",
+          "enabled": false
+        }
+      }
+    }
+  }
+}`
+}
@@ -0,0 +1,82 @@
+package services
+
+import (
+	"github.com/TeamD2018/geo-rest/models"
+	"github.com/TeamD2018/geo-rest/services/interfaces"
+	"github.com/olivere/elastic"
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+// RouteArchiveBehaviourSuite checks that archived routes are found by courier and by an overlapping time window,
+// and that Find honours its limit.
+type RouteArchiveBehaviourSuite struct {
+	suite.Suite
+	newArchive func() interfaces.RouteArchive
+	archive    interfaces.RouteArchive
+}
+
+func (s *RouteArchiveBehaviourSuite) BeforeTest(suiteName, testName string) {
+	s.archive = s.newArchive()
+}
+
+func (s *RouteArchiveBehaviourSuite) save(courierID string, from, to uint64) *models.ArchivedRoute {
+	route := models.NewArchivedRoute(courierID, models.Points{
+		{Point: elastic.GeoPointFromLatLon(55.75, 37.61), Ts: from},
+		{Point: elastic.GeoPointFromLatLon(55.76, 37.62), Ts: to},
+	}, int64(to))
+	s.Require().NoError(s.archive.Save(route))
+	return route
+}
+
+func (s *RouteArchiveBehaviourSuite) ids(routes models.ArchivedRoutes) []string {
+	ids := make([]string, 0, len(routes))
+	for _, r := range routes {
+		ids = append(ids, r.ID)
+	}
+	return ids
+}
+
+func (s *RouteArchiveBehaviourSuite) TestSaveAndFindByCourier() {
+	saved := s.save("courier", 10, 20)
+	s.save("other", 10, 20)
+	s.NotEmpty(saved.ID)
+
+	routes, err := s.archive.Find(&models.RouteArchiveQuery{CourierID: "courier"})
+	if !s.NoError(err) {
+		return
+	}
+	if s.Len(routes, 1) {
+		s.Equal(saved, routes[0])
+	}
+}
+
+func (s *RouteArchiveBehaviourSuite) TestFindOverlappingWindow() {
+	early := s.save("courier", 10, 20)
+	middle := s.save("courier", 30, 40)
+	s.save("courier", 50, 60)
+
+	routes, err := s.archive.Find(&models.RouteArchiveQuery{CourierID: "courier", Since: 15, Until: 35})
+	if !s.NoError(err) {
+		return
+	}
+	s.Equal([]string{middle.ID, early.ID}, s.ids(routes))
+}
+
+func (s *RouteArchiveBehaviourSuite) TestFindLimit() {
+	s.save("courier", 10, 20)
+	s.save("other", 30, 40)
+	latest := s.save("courier", 50, 60)
+
+	routes, err := s.archive.Find(&models.RouteArchiveQuery{Limit: 1})
+	if !s.NoError(err) {
+		return
+	}
+	s.Equal([]string{latest.ID}, s.ids(routes))
+}
+
+func TestUnitMemoryRouteArchive(t *testing.T) {
+	suite.Run(t, &RouteArchiveBehaviourSuite{newArchive: func() interfaces.RouteArchive {
+		return NewMemoryRouteArchive()
+	}})
+}
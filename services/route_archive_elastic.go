@@ -0,0 +1,134 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/TeamD2018/geo-rest/models"
+	"github.com/olivere/elastic"
+	"github.com/satori/go.uuid"
+	"go.uber.org/zap"
+)
+
+const RouteArchiveIndex = "route_archive"
+
+const DefaultRouteArchiveReturnSize = 20
+
+// RouteArchiveElastic keeps archived routes in Elasticsearch, one document per trip.
+type RouteArchiveElastic struct {
+	Elastic *elastic.Client
+	index   string
+	Logger  *zap.Logger
+}
+
+func NewRouteArchiveElastic(client *elastic.Client, logger *zap.Logger, index string) *RouteArchiveElastic {
+	if index == "" {
+		index = RouteArchiveIndex
+	}
+	if logger == nil {
+		logger, _ = zap.NewDevelopment()
+	}
+	return &RouteArchiveElastic{
+		Elastic: client,
+		index:   index,
+		Logger:  logger,
+	}
+}
+
+func (ra *RouteArchiveElastic) Save(route *models.ArchivedRoute) error {
+	route.ID = uuid.NewV4().String()
+	_, err := ra.Elastic.Index().
+		Index(ra.index).
+		Type("_doc").
+		Id(route.ID).
+		BodyJson(route).
+		Do(context.Background())
+	if err != nil {
+		route.ID = ""
+		return err
+	}
+	return nil
+}
+
+func (ra *RouteArchiveElastic) Find(query *models.RouteArchiveQuery) (models.ArchivedRoutes, error) {
+	filter := elastic.NewBoolQuery()
+	if query.CourierID != "" {
+		filter = filter.Filter(elastic.NewTermQuery("courier_id", query.CourierID))
+	}
+	filter = filter.Filter(elastic.NewRangeQuery("finished_at").Gte(query.Since))
+	if query.Until > 0 {
+		filter = filter.Filter(elastic.NewRangeQuery("started_at").Lte(query.Until))
+	}
+	size := query.Limit
+	if size <= 0 {
+		size = DefaultRouteArchiveReturnSize
+	}
+	res, err := ra.Elastic.Search(ra.index).
+		Type("_doc").
+		Query(filter).
+		Sort("started_at", false).
+		Size(size).
+		Do(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	routes := make(models.ArchivedRoutes, 0, len(res.Hits.Hits))
+	for _, hit := range res.Hits.Hits {
+		var route models.ArchivedRoute
+		if err := json.Unmarshal(*hit.Source, &route); err != nil {
+			return nil, models.ErrUnmarshalJSON
+		}
+		route.ID = hit.Id
+		routes = append(routes, &route)
+	}
+	return routes, nil
+}
+
+func (ra *RouteArchiveElastic) EnsureMapping() error {
+	indexName, mapping := ra.GetMapping()
+
+	ctx := context.Background()
+	exists, err := ra.Elastic.IndexExists(indexName).Do(ctx)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		_, err := ra.Elastic.CreateIndex(indexName).BodyString(mapping).Do(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (ra *RouteArchiveElastic) GetIndex() string {
+	return ra.index
+}
+
+func (ra *RouteArchiveElastic) GetMapping() (indexName string, mapping string) {
+	return ra.index, `{
+  "mappings": {
+    "_doc": {
+      "properties": {
+        "courier_id": {
+          "type": "keyword"
+        },
+        "started_at": {
+          "type": "long"
+        },
+        "finished_at": {
+          "type": "long"
+        },
+        "archived_at": {
+          "type": "long"
+        },
+        "geo_history": {
+          "type": "object",
+          "enabled": false
+        }
+      }
+    }
+  }
+}`
+}
@@ -0,0 +1,164 @@
+package services
+
+import (
+	"github.com/TeamD2018/geo-rest/models"
+	"github.com/TeamD2018/geo-rest/services/interfaces"
+	"github.com/olivere/elastic"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+	"testing"
+)
+
+// RouteDAOBehaviourSuite checks the since/until window, ordering and paging of GetRoute,
+// rejection of invalid points and fixes, the accuracy filter and dropping a route with its courier.
+type RouteDAOBehaviourSuite struct {
+	suite.Suite
+	newDAO    func() interfaces.GeoRouteInterface
+	dao       interfaces.GeoRouteInterface
+	courierID string
+}
+
+func (s *RouteDAOBehaviourSuite) BeforeTest(suiteName, testName string) {
+	s.dao = s.newDAO()
+	s.courierID = "550e8400-e29b-41d4-a716-446655440000"
+	s.Require().NoError(s.dao.CreateCourier(s.courierID))
+}
+
+func (s *RouteDAOBehaviourSuite) addPoints(timestamps ...uint64) {
+	for i, ts := range timestamps {
+		s.Require().NoError(s.dao.AddPointToRoute(s.courierID, &models.PointWithTs{
+			Point: elastic.GeoPointFromLatLon(float64(i), 37.5),
+			Ts:    ts,
+		}))
+	}
+}
+
+func (s *RouteDAOBehaviourSuite) timestamps(points models.Points) []uint64 {
+	ts := make([]uint64, 0, len(points))
+	for _, p := range points {
+		ts = append(ts, p.Ts)
+	}
+	return ts
+}
+
+func (s *RouteDAOBehaviourSuite) TestGetRouteSince() {
+	s.addPoints(1, 2, 3)
+	page, err := s.dao.GetRoute(s.courierID, &models.RouteQuery{Since: 1})
+	if !s.NoError(err) {
+		return
+	}
+	s.Equal([]uint64{2, 3}, s.timestamps(page.Points))
+	s.Nil(page.Next)
+}
+
+func (s *RouteDAOBehaviourSuite) TestGetRouteUntil() {
+	s.addPoints(1, 2, 3, 4)
+	page, err := s.dao.GetRoute(s.courierID, &models.RouteQuery{Since: 1, Until: 3})
+	if !s.NoError(err) {
+		return
+	}
+	s.Equal([]uint64{2, 3}, s.timestamps(page.Points))
+}
+
+func (s *RouteDAOBehaviourSuite) TestGetRouteOrdersByTimestamp() {
+	s.addPoints(3, 1, 2)
+	page, err := s.dao.GetRoute(s.courierID, &models.RouteQuery{})
+	if !s.NoError(err) {
+		return
+	}
+	s.Equal([]uint64{1, 2, 3}, s.timestamps(page.Points))
+}
+
+func (s *RouteDAOBehaviourSuite) TestGetRoutePages() {
+	s.addPoints(1, 2, 2, 2, 3)
+	query := &models.RouteQuery{Limit: 2}
+	var got []uint64
+	var lats []float64
+	for pages := 0; pages < 5; pages++ {
+		page, err := s.dao.GetRoute(s.courierID, query)
+		if !s.NoError(err) {
+			return
+		}
+		s.True(len(page.Points) <= 2)
+		got = append(got, s.timestamps(page.Points)...)
+		for _, p := range page.Points {
+			lats = append(lats, p.Point.Lat)
+		}
+		if page.Next == nil {
+			break
+		}
+		query.After = page.Next
+	}
+	s.Equal([]uint64{1, 2, 2, 2, 3}, got)
+	s.Equal([]float64{0, 1, 2, 3, 4}, lats)
+}
+
+func (s *RouteDAOBehaviourSuite) TestGetRouteFullLastPage() {
+	s.addPoints(1, 2)
+	page, err := s.dao.GetRoute(s.courierID, &models.RouteQuery{Limit: 2})
+	if !s.NoError(err) {
+		return
+	}
+	s.Len(page.Points, 2)
+	s.Nil(page.Next)
+}
+
+func (s *RouteDAOBehaviourSuite) TestAddInvalidPoint() {
+	err := s.dao.AddPointToRoute(s.courierID, &models.PointWithTs{Point: elastic.GeoPointFromLatLon(91, 0)})
+	s.Error(err)
+}
+
+func (s *RouteDAOBehaviourSuite) TestAddInvalidFix() {
+	bearing := 360.0
+	err := s.dao.AddPointToRoute(s.courierID, &models.PointWithTs{
+		Point: elastic.GeoPointFromLatLon(55, 37),
+		Fix:   models.Fix{Bearing: &bearing},
+	})
+	s.Error(err)
+}
+
+func (s *RouteDAOBehaviourSuite) TestGetRouteKeepsFix() {
+	accuracy, speed, bearing, altitude, provider := 4.5, 3.0, 90.0, 150.0, models.ProviderGPS
+	fix := models.Fix{Accuracy: &accuracy, Speed: &speed, Bearing: &bearing, Altitude: &altitude, Provider: &provider}
+	s.Require().NoError(s.dao.AddPointToRoute(s.courierID, &models.PointWithTs{
+		Point: elastic.GeoPointFromLatLon(55, 37),
+		Ts:    1,
+		Fix:   fix,
+	}))
+	page, err := s.dao.GetRoute(s.courierID, &models.RouteQuery{})
+	if !s.NoError(err) || !s.Len(page.Points, 1) {
+		return
+	}
+	s.Equal(fix, page.Points[0].Fix)
+}
+
+func (s *RouteDAOBehaviourSuite) TestGetRouteMaxAccuracy() {
+	for i, accuracy := range []float64{5, 50, 10} {
+		accuracy := accuracy
+		s.Require().NoError(s.dao.AddPointToRoute(s.courierID, &models.PointWithTs{
+			Point: elastic.GeoPointFromLatLon(float64(i), 37.5),
+			Ts:    uint64(i + 1),
+			Fix:   models.Fix{Accuracy: &accuracy},
+		}))
+	}
+	s.addPoints(4)
+	page, err := s.dao.GetRoute(s.courierID, &models.RouteQuery{MaxAccuracy: 10})
+	if !s.NoError(err) {
+		return
+	}
+	s.Equal([]uint64{1, 3, 4}, s.timestamps(page.Points))
+}
+
+func (s *RouteDAOBehaviourSuite) TestDeleteCourier() {
+	s.addPoints(1)
+	s.NoError(s.dao.DeleteCourier(s.courierID))
+	page, err := s.dao.GetRoute(s.courierID, &models.RouteQuery{})
+	s.NoError(err)
+	s.Empty(page.Points)
+}
+
+func TestUnitMemoryRouteDAO(t *testing.T) {
+	suite.Run(t, &RouteDAOBehaviourSuite{newDAO: func() interfaces.GeoRouteInterface {
+		return NewMemoryRouteDAO(zap.NewNop())
+	}})
+}
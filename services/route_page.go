@@ -0,0 +1,44 @@
+package services
+
+import "github.com/TeamD2018/geo-rest/models"
+
+// routePageBuilder collects a page of points fed in (ts, seq) order.
+type routePageBuilder struct {
+	query *models.RouteQuery
+	page  *models.RoutePage
+	last  models.RouteCursor
+}
+
+func newRoutePageBuilder(query *models.RouteQuery) *routePageBuilder {
+	if query == nil {
+		query = &models.RouteQuery{}
+	}
+	return &routePageBuilder{
+		query: query,
+		page:  &models.RoutePage{Points: models.Points{}},
+	}
+}
+
+// add offers the next point and reports whether later points are still wanted.
+func (b *routePageBuilder) add(point *models.PointWithTs, seq uint64) bool {
+	if b.query.Since >= 0 && point.Ts <= uint64(b.query.Since) {
+		return true
+	}
+	if b.query.After != nil && b.query.After.Passed(point.Ts, seq) {
+		return true
+	}
+	if b.query.Until > 0 && point.Ts > uint64(b.query.Until) {
+		return false
+	}
+	if !point.Fix.AccurateTo(b.query.MaxAccuracy) {
+		return true
+	}
+	if b.query.Limit > 0 && len(b.page.Points) == b.query.Limit {
+		next := b.last
+		b.page.Next = &next
+		return false
+	}
+	b.page.Points = append(b.page.Points, point)
+	b.last = models.RouteCursor{Ts: point.Ts, Seq: seq}
+	return true
+}
@@ -0,0 +1,125 @@
+package services
+
+import (
+	"github.com/TeamD2018/geo-rest/models"
+	"github.com/TeamD2018/geo-rest/services/geo"
+	"github.com/TeamD2018/geo-rest/services/interfaces"
+	"github.com/olivere/elastic"
+	"time"
+)
+
+const (
+	DefaultStopDuration = 5 * time.Minute
+	DefaultStopRadius   = 50
+)
+
+// RouteStatsService computes distance, timing and stops over routes from a GeoRouteInterface.
+type RouteStatsService struct {
+	RouteDAO interfaces.GeoRouteInterface
+	// Minimal dwell that counts as a stop
+	StopDuration time.Duration
+	// Radius in metres a courier must stay within during a stop
+	StopRadius float64
+}
+
+func NewRouteStatsService(routeDAO interfaces.GeoRouteInterface, stopDuration time.Duration, stopRadius float64) *RouteStatsService {
+	if stopDuration <= 0 {
+		stopDuration = DefaultStopDuration
+	}
+	if stopRadius <= 0 {
+		stopRadius = DefaultStopRadius
+	}
+	return &RouteStatsService{
+		RouteDAO:     routeDAO,
+		StopDuration: stopDuration,
+		StopRadius:   stopRadius,
+	}
+}
+
+// Stats returns statistics for the points of the whole route window selected by query; its Limit and After are ignored.
+// Zero stopDuration or stopRadius fall back to the service defaults.
+func (rs *RouteStatsService) Stats(courierID string, query *models.RouteQuery, stopDuration time.Duration, stopRadius float64) (*models.RouteStats, error) {
+	if query == nil {
+		query = &models.RouteQuery{}
+	}
+	page, err := rs.RouteDAO.GetRoute(courierID, &models.RouteQuery{
+		Since:       query.Since,
+		Until:       query.Until,
+		MaxAccuracy: query.MaxAccuracy,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if stopDuration <= 0 {
+		stopDuration = rs.StopDuration
+	}
+	if stopRadius <= 0 {
+		stopRadius = rs.StopRadius
+	}
+	stats := CalculateRouteStats(page.Points, stopDuration, stopRadius)
+	stats.CourierID = courierID
+	stats.Since = query.Since
+	stats.Until = query.Until
+	return stats, nil
+}
+
+// CalculateRouteStats computes statistics over points ordered by timestamp.
+func CalculateRouteStats(points models.Points, stopDuration time.Duration, stopRadius float64) *models.RouteStats {
+	stats := &models.RouteStats{
+		PointsCount: len(points),
+		Stops:       DetectStops(points, stopDuration, stopRadius),
+	}
+	if len(points) < 2 {
+		return stats
+	}
+	for i := 1; i < len(points); i++ {
+		distance := geo.Distance(points[i-1].Point, points[i].Point)
+		stats.Distance += distance
+		if dt := float64(points[i].Ts) - float64(points[i-1].Ts); dt > 0 && distance/dt > stats.MaxSpeed {
+			stats.MaxSpeed = distance / dt
+		}
+	}
+	stats.Duration = int64(points[len(points)-1].Ts) - int64(points[0].Ts)
+	for _, stop := range stats.Stops {
+		stats.IdleTime += stop.Duration
+	}
+	stats.MovingTime = stats.Duration - stats.IdleTime
+	if stats.MovingTime > 0 {
+		stats.AverageSpeed = stats.Distance / float64(stats.MovingTime)
+	}
+	return stats
+}
+
+// DetectStops finds runs of consecutive points that stay within radius metres of the run's first point
+// for at least minDuration.
+func DetectStops(points models.Points, minDuration time.Duration, radius float64) []*models.RouteStop {
+	stops := make([]*models.RouteStop, 0)
+	for start := 0; start < len(points); {
+		end := start
+		for end+1 < len(points) && geo.Distance(points[start].Point, points[end+1].Point) <= radius {
+			end++
+		}
+		duration := int64(points[end].Ts) - int64(points[start].Ts)
+		if end > start && time.Duration(duration)*time.Second >= minDuration {
+			stops = append(stops, &models.RouteStop{
+				StartedAt: points[start].Ts,
+				EndedAt:   points[end].Ts,
+				Duration:  duration,
+				Centroid:  centroid(points[start : end+1]),
+			})
+			start = end + 1
+			continue
+		}
+		start++
+	}
+	return stops
+}
+
+func centroid(points models.Points) *elastic.GeoPoint {
+	var lat, lon float64
+	for _, p := range points {
+		lat += p.Point.Lat
+		lon += p.Point.Lon
+	}
+	return elastic.GeoPointFromLatLon(lat/float64(len(points)), lon/float64(len(points)))
+}
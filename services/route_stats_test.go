@@ -0,0 +1,106 @@
+package services
+
+import (
+	"github.com/TeamD2018/geo-rest/models"
+	"github.com/olivere/elastic"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+	"testing"
+	"time"
+)
+
+type RouteStatsTestSuite struct {
+	suite.Suite
+	routeDAO *MemoryRouteDAO
+	service  *RouteStatsService
+}
+
+func (s *RouteStatsTestSuite) BeforeTest(suiteName, testName string) {
+	s.routeDAO = NewMemoryRouteDAO(zap.NewNop())
+	s.service = NewRouteStatsService(s.routeDAO, 5*time.Minute, 50)
+}
+
+// route moves north by 0.001 degree (~111 m) every minute.
+func (s *RouteStatsTestSuite) route() models.Points {
+	return models.Points{
+		{Point: elastic.GeoPointFromLatLon(55.700, 37.6), Ts: 0},
+		{Point: elastic.GeoPointFromLatLon(55.701, 37.6), Ts: 60},
+		{Point: elastic.GeoPointFromLatLon(55.702, 37.6), Ts: 120},
+		{Point: elastic.GeoPointFromLatLon(55.703, 37.6), Ts: 180},
+	}
+}
+
+// stoppingRoute stays within ~11 m from ts 300 to ts 600.
+func (s *RouteStatsTestSuite) stoppingRoute() models.Points {
+	return models.Points{
+		{Point: elastic.GeoPointFromLatLon(55.700, 37.6), Ts: 60},
+		{Point: elastic.GeoPointFromLatLon(55.701, 37.6), Ts: 120},
+		{Point: elastic.GeoPointFromLatLon(55.702, 37.6), Ts: 180},
+		{Point: elastic.GeoPointFromLatLon(55.7201, 37.6), Ts: 300},
+		{Point: elastic.GeoPointFromLatLon(55.7202, 37.6), Ts: 480},
+		{Point: elastic.GeoPointFromLatLon(55.7201, 37.6), Ts: 600},
+		{Point: elastic.GeoPointFromLatLon(55.721, 37.6), Ts: 660},
+	}
+}
+
+func (s *RouteStatsTestSuite) TestStraightRoute() {
+	stats := CalculateRouteStats(s.route(), 5*time.Minute, 50)
+	s.Equal(4, stats.PointsCount)
+	s.InDelta(333.6, stats.Distance, 1)
+	s.Equal(int64(180), stats.Duration)
+	s.Equal(int64(180), stats.MovingTime)
+	s.Equal(int64(0), stats.IdleTime)
+	s.InDelta(1.853, stats.AverageSpeed, 0.01)
+	s.InDelta(1.853, stats.MaxSpeed, 0.01)
+	s.Empty(stats.Stops)
+}
+
+func (s *RouteStatsTestSuite) TestStops() {
+	stats := CalculateRouteStats(s.stoppingRoute(), 5*time.Minute, 50)
+	if s.Len(stats.Stops, 1) {
+		stop := stats.Stops[0]
+		s.Equal(uint64(300), stop.StartedAt)
+		s.Equal(uint64(600), stop.EndedAt)
+		s.Equal(int64(300), stop.Duration)
+		s.InDelta(55.72013, stop.Centroid.Lat, 1e-4)
+	}
+	s.Equal(int64(600), stats.Duration)
+	s.Equal(int64(300), stats.IdleTime)
+	s.Equal(int64(300), stats.MovingTime)
+	s.InDelta(stats.Distance/300, stats.AverageSpeed, 1e-9)
+	s.InDelta(2012.6/120, stats.MaxSpeed, 0.1)
+}
+
+func (s *RouteStatsTestSuite) TestShortDwellIsNotAStop() {
+	stats := CalculateRouteStats(s.stoppingRoute(), 6*time.Minute, 50)
+	s.Empty(stats.Stops)
+	s.Equal(stats.Duration, stats.MovingTime)
+}
+
+func (s *RouteStatsTestSuite) TestStatsWindow() {
+	courierID := "550e8400-e29b-41d4-a716-446655440000"
+	for _, p := range s.stoppingRoute() {
+		s.NoError(s.routeDAO.AddPointToRoute(courierID, p))
+	}
+	stats, err := s.service.Stats(courierID, &models.RouteQuery{Since: 60, Until: 300}, 0, 0)
+	if !s.NoError(err) {
+		return
+	}
+	s.Equal(courierID, stats.CourierID)
+	s.Equal(3, stats.PointsCount)
+	s.Equal(int64(180), stats.Duration)
+	s.Empty(stats.Stops)
+}
+
+func (s *RouteStatsTestSuite) TestEmptyRoute() {
+	stats, err := s.service.Stats("unknown", nil, 0, 0)
+	if !s.NoError(err) {
+		return
+	}
+	s.Equal(0, stats.PointsCount)
+	s.NotNil(stats.Stops)
+}
+
+func TestUnitRouteStats(t *testing.T) {
+	suite.Run(t, new(RouteStatsTestSuite))
+}
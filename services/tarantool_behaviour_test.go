@@ -0,0 +1,78 @@
+// +build tarantool
+
+package services
+
+import (
+	"fmt"
+	"github.com/TeamD2018/geo-rest/migrations"
+	"github.com/TeamD2018/geo-rest/services/interfaces"
+	"github.com/ory/dockertest"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"github.com/tarantool/go-tarantool"
+	"go.uber.org/zap"
+	"testing"
+)
+
+// tarantoolContainer runs one migrated Tarantool for a whole test; spaces are truncated before every suite test.
+type tarantoolContainer struct {
+	t        *testing.T
+	pool     *dockertest.Pool
+	resource *dockertest.Resource
+	client   *tarantool.Connection
+}
+
+func startTarantool(t *testing.T) *tarantoolContainer {
+	pool, err := dockertest.NewPool("")
+	require.NoError(t, err, "could not connect to docker")
+
+	resource, err := pool.Run("tarantool/tarantool", "1.10.2", []string{})
+	require.NoError(t, err, "could not start resource")
+
+	var c *tarantool.Connection
+	err = pool.Retry(func() error {
+		addr := fmt.Sprintf("localhost:%s", resource.GetPort("3301/tcp"))
+
+		var err error
+		c, err = tarantool.Connect(addr, tarantool.Opts{})
+		return err
+	})
+	if err == nil {
+		err = migrations.Driver{Client: c, Logger: zap.NewNop()}.Run()
+	}
+	if err != nil {
+		pool.Purge(resource)
+		require.NoError(t, err, "could not prepare tarantool")
+	}
+	return &tarantoolContainer{t: t, pool: pool, resource: resource, client: c}
+}
+
+func (tc *tarantoolContainer) purge() {
+	tc.client.Close()
+	if err := tc.pool.Purge(tc.resource); err != nil {
+		tc.t.Logf("could not purge resource: %s", err)
+	}
+}
+
+func (tc *tarantoolContainer) truncate(space string) {
+	_, err := tc.client.Eval("box.space[...]:truncate()", []interface{}{space})
+	require.NoError(tc.t, err)
+}
+
+func TestIntegrationTarantoolRouteDAO(t *testing.T) {
+	tc := startTarantool(t)
+	defer tc.purge()
+	suite.Run(t, &RouteDAOBehaviourSuite{newDAO: func() interfaces.GeoRouteInterface {
+		tc.truncate("couriers_route")
+		return NewTarantoolRouteDAO(tc.client, zap.NewNop())
+	}})
+}
+
+func TestIntegrationTarantoolChangeLog(t *testing.T) {
+	tc := startTarantool(t)
+	defer tc.purge()
+	suite.Run(t, &ChangeLogBehaviourSuite{newLog: func() interfaces.ChangeLog {
+		tc.truncate("changes")
+		return NewTarantoolChangeLog(tc.client, zap.NewNop())
+	}})
+}
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"encoding/json"
+	"github.com/TeamD2018/geo-rest/models"
+	"github.com/tarantool/go-tarantool"
+	"go.uber.org/zap"
+)
+
+const (
+	appendChangeFuncName = "append_change"
+	getChangesFuncName   = "get_changes"
+	trimChangesFuncName  = "trim_changes"
+)
+
+// TarantoolChangeLog keeps the change log in a Tarantool space; seq comes from a Tarantool sequence.
+type TarantoolChangeLog struct {
+	l      *zap.Logger
+	client *tarantool.Connection
+}
+
+func NewTarantoolChangeLog(client *tarantool.Connection, logger *zap.Logger) *TarantoolChangeLog {
+	return &TarantoolChangeLog{
+		client: client,
+		l:      logger,
+	}
+}
+
+func (tnt *TarantoolChangeLog) Append(change *models.Change) error {
+	resp, err := tnt.client.Call17(appendChangeFuncName, []interface{}{map[string]interface{}{
+		"ts":         change.Ts,
+		"entity":     change.Entity,
+		"op":         change.Op,
+		"id":         change.ID,
+		"courier_id": change.CourierID,
+		"data":       string(change.Data),
+	}})
+	if err != nil {
+		tnt.l.Error("fail to append change", zap.Error(err))
+		return err
+	}
+	change.Seq = asUint64(resp.Data[0])
+	return nil
+}
+
+func (tnt *TarantoolChangeLog) Read(after uint64, limit int) (models.Changes, error) {
+	if limit <= 0 {
+		limit = DefaultChangesReturnSize
+	}
+	resp, err := tnt.client.Call17(getChangesFuncName, []interface{}{after, limit})
+	if err != nil {
+		tnt.l.Error("fail to read changes", zap.Error(err))
+		return nil, err
+	}
+	rawChanges := resp.Data[0].([]interface{})
+	changes := make(models.Changes, 0, len(rawChanges))
+	for _, c := range rawChanges {
+		raw := c.(map[interface{}]interface{})
+		change := &models.Change{
+			Seq:    asUint64(raw["seq"]),
+			Ts:     int64(asFloat64(raw["ts"])),
+			Entity: raw["entity"].(string),
+			Op:     raw["op"].(string),
+			ID:     raw["id"].(string),
+		}
+		if courierID := asOptionalString(raw["courier_id"]); courierID != nil {
+			change.CourierID = *courierID
+		}
+		if data := asOptionalString(raw["data"]); data != nil {
+			change.Data = json.RawMessage(*data)
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+func (tnt *TarantoolChangeLog) Trim(before int64) (int, error) {
+	resp, err := tnt.client.Call17(trimChangesFuncName, []interface{}{before})
+	if err != nil {
+		tnt.l.Error("fail to trim changes", zap.Error(err))
+		return 0, err
+	}
+	return int(asUint64(resp.Data[0])), nil
+}
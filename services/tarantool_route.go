@@ -52,6 +52,13 @@ func (tnt *TarantoolRouteDAO) AddPointToRoute(courierID string, point *models.Po
 		"lon": point.Point.Lon,
 		"ts":  point.Ts,
 	}
+	setOptional(p, "accuracy", point.Accuracy)
+	setOptional(p, "speed", point.Speed)
+	setOptional(p, "bearing", point.Bearing)
+	setOptional(p, "altitude", point.Altitude)
+	if point.Provider != nil {
+		p["provider"] = *point.Provider
+	}
 	_, err := tnt.client.Call17(addPointToRouteFuncName, []interface{}{courierID, p})
 	if err != nil {
 		tnt.l.Sugar().Error(err)
@@ -60,18 +67,110 @@ func (tnt *TarantoolRouteDAO) AddPointToRoute(courierID string, point *models.Po
 	return nil
 }
 
-func (tnt *TarantoolRouteDAO) GetRoute(courierID string, since int64) ([]*models.PointWithTs, error) {
-	resp, err := tnt.client.Call17(getRouteFuncName, []interface{}{courierID, since})
-	points := make([]*models.PointWithTs, len(resp.Data[0].([]interface{})))
+func (tnt *TarantoolRouteDAO) GetRoute(courierID string, query *models.RouteQuery) (*models.RoutePage, error) {
+	builder := newRoutePageBuilder(query)
+	limit := 0
+	if builder.query.Limit > 0 {
+		// one extra point tells whether there is a next page
+		limit = builder.query.Limit + 1
+	}
+	var after interface{}
+	if builder.query.After != nil {
+		after = []interface{}{builder.query.After.Ts, builder.query.After.Seq}
+	}
+	resp, err := tnt.client.Call17(getRouteFuncName, []interface{}{
+		courierID, builder.query.Since, builder.query.Until, limit, after, builder.query.MaxAccuracy,
+	})
 	if err != nil {
 		tnt.l.Sugar().Errorw("msg", "resp", resp, "error", err)
 		return nil, err
 	}
-	for i, p := range resp.Data[0].([]interface{}) {
-		points[i] = &models.PointWithTs{
-			Point: elastic.GeoPointFromLatLon(p.(map[interface{}]interface{})["lat"].(float64), p.(map[interface{}]interface{})["lon"].(float64)),
-			Ts:    p.(map[interface{}]interface{})["ts"].(uint64),
+	rawPoints := resp.Data[0].([]interface{})
+	for _, p := range rawPoints {
+		point := p.(map[interface{}]interface{})
+		next := builder.add(&models.PointWithTs{
+			Point: elastic.GeoPointFromLatLon(asFloat64(point["lat"]), asFloat64(point["lon"])),
+			Ts:    asUint64(point["ts"]),
+			Fix: models.Fix{
+				Accuracy: asOptionalFloat64(point["accuracy"]),
+				Speed:    asOptionalFloat64(point["speed"]),
+				Bearing:  asOptionalFloat64(point["bearing"]),
+				Altitude: asOptionalFloat64(point["altitude"]),
+				Provider: asOptionalString(point["provider"]),
+			},
+		}, asUint64(point["seq"]))
+		if !next {
+			break
 		}
 	}
-	return points, nil
+	return builder.page, nil
+}
+
+func setOptional(p map[string]interface{}, key string, value *float64) {
+	if value != nil {
+		p[key] = *value
+	}
+}
+
+func asOptionalFloat64(value interface{}) *float64 {
+	if value == nil {
+		return nil
+	}
+	v := asFloat64(value)
+	return &v
+}
+
+func asOptionalString(value interface{}) *string {
+	v, ok := value.(string)
+	if !ok {
+		return nil
+	}
+	return &v
+}
+
+// asFloat64 converts a msgpack number to float64; Tarantool sends integral floats as integers.
+func asFloat64(value interface{}) float64 {
+	switch v := value.(type) {
+	case float64:
+		return v
+	case float32:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case int32:
+		return float64(v)
+	case int16:
+		return float64(v)
+	case int8:
+		return float64(v)
+	case int:
+		return float64(v)
+	default:
+		return float64(asUint64(value))
+	}
+}
+
+func asUint64(value interface{}) uint64 {
+	switch v := value.(type) {
+	case uint64:
+		return v
+	case int64:
+		return uint64(v)
+	case uint32:
+		return uint64(v)
+	case int32:
+		return uint64(v)
+	case uint16:
+		return uint64(v)
+	case int16:
+		return uint64(v)
+	case uint8:
+		return uint64(v)
+	case int8:
+		return uint64(v)
+	case int:
+		return uint64(v)
+	default:
+		return 0
+	}
 }
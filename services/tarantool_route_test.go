@@ -114,11 +114,90 @@ func (s *TarantoolRouteTestSuite) TestAddPointToRouteOK() {
 }
 
 func (s *TarantoolRouteTestSuite) TestTarantoolRouteTestSuit_GetRoute_OK_IfNoCourierExists(){
-	points, err := s.routeDAO.GetRoute(s.testCourier.ID, 0)
+	page, err := s.routeDAO.GetRoute(s.testCourier.ID, &models.RouteQuery{})
 	if !s.NoError(err) {
 		return
 	}
-	s.Empty(points)
+	s.Empty(page.Points)
+}
+
+func (s *TarantoolRouteTestSuite) TestTarantoolRouteTestSuit_GetRoute_Since() {
+	s.NoError(s.routeDAO.CreateCourier(s.testCourier.ID))
+	for ts := uint64(1); ts <= 3; ts++ {
+		err := s.routeDAO.AddPointToRoute(s.testCourier.ID, &models.PointWithTs{
+			Point: s.testCourier.Location.Point, Ts: ts,
+		})
+		if !s.NoError(err) {
+			return
+		}
+	}
+	page, err := s.routeDAO.GetRoute(s.testCourier.ID, &models.RouteQuery{Since: 1})
+	if !s.NoError(err) {
+		return
+	}
+	if s.Len(page.Points, 2) {
+		s.Equal(uint64(2), page.Points[0].Ts)
+		s.Equal(uint64(3), page.Points[1].Ts)
+	}
+	s.NoError(s.routeDAO.DeleteCourier(s.testCourier.ID))
+	page, err = s.routeDAO.GetRoute(s.testCourier.ID, &models.RouteQuery{})
+	s.NoError(err)
+	s.Empty(page.Points)
+}
+
+func (s *TarantoolRouteTestSuite) TestTarantoolRouteTestSuit_GetRoute_Pages() {
+	s.NoError(s.routeDAO.CreateCourier(s.testCourier.ID))
+	for _, ts := range []uint64{1, 2, 2, 2, 3, 4} {
+		err := s.routeDAO.AddPointToRoute(s.testCourier.ID, &models.PointWithTs{
+			Point: s.testCourier.Location.Point, Ts: ts,
+		})
+		if !s.NoError(err) {
+			return
+		}
+	}
+	query := &models.RouteQuery{Since: 1, Until: 3, Limit: 2}
+	page, err := s.routeDAO.GetRoute(s.testCourier.ID, query)
+	if !s.NoError(err) {
+		return
+	}
+	s.Len(page.Points, 2)
+	if !s.NotNil(page.Next) {
+		return
+	}
+	query.After = page.Next
+	page, err = s.routeDAO.GetRoute(s.testCourier.ID, query)
+	if !s.NoError(err) {
+		return
+	}
+	if s.Len(page.Points, 2) {
+		s.Equal(uint64(2), page.Points[0].Ts)
+		s.Equal(uint64(3), page.Points[1].Ts)
+	}
+	s.Nil(page.Next)
+}
+
+func (s *TarantoolRouteTestSuite) TestTarantoolRouteTestSuit_MigrateLegacyRoute() {
+	_, err := s.client.Eval(`
+		box.space.couriers_route:drop()
+		local legacy = box.schema.space.create('couriers_route', { field_count = 2 })
+		legacy:create_index('courier_id', { type = 'HASH', unique = true, parts = { 1, 'string' } })
+		legacy:insert { ...,  { { lat = 1.5, lon = 2.5, ts = 10 }, { lat = 3.5, lon = 4.5, ts = 20 } } }
+	`, []interface{}{s.testCourier.ID})
+	if !s.NoError(err) {
+		return
+	}
+	_, err = s.client.Call17("create_couriers_route_space", []interface{}{})
+	if !s.NoError(err) {
+		return
+	}
+	page, err := s.routeDAO.GetRoute(s.testCourier.ID, &models.RouteQuery{})
+	if !s.NoError(err) {
+		return
+	}
+	if s.Len(page.Points, 2) {
+		s.Equal(uint64(10), page.Points[0].Ts)
+		s.Equal(3.5, page.Points[1].Point.Lat)
+	}
 }
 
 func (s *TarantoolRouteTestSuite) TearDownSuite() {
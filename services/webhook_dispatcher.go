@@ -0,0 +1,409 @@
+package services
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/TeamD2018/geo-rest/models"
+	"github.com/TeamD2018/geo-rest/services/interfaces"
+	"github.com/satori/go.uuid"
+	"go.uber.org/zap"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultWebhookWorkers        = 4
+	DefaultWebhookQueueSize      = 1000
+	DefaultWebhookMaxAttempts    = 5
+	DefaultWebhookInitialBackoff = time.Second
+	DefaultWebhookMaxBackoff     = 5 * time.Minute
+	DefaultWebhookTimeout        = 10 * time.Second
+)
+
+// Headers of webhook requests. The signature is "sha256=" and the hex HMAC-SHA256 of "<timestamp>.<body>"
+// keyed with the subscription secret.
+const (
+	WebhookEventHeader     = "X-Webhook-Event"
+	WebhookDeliveryHeader  = "X-Webhook-Delivery"
+	WebhookTimestampHeader = "X-Webhook-Timestamp"
+	WebhookSignatureHeader = "X-Webhook-Signature"
+)
+
+var errWebhookDispatcherStopped = errors.New("webhook dispatcher stopped")
+
+// WebhookDispatcher manages webhook subscriptions and posts events to them in the background.
+//
+// A failed delivery is retried after InitialBackoff, doubling up to MaxBackoff, until MaxAttempts is reached.
+// Network errors, 408, 429 and 5xx responses are retried, any other response moves the delivery to the
+// dead letters of the subscription at once. Retries are scheduled in process memory and lost on restart.
+type WebhookDispatcher struct {
+	Store          interfaces.WebhookStore
+	Client         *http.Client
+	Logger         *zap.Logger
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Now            func() time.Time
+
+	events     chan *models.WebhookEvent
+	deliveries chan *webhookDelivery
+	done       chan struct{}
+	closeOnce  sync.Once
+	workers    sync.WaitGroup
+	// deliveries not yet delivered or dead-lettered, retries included
+	pending sync.WaitGroup
+	// deliveries waiting for their backoff to pass
+	retryMu  sync.Mutex
+	retries  map[*webhookDelivery]*time.Timer
+	retrying sync.WaitGroup
+}
+
+type webhookDelivery struct {
+	subscription *models.WebhookSubscription
+	event        *models.WebhookEvent
+	body         []byte
+	attempts     int
+}
+
+func NewWebhookDispatcher(store interfaces.WebhookStore, logger *zap.Logger, workers, queueSize, maxAttempts int,
+	initialBackoff, maxBackoff, timeout time.Duration) *WebhookDispatcher {
+	if workers <= 0 {
+		workers = DefaultWebhookWorkers
+	}
+	if queueSize <= 0 {
+		queueSize = DefaultWebhookQueueSize
+	}
+	if maxAttempts <= 0 {
+		maxAttempts = DefaultWebhookMaxAttempts
+	}
+	if initialBackoff <= 0 {
+		initialBackoff = DefaultWebhookInitialBackoff
+	}
+	if maxBackoff < initialBackoff {
+		maxBackoff = DefaultWebhookMaxBackoff
+	}
+	if timeout <= 0 {
+		timeout = DefaultWebhookTimeout
+	}
+	d := &WebhookDispatcher{
+		Store:          store,
+		Client:         &http.Client{Timeout: timeout},
+		Logger:         logger,
+		MaxAttempts:    maxAttempts,
+		InitialBackoff: initialBackoff,
+		MaxBackoff:     maxBackoff,
+		Now:            time.Now,
+		events:         make(chan *models.WebhookEvent, queueSize),
+		deliveries:     make(chan *webhookDelivery, queueSize),
+		done:           make(chan struct{}),
+		retries:        make(map[*webhookDelivery]*time.Timer),
+	}
+	d.workers.Add(workers + 1)
+	go d.fanOut()
+	for i := 0; i < workers; i++ {
+		go d.deliver()
+	}
+	return d
+}
+
+func (d *WebhookDispatcher) Create(create *models.WebhookSubscriptionCreate) (*models.WebhookSubscription, error) {
+	endpoint, err := url.Parse(create.URL)
+	if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+		return nil, models.ErrOneOfParameterHaveIncorrectFormat.SetParameter("url")
+	}
+	for _, eventType := range create.EventTypes {
+		if !models.IsWebhookEventType(eventType) {
+			return nil, models.ErrOneOfParameterHaveIncorrectFormat.SetParameter("event_types")
+		}
+	}
+	secret := create.Secret
+	if secret == "" {
+		if secret, err = newWebhookSecret(); err != nil {
+			return nil, err
+		}
+	}
+	subscription := &models.WebhookSubscription{
+		URL:        create.URL,
+		Secret:     secret,
+		EventTypes: create.EventTypes,
+		CreatedAt:  d.Now().Unix(),
+	}
+	if err := d.Store.Create(subscription); err != nil {
+		return nil, err
+	}
+	return subscription, nil
+}
+
+func (d *WebhookDispatcher) Get(subscriptionID string) (*models.WebhookSubscription, error) {
+	return d.Store.Get(subscriptionID)
+}
+
+func (d *WebhookDispatcher) Delete(subscriptionID string) error {
+	return d.Store.Delete(subscriptionID)
+}
+
+func (d *WebhookDispatcher) List() (models.WebhookSubscriptions, error) {
+	return d.Store.List()
+}
+
+func (d *WebhookDispatcher) DeadLetters(subscriptionID string, limit int) (models.WebhookDeadLetters, error) {
+	if _, err := d.Store.Get(subscriptionID); err != nil {
+		return nil, err
+	}
+	return d.Store.DeadLetters(subscriptionID, limit)
+}
+
+func (d *WebhookDispatcher) Redeliver(subscriptionID, deadLetterID string) error {
+	subscription, err := d.Store.Get(subscriptionID)
+	if err != nil {
+		return err
+	}
+	deadLetter, err := d.Store.GetDeadLetter(subscriptionID, deadLetterID)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(deadLetter.Event)
+	if err != nil {
+		return err
+	}
+	if err := d.Store.DeleteDeadLetter(subscriptionID, deadLetterID); err != nil {
+		return err
+	}
+	d.enqueue(&webhookDelivery{subscription: subscription, event: deadLetter.Event, body: body})
+	return nil
+}
+
+func (d *WebhookDispatcher) Dispatch(eventType string, data interface{}) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		d.Logger.Error("fail to marshal webhook event", zap.Error(err), zap.String("type", eventType))
+		return
+	}
+	event := &models.WebhookEvent{
+		ID:         uuid.NewV4().String(),
+		Type:       eventType,
+		OccurredAt: d.Now().Unix(),
+		Data:       raw,
+	}
+	d.pending.Add(1)
+	select {
+	case <-d.done:
+		d.pending.Done()
+		d.Logger.Warn("webhook dispatcher stopped, event dropped", zap.String("type", eventType))
+	case d.events <- event:
+	default:
+		d.pending.Done()
+		d.Logger.Error("webhook queue is full, event dropped", zap.String("type", eventType), zap.String("event_id", event.ID))
+	}
+}
+
+// Wait blocks until every event dispatched so far is delivered or dead-lettered.
+func (d *WebhookDispatcher) Wait() {
+	d.pending.Wait()
+}
+
+// Close stops the workers. Queued deliveries and pending retries are moved to dead letters, queued events are dropped.
+// Once Close returns the dispatcher no longer uses its store.
+func (d *WebhookDispatcher) Close() {
+	d.closeOnce.Do(func() {
+		close(d.done)
+		d.workers.Wait()
+		// workers schedule retries, so none is added from here on
+		d.retryMu.Lock()
+		stopped := make([]*webhookDelivery, 0, len(d.retries))
+		for delivery, timer := range d.retries {
+			if timer.Stop() {
+				stopped = append(stopped, delivery)
+				d.retrying.Done()
+			}
+			delete(d.retries, delivery)
+		}
+		d.retryMu.Unlock()
+		for _, delivery := range stopped {
+			d.deadLetter(delivery, 0, errWebhookDispatcherStopped)
+		}
+		// retries already firing see the dispatcher stopped and dead-letter themselves
+		d.retrying.Wait()
+		for {
+			select {
+			case event := <-d.events:
+				d.Logger.Warn("webhook dispatcher stopped, event dropped", zap.String("event_id", event.ID))
+				d.pending.Done()
+			case delivery := <-d.deliveries:
+				d.deadLetter(delivery, 0, errWebhookDispatcherStopped)
+			default:
+				return
+			}
+		}
+	})
+}
+
+func (d *WebhookDispatcher) fanOut() {
+	defer d.workers.Done()
+	for {
+		select {
+		case <-d.done:
+			return
+		case event := <-d.events:
+			subscriptions, err := d.Store.List()
+			if err != nil {
+				d.Logger.Error("fail to list webhooks, event dropped", zap.Error(err), zap.String("event_id", event.ID))
+				d.pending.Done()
+				continue
+			}
+			body, err := json.Marshal(event)
+			if err != nil {
+				d.Logger.Error("fail to marshal webhook event", zap.Error(err), zap.String("event_id", event.ID))
+				d.pending.Done()
+				continue
+			}
+			for _, subscription := range subscriptions {
+				if subscription.Wants(event.Type) {
+					d.enqueue(&webhookDelivery{subscription: subscription, event: event, body: body})
+				}
+			}
+			d.pending.Done()
+		}
+	}
+}
+
+func (d *WebhookDispatcher) enqueue(delivery *webhookDelivery) {
+	d.pending.Add(1)
+	d.requeue(delivery)
+}
+
+// requeue hands a pending delivery to the workers.
+func (d *WebhookDispatcher) requeue(delivery *webhookDelivery) {
+	select {
+	case <-d.done:
+		d.deadLetter(delivery, 0, errWebhookDispatcherStopped)
+		return
+	default:
+	}
+	select {
+	case <-d.done:
+		d.deadLetter(delivery, 0, errWebhookDispatcherStopped)
+	case d.deliveries <- delivery:
+	}
+}
+
+func (d *WebhookDispatcher) deliver() {
+	defer d.workers.Done()
+	for {
+		select {
+		case <-d.done:
+			return
+		case delivery := <-d.deliveries:
+			delivery.attempts++
+			status, err := d.post(delivery)
+			if err == nil {
+				d.pending.Done()
+				continue
+			}
+			if !retryableWebhookStatus(status) || delivery.attempts >= d.MaxAttempts {
+				d.deadLetter(delivery, status, err)
+				continue
+			}
+			d.retryLater(delivery)
+		}
+	}
+}
+
+// retryLater requeues the delivery once its backoff passes.
+func (d *WebhookDispatcher) retryLater(delivery *webhookDelivery) {
+	d.retryMu.Lock()
+	defer d.retryMu.Unlock()
+	d.retrying.Add(1)
+	d.retries[delivery] = time.AfterFunc(d.backoff(delivery.attempts), func() {
+		defer d.retrying.Done()
+		d.retryMu.Lock()
+		delete(d.retries, delivery)
+		d.retryMu.Unlock()
+		d.requeue(delivery)
+	})
+}
+
+func (d *WebhookDispatcher) post(delivery *webhookDelivery) (int, error) {
+	timestamp := d.Now().Unix()
+	req, err := http.NewRequest(http.MethodPost, delivery.subscription.URL, bytes.NewReader(delivery.body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookEventHeader, delivery.event.Type)
+	req.Header.Set(WebhookDeliveryHeader, delivery.event.ID)
+	req.Header.Set(WebhookTimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(WebhookSignatureHeader, SignWebhook(delivery.subscription.Secret, timestamp, delivery.body))
+	res, err := d.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	io.Copy(ioutil.Discard, io.LimitReader(res.Body, 64*1024))
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, fmt.Errorf("unexpected status %s", res.Status)
+	}
+	return res.StatusCode, nil
+}
+
+func (d *WebhookDispatcher) deadLetter(delivery *webhookDelivery, status int, err error) {
+	defer d.pending.Done()
+	deadLetter := &models.WebhookDeadLetter{
+		SubscriptionID: delivery.subscription.ID,
+		Event:          delivery.event,
+		Attempts:       delivery.attempts,
+		LastStatus:     status,
+		LastError:      err.Error(),
+		FailedAt:       d.Now().Unix(),
+	}
+	if err := d.Store.AddDeadLetter(deadLetter); err != nil {
+		d.Logger.Error("fail to store webhook dead letter", zap.Error(err), zap.Any("dead_letter", deadLetter))
+		return
+	}
+	d.Logger.Warn("webhook delivery failed", zap.String("subscription_id", deadLetter.SubscriptionID),
+		zap.String("event_id", delivery.event.ID), zap.Int("attempts", delivery.attempts), zap.String("error", deadLetter.LastError))
+}
+
+func (d *WebhookDispatcher) backoff(attempts int) time.Duration {
+	backoff := d.InitialBackoff
+	for i := 1; i < attempts && backoff < d.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > d.MaxBackoff {
+		backoff = d.MaxBackoff
+	}
+	return backoff
+}
+
+// retryableWebhookStatus reports whether a delivery answered with status, zero for no answer, may succeed later.
+func retryableWebhookStatus(status int) bool {
+	return status == 0 || status == http.StatusRequestTimeout || status == http.StatusTooManyRequests || status >= 500
+}
+
+// SignWebhook returns the X-Webhook-Signature value of body sent at timestamp.
+func SignWebhook(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func newWebhookSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(secret), nil
+}
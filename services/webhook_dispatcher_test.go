@@ -0,0 +1,202 @@
+package services
+
+import (
+	"encoding/json"
+	"github.com/TeamD2018/geo-rest/models"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+type webhookRequest struct {
+	header http.Header
+	body   []byte
+}
+
+type WebhookDispatcherTestSuite struct {
+	suite.Suite
+	store      *MemoryWebhookStore
+	dispatcher *WebhookDispatcher
+	receiver   *httptest.Server
+
+	mu       sync.Mutex
+	requests []webhookRequest
+	// statuses answered in turn, 200 once exhausted
+	statuses []int
+}
+
+func (s *WebhookDispatcherTestSuite) BeforeTest(suiteName, testName string) {
+	s.requests = nil
+	s.statuses = nil
+	s.receiver = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		s.mu.Lock()
+		s.requests = append(s.requests, webhookRequest{header: r.Header, body: body})
+		status := http.StatusOK
+		if len(s.statuses) > 0 {
+			status, s.statuses = s.statuses[0], s.statuses[1:]
+		}
+		s.mu.Unlock()
+		w.WriteHeader(status)
+	}))
+	s.store = NewMemoryWebhookStore()
+	s.dispatcher = NewWebhookDispatcher(s.store, zap.NewNop(), 2, 10, 3, time.Millisecond, 4*time.Millisecond, time.Second)
+}
+
+func (s *WebhookDispatcherTestSuite) AfterTest(suiteName, testName string) {
+	s.dispatcher.Close()
+	s.receiver.Close()
+}
+
+func (s *WebhookDispatcherTestSuite) subscribe(eventTypes ...string) *models.WebhookSubscription {
+	subscription, err := s.dispatcher.Create(&models.WebhookSubscriptionCreate{
+		URL:        s.receiver.URL,
+		Secret:     "secret",
+		EventTypes: eventTypes,
+	})
+	s.Require().NoError(err)
+	return subscription
+}
+
+func (s *WebhookDispatcherTestSuite) received() []webhookRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]webhookRequest{}, s.requests...)
+}
+
+func (s *WebhookDispatcherTestSuite) TestCreateValidates() {
+	_, err := s.dispatcher.Create(&models.WebhookSubscriptionCreate{URL: "ftp://erp"})
+	s.Error(err)
+	_, err = s.dispatcher.Create(&models.WebhookSubscriptionCreate{URL: s.receiver.URL, EventTypes: []string{"order.lost"}})
+	s.Error(err)
+
+	subscription, err := s.dispatcher.Create(&models.WebhookSubscriptionCreate{URL: s.receiver.URL})
+	if s.NoError(err) {
+		s.Len(subscription.Secret, 64)
+	}
+}
+
+func (s *WebhookDispatcherTestSuite) TestDeliversSignedEvent() {
+	s.subscribe()
+	s.dispatcher.Dispatch(models.WebhookOrderCreated, &models.Order{ID: "order"})
+	s.dispatcher.Wait()
+
+	requests := s.received()
+	if !s.Len(requests, 1) {
+		return
+	}
+	request := requests[0]
+	timestamp, err := strconv.ParseInt(request.header.Get(WebhookTimestampHeader), 10, 64)
+	s.NoError(err)
+	s.Equal(SignWebhook("secret", timestamp, request.body), request.header.Get(WebhookSignatureHeader))
+	s.NotEqual(SignWebhook("other", timestamp, request.body), request.header.Get(WebhookSignatureHeader))
+	s.Equal(models.WebhookOrderCreated, request.header.Get(WebhookEventHeader))
+
+	var event models.WebhookEvent
+	s.NoError(json.Unmarshal(request.body, &event))
+	s.Equal(models.WebhookOrderCreated, event.Type)
+	s.Equal(event.ID, request.header.Get(WebhookDeliveryHeader))
+	var order models.Order
+	s.NoError(json.Unmarshal(event.Data, &order))
+	s.Equal("order", order.ID)
+}
+
+func (s *WebhookDispatcherTestSuite) TestFiltersEventTypes() {
+	s.subscribe(models.WebhookOrderDelivered)
+	s.dispatcher.Dispatch(models.WebhookOrderCreated, &models.Order{ID: "order"})
+	s.dispatcher.Dispatch(models.WebhookOrderDelivered, &models.Order{ID: "order"})
+	s.dispatcher.Wait()
+
+	requests := s.received()
+	if s.Len(requests, 1) {
+		s.Equal(models.WebhookOrderDelivered, requests[0].header.Get(WebhookEventHeader))
+	}
+}
+
+func (s *WebhookDispatcherTestSuite) TestRetriesWithBackoff() {
+	subscription := s.subscribe()
+	s.statuses = []int{http.StatusServiceUnavailable, http.StatusTooManyRequests}
+	s.dispatcher.Dispatch(models.WebhookCourierActivated, &models.Courier{ID: "courier"})
+	s.dispatcher.Wait()
+
+	s.Len(s.received(), 3)
+	deadLetters, err := s.dispatcher.DeadLetters(subscription.ID, 0)
+	if s.NoError(err) {
+		s.Empty(deadLetters)
+	}
+	s.Equal(time.Millisecond, s.dispatcher.backoff(1))
+	s.Equal(2*time.Millisecond, s.dispatcher.backoff(2))
+	s.Equal(4*time.Millisecond, s.dispatcher.backoff(5))
+}
+
+func (s *WebhookDispatcherTestSuite) TestCloseDeadLettersPendingRetry() {
+	subscription := s.subscribe()
+	s.statuses = []int{http.StatusServiceUnavailable}
+	s.dispatcher.InitialBackoff = time.Hour
+	s.dispatcher.MaxBackoff = time.Hour
+	s.dispatcher.Dispatch(models.WebhookCourierActivated, &models.Courier{ID: "courier"})
+	for deadline := time.Now().Add(time.Second); len(s.received()) == 0 && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	s.Require().Len(s.received(), 1)
+
+	// the worker has the failed attempt in hand, Close waits for it to schedule the retry
+	s.dispatcher.Close()
+
+	deadLetters, err := s.store.DeadLetters(subscription.ID, 0)
+	if s.NoError(err) && s.Len(deadLetters, 1) {
+		s.Equal(1, deadLetters[0].Attempts)
+		s.Equal(errWebhookDispatcherStopped.Error(), deadLetters[0].LastError)
+	}
+	s.Len(s.received(), 1)
+}
+
+func (s *WebhookDispatcherTestSuite) TestDeadLetterAfterMaxAttempts() {
+	subscription := s.subscribe()
+	s.statuses = []int{http.StatusInternalServerError, http.StatusInternalServerError, http.StatusBadGateway}
+	s.dispatcher.Dispatch(models.WebhookCourierDeleted, &models.WebhookDeleted{ID: "courier"})
+	s.dispatcher.Wait()
+
+	s.Len(s.received(), 3)
+	deadLetters, err := s.dispatcher.DeadLetters(subscription.ID, 0)
+	if !s.NoError(err) || !s.Len(deadLetters, 1) {
+		return
+	}
+	s.Equal(3, deadLetters[0].Attempts)
+	s.Equal(http.StatusBadGateway, deadLetters[0].LastStatus)
+	s.Equal(models.WebhookCourierDeleted, deadLetters[0].Event.Type)
+
+	s.Require().NoError(s.dispatcher.Redeliver(subscription.ID, deadLetters[0].ID))
+	s.dispatcher.Wait()
+	requests := s.received()
+	if s.Len(requests, 4) {
+		s.Equal(deadLetters[0].Event.ID, requests[3].header.Get(WebhookDeliveryHeader))
+	}
+	deadLetters, err = s.dispatcher.DeadLetters(subscription.ID, 0)
+	if s.NoError(err) {
+		s.Empty(deadLetters)
+	}
+}
+
+func (s *WebhookDispatcherTestSuite) TestClientErrorIsNotRetried() {
+	subscription := s.subscribe()
+	s.statuses = []int{http.StatusBadRequest}
+	s.dispatcher.Dispatch(models.WebhookOrderDeleted, &models.WebhookDeleted{ID: "order"})
+	s.dispatcher.Wait()
+
+	s.Len(s.received(), 1)
+	deadLetters, err := s.dispatcher.DeadLetters(subscription.ID, 0)
+	if s.NoError(err) && s.Len(deadLetters, 1) {
+		s.Equal(1, deadLetters[0].Attempts)
+	}
+}
+
+func TestUnitWebhookDispatcher(t *testing.T) {
+	suite.Run(t, new(WebhookDispatcherTestSuite))
+}
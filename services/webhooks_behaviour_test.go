@@ -0,0 +1,110 @@
+package services
+
+import (
+	"github.com/TeamD2018/geo-rest/models"
+	"github.com/TeamD2018/geo-rest/services/interfaces"
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+// WebhooksBehaviourSuite checks subscription CRUD, listing and deleting dead letters newest first,
+// and that deleting a subscription drops its dead letters.
+type WebhooksBehaviourSuite struct {
+	suite.Suite
+	newStore func() interfaces.WebhookStore
+	store    interfaces.WebhookStore
+}
+
+func (s *WebhooksBehaviourSuite) BeforeTest(suiteName, testName string) {
+	s.store = s.newStore()
+}
+
+func (s *WebhooksBehaviourSuite) create(createdAt int64) *models.WebhookSubscription {
+	subscription := &models.WebhookSubscription{
+		URL:        "http://erp.local/hooks",
+		Secret:     "secret",
+		EventTypes: []string{models.WebhookOrderCreated},
+		CreatedAt:  createdAt,
+	}
+	s.Require().NoError(s.store.Create(subscription))
+	return subscription
+}
+
+func (s *WebhooksBehaviourSuite) addDeadLetter(subscriptionID string, failedAt int64) *models.WebhookDeadLetter {
+	deadLetter := &models.WebhookDeadLetter{
+		SubscriptionID: subscriptionID,
+		Event:          &models.WebhookEvent{ID: "event", Type: models.WebhookOrderCreated, Data: []byte(`{"id":"order"}`)},
+		Attempts:       3,
+		LastError:      "unexpected status 500",
+		FailedAt:       failedAt,
+	}
+	s.Require().NoError(s.store.AddDeadLetter(deadLetter))
+	return deadLetter
+}
+
+func (s *WebhooksBehaviourSuite) TestCreateGetList() {
+	second := s.create(20)
+	first := s.create(10)
+	s.NotEmpty(first.ID)
+
+	got, err := s.store.Get(first.ID)
+	if s.NoError(err) {
+		s.Equal(first, got)
+	}
+	subscriptions, err := s.store.List()
+	if s.NoError(err) && s.Len(subscriptions, 2) {
+		s.Equal(first.ID, subscriptions[0].ID)
+		s.Equal(second.ID, subscriptions[1].ID)
+	}
+	_, err = s.store.Get("unknown")
+	s.Error(err)
+}
+
+func (s *WebhooksBehaviourSuite) TestDeadLetters() {
+	subscription := s.create(10)
+	early := s.addDeadLetter(subscription.ID, 10)
+	late := s.addDeadLetter(subscription.ID, 20)
+	s.addDeadLetter("other", 30)
+
+	deadLetters, err := s.store.DeadLetters(subscription.ID, 0)
+	if s.NoError(err) && s.Len(deadLetters, 2) {
+		s.Equal(late, deadLetters[0])
+		s.Equal(early.ID, deadLetters[1].ID)
+	}
+	deadLetters, err = s.store.DeadLetters(subscription.ID, 1)
+	if s.NoError(err) && s.Len(deadLetters, 1) {
+		s.Equal(late.ID, deadLetters[0].ID)
+	}
+
+	got, err := s.store.GetDeadLetter(subscription.ID, early.ID)
+	if s.NoError(err) {
+		s.Equal(early, got)
+	}
+	_, err = s.store.GetDeadLetter("other", early.ID)
+	s.Error(err)
+
+	s.Require().NoError(s.store.DeleteDeadLetter(subscription.ID, early.ID))
+	s.Error(s.store.DeleteDeadLetter(subscription.ID, early.ID))
+	deadLetters, err = s.store.DeadLetters(subscription.ID, 0)
+	if s.NoError(err) && s.Len(deadLetters, 1) {
+		s.Equal(late.ID, deadLetters[0].ID)
+	}
+}
+
+func (s *WebhooksBehaviourSuite) TestDeleteDropsDeadLetters() {
+	subscription := s.create(10)
+	s.addDeadLetter(subscription.ID, 10)
+
+	s.Require().NoError(s.store.Delete(subscription.ID))
+	s.Error(s.store.Delete(subscription.ID))
+	deadLetters, err := s.store.DeadLetters(subscription.ID, 0)
+	if s.NoError(err) {
+		s.Empty(deadLetters)
+	}
+}
+
+func TestUnitMemoryWebhooks(t *testing.T) {
+	suite.Run(t, &WebhooksBehaviourSuite{newStore: func() interfaces.WebhookStore {
+		return NewMemoryWebhookStore()
+	}})
+}
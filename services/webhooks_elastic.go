@@ -0,0 +1,287 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/TeamD2018/geo-rest/models"
+	"github.com/olivere/elastic"
+	"github.com/satori/go.uuid"
+	"go.uber.org/zap"
+)
+
+const (
+	WebhooksIndex           = "webhooks"
+	WebhookDeadLettersIndex = "webhook_dead_letters"
+)
+
+const (
+	DefaultWebhookDeadLettersReturnSize = 50
+	// Upper bound of subscriptions read at once, every event is matched against all of them
+	MaxWebhookSubscriptions = 1000
+)
+
+// WebhooksElastic keeps webhook subscriptions and their dead letters in Elasticsearch.
+type WebhooksElastic struct {
+	Elastic         *elastic.Client
+	index           string
+	deadLetterIndex string
+	Logger          *zap.Logger
+}
+
+func NewWebhooksElastic(client *elastic.Client, logger *zap.Logger, index, deadLetterIndex string) *WebhooksElastic {
+	if index == "" {
+		index = WebhooksIndex
+	}
+	if deadLetterIndex == "" {
+		deadLetterIndex = WebhookDeadLettersIndex
+	}
+	if logger == nil {
+		logger, _ = zap.NewDevelopment()
+	}
+	return &WebhooksElastic{
+		Elastic:         client,
+		index:           index,
+		deadLetterIndex: deadLetterIndex,
+		Logger:          logger,
+	}
+}
+
+func (we *WebhooksElastic) Create(subscription *models.WebhookSubscription) error {
+	subscription.ID = uuid.NewV4().String()
+	_, err := we.Elastic.Index().
+		Index(we.index).
+		Type("_doc").
+		Id(subscription.ID).
+		BodyJson(subscription).
+		Refresh("true").
+		Do(context.Background())
+	if err != nil {
+		subscription.ID = ""
+		return err
+	}
+	return nil
+}
+
+func (we *WebhooksElastic) Get(subscriptionID string) (*models.WebhookSubscription, error) {
+	res, err := we.Elastic.Get().
+		Index(we.index).
+		Type("_doc").
+		Id(subscriptionID).
+		Do(context.Background())
+	if err != nil {
+		if elastic.IsNotFound(err) {
+			return nil, models.ErrEntityNotFound.SetParameter(subscriptionID)
+		}
+		return nil, err
+	}
+	var subscription models.WebhookSubscription
+	if err := json.Unmarshal(*res.Source, &subscription); err != nil {
+		return nil, models.ErrUnmarshalJSON
+	}
+	subscription.ID = res.Id
+	return &subscription, nil
+}
+
+func (we *WebhooksElastic) Delete(subscriptionID string) error {
+	_, err := we.Elastic.Delete().
+		Index(we.index).
+		Type("_doc").
+		Id(subscriptionID).
+		Refresh("true").
+		Do(context.Background())
+	if err != nil {
+		if elastic.IsNotFound(err) {
+			return models.ErrEntityNotFound.SetParameter(subscriptionID)
+		}
+		return err
+	}
+	_, err = we.Elastic.DeleteByQuery(we.deadLetterIndex).
+		Query(elastic.NewTermQuery("subscription_id", subscriptionID)).
+		Do(context.Background())
+	if err != nil {
+		we.Logger.Error("fail to delete webhook dead letters", zap.Error(err), zap.String("subscription_id", subscriptionID))
+	}
+	return nil
+}
+
+func (we *WebhooksElastic) List() (models.WebhookSubscriptions, error) {
+	res, err := we.Elastic.Search(we.index).
+		Type("_doc").
+		Query(elastic.NewMatchAllQuery()).
+		Sort("created_at", true).
+		Size(MaxWebhookSubscriptions).
+		Do(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	subscriptions := make(models.WebhookSubscriptions, 0, len(res.Hits.Hits))
+	for _, hit := range res.Hits.Hits {
+		var subscription models.WebhookSubscription
+		if err := json.Unmarshal(*hit.Source, &subscription); err != nil {
+			return nil, models.ErrUnmarshalJSON
+		}
+		subscription.ID = hit.Id
+		subscriptions = append(subscriptions, &subscription)
+	}
+	sortWebhookSubscriptions(subscriptions)
+	return subscriptions, nil
+}
+
+func (we *WebhooksElastic) AddDeadLetter(deadLetter *models.WebhookDeadLetter) error {
+	deadLetter.ID = uuid.NewV4().String()
+	_, err := we.Elastic.Index().
+		Index(we.deadLetterIndex).
+		Type("_doc").
+		Id(deadLetter.ID).
+		BodyJson(deadLetter).
+		Do(context.Background())
+	if err != nil {
+		deadLetter.ID = ""
+		return err
+	}
+	return nil
+}
+
+func (we *WebhooksElastic) GetDeadLetter(subscriptionID, deadLetterID string) (*models.WebhookDeadLetter, error) {
+	res, err := we.Elastic.Get().
+		Index(we.deadLetterIndex).
+		Type("_doc").
+		Id(deadLetterID).
+		Do(context.Background())
+	if err != nil {
+		if elastic.IsNotFound(err) {
+			return nil, models.ErrEntityNotFound.SetParameter(deadLetterID)
+		}
+		return nil, err
+	}
+	var deadLetter models.WebhookDeadLetter
+	if err := json.Unmarshal(*res.Source, &deadLetter); err != nil {
+		return nil, models.ErrUnmarshalJSON
+	}
+	if deadLetter.SubscriptionID != subscriptionID {
+		return nil, models.ErrEntityNotFound.SetParameter(deadLetterID)
+	}
+	deadLetter.ID = res.Id
+	return &deadLetter, nil
+}
+
+func (we *WebhooksElastic) DeleteDeadLetter(subscriptionID, deadLetterID string) error {
+	if _, err := we.GetDeadLetter(subscriptionID, deadLetterID); err != nil {
+		return err
+	}
+	_, err := we.Elastic.Delete().
+		Index(we.deadLetterIndex).
+		Type("_doc").
+		Id(deadLetterID).
+		Do(context.Background())
+	if err != nil {
+		if elastic.IsNotFound(err) {
+			return models.ErrEntityNotFound.SetParameter(deadLetterID)
+		}
+		return err
+	}
+	return nil
+}
+
+func (we *WebhooksElastic) DeadLetters(subscriptionID string, limit int) (models.WebhookDeadLetters, error) {
+	if limit <= 0 {
+		limit = DefaultWebhookDeadLettersReturnSize
+	}
+	res, err := we.Elastic.Search(we.deadLetterIndex).
+		Type("_doc").
+		Query(elastic.NewTermQuery("subscription_id", subscriptionID)).
+		Sort("failed_at", false).
+		Size(limit).
+		Do(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	found := make(models.WebhookDeadLetters, 0, len(res.Hits.Hits))
+	for _, hit := range res.Hits.Hits {
+		var deadLetter models.WebhookDeadLetter
+		if err := json.Unmarshal(*hit.Source, &deadLetter); err != nil {
+			return nil, models.ErrUnmarshalJSON
+		}
+		deadLetter.ID = hit.Id
+		found = append(found, &deadLetter)
+	}
+	return found, nil
+}
+
+func (we *WebhooksElastic) EnsureMapping() error {
+	ctx := context.Background()
+	for _, mapping := range []func() (string, string){we.GetMapping, we.GetDeadLetterMapping} {
+		indexName, body := mapping()
+		exists, err := we.Elastic.IndexExists(indexName).Do(ctx)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			_, err := we.Elastic.CreateIndex(indexName).BodyString(body).Do(ctx)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (we *WebhooksElastic) GetIndex() string {
+	return we.index
+}
+
+func (we *WebhooksElastic) GetMapping() (indexName string, mapping string) {
+	return we.index, `{
+  "mappings": {
+    "_doc": {
+      "properties": {
+        "url": {
+          "type": "keyword",
+          "index": false
+        },
+        "secret": {
+          "type": "keyword",
+          "index": false
+        },
+        "event_types": {
+          "type": "keyword"
+        },
+        "created_at": {
+          "type": "long"
+        }
+      }
+    }
+  }
+}`
+}
+
+func (we *WebhooksElastic) GetDeadLetterMapping() (indexName string, mapping string) {
+	return we.deadLetterIndex, `{
+  "mappings": {
+    "_doc": {
+      "properties": {
+        "subscription_id": {
+          "type": "keyword"
+        },
+        "event": {
+          "type": "object",
+          "enabled": false
+        },
+        "attempts": {
+          "type": "integer"
+        },
+        "last_status": {
+          "type": "integer"
+        },
+        "last_error": {
+          "type": "text",
+          "index": false
+        },
+        "failed_at": {
+          "type": "long"
+        }
+      }
+    }
+  }
+}`
+}